@@ -7,16 +7,19 @@ import (
 	"database/sql"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"mime"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"go-clipboard/plugin"
+	"go-clipboard/store"
 
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 	"golang.design/x/clipboard"
@@ -26,10 +29,12 @@ import (
 type App struct {
 	ctx            context.Context
 	db             *sql.DB
+	store          *store.Service
 	tempDir        string
 	mu             sync.Mutex
 	watcherManager *WatcherManager
 	pluginManager  *plugin.Manager
+	revealed       map[int64]time.Time // sensitive clips revealed until, see RevealClip
 }
 
 // NewApp creates a new App instance
@@ -50,6 +55,18 @@ func (a *App) emitWatchImport(filename string) {
 	runtime.EventsEmit(a.ctx, "watch:import", filename)
 }
 
+// emitClipsChanged tells the frontend to refresh after clips or tags were
+// changed in the background (by a plugin or the folder watcher)
+func (a *App) emitClipsChanged(e store.Event) {
+	if e.Actor.Kind == store.ActorUser {
+		return // the frontend refreshes after its own calls
+	}
+	runtime.EventsEmit(a.ctx, "clips:changed", map[string]string{
+		"event": e.Name,
+		"actor": e.Actor.Kind,
+	})
+}
+
 // RefreshWatches reloads the watcher configuration
 func (a *App) RefreshWatches() error {
 	if a.watcherManager != nil {
@@ -68,9 +85,19 @@ func (a *App) startup(ctx context.Context) {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 	a.db = db
+	a.store = store.New(db)
+	a.store.Subscribe(a.emitClipsChanged)
+	if err := a.store.LoadEncryption(); err != nil {
+		log.Printf("Warning: Failed to load encryption settings: %v", err)
+	}
+	if policy, err := loadSecretPolicy(a.db); err != nil {
+		log.Printf("Warning: Failed to load secret policy: %v", err)
+	} else {
+		a.store.SetSecretPolicy(policy)
+	}
 
 	// Start cleanup job for expired clips
-	startCleanupJob(a.db)
+	startCleanupJob(a.db, a.store)
 
 	// Initialize temp directory
 	if err := a.initTempDir(); err != nil {
@@ -96,7 +123,7 @@ func (a *App) startup(ctx context.Context) {
 	// Initialize plugin manager
 	dataDir, _ := getDataDir()
 	pluginsDir := filepath.Join(dataDir, "plugins")
-	pm, err := plugin.NewManager(ctx, a.db, pluginsDir)
+	pm, err := plugin.NewManager(ctx, a.db, a.store, pluginsDir)
 	if err != nil {
 		log.Printf("Warning: Failed to initialize plugin manager: %v", err)
 	} else {
@@ -171,7 +198,12 @@ type ClipPreview struct {
 	ExpiresAt   *time.Time `json:"expires_at"`
 	Preview     string     `json:"preview"`
 	IsArchived  bool       `json:"is_archived"`
+	IsPinned    bool       `json:"is_pinned"`
 	Tags        []Tag      `json:"tags"`
+	SourceKind  string     `json:"source_kind"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"` // set for clips in the trash
+	Secrets     []string   `json:"secrets,omitempty"`    // secret rules that matched the data
+	IsSensitive bool       `json:"is_sensitive"`         // preview is left empty until revealed
 }
 
 // ClipData for full clip retrieval
@@ -180,13 +212,52 @@ type ClipData struct {
 	ContentType string `json:"content_type"`
 	Data        string `json:"data"` // base64 encoded for binary, raw for text
 	Filename    string `json:"filename"`
+	ParentID    *int64 `json:"parent_id"` // clip this one was derived from
+
+	// Provenance and content info, not set for revisions
+	Source    *ClipSource `json:"source,omitempty"`
+	Width     int         `json:"width,omitempty"` // image dimensions
+	Height    int         `json:"height,omitempty"`
+	LineCount int         `json:"line_count,omitempty"` // text line count
+	Language  string      `json:"language,omitempty"`   // detected text language
+}
+
+// ClipSource describes where a clip came from
+type ClipSource struct {
+	Kind       string     `json:"kind"` // "paste", "file", "watch", "plugin", "url", "editor"; empty for older clips
+	Path       string     `json:"path,omitempty"`
+	URL        string     `json:"url,omitempty"`
+	PluginID   int64      `json:"plugin_id,omitempty"`
+	Action     string     `json:"action,omitempty"`      // plugin action or handler
+	ModifiedAt *time.Time `json:"modified_at,omitempty"` // modification time of the original file
+}
+
+// ClipFilter narrows the clips returned by SearchClips. Empty fields don't filter.
+type ClipFilter = store.Filter
+
+// ClipRevision describes a previous version of a clip
+type ClipRevision struct {
+	ID          int64     `json:"id"`
+	ContentType string    `json:"content_type"`
+	Filename    string    `json:"filename"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// DiffLine is one line of a diff between two text revisions
+type DiffLine struct {
+	Op   string `json:"op"` // "equal", "insert" or "delete"
+	Text string `json:"text"`
 }
 
 // FileData for uploads - binary data as base64
 type FileData struct {
-	Name        string `json:"name"`
-	ContentType string `json:"content_type"`
-	Data        string `json:"data"` // base64 encoded
+	Name         string `json:"name"`
+	ContentType  string `json:"content_type"`
+	Data         string `json:"data"`                    // base64 encoded
+	Source       string `json:"source,omitempty"`        // "paste" or "file", see store.Source
+	Path         string `json:"path,omitempty"`          // original path, if known
+	LastModified int64  `json:"last_modified,omitempty"` // Unix milliseconds, if known
 }
 
 // WatchedFolder represents a folder being watched for new files
@@ -215,6 +286,48 @@ type WatchedFolderConfig struct {
 	AutoTagID       *int64   `json:"auto_tag_id"`
 }
 
+// Collection is a named, ordered group of clips
+type Collection struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Count       int       `json:"count"` // Number of clips in the collection
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// SavedSearch is a named filter with the number of clips matching it
+type SavedSearch struct {
+	ID        int64      `json:"id"`
+	Name      string     `json:"name"`
+	Filter    ClipFilter `json:"filter"`
+	Count     int        `json:"count"` // Number of clips currently matching
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// RetentionPolicy decides which clips the cleanup job deletes, see store.RetentionPolicy
+type RetentionPolicy = store.RetentionPolicy
+
+// RetentionPreview summarizes the clips a retention policy deletes
+type RetentionPreview struct {
+	Count      int             `json:"count"`
+	FreedBytes int64           `json:"freed_bytes"`
+	ByReason   map[string]int  `json:"by_reason"` // e.g. "max_age", "max_clips"
+	Clips      []RetentionClip `json:"clips"`     // oldest first, at most maxRetentionPreviewClips
+}
+
+// RetentionClip is a clip deleted by a retention policy
+type RetentionClip struct {
+	ID          int64     `json:"id"`
+	ContentType string    `json:"content_type"`
+	Filename    string    `json:"filename"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"created_at"`
+	Reason      string    `json:"reason"`
+}
+
+// maxRetentionPreviewClips limits the clips listed in a retention preview
+const maxRetentionPreviewClips = 100
+
 // Tag represents a clip tag with color
 type Tag struct {
 	ID    int64  `json:"id"`
@@ -223,63 +336,41 @@ type Tag struct {
 	Count int    `json:"count"` // Number of clips using this tag
 }
 
-// Constants for tag operations
-const (
-	maxTagNameLength = 50
-	defaultClipLimit = 50
-)
-
-// tagColors is the palette of colors auto-assigned to new tags
-var tagColors = []string{
-	"#78716C", // stone
-	"#EF4444", // red
-	"#F59E0B", // amber
-	"#22C55E", // green
-	"#3B82F6", // blue
-	"#8B5CF6", // violet
-	"#EC4899", // pink
-	"#06B6D4", // cyan
-}
+const defaultClipLimit = 50
 
 // GetClips retrieves a list of clips for the gallery, optionally filtered by tags
 func (a *App) GetClips(archived bool, tagIDs []int64) ([]ClipPreview, error) {
-	archivedInt := 0
-	if archived {
-		archivedInt = 1
-	}
+	return a.SearchClips(ClipFilter{Archived: archived, TagIDs: tagIDs})
+}
 
-	var query string
-	var args []interface{}
+// SearchClips retrieves a list of clips for the gallery matching a filter
+func (a *App) SearchClips(filter ClipFilter) ([]ClipPreview, error) {
+	condition, args, err := filter.Condition("c")
+	if err != nil {
+		return nil, err
+	}
 
-	if len(tagIDs) > 0 {
-		// Filter by tags (AND logic - clip must have ALL selected tags)
-		placeholders := make([]string, len(tagIDs))
-		for i, tagID := range tagIDs {
-			placeholders[i] = "?"
-			args = append(args, tagID)
-		}
-		args = append(args, archivedInt, len(tagIDs))
-
-		query = fmt.Sprintf(`
-		SELECT c.id, c.content_type, c.filename, c.created_at, c.expires_at, SUBSTR(c.data, 1, 500), c.is_archived
-		FROM clips c
-		INNER JOIN clip_tags ct ON c.id = ct.clip_id
-		WHERE ct.tag_id IN (%s)
-		  AND c.is_archived = ?
-		  AND (c.expires_at IS NULL OR c.expires_at > CURRENT_TIMESTAMP)
-		GROUP BY c.id
-		HAVING COUNT(DISTINCT ct.tag_id) = ?
-		ORDER BY c.created_at DESC
-		LIMIT %d`, strings.Join(placeholders, ","), defaultClipLimit)
-	} else {
-		args = append(args, archivedInt)
-		query = fmt.Sprintf(`
-		SELECT id, content_type, filename, created_at, expires_at, SUBSTR(data, 1, 500), is_archived
-		FROM clips
-		WHERE is_archived = ? AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)
-		ORDER BY created_at DESC
-		LIMIT %d`, defaultClipLimit)
+	// Clips of a collection are listed in the collection's order
+	from := "clips c"
+	orderBy := "c.is_pinned DESC, c.sort_position IS NULL, c.sort_position, c.created_at DESC"
+	if filter.Trashed {
+		orderBy = "c.deleted_at DESC"
 	}
+	if filter.CollectionID != 0 {
+		from += " INNER JOIN collection_clips cc ON cc.clip_id = c.id AND cc.collection_id = ?"
+		orderBy = "cc.position"
+		args = append([]interface{}{filter.CollectionID}, args...)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT c.id, c.content_type, c.filename, c.created_at, c.expires_at,
+			CASE WHEN c.is_encrypted = 0 THEN SUBSTR(c.data, 1, 500)
+				WHEN c.content_type LIKE 'text/%%' OR c.content_type = 'application/json' THEN c.data END,
+			c.is_encrypted, c.is_archived, c.is_pinned, c.source_kind, c.deleted_at, c.secrets, c.is_sensitive
+		FROM %s
+		WHERE %s
+		ORDER BY %s
+		LIMIT %d`, from, condition, orderBy, defaultClipLimit)
 
 	rows, err := a.db.Query(query, args...)
 	if err != nil {
@@ -287,6 +378,7 @@ func (a *App) GetClips(archived bool, tagIDs []int64) ([]ClipPreview, error) {
 	}
 	defer rows.Close()
 
+	secretPolicy := a.store.SecretPolicy()
 	var clips []ClipPreview
 	var clipIDs []int64
 	for rows.Next() {
@@ -294,22 +386,46 @@ func (a *App) GetClips(archived bool, tagIDs []int64) ([]ClipPreview, error) {
 		var filename sql.NullString
 		var expiresAt sql.NullTime
 		var previewData []byte
-		var isArchivedInt int
+		var isEncrypted bool
+		var isArchivedInt, isPinnedInt int
+		var sourceKind sql.NullString
+		var deletedAt sql.NullTime
+		var secrets sql.NullString
 
-		if err := rows.Scan(&clip.ID, &clip.ContentType, &filename, &clip.CreatedAt, &expiresAt, &previewData, &isArchivedInt); err != nil {
+		if err := rows.Scan(&clip.ID, &clip.ContentType, &filename, &clip.CreatedAt, &expiresAt, &previewData, &isEncrypted, &isArchivedInt, &isPinnedInt, &sourceKind, &deletedAt, &secrets, &clip.IsSensitive); err != nil {
 			log.Printf("Failed to scan clip row: %v\n", err)
 			continue
 		}
 
 		clip.Filename = filename.String
 		clip.IsArchived = isArchivedInt == 1
+		clip.IsPinned = isPinnedInt == 1
+		clip.SourceKind = sourceKind.String
 		if expiresAt.Valid {
 			clip.ExpiresAt = &expiresAt.Time
 		}
+		if deletedAt.Valid {
+			clip.DeletedAt = &deletedAt.Time
+		}
+		clip.Secrets = store.ParseSecrets(secrets)
 
-		// Only set string preview for text-based types
-		if strings.HasPrefix(clip.ContentType, "text/") || clip.ContentType == "application/json" {
+		// Only set string preview for text-based types. Encrypted text is
+		// loaded in full and shown once the database is unlocked.
+		if clip.IsSensitive {
+			clip.Preview = ""
+		} else if strings.HasPrefix(clip.ContentType, "text/") || clip.ContentType == "application/json" {
+			if isEncrypted {
+				if previewData, err = a.store.DecryptData(previewData, true); err != nil {
+					previewData = nil
+				}
+				if len(previewData) > 500 {
+					previewData = previewData[:500]
+				}
+			}
 			clip.Preview = string(previewData)
+			if len(clip.Secrets) > 0 && secretPolicy.MaskPreviews {
+				clip.Preview = secretPolicy.MaskSecrets(clip.Preview)
+			}
 		} else {
 			clip.Preview = ""
 		}
@@ -382,23 +498,49 @@ func (a *App) getTagsForClips(clipIDs []int64) (map[int64][]Tag, error) {
 
 // GetClipData retrieves full clip data by ID
 func (a *App) GetClipData(id int64) (*ClipData, error) {
+	if err := a.checkRevealed(id); err != nil {
+		return nil, err
+	}
+
 	var contentType string
 	var data []byte
+	var encrypted bool
 	var filename sql.NullString
+	var parentID sql.NullInt64
 
-	row := a.db.QueryRow("SELECT content_type, data, filename FROM clips WHERE id = ?", id)
-	if err := row.Scan(&contentType, &data, &filename); err != nil {
+	row := a.db.QueryRow("SELECT content_type, data, is_encrypted, filename, parent_id FROM clips WHERE id = ? AND deleted_at IS NULL", id)
+	if err := row.Scan(&contentType, &data, &encrypted, &filename, &parentID); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("clip not found")
 		}
 		return nil, fmt.Errorf("failed to get clip: %w", err)
 	}
+	data, err := a.store.DecryptData(data, encrypted)
+	if err != nil {
+		return nil, err
+	}
 
 	clip := &ClipData{
 		ID:          id,
 		ContentType: contentType,
 		Filename:    filename.String,
 	}
+	if parentID.Valid {
+		clip.ParentID = &parentID.Int64
+	}
+
+	if info, err := a.store.GetClipInfo(id); err == nil {
+		clip.Source = &ClipSource{
+			Kind:       info.Source.Kind,
+			Path:       info.Source.Path,
+			URL:        info.Source.URL,
+			PluginID:   info.Source.PluginID,
+			Action:     info.Source.Action,
+			ModifiedAt: info.Source.ModTime,
+		}
+		clip.Width, clip.Height = info.Width, info.Height
+		clip.LineCount, clip.Language = info.LineCount, info.Language
+	}
 
 	// For text content, return as-is; for binary, base64 encode
 	if strings.HasPrefix(contentType, "text/") || contentType == "application/json" {
@@ -412,40 +554,34 @@ func (a *App) GetClipData(id int64) (*ClipData, error) {
 
 // UploadFileAndGetID uploads a single file and returns the clip ID
 func (a *App) UploadFileAndGetID(file FileData) (int64, error) {
+	return a.uploadFile(store.User, file, nil)
+}
+
+// uploadFile decodes a file and stores it as a clip on behalf of actor
+func (a *App) uploadFile(actor store.Actor, file FileData, expiresAt *time.Time) (int64, error) {
 	// Decode base64 data
 	data, err := base64.StdEncoding.DecodeString(file.Data)
 	if err != nil {
 		return 0, fmt.Errorf("failed to decode base64 data: %w", err)
 	}
 
-	contentType := file.ContentType
-
-	// Special handling for text
-	if contentType == "text/plain" || contentType == "" {
-		textData := string(data)
-		trimmedText := strings.TrimSpace(textData)
-
-		if strings.HasPrefix(trimmedText, "<!DOCTYPE html") {
-			contentType = "text/html"
-		} else if isJSON(trimmedText) {
-			contentType = "application/json"
-		} else {
-			contentType = "text/plain"
-		}
+	src := store.Source{Kind: file.Source, Path: file.Path}
+	if file.LastModified > 0 {
+		modTime := time.UnixMilli(file.LastModified)
+		src.ModTime = &modTime
 	}
 
-	result, err := a.db.Exec("INSERT INTO clips (content_type, data, filename) VALUES (?, ?, ?)",
-		contentType, data, file.Name)
-	if err != nil {
-		return 0, fmt.Errorf("failed to insert into db: %w", err)
-	}
-
-	id, err := result.LastInsertId()
+	clip, err := a.store.CreateClip(actor, store.NewClip{
+		ContentType: file.ContentType,
+		Data:        data,
+		Filename:    file.Name,
+		ExpiresAt:   expiresAt,
+		Source:      src,
+	})
 	if err != nil {
-		return 0, fmt.Errorf("failed to get inserted ID: %w", err)
+		return 0, err
 	}
-
-	return id, nil
+	return clip.ID, nil
 }
 
 // UploadFiles handles file uploads
@@ -456,237 +592,250 @@ func (a *App) UploadFiles(files []FileData, expirationMinutes int) error {
 		expiresAt = &t
 	}
 
+	// Other files are still saved when one is refused for containing a secret
+	var refused []string
 	for _, file := range files {
-		// Decode base64 data
-		data, err := base64.StdEncoding.DecodeString(file.Data)
-		if err != nil {
-			log.Printf("Failed to decode base64 data for file %s: %v", file.Name, err)
-			continue
-		}
-
-		contentType := file.ContentType
-
-		// Special handling for text
-		if contentType == "text/plain" || contentType == "" {
-			textData := string(data)
-			trimmedText := strings.TrimSpace(textData)
-
-			if strings.HasPrefix(trimmedText, "<!DOCTYPE html") {
-				contentType = "text/html"
-			} else if isJSON(trimmedText) {
-				contentType = "application/json"
-			} else {
-				contentType = "text/plain"
+		if _, err := a.uploadFile(store.User, file, expiresAt); err != nil {
+			log.Printf("Failed to upload file %s: %v", file.Name, err)
+			if errors.Is(err, store.ErrSecretDetected) {
+				refused = append(refused, file.Name)
 			}
 		}
-
-		result, err := a.db.Exec("INSERT INTO clips (content_type, data, filename, expires_at) VALUES (?, ?, ?, ?)",
-			contentType, data, file.Name, expiresAt)
-		if err != nil {
-			log.Printf("Failed to insert into db: %v\n", err)
-			continue
-		}
-
-		// Emit plugin event
-		if a.pluginManager != nil {
-			clipID, _ := result.LastInsertId()
-			a.pluginManager.EmitEvent("clip:created", map[string]interface{}{
-				"id":           clipID,
-				"content_type": contentType,
-				"filename":     file.Name,
-			})
-		}
 	}
 
+	if len(refused) > 0 {
+		return fmt.Errorf("%w, not saved: %s", store.ErrSecretDetected, strings.Join(refused, ", "))
+	}
 	return nil
 }
 
 // DeleteClip deletes a clip by ID
 func (a *App) DeleteClip(id int64) error {
-	// Get tag IDs before deleting (to clean up orphaned tags)
-	rows, err := a.db.Query("SELECT tag_id FROM clip_tags WHERE clip_id = ?", id)
+	return a.store.TrashClips(store.User, []int64{id})
+}
+
+// ToggleArchive toggles the archived status of a clip
+func (a *App) ToggleArchive(id int64) error {
+	return a.store.ToggleArchive(store.User, []int64{id})
+}
+
+// CancelExpiration removes the expiration for a clip
+func (a *App) CancelExpiration(id int64) error {
+	return a.store.CancelExpiration(store.User, id)
+}
+
+// SetClipPinned pins or unpins a clip. Pinned clips are listed first, never
+// expire and are kept by BulkDelete unless forced.
+func (a *App) SetClipPinned(id int64, pinned bool) error {
+	return a.store.SetPinned(store.User, id, pinned)
+}
+
+// sensitiveRevealDuration is how long a revealed sensitive clip can be read
+const sensitiveRevealDuration = 2 * time.Minute
+
+// SetClipsSensitive marks clips as sensitive or clears the mark. Sensitive
+// clips have no preview, their data can only be read after RevealClip and
+// plugins can't see them without the sensitive_clips permission. Marked clips
+// expire after the sensitive expiration setting unless pinned.
+func (a *App) SetClipsSensitive(ids []int64, sensitive bool) error {
+	minutes, err := loadSensitiveExpireMinutes(a.db)
 	if err != nil {
-		return fmt.Errorf("failed to query clip tags: %w", err)
+		return err
 	}
-	var tagIDs []int64
-	for rows.Next() {
-		var tagID int64
-		if err := rows.Scan(&tagID); err == nil {
-			tagIDs = append(tagIDs, tagID)
+	if !sensitive {
+		a.mu.Lock()
+		for _, id := range ids {
+			delete(a.revealed, id)
 		}
+		a.mu.Unlock()
 	}
-	rows.Close()
+	return a.store.SetSensitive(store.User, ids, sensitive, time.Duration(minutes)*time.Minute)
+}
 
-	// Explicitly delete clip_tags (don't rely on CASCADE)
-	_, err = a.db.Exec("DELETE FROM clip_tags WHERE clip_id = ?", id)
+// RevealClip returns the data of a clip and lets GetClipData and the
+// file actions read it for a short while if it is sensitive
+func (a *App) RevealClip(id int64) (*ClipData, error) {
+	sensitive, err := a.store.IsSensitive(id)
 	if err != nil {
-		return fmt.Errorf("failed to delete clip tags: %w", err)
+		return nil, err
 	}
-
-	// Delete the clip
-	_, err = a.db.Exec("DELETE FROM clips WHERE id = ?", id)
-	if err != nil {
-		return fmt.Errorf("failed to delete clip: %w", err)
+	if sensitive {
+		a.mu.Lock()
+		if a.revealed == nil {
+			a.revealed = make(map[int64]time.Time)
+		}
+		a.revealed[id] = time.Now().Add(sensitiveRevealDuration)
+		a.mu.Unlock()
 	}
+	return a.GetClipData(id)
+}
 
-	// Clean up orphaned tags
-	for _, tagID := range tagIDs {
-		a.deleteTagIfOrphaned(tagID)
+// checkRevealed returns store.ErrNotRevealed if a clip is sensitive and
+// wasn't revealed recently
+func (a *App) checkRevealed(id int64) error {
+	sensitive, err := a.store.IsSensitive(id)
+	if err != nil || !sensitive {
+		// Missing clips are reported by the caller
+		return nil
 	}
 
-	// Emit plugin event
-	if a.pluginManager != nil {
-		a.pluginManager.EmitEvent("clip:deleted", id)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	until, ok := a.revealed[id]
+	if !ok || time.Now().After(until) {
+		delete(a.revealed, id)
+		return store.ErrNotRevealed
 	}
 	return nil
 }
 
-// ToggleArchive toggles the archived status of a clip
-func (a *App) ToggleArchive(id int64) error {
-	_, err := a.db.Exec("UPDATE clips SET is_archived = NOT is_archived WHERE id = ?", id)
+// ReorderClips sets the manual order of clips. The given clips are listed in
+// this order before the others of their group (pinned or not).
+func (a *App) ReorderClips(ids []int64) error {
+	return a.store.ReorderClips(store.User, ids)
+}
+
+// SaveEditedClip saves the result of the editor. With overwrite the source clip
+// is replaced and its previous content kept as a revision; otherwise a new clip
+// derived from the source is created. Returns the ID of the saved clip.
+func (a *App) SaveEditedClip(sourceID int64, file FileData, overwrite bool) (int64, error) {
+	data, err := base64.StdEncoding.DecodeString(file.Data)
 	if err != nil {
-		return fmt.Errorf("failed to toggle archive: %w", err)
+		return 0, fmt.Errorf("failed to decode base64 data: %w", err)
 	}
 
-	// Emit plugin event
-	if a.pluginManager != nil {
-		// Get current archived state after toggle
-		var isArchived int
-		a.db.QueryRow("SELECT is_archived FROM clips WHERE id = ?", id).Scan(&isArchived)
-		if isArchived == 1 {
-			a.pluginManager.EmitEvent("clip:archived", map[string]interface{}{
-				"id": id,
-			})
-		} else {
-			a.pluginManager.EmitEvent("clip:unarchived", map[string]interface{}{
-				"id": id,
-			})
-		}
+	if overwrite {
+		err := a.store.UpdateClip(store.User, sourceID, store.ClipUpdate{
+			Data:        data,
+			ContentType: &file.ContentType,
+			Filename:    &file.Name,
+		})
+		return sourceID, err
 	}
 
-	return nil
-}
-
-// CancelExpiration removes the expiration for a clip
-func (a *App) CancelExpiration(id int64) error {
-	_, err := a.db.Exec("UPDATE clips SET expires_at = NULL WHERE id = ?", id)
+	clip, err := a.store.CreateClip(store.User, store.NewClip{
+		ContentType: file.ContentType,
+		Data:        data,
+		Filename:    file.Name,
+		ParentID:    sourceID,
+		Source:      store.Source{Kind: store.SourceEditor},
+	})
 	if err != nil {
-		return fmt.Errorf("failed to cancel expiration: %w", err)
+		return 0, err
 	}
-	return nil
+	return clip.ID, nil
 }
 
-// --- Tag Methods ---
+// --- Revision Methods ---
 
-// CreateTag creates a new tag with auto-assigned color
-func (a *App) CreateTag(name string) (*Tag, error) {
-	name = strings.TrimSpace(name)
-	if name == "" {
-		return nil, fmt.Errorf("tag name cannot be empty")
+// GetClipRevisions returns the previous versions of a clip, newest first
+func (a *App) GetClipRevisions(clipID int64) ([]ClipRevision, error) {
+	revisions, err := a.store.ListRevisions(clipID)
+	if err != nil {
+		return nil, err
 	}
-	if len(name) > maxTagNameLength {
-		return nil, fmt.Errorf("tag name too long (max %d characters)", maxTagNameLength)
+
+	result := make([]ClipRevision, len(revisions))
+	for i, r := range revisions {
+		result[i] = ClipRevision{
+			ID:          r.ID,
+			ContentType: r.ContentType,
+			Filename:    r.Filename,
+			Size:        r.Size,
+			CreatedAt:   r.CreatedAt,
+		}
 	}
+	return result, nil
+}
 
-	// Use transaction to prevent race condition in color assignment
-	tx, err := a.db.Begin()
+// GetClipRevisionData returns the content of a revision in the same form as
+// GetClipData
+func (a *App) GetClipRevisionData(clipID, revisionID int64) (*ClipData, error) {
+	if err := a.checkRevealed(clipID); err != nil {
+		return nil, err
+	}
+	contentType, data, filename, err := a.store.RevisionData(clipID, revisionID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, err
 	}
-	defer tx.Rollback()
 
-	// Get count of existing tags to determine color (within transaction)
-	var count int
-	if err := tx.QueryRow("SELECT COUNT(*) FROM tags").Scan(&count); err != nil {
-		return nil, fmt.Errorf("failed to count tags: %w", err)
+	clip := &ClipData{
+		ID:          clipID,
+		ContentType: contentType,
+		Filename:    filename,
 	}
-	color := tagColors[count%len(tagColors)]
-
-	result, err := tx.Exec("INSERT INTO tags (name, color) VALUES (?, ?)", name, color)
-	if err != nil {
-		if strings.Contains(err.Error(), "UNIQUE") {
-			return nil, fmt.Errorf("tag already exists: %s", name)
-		}
-		return nil, fmt.Errorf("failed to create tag: %w", err)
+	if store.IsText(contentType) {
+		clip.Data = string(data)
+	} else {
+		clip.Data = base64.StdEncoding.EncodeToString(data)
 	}
+	return clip, nil
+}
 
-	id, err := result.LastInsertId()
+// DiffClipRevisions returns the line diff between two text revisions of a clip.
+// Use 0 as a revision ID for the clip's current content.
+func (a *App) DiffClipRevisions(clipID, fromRevisionID, toRevisionID int64) ([]DiffLine, error) {
+	if err := a.checkRevealed(clipID); err != nil {
+		return nil, err
+	}
+	lines, err := a.store.DiffRevisions(clipID, fromRevisionID, toRevisionID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get tag ID: %w", err)
+		return nil, err
 	}
 
-	if err := tx.Commit(); err != nil {
-		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	result := make([]DiffLine, len(lines))
+	for i, l := range lines {
+		result[i] = DiffLine{Op: l.Op, Text: l.Text}
 	}
+	return result, nil
+}
 
-	// Emit plugin event
-	if a.pluginManager != nil {
-		a.pluginManager.EmitEvent("tag:created", map[string]interface{}{
-			"id":    id,
-			"name":  name,
-			"color": color,
-		})
+// RestoreClipRevision replaces a clip's content with a previous revision. The
+// current content is kept as a new revision.
+func (a *App) RestoreClipRevision(clipID, revisionID int64) error {
+	return a.store.RestoreRevision(store.User, clipID, revisionID)
+}
+
+// GetDerivedClips returns the IDs of clips derived from a clip, such as
+// plugin results and edited copies
+func (a *App) GetDerivedClips(clipID int64) ([]int64, error) {
+	return a.store.DerivedClips(clipID)
+}
+
+// --- Tag Methods ---
+
+// CreateTag creates a new tag with auto-assigned color
+func (a *App) CreateTag(name string) (*Tag, error) {
+	tag, err := a.store.CreateTag(store.User, name)
+	if err != nil {
+		return nil, err
 	}
 
 	return &Tag{
-		ID:    id,
-		Name:  name,
-		Color: color,
+		ID:    tag.ID,
+		Name:  tag.Name,
+		Color: tag.Color,
 		Count: 0,
 	}, nil
 }
 
 // UpdateTag updates a tag's name and/or color
 func (a *App) UpdateTag(id int64, name, color string) error {
-	name = strings.TrimSpace(name)
-	if name == "" {
-		return fmt.Errorf("tag name cannot be empty")
-	}
-	if len(name) > maxTagNameLength {
-		return fmt.Errorf("tag name too long (max %d characters)", maxTagNameLength)
-	}
-
-	_, err := a.db.Exec("UPDATE tags SET name = ?, color = ? WHERE id = ?", name, color, id)
-	if err != nil {
-		if strings.Contains(err.Error(), "UNIQUE") {
-			return fmt.Errorf("tag name already exists: %s", name)
-		}
-		return fmt.Errorf("failed to update tag: %w", err)
-	}
-
-	// Emit plugin event
-	if a.pluginManager != nil {
-		a.pluginManager.EmitEvent("tag:updated", map[string]interface{}{
-			"id":    id,
-			"name":  name,
-			"color": color,
-		})
-	}
-
-	return nil
+	return a.store.UpdateTag(store.User, id, name, color)
 }
 
 // DeleteTag deletes a tag (clip_tags cascade delete handles associations)
 func (a *App) DeleteTag(id int64) error {
-	_, err := a.db.Exec("DELETE FROM tags WHERE id = ?", id)
-	if err != nil {
-		return fmt.Errorf("failed to delete tag: %w", err)
-	}
-
-	// Emit plugin event
-	if a.pluginManager != nil {
-		a.pluginManager.EmitEvent("tag:deleted", id)
-	}
-
-	return nil
+	return a.store.DeleteTag(store.User, id)
 }
 
 // GetTags retrieves all tags with usage counts
 func (a *App) GetTags() ([]Tag, error) {
 	rows, err := a.db.Query(`
-		SELECT t.id, t.name, t.color, COUNT(ct.clip_id) as count
+		SELECT t.id, t.name, t.color, COUNT(c.id) as count
 		FROM tags t
 		LEFT JOIN clip_tags ct ON t.id = ct.tag_id
+		LEFT JOIN clips c ON c.id = ct.clip_id AND c.deleted_at IS NULL
 		GROUP BY t.id
 		ORDER BY t.name
 	`)
@@ -713,131 +862,22 @@ func (a *App) GetTags() ([]Tag, error) {
 
 // AddTagToClip adds a tag to a clip
 func (a *App) AddTagToClip(clipID, tagID int64) error {
-	_, err := a.db.Exec("INSERT OR IGNORE INTO clip_tags (clip_id, tag_id) VALUES (?, ?)", clipID, tagID)
-	if err != nil {
-		return fmt.Errorf("failed to add tag to clip: %w", err)
-	}
-
-	// Emit plugin event
-	if a.pluginManager != nil {
-		a.pluginManager.EmitEvent("tag:added_to_clip", map[string]interface{}{
-			"tag_id":  tagID,
-			"clip_id": clipID,
-		})
-	}
-
-	return nil
+	return a.store.AddTagToClip(store.User, clipID, tagID)
 }
 
 // RemoveTagFromClip removes a tag from a clip
 func (a *App) RemoveTagFromClip(clipID, tagID int64) error {
-	_, err := a.db.Exec("DELETE FROM clip_tags WHERE clip_id = ? AND tag_id = ?", clipID, tagID)
-	if err != nil {
-		return fmt.Errorf("failed to remove tag from clip: %w", err)
-	}
-
-	// Emit plugin event
-	if a.pluginManager != nil {
-		a.pluginManager.EmitEvent("tag:removed_from_clip", map[string]interface{}{
-			"tag_id":  tagID,
-			"clip_id": clipID,
-		})
-	}
-
-	// Clean up orphaned tag
-	a.deleteTagIfOrphaned(tagID)
-	return nil
-}
-
-// deleteTagIfOrphaned deletes a tag if it has no associated clips
-func (a *App) deleteTagIfOrphaned(tagID int64) {
-	var count int
-	err := a.db.QueryRow("SELECT COUNT(*) FROM clip_tags WHERE tag_id = ?", tagID).Scan(&count)
-	if err != nil {
-		return
-	}
-	if count == 0 {
-		_, err := a.db.Exec("DELETE FROM tags WHERE id = ?", tagID)
-		if err == nil && a.pluginManager != nil {
-			a.pluginManager.EmitEvent("tag:deleted", tagID)
-		}
-	}
+	return a.store.RemoveTagFromClip(store.User, clipID, tagID)
 }
 
 // BulkAddTag adds a tag to multiple clips
 func (a *App) BulkAddTag(clipIDs []int64, tagID int64) error {
-	if len(clipIDs) == 0 {
-		return nil
-	}
-
-	tx, err := a.db.Begin()
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
-	}
-	defer tx.Rollback()
-
-	stmt, err := tx.Prepare("INSERT OR IGNORE INTO clip_tags (clip_id, tag_id) VALUES (?, ?)")
-	if err != nil {
-		return fmt.Errorf("failed to prepare statement: %w", err)
-	}
-	defer stmt.Close()
-
-	for _, clipID := range clipIDs {
-		if _, err := stmt.Exec(clipID, tagID); err != nil {
-			return fmt.Errorf("failed to add tag to clip %d: %w", clipID, err)
-		}
-	}
-
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
-
-	// Emit plugin events for each clip
-	if a.pluginManager != nil {
-		for _, clipID := range clipIDs {
-			a.pluginManager.EmitEvent("tag:added_to_clip", map[string]interface{}{
-				"tag_id":  tagID,
-				"clip_id": clipID,
-			})
-		}
-	}
-
-	return nil
+	return a.store.AddTagToClips(store.User, clipIDs, tagID)
 }
 
 // BulkRemoveTag removes a tag from multiple clips
 func (a *App) BulkRemoveTag(clipIDs []int64, tagID int64) error {
-	if len(clipIDs) == 0 {
-		return nil
-	}
-
-	placeholders := make([]string, len(clipIDs))
-	args := make([]interface{}, len(clipIDs)+1)
-	args[0] = tagID
-	for i, id := range clipIDs {
-		placeholders[i] = "?"
-		args[i+1] = id
-	}
-
-	query := fmt.Sprintf("DELETE FROM clip_tags WHERE tag_id = ? AND clip_id IN (%s)", strings.Join(placeholders, ","))
-	_, err := a.db.Exec(query, args...)
-	if err != nil {
-		return fmt.Errorf("failed to bulk remove tag: %w", err)
-	}
-
-	// Emit plugin events for each clip
-	if a.pluginManager != nil {
-		for _, clipID := range clipIDs {
-			a.pluginManager.EmitEvent("tag:removed_from_clip", map[string]interface{}{
-				"tag_id":  tagID,
-				"clip_id": clipID,
-			})
-		}
-	}
-
-	// Clean up orphaned tag
-	a.deleteTagIfOrphaned(tagID)
-	return nil
+	return a.store.RemoveTagFromClips(store.User, clipIDs, tagID)
 }
 
 // GetClipTags returns tags for a specific clip
@@ -870,81 +910,300 @@ func (a *App) GetClipTags(clipID int64) ([]Tag, error) {
 	return tags, nil
 }
 
-// BulkDelete deletes multiple clips at once
-func (a *App) BulkDelete(ids []int64) error {
-	if len(ids) == 0 {
-		return nil
+// --- Collection Methods ---
+
+// GetCollections retrieves all collections with clip counts
+func (a *App) GetCollections() ([]Collection, error) {
+	collections, err := a.store.ListCollections()
+	if err != nil {
+		return nil, err
 	}
+	return toCollections(collections), nil
+}
 
-	placeholders := make([]string, len(ids))
-	args := make([]interface{}, len(ids))
-	for i, id := range ids {
-		placeholders[i] = "?"
-		args[i] = id
+// CreateCollection creates an empty collection
+func (a *App) CreateCollection(name, description string) (*Collection, error) {
+	collection, err := a.store.CreateCollection(store.User, name, description)
+	if err != nil {
+		return nil, err
 	}
+	return &toCollections([]store.Collection{*collection})[0], nil
+}
+
+// UpdateCollection renames a collection and sets its description
+func (a *App) UpdateCollection(id int64, name, description string) error {
+	return a.store.UpdateCollection(store.User, id, name, description)
+}
+
+// DeleteCollection deletes a collection, keeping its clips
+func (a *App) DeleteCollection(id int64) error {
+	return a.store.DeleteCollection(store.User, id)
+}
+
+// AddClipsToCollection appends clips to the end of a collection
+func (a *App) AddClipsToCollection(collectionID int64, clipIDs []int64) error {
+	return a.store.AddToCollection(store.User, collectionID, clipIDs)
+}
 
-	// Get all tag IDs associated with these clips before deleting
-	tagQuery := fmt.Sprintf("SELECT DISTINCT tag_id FROM clip_tags WHERE clip_id IN (%s)", strings.Join(placeholders, ","))
-	rows, err := a.db.Query(tagQuery, args...)
+// RemoveClipsFromCollection removes clips from a collection
+func (a *App) RemoveClipsFromCollection(collectionID int64, clipIDs []int64) error {
+	return a.store.RemoveFromCollection(store.User, collectionID, clipIDs)
+}
+
+// ReorderCollection moves clips to the front of a collection in the given order
+func (a *App) ReorderCollection(collectionID int64, clipIDs []int64) error {
+	return a.store.ReorderCollection(store.User, collectionID, clipIDs)
+}
+
+// GetClipCollections returns the collections a clip belongs to
+func (a *App) GetClipCollections(clipID int64) ([]Collection, error) {
+	collections, err := a.store.ClipCollections(clipID)
 	if err != nil {
-		return fmt.Errorf("failed to query clip tags: %w", err)
+		return nil, err
 	}
-	var tagIDs []int64
-	for rows.Next() {
-		var tagID int64
-		if err := rows.Scan(&tagID); err == nil {
-			tagIDs = append(tagIDs, tagID)
+	return toCollections(collections), nil
+}
+
+func toCollections(collections []store.Collection) []Collection {
+	result := make([]Collection, len(collections))
+	for i, c := range collections {
+		result[i] = Collection{
+			ID:          c.ID,
+			Name:        c.Name,
+			Description: c.Description,
+			Count:       c.Count,
+			CreatedAt:   c.CreatedAt,
 		}
 	}
-	rows.Close()
+	return result
+}
+
+// --- Saved Search Methods ---
+
+// GetSavedSearches retrieves all saved searches with their current match counts
+func (a *App) GetSavedSearches() ([]SavedSearch, error) {
+	searches, err := a.store.ListSavedSearches()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]SavedSearch, 0, len(searches))
+	for _, s := range searches {
+		count, err := a.store.CountClips(s.Filter)
+		if err != nil {
+			log.Printf("Failed to count clips of saved search %d: %v", s.ID, err)
+		}
+		result = append(result, SavedSearch{
+			ID:        s.ID,
+			Name:      s.Name,
+			Filter:    s.Filter,
+			Count:     count,
+			CreatedAt: s.CreatedAt,
+		})
+	}
+	return result, nil
+}
+
+// CreateSavedSearch saves a filter under a name
+func (a *App) CreateSavedSearch(name string, filter ClipFilter) (*SavedSearch, error) {
+	s, err := a.store.CreateSavedSearch(name, filter)
+	if err != nil {
+		return nil, err
+	}
+	count, _ := a.store.CountClips(s.Filter)
+	return &SavedSearch{ID: s.ID, Name: s.Name, Filter: s.Filter, Count: count, CreatedAt: s.CreatedAt}, nil
+}
+
+// UpdateSavedSearch renames a saved search and replaces its filter
+func (a *App) UpdateSavedSearch(id int64, name string, filter ClipFilter) error {
+	return a.store.UpdateSavedSearch(id, name, filter)
+}
+
+// DeleteSavedSearch deletes a saved search
+func (a *App) DeleteSavedSearch(id int64) error {
+	return a.store.DeleteSavedSearch(id)
+}
 
-	// Explicitly delete clip_tags (don't rely on CASCADE)
-	clipTagsQuery := fmt.Sprintf("DELETE FROM clip_tags WHERE clip_id IN (%s)", strings.Join(placeholders, ","))
-	_, err = a.db.Exec(clipTagsQuery, args...)
+// RunSavedSearch retrieves the clips matching a saved search
+func (a *App) RunSavedSearch(id int64) ([]ClipPreview, error) {
+	s, err := a.store.GetSavedSearch(id)
 	if err != nil {
-		return fmt.Errorf("failed to delete clip tags: %w", err)
+		return nil, err
+	}
+	return a.SearchClips(s.Filter)
+}
+
+// BulkDelete deletes multiple clips at once. Pinned clips are kept unless
+// force is set; it returns how many were kept.
+func (a *App) BulkDelete(ids []int64, force bool) (int, error) {
+	if force {
+		return 0, a.store.TrashClips(store.User, ids)
 	}
 
-	// Delete the clips
-	query := fmt.Sprintf("DELETE FROM clips WHERE id IN (%s)", strings.Join(placeholders, ","))
-	_, err = a.db.Exec(query, args...)
+	unpinned, pinned, err := a.store.SplitPinned(ids)
 	if err != nil {
-		return fmt.Errorf("failed to bulk delete: %w", err)
+		return 0, err
 	}
+	return len(pinned), a.store.TrashClips(store.User, unpinned)
+}
 
-	// Clean up orphaned tags
-	for _, tagID := range tagIDs {
-		a.deleteTagIfOrphaned(tagID)
+// GetTrash retrieves the clips in the trash, most recently deleted first
+func (a *App) GetTrash() ([]ClipPreview, error) {
+	return a.SearchClips(ClipFilter{Trashed: true})
+}
+
+// RestoreClips moves clips out of the trash
+func (a *App) RestoreClips(ids []int64) error {
+	return a.store.RestoreClips(store.User, ids)
+}
+
+// PurgeClips permanently deletes clips from the trash
+func (a *App) PurgeClips(ids []int64) error {
+	return a.store.PurgeClips(store.User, ids)
+}
+
+// EmptyTrash permanently deletes all clips in the trash and returns how many
+// were deleted
+func (a *App) EmptyTrash() (int, error) {
+	return a.store.EmptyTrash(store.User)
+}
+
+// GetTrashRetentionDays returns how long trashed clips are kept before they
+// are purged. 0 means forever.
+func (a *App) GetTrashRetentionDays() (int, error) {
+	return loadTrashRetentionDays(a.db)
+}
+
+// SetTrashRetentionDays sets how long trashed clips are kept. 0 keeps them
+// until the trash is emptied.
+func (a *App) SetTrashRetentionDays(days int) error {
+	if days < 0 {
+		return fmt.Errorf("trash retention cannot be negative")
 	}
-	return nil
+	return a.SetSetting(trashRetentionSetting, strconv.Itoa(days))
 }
 
-// BulkArchive toggles the archived status of multiple clips
-func (a *App) BulkArchive(ids []int64) error {
-	if len(ids) == 0 {
-		return nil
+// GetSensitiveExpireMinutes returns after how many minutes clips expire once
+// marked sensitive. 0 means they don't.
+func (a *App) GetSensitiveExpireMinutes() (int, error) {
+	return loadSensitiveExpireMinutes(a.db)
+}
+
+// SetSensitiveExpireMinutes sets after how many minutes clips expire once
+// marked sensitive. 0 keeps their expiration.
+func (a *App) SetSensitiveExpireMinutes(minutes int) error {
+	if minutes < 0 {
+		return fmt.Errorf("sensitive expiration cannot be negative")
 	}
+	return a.SetSetting(sensitiveExpireSetting, strconv.Itoa(minutes))
+}
 
-	placeholders := make([]string, len(ids))
-	args := make([]interface{}, len(ids))
-	for i, id := range ids {
-		placeholders[i] = "?"
-		args[i] = id
+// EncryptionKey is a passphrase or key file that protects the clip data
+type EncryptionKey = store.MasterKey
+
+// EncryptionStatus describes whether clip data is encrypted and unlocked
+type EncryptionStatus = store.EncryptionStatus
+
+// GetEncryptionStatus reports whether clip data is encrypted and unlocked
+func (a *App) GetEncryptionStatus() (*EncryptionStatus, error) {
+	return a.store.EncryptionStatus()
+}
+
+// EnableEncryption encrypts all clip data with key. Plugin secrets move from
+// their key file to a key derived from the data key.
+func (a *App) EnableEncryption(key EncryptionKey) error {
+	return a.reencryptSecrets(func() error { return a.store.EnableEncryption(key) })
+}
+
+// DisableEncryption decrypts all clip data. The current key is required.
+// Plugin secrets move back to a key file.
+func (a *App) DisableEncryption(key EncryptionKey) error {
+	return a.reencryptSecrets(func() error { return a.store.DisableEncryption(key) })
+}
+
+// reencryptSecrets runs change, which turns encryption on or off, and
+// re-encrypts the plugin secrets to match
+func (a *App) reencryptSecrets(change func() error) error {
+	if a.pluginManager == nil {
+		return change()
 	}
+	return a.pluginManager.Secrets().Reencrypt(change)
+}
+
+// UnlockDatabase makes encrypted clips readable until the app quits or the
+// database is locked again
+func (a *App) UnlockDatabase(key EncryptionKey) error {
+	return a.store.Unlock(key)
+}
 
-	query := fmt.Sprintf("UPDATE clips SET is_archived = NOT is_archived WHERE id IN (%s)", strings.Join(placeholders, ","))
-	_, err := a.db.Exec(query, args...)
+// LockDatabase forgets the key so encrypted clips can't be read
+func (a *App) LockDatabase() error {
+	status, err := a.store.EncryptionStatus()
 	if err != nil {
-		return fmt.Errorf("failed to bulk archive: %w", err)
+		return err
+	}
+	if !status.Enabled {
+		return store.ErrEncryptionDisabled
 	}
+	a.store.Lock()
 	return nil
 }
 
+// ChangeEncryptionKey replaces the passphrase or key file that protects the
+// clip data
+func (a *App) ChangeEncryptionKey(current, next EncryptionKey) error {
+	return a.store.Rekey(current, next)
+}
+
+// CreateKeyFile opens a save dialog and writes a new random key file there.
+// Returns the path, or "" if the user cancelled.
+func (a *App) CreateKeyFile() (string, error) {
+	savePath, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		DefaultFilename: "mahpastes.key",
+		Title:           "Create Key File",
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to show save dialog: %w", err)
+	}
+
+	if savePath == "" {
+		return "", nil // User cancelled
+	}
+
+	if err := store.GenerateKeyFile(savePath); err != nil {
+		return "", err
+	}
+
+	return savePath, nil
+}
+
+// SelectKeyFile opens a file picker for an existing key file. Returns "" if
+// the user cancelled.
+func (a *App) SelectKeyFile() (string, error) {
+	openPath, err := runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
+		Title: "Select Key File",
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to show open dialog: %w", err)
+	}
+	return openPath, nil
+}
+
+// BulkArchive toggles the archived status of multiple clips
+func (a *App) BulkArchive(ids []int64) error {
+	return a.store.ToggleArchive(store.User, ids)
+}
+
 // BulkDownloadToFile creates a ZIP archive and saves it using native save dialog
 func (a *App) BulkDownloadToFile(ids []int64) error {
 	if len(ids) == 0 {
 		return fmt.Errorf("no IDs provided")
 	}
+	for _, id := range ids {
+		if err := a.checkRevealed(id); err != nil {
+			return fmt.Errorf("clip %d: %w", id, err)
+		}
+	}
 
 	// Create placeholders for the IN clause
 	placeholders := make([]string, len(ids))
@@ -954,7 +1213,7 @@ func (a *App) BulkDownloadToFile(ids []int64) error {
 		args[i] = id
 	}
 
-	query := fmt.Sprintf("SELECT id, content_type, filename, data FROM clips WHERE id IN (%s)", strings.Join(placeholders, ","))
+	query := fmt.Sprintf("SELECT id, content_type, filename, data, is_encrypted FROM clips WHERE id IN (%s)", strings.Join(placeholders, ","))
 	rows, err := a.db.Query(query, args...)
 	if err != nil {
 		return fmt.Errorf("failed to query clips: %w", err)
@@ -970,11 +1229,15 @@ func (a *App) BulkDownloadToFile(ids []int64) error {
 		var contentType string
 		var filename sql.NullString
 		var data []byte
+		var encrypted bool
 
-		if err := rows.Scan(&id, &contentType, &filename, &data); err != nil {
+		if err := rows.Scan(&id, &contentType, &filename, &data, &encrypted); err != nil {
 			log.Printf("Failed to scan clip for download: %v\n", err)
 			continue
 		}
+		if data, err = a.store.DecryptData(data, encrypted); err != nil {
+			return err
+		}
 
 		// Determine a filename for the zip entry
 		name := filename.String
@@ -1031,17 +1294,26 @@ func (a *App) BulkDownloadToFile(ids []int64) error {
 
 // CreateTempFile creates a temporary file from a clip and returns its path
 func (a *App) CreateTempFile(id int64) (string, error) {
+	if err := a.checkRevealed(id); err != nil {
+		return "", err
+	}
+
 	var data []byte
+	var encrypted bool
 	var filename sql.NullString
 	var contentType string
 
-	row := a.db.QueryRow("SELECT data, filename, content_type FROM clips WHERE id = ?", id)
-	if err := row.Scan(&data, &filename, &contentType); err != nil {
+	row := a.db.QueryRow("SELECT data, is_encrypted, filename, content_type FROM clips WHERE id = ? AND deleted_at IS NULL", id)
+	if err := row.Scan(&data, &encrypted, &filename, &contentType); err != nil {
 		if err == sql.ErrNoRows {
 			return "", fmt.Errorf("clip not found")
 		}
 		return "", fmt.Errorf("failed to get clip: %w", err)
 	}
+	data, err := a.store.DecryptData(data, encrypted)
+	if err != nil {
+		return "", err
+	}
 
 	// Create a safe filename
 	safeName := fmt.Sprintf("%d", id)
@@ -1128,26 +1400,40 @@ func (a *App) ReadFileFromPath(path string) (*FileData, error) {
 		contentType = "application/octet-stream"
 	}
 
-	return &FileData{
+	fileData := &FileData{
 		Name:        filepath.Base(path),
 		ContentType: contentType,
 		Data:        base64.StdEncoding.EncodeToString(data),
-	}, nil
+		Path:        path,
+	}
+	if info, err := os.Stat(path); err == nil {
+		fileData.LastModified = info.ModTime().UnixMilli()
+	}
+	return fileData, nil
 }
 
 // SaveClipToFile saves a single clip to file using native save dialog
 func (a *App) SaveClipToFile(id int64) error {
+	if err := a.checkRevealed(id); err != nil {
+		return err
+	}
+
 	var data []byte
+	var encrypted bool
 	var filename sql.NullString
 	var contentType string
 
-	row := a.db.QueryRow("SELECT data, filename, content_type FROM clips WHERE id = ?", id)
-	if err := row.Scan(&data, &filename, &contentType); err != nil {
+	row := a.db.QueryRow("SELECT data, is_encrypted, filename, content_type FROM clips WHERE id = ? AND deleted_at IS NULL", id)
+	if err := row.Scan(&data, &encrypted, &filename, &contentType); err != nil {
 		if err == sql.ErrNoRows {
 			return fmt.Errorf("clip not found")
 		}
 		return fmt.Errorf("failed to get clip: %w", err)
 	}
+	data, err := a.store.DecryptData(data, encrypted)
+	if err != nil {
+		return err
+	}
 
 	// Determine default filename
 	defaultFilename := filename.String
@@ -1235,12 +1521,6 @@ func (a *App) ConfirmRestoreBackup(backupPath string) error {
 	return a.RestoreBackup(backupPath)
 }
 
-// isJSON checks if a string is valid JSON
-func isJSON(s string) bool {
-	var js json.RawMessage
-	return json.Unmarshal([]byte(s), &js) == nil
-}
-
 // GetWatchedFolders retrieves all watched folders
 func (a *App) GetWatchedFolders() ([]WatchedFolder, error) {
 	rows, err := a.db.Query(`
@@ -1528,3 +1808,150 @@ func (a *App) SetSetting(key string, value string) error {
 	return err
 }
 
+// GetRetentionPolicy retrieves the retention policy applied by the cleanup job
+func (a *App) GetRetentionPolicy() (RetentionPolicy, error) {
+	return loadRetentionPolicy(a.db)
+}
+
+// SetRetentionPolicy validates and stores the retention policy. It takes
+// effect on the next cleanup run.
+func (a *App) SetRetentionPolicy(policy RetentionPolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(policy)
+	if err != nil {
+		return fmt.Errorf("failed to encode retention policy: %w", err)
+	}
+	return a.SetSetting(retentionPolicySetting, string(data))
+}
+
+// SecretPolicy decides what happens to clips that contain secrets, see
+// store.SecretPolicy
+type SecretPolicy = store.SecretPolicy
+
+// GetSecretPolicy retrieves the policy applied to clips that contain secrets
+func (a *App) GetSecretPolicy() (SecretPolicy, error) {
+	return a.store.SecretPolicy(), nil
+}
+
+// SetSecretPolicy validates, stores and applies the secret policy. Clips that
+// were already saved keep their flags until RescanSecrets is called.
+func (a *App) SetSecretPolicy(policy SecretPolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(policy)
+	if err != nil {
+		return fmt.Errorf("failed to encode secret policy: %w", err)
+	}
+	if err := a.SetSetting(secretPolicySetting, string(data)); err != nil {
+		return err
+	}
+	a.store.SetSecretPolicy(policy)
+	return nil
+}
+
+// GetSecretRules returns the names of the rules the secret scanner can apply
+func (a *App) GetSecretRules() []string {
+	return store.SecretRules()
+}
+
+// RescanSecrets scans all existing text clips with the current secret policy
+// and returns how many contain secrets
+func (a *App) RescanSecrets() (int, error) {
+	return a.store.RescanSecrets()
+}
+
+// PreviewRetention returns the clips a policy would delete now, without
+// deleting anything
+func (a *App) PreviewRetention(policy RetentionPolicy) (*RetentionPreview, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	report, err := a.store.PlanRetention(policy)
+	if err != nil {
+		return nil, err
+	}
+	return toRetentionPreview(report), nil
+}
+
+// ApplyRetention applies the stored retention policy immediately
+func (a *App) ApplyRetention() (*RetentionPreview, error) {
+	policy, err := loadRetentionPolicy(a.db)
+	if err != nil {
+		return nil, err
+	}
+	report, err := a.store.ApplyRetention(store.User, policy)
+	if err != nil {
+		return nil, err
+	}
+	return toRetentionPreview(report), nil
+}
+
+func toRetentionPreview(report *store.RetentionReport) *RetentionPreview {
+	preview := &RetentionPreview{
+		Count:      len(report.Clips),
+		FreedBytes: report.FreedBytes,
+		ByReason:   report.ByReason,
+		Clips:      []RetentionClip{},
+	}
+	for i, c := range report.Clips {
+		if i == maxRetentionPreviewClips {
+			break
+		}
+		preview.Clips = append(preview.Clips, RetentionClip{
+			ID:          c.ID,
+			ContentType: c.ContentType,
+			Filename:    c.Filename,
+			Size:        c.Size,
+			CreatedAt:   c.CreatedAt,
+			Reason:      c.Reason,
+		})
+	}
+	return preview
+}
+
+// AuditEntry is a row of the audit log, see store.AuditEntry
+type AuditEntry = store.AuditEntry
+
+// AuditFilter selects audit log entries, see store.AuditFilter
+type AuditFilter = store.AuditFilter
+
+// GetAuditLog returns the audit log entries matching a filter, newest first
+func (a *App) GetAuditLog(filter AuditFilter) ([]AuditEntry, error) {
+	return a.store.QueryAudit(filter)
+}
+
+// ExportAuditLog opens a save dialog and writes the audit log entries matching
+// a filter to a JSON file. It returns the path written, or "" if cancelled.
+func (a *App) ExportAuditLog(filter AuditFilter) (string, error) {
+	entries, err := a.store.QueryAudit(filter)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode audit log: %w", err)
+	}
+
+	savePath, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		DefaultFilename: fmt.Sprintf("mahpastes-audit-%s.json", time.Now().Format("2006-01-02")),
+		Title:           "Export Audit Log",
+		Filters: []runtime.FileFilter{
+			{DisplayName: "JSON Files", Pattern: "*.json"},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to show save dialog: %w", err)
+	}
+	if savePath == "" {
+		return "", nil // User cancelled
+	}
+
+	if err := os.WriteFile(savePath, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write file: %w", err)
+	}
+	return savePath, nil
+}
+
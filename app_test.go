@@ -0,0 +1,84 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"go-clipboard/schema"
+	"go-clipboard/store"
+)
+
+// newTestApp creates an App backed by a temporary database with the app's schema
+func newTestApp(t *testing.T) *App {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := schema.Migrate(db); err != nil {
+		t.Fatalf("schema.Migrate failed: %v", err)
+	}
+	return &App{db: db, store: store.New(db), tempDir: t.TempDir()}
+}
+
+func TestDiffClipRevisions_RequiresReveal(t *testing.T) {
+	a := newTestApp(t)
+
+	clip, err := a.store.CreateClip(store.User, store.NewClip{ContentType: "text/plain", Data: []byte("secret one")})
+	if err != nil {
+		t.Fatalf("CreateClip failed: %v", err)
+	}
+	if err := a.store.UpdateClip(store.User, clip.ID, store.ClipUpdate{Data: []byte("secret two")}); err != nil {
+		t.Fatalf("UpdateClip failed: %v", err)
+	}
+	revisions, err := a.store.ListRevisions(clip.ID)
+	if err != nil || len(revisions) != 1 {
+		t.Fatalf("Expected 1 revision, got %d (%v)", len(revisions), err)
+	}
+	if _, err := a.DiffClipRevisions(clip.ID, revisions[0].ID, 0); err != nil {
+		t.Fatalf("Expected diffing a normal clip to work, got %v", err)
+	}
+
+	if err := a.store.SetSensitive(store.User, []int64{clip.ID}, true, 0); err != nil {
+		t.Fatalf("SetSensitive failed: %v", err)
+	}
+	if _, err := a.DiffClipRevisions(clip.ID, revisions[0].ID, 0); !errors.Is(err, store.ErrNotRevealed) {
+		t.Fatalf("Expected diffing an unrevealed sensitive clip to fail, got %v", err)
+	}
+
+	if _, err := a.RevealClip(clip.ID); err != nil {
+		t.Fatalf("RevealClip failed: %v", err)
+	}
+	if _, err := a.DiffClipRevisions(clip.ID, revisions[0].ID, 0); err != nil {
+		t.Errorf("Expected diffing a revealed clip to work, got %v", err)
+	}
+}
+
+func TestGetClipData_SkipsTrashedClips(t *testing.T) {
+	a := newTestApp(t)
+
+	clip, err := a.store.CreateClip(store.User, store.NewClip{ContentType: "text/plain", Data: []byte("hello")})
+	if err != nil {
+		t.Fatalf("CreateClip failed: %v", err)
+	}
+	if _, err := a.GetClipData(clip.ID); err != nil {
+		t.Fatalf("Expected GetClipData to work, got %v", err)
+	}
+	if _, err := a.CreateTempFile(clip.ID); err != nil {
+		t.Fatalf("Expected CreateTempFile to work, got %v", err)
+	}
+
+	if err := a.store.TrashClips(store.User, []int64{clip.ID}); err != nil {
+		t.Fatalf("TrashClips failed: %v", err)
+	}
+	if _, err := a.GetClipData(clip.ID); err == nil {
+		t.Error("Expected GetClipData to skip a trashed clip")
+	}
+	if _, err := a.CreateTempFile(clip.ID); err == nil {
+		t.Error("Expected CreateTempFile to skip a trashed clip")
+	}
+}
@@ -6,11 +6,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"time"
+
+	"go-clipboard/plugin"
+	"go-clipboard/store"
 )
 
 const (
@@ -32,8 +36,10 @@ type BackupManifest struct {
 type BackupSummary struct {
 	Clips        int `json:"clips"`
 	Tags         int `json:"tags"`
+	Collections  int `json:"collections"`
 	Plugins      int `json:"plugins"`
 	WatchFolders int `json:"watch_folders"`
+	SecretClips  int `json:"secret_clips"` // clips left out because they contain secrets
 }
 
 // sensitiveSettingPatterns defines patterns for settings that should not be backed up
@@ -55,6 +61,40 @@ func isSensitiveSetting(key string) bool {
 	return false
 }
 
+// skipClipRows returns an export filter that leaves out rows whose column
+// refers to one of the skipped clips
+func skipClipRows(skipped map[int64]bool, column string) func(map[string]interface{}) bool {
+	if len(skipped) == 0 {
+		return nil
+	}
+	return func(row map[string]interface{}) bool {
+		id, ok := row[column].(int64)
+		return ok && skipped[id]
+	}
+}
+
+// secretClipIDs returns the clips flagged for secrets, unless the secret
+// policy includes them in backups
+func (a *App) secretClipIDs() (map[int64]bool, error) {
+	skipped := map[int64]bool{}
+	if a.store.SecretPolicy().IncludeInBackups {
+		return skipped, nil
+	}
+	rows, err := a.db.Query("SELECT id FROM clips WHERE secrets IS NOT NULL")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query clips with secrets: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		skipped[id] = true
+	}
+	return skipped, rows.Err()
+}
+
 // exportTableToSQL exports a table to SQL INSERT statements
 func exportTableToSQL(db *sql.DB, tableName string, w io.Writer, excludeCallback func(map[string]interface{}) bool) (int, error) {
 	rows, err := db.Query(fmt.Sprintf("SELECT * FROM %s", tableName))
@@ -151,7 +191,9 @@ func formatSQLValue(v interface{}) string {
 }
 
 // CreateBackup creates a backup ZIP file at the specified path
-func (a *App) CreateBackup(destPath string) error {
+func (a *App) CreateBackup(destPath string) (err error) {
+	defer func() { a.auditBackup(store.AuditBackupCreated, destPath, err) }()
+
 	// Create temp directory for staging
 	tempDir, err := os.MkdirTemp("", "mahpastes-backup-*")
 	if err != nil {
@@ -178,10 +220,10 @@ func (a *App) CreateBackup(destPath string) error {
 		return fmt.Errorf("failed to create plugins directory: %w", err)
 	}
 
-	// Copy .lua files
+	// Copy .lua files, zipped plugin packages and detached signatures
 	if entries, err := os.ReadDir(pluginsDir); err == nil {
 		for _, entry := range entries {
-			if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".lua") {
+			if !entry.IsDir() && isPluginBackupFile(entry.Name()) {
 				src := filepath.Join(pluginsDir, entry.Name())
 				dst := filepath.Join(tempPluginsDir, entry.Name())
 				if err := copyFile(src, dst); err != nil {
@@ -235,9 +277,14 @@ func (a *App) exportDatabaseToSQL(destPath string) (BackupSummary, []string, err
 	f.WriteString(fmt.Sprintf("-- Created: %s\n", time.Now().Format(time.RFC3339)))
 	f.WriteString(fmt.Sprintf("-- Format version: %d\n\n", BackupFormatVersion))
 
-	// Export clips
+	// Export clips, leaving out those that contain secrets
+	skipped, err := a.secretClipIDs()
+	if err != nil {
+		return summary, excluded, err
+	}
+	summary.SecretClips = len(skipped)
 	f.WriteString("-- Table: clips\n")
-	count, err := exportTableToSQL(a.db, "clips", f, nil)
+	count, err := exportTableToSQL(a.db, "clips", f, skipClipRows(skipped, "id"))
 	if err != nil {
 		return summary, excluded, fmt.Errorf("failed to export clips: %w", err)
 	}
@@ -255,12 +302,53 @@ func (a *App) exportDatabaseToSQL(destPath string) (BackupSummary, []string, err
 
 	// Export clip_tags
 	f.WriteString("-- Table: clip_tags\n")
-	_, err = exportTableToSQL(a.db, "clip_tags", f, nil)
+	_, err = exportTableToSQL(a.db, "clip_tags", f, skipClipRows(skipped, "clip_id"))
 	if err != nil {
 		return summary, excluded, fmt.Errorf("failed to export clip_tags: %w", err)
 	}
 	f.WriteString("\n")
 
+	// Export clip_metadata
+	f.WriteString("-- Table: clip_metadata\n")
+	_, err = exportTableToSQL(a.db, "clip_metadata", f, skipClipRows(skipped, "clip_id"))
+	if err != nil {
+		return summary, excluded, fmt.Errorf("failed to export clip_metadata: %w", err)
+	}
+	f.WriteString("\n")
+
+	// Export collections
+	f.WriteString("-- Table: collections\n")
+	count, err = exportTableToSQL(a.db, "collections", f, nil)
+	if err != nil {
+		return summary, excluded, fmt.Errorf("failed to export collections: %w", err)
+	}
+	summary.Collections = count
+	f.WriteString("\n")
+
+	// Export collection_clips
+	f.WriteString("-- Table: collection_clips\n")
+	_, err = exportTableToSQL(a.db, "collection_clips", f, skipClipRows(skipped, "clip_id"))
+	if err != nil {
+		return summary, excluded, fmt.Errorf("failed to export collection_clips: %w", err)
+	}
+	f.WriteString("\n")
+
+	// Export saved searches
+	f.WriteString("-- Table: saved_searches\n")
+	_, err = exportTableToSQL(a.db, "saved_searches", f, nil)
+	if err != nil {
+		return summary, excluded, fmt.Errorf("failed to export saved_searches: %w", err)
+	}
+	f.WriteString("\n")
+
+	// Export saved_search_matches
+	f.WriteString("-- Table: saved_search_matches\n")
+	_, err = exportTableToSQL(a.db, "saved_search_matches", f, skipClipRows(skipped, "clip_id"))
+	if err != nil {
+		return summary, excluded, fmt.Errorf("failed to export saved_search_matches: %w", err)
+	}
+	f.WriteString("\n")
+
 	// Export settings (excluding sensitive ones)
 	f.WriteString("-- Table: settings\n")
 	_, err = exportTableToSQL(a.db, "settings", f, func(row map[string]interface{}) bool {
@@ -277,6 +365,15 @@ func (a *App) exportDatabaseToSQL(destPath string) (BackupSummary, []string, err
 	}
 	f.WriteString("\n")
 
+	// Export encryption settings. Encrypted clip data is exported as is, so
+	// the wrapped data key is needed to read it after a restore.
+	f.WriteString("-- Table: encryption\n")
+	_, err = exportTableToSQL(a.db, "encryption", f, nil)
+	if err != nil {
+		return summary, excluded, fmt.Errorf("failed to export encryption: %w", err)
+	}
+	f.WriteString("\n")
+
 	// Export watched_folders
 	f.WriteString("-- Table: watched_folders\n")
 	count, err = exportTableToSQL(a.db, "watched_folders", f, nil)
@@ -295,9 +392,28 @@ func (a *App) exportDatabaseToSQL(destPath string) (BackupSummary, []string, err
 	summary.Plugins = count
 	f.WriteString("\n")
 
-	// Export plugin_storage
+	// Export plugin_storage, leaving out password settings that haven't been
+	// moved to plugin_secrets yet. plugin_secrets is never exported: its key
+	// file isn't part of the backup.
 	f.WriteString("-- Table: plugin_storage\n")
-	_, err = exportTableToSQL(a.db, "plugin_storage", f, nil)
+	passwordKeys := make(map[int64]map[string]bool)
+	_, err = exportTableToSQL(a.db, "plugin_storage", f, func(row map[string]interface{}) bool {
+		pluginID, _ := row["plugin_id"].(int64)
+		key, _ := row["key"].(string)
+		if a.pluginManager == nil {
+			return false
+		}
+		keys, ok := passwordKeys[pluginID]
+		if !ok {
+			keys = make(map[string]bool)
+			list, _ := a.pluginManager.PasswordKeys(pluginID)
+			for _, k := range list {
+				keys[k] = true
+			}
+			passwordKeys[pluginID] = keys
+		}
+		return keys[key]
+	})
 	if err != nil {
 		return summary, excluded, fmt.Errorf("failed to export plugin_storage: %w", err)
 	}
@@ -313,6 +429,22 @@ func (a *App) exportDatabaseToSQL(destPath string) (BackupSummary, []string, err
 	return summary, excluded, nil
 }
 
+// auditBackup records a backup or restore run, and its error if it failed
+func (a *App) auditBackup(action, path string, runErr error) {
+	details := map[string]interface{}{"path": path}
+	if runErr != nil {
+		details["error"] = runErr.Error()
+	}
+	if err := a.store.Audit(store.User, action, store.TargetBackup, 0, details); err != nil {
+		log.Printf("Failed to audit %s: %v", action, err)
+	}
+}
+
+// isPluginBackupFile checks if a file in the plugins directory belongs in a backup
+func isPluginBackupFile(name string) bool {
+	return plugin.IsPluginFile(name) || strings.HasSuffix(name, plugin.SignatureExt)
+}
+
 // copyFile copies a file from src to dst
 func copyFile(src, dst string) error {
 	sourceFile, err := os.Open(src)
@@ -425,7 +557,9 @@ func ValidateBackup(backupPath string) (*BackupManifest, error) {
 }
 
 // RestoreBackup restores data from a backup ZIP file
-func (a *App) RestoreBackup(backupPath string) error {
+func (a *App) RestoreBackup(backupPath string) (err error) {
+	defer func() { a.auditBackup(store.AuditBackupRestored, backupPath, err) }()
+
 	// Validate first
 	manifest, err := ValidateBackup(backupPath)
 	if err != nil {
@@ -480,11 +614,20 @@ func (a *App) RestoreBackup(backupPath string) error {
 	// Clear all existing data
 	tables := []string{
 		"clip_tags",
+		"clip_metadata",
+		"clip_revisions", // not backed up, and the clips they belong to are replaced
+		"collection_clips",
+		"saved_search_matches",
 		"clips",
 		"tags",
+		"collections",
+		"saved_searches",
 		"settings",
+		"encryption",
 		"watched_folders",
 		"plugin_storage",
+		"plugin_secrets",     // not backed up, and the plugins they belong to are replaced
+		"plugin_network_log", // not backed up either
 		"plugin_permissions",
 		"plugins",
 	}
@@ -551,6 +694,16 @@ func (a *App) RestoreBackup(backupPath string) error {
 		return fmt.Errorf("failed to commit restore: %w", err)
 	}
 
+	// The restored database may use a different key, or none
+	if err := a.store.LoadEncryption(); err != nil {
+		fmt.Printf("Warning: failed to load encryption settings: %v\n", err)
+	}
+	if policy, err := loadSecretPolicy(a.db); err != nil {
+		fmt.Printf("Warning: failed to load secret policy: %v\n", err)
+	} else {
+		a.store.SetSecretPolicy(policy)
+	}
+
 	// Copy plugin files
 	dataDir, err := getDataDir()
 	if err != nil {
@@ -568,7 +721,7 @@ func (a *App) RestoreBackup(backupPath string) error {
 
 	// Extract plugin files from backup
 	for _, f := range r.File {
-		if strings.HasPrefix(f.Name, "plugins/") && strings.HasSuffix(f.Name, ".lua") {
+		if strings.HasPrefix(f.Name, "plugins/") && isPluginBackupFile(f.Name) {
 			destPath := filepath.Join(dataDir, f.Name)
 			if err := extractZipFile(f, destPath, dataDir); err != nil {
 				fmt.Printf("Warning: failed to extract plugin %s: %v\n", f.Name, err)
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync/atomic"
+
+	"go-clipboard/plugin"
+	"go-clipboard/schema"
+)
+
+const pluginUsage = `Usage: mahpastes plugin test [-v] SPEC...
+
+Runs plugin test specs without starting the app. Each spec runs on a fresh
+in-memory database with frontend events captured, schedules on a fake clock
+and HTTP requests answered from the spec's fixtures.
+`
+
+// testDBCounter names in-memory databases so each spec gets its own
+var testDBCounter atomic.Int64
+
+// runPluginCommand runs `mahpastes plugin ...` and returns the exit code
+func runPluginCommand(args []string) int {
+	if len(args) == 0 || args[0] != "test" {
+		fmt.Fprint(os.Stderr, pluginUsage)
+		return 2
+	}
+
+	flags := flag.NewFlagSet("plugin test", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, pluginUsage) }
+	verbose := flags.Bool("v", false, "show plugin logs")
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+
+	failed := 0
+	for _, path := range flags.Args() {
+		if !runPluginSpec(path) {
+			failed++
+		}
+	}
+	if failed > 0 {
+		fmt.Printf("\n%d of %d specs failed\n", failed, flags.NArg())
+		return 1
+	}
+	fmt.Printf("\n%d specs passed\n", flags.NArg())
+	return 0
+}
+
+// runPluginSpec runs one spec and prints its results, returning whether it passed
+func runPluginSpec(path string) bool {
+	fmt.Println(path)
+	fail := func(err error) bool {
+		fmt.Printf("  FAIL %v\n", err)
+		return false
+	}
+
+	spec, err := plugin.LoadSpec(path)
+	if err != nil {
+		return fail(err)
+	}
+	start, err := spec.StartTime()
+	if err != nil {
+		return fail(err)
+	}
+
+	db, err := openTestDB()
+	if err != nil {
+		return fail(err)
+	}
+	defer db.Close()
+
+	dir, err := os.MkdirTemp("", "mahpastes-plugin-test-")
+	if err != nil {
+		return fail(fmt.Errorf("failed to create temp directory: %w", err))
+	}
+	defer os.RemoveAll(dir)
+
+	h, err := plugin.NewHarness(db, dir, start)
+	if err != nil {
+		return fail(err)
+	}
+	defer h.Close()
+
+	results, err := h.RunSpec(spec)
+	if err != nil {
+		return fail(err)
+	}
+
+	passed := true
+	for _, r := range results {
+		if r.Error != "" {
+			passed = false
+			fmt.Printf("  FAIL %d. %s: %s\n", r.Step, r.Name, r.Error)
+		} else {
+			fmt.Printf("  ok   %d. %s\n", r.Step, r.Name)
+		}
+	}
+	if len(results) < len(spec.Steps) {
+		fmt.Printf("  skipped %d steps after the failure\n", len(spec.Steps)-len(results))
+	}
+	for _, unused := range h.Replay().Unused() {
+		fmt.Printf("  note: fixture for %s %s was not used\n", unused.Request.Method, unused.Request.URL)
+	}
+	return passed
+}
+
+// openTestDB opens an empty in-memory database with the app's schema
+func openTestDB() (*sql.DB, error) {
+	name := fmt.Sprintf("file:plugin-test-%d?mode=memory&cache=shared&_busy_timeout=5000&_foreign_keys=on", testDBCounter.Add(1))
+	db, err := sql.Open("sqlite3", name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open db: %w", err)
+	}
+	if err := schema.Migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
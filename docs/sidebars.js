@@ -24,9 +24,14 @@ const sidebars = {
         'features/tags',
         'features/auto-delete',
         'features/archive',
+        'features/trash',
         'features/watch-folders',
         'features/bulk-actions',
         'features/backup-restore',
+        'features/encryption',
+        'features/secret-detection',
+        'features/sensitive-clips',
+        'features/audit-log',
       ],
     },
     {
@@ -45,6 +50,7 @@ const sidebars = {
             'plugins/writing-plugins/plugin-manifest',
             'plugins/writing-plugins/event-handling',
             'plugins/writing-plugins/settings-storage',
+            'plugins/writing-plugins/testing',
           ],
         },
         'plugins/api-reference',
@@ -216,6 +216,17 @@ export class AppHelper {
     await this.page.locator(selectors.cardMenu.archive).click();
   }
 
+  async toggleSensitive(filename: string): Promise<void> {
+    const clip = await this.getClipByFilename(filename);
+    await clip.hover();
+    // Open the card menu
+    await clip.locator(selectors.clipActions.menuTrigger).click();
+    // Wait for menu to appear
+    await this.page.waitForSelector(selectors.cardMenu.dropdown);
+    // Click mark/unmark sensitive
+    await this.page.locator(selectors.cardMenu.sensitive).click();
+  }
+
   async editClip(filename: string): Promise<void> {
     const clip = await this.getClipByFilename(filename);
     await clip.hover();
@@ -272,6 +283,9 @@ export class AppHelper {
           // Ignore individual delete errors
         }
       }
+      // Deleted clips go to the trash
+      // @ts-ignore
+      await window.go.main.App.EmptyTrash();
     });
     // Refresh the page to update the UI
     await this.page.reload();
@@ -437,6 +451,8 @@ export class AppHelper {
             // Ignore
           }
         }
+        // @ts-ignore
+        await window.go.main.App.EmptyTrash();
       });
     } catch {
       // Ignore errors
@@ -487,11 +503,14 @@ export class AppHelper {
       // Ignore
     }
 
-    // Switch to active view if in archive
+    // Switch to active view if in archive or trash
     try {
       if (await this.isArchiveViewActive()) {
         await this.toggleArchiveView();
       }
+      if (await this.isTrashViewActive()) {
+        await this.toggleTrashView();
+      }
     } catch {
       // Ignore
     }
@@ -566,7 +585,7 @@ export class AppHelper {
       // All modals use opacity-0/pointer-events-none when closed
       const modalIds = [
         'confirm-dialog', 'restore-confirm-dialog', 'folder-modal',
-        'settings-modal', 'plugin-options-modal',
+        'settings-modal', 'plugin-options-modal', 'unlock-dialog',
       ];
       for (const id of modalIds) {
         const el = document.getElementById(id);
@@ -1039,6 +1058,26 @@ export class AppHelper {
     return pressed === 'true';
   }
 
+  async toggleTrashView(): Promise<void> {
+    await this.page.locator(selectors.header.trashButton).click();
+    // Wait for gallery to re-render after view toggle
+    await this.page.waitForFunction(() => (window as any).__appReady === true, { timeout: 5000 });
+  }
+
+  async isTrashViewActive(): Promise<boolean> {
+    const btn = this.page.locator(selectors.header.trashButton);
+    const pressed = await btn.getAttribute('aria-pressed');
+    return pressed === 'true';
+  }
+
+  // Permanently deletes every clip in the trash
+  async emptyTrash(): Promise<void> {
+    await this.page.evaluate(async () => {
+      // @ts-ignore - Wails runtime
+      await window.go.main.App.EmptyTrash();
+    });
+  }
+
   // ==================== Dialogs & Toasts ====================
 
   async confirmDialog(): Promise<void> {
@@ -1511,6 +1550,13 @@ export class AppHelper {
     }, pluginPath);
   }
 
+  async getAuditLog(filter: Record<string, unknown> = {}): Promise<any[]> {
+    return this.page.evaluate(async (f) => {
+      // @ts-ignore - Wails runtime
+      return await window.go.main.App.GetAuditLog(f) || [];
+    }, filter);
+  }
+
   async getPluginStorage(pluginId: number, key: string): Promise<string> {
     return this.page.evaluate(async ({ id, k }) => {
       // @ts-ignore - Wails runtime
@@ -1594,6 +1640,13 @@ export class AppHelper {
     }, pluginId);
   }
 
+  async getPluginNetworkLog(pluginId: number): Promise<Array<{ method: string; host: string; status: number; error?: string }>> {
+    return this.page.evaluate(async (id) => {
+      // @ts-ignore - Wails runtime
+      return await window.go.main.PluginService.GetPluginNetworkLog(id, 0);
+    }, pluginId);
+  }
+
   async deleteAllPlugins(): Promise<void> {
     await this.page.evaluate(async () => {
       // @ts-ignore - Wails runtime
@@ -1853,6 +1906,33 @@ export class AppHelper {
     await this.page.waitForSelector(`${selectors.settings.modal}.opacity-0`, { timeout: 5000 });
   }
 
+  // ==================== Encryption ====================
+
+  async enableEncryption(passphrase: string): Promise<void> {
+    await this.openSettingsModal();
+    await this.page.locator(selectors.encryption.newPassphrase).fill(passphrase);
+    await this.page.locator(selectors.encryption.enableButton).click();
+    await expect(this.page.locator(selectors.encryption.changeButton)).toBeVisible({ timeout: 10000 });
+  }
+
+  // Decrypts the clips if they are encrypted, so later tests start unencrypted
+  async disableEncryptionViaAPI(passphrase: string): Promise<void> {
+    await this.page.evaluate(async (passphrase) => {
+      // @ts-ignore - Wails runtime
+      const App = window.go.main.App;
+      const status = await App.GetEncryptionStatus();
+      if (status.enabled) {
+        await App.DisableEncryption({ passphrase, key_file: '' });
+      }
+    }, passphrase);
+  }
+
+  async unlock(passphrase: string): Promise<void> {
+    await this.page.waitForSelector(`${selectors.encryption.unlockDialog}.opacity-100`, { timeout: 5000 });
+    await this.page.locator(selectors.encryption.unlockPassphrase).fill(passphrase);
+    await this.page.locator(selectors.encryption.unlockButton).click();
+  }
+
   async createBackupViaAPI(): Promise<string> {
     // Create backup programmatically and return the path
     const tempDir = await this.page.evaluate(() => {
@@ -7,6 +7,8 @@ Plugin = {
   description = "E2E test plugin for UI extensions",
   author = "mahpastes",
 
+  scopes = {"clips.read", "clips.write"},
+
   settings = {
     {key = "prefix", type = "text", label = "Output prefix", default = "processed"},
   },
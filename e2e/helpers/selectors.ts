@@ -10,6 +10,8 @@ export const selectors = {
     watchButton: '#toggle-watch-view-btn',
     watchIndicator: '#watch-indicator',
     archiveButton: '#toggle-archive-view-btn',
+    trashButton: '#toggle-trash-view-btn',
+    emptyTrashButton: '#empty-trash-btn',
     clearAllButton: '#delete-all-temp-btn',
     settingsButton: '#open-settings-btn',
   },
@@ -35,6 +37,8 @@ export const selectors = {
     clipType: '#gallery > li span',
     expirationBadge: '.absolute.top-2.left-2',
     emptyState: '#empty-state',
+    secretBadge: '.secret-badge',
+    sensitiveMask: '.sensitive-mask',
   },
 
   // Clip card actions (now in dropdown menu)
@@ -52,6 +56,9 @@ export const selectors = {
     tags: '.card-menu-dropdown [data-action="tags"]',
     archive: '.card-menu-dropdown [data-action="archive"]',
     delete: '.card-menu-dropdown [data-action="delete"]',
+    restore: '.card-menu-dropdown [data-action="restore"]',
+    purge: '.card-menu-dropdown [data-action="purge"]',
+    sensitive: '.card-menu-dropdown [data-action="sensitive"]',
     pluginAction: '.card-menu-dropdown [data-action="plugin"]',
     divider: '.card-menu-dropdown .card-menu-divider',
   },
@@ -247,6 +254,21 @@ export const selectors = {
     restoreConfirmYes: '#restore-confirm-yes',
     restoreBackupInfo: '#restore-backup-info',
   },
+
+  // Encryption
+  encryption: {
+    status: '[data-testid="encryption-status"]',
+    currentPassphrase: '#encryption-current-passphrase',
+    newPassphrase: '#encryption-new-passphrase',
+    enableButton: '#encryption-enable-btn',
+    changeButton: '#encryption-change-btn',
+    lockButton: '#encryption-lock-btn',
+    disableButton: '#encryption-disable-btn',
+    unlockDialog: '#unlock-dialog',
+    unlockPassphrase: '#unlock-passphrase',
+    unlockButton: '#unlock-btn',
+    unlockError: '#unlock-error',
+  },
 } as const;
 
 export type Selectors = typeof selectors;
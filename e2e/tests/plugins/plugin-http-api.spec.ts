@@ -71,6 +71,25 @@ test.describe('Plugin HTTP API', () => {
     expect(domainError).toContain('domain not in allowlist');
   });
 
+  test('should approve declared domains and log refused requests', async ({ app }) => {
+    const pluginPath = path.join(TEST_PLUGINS_DIR, 'http-test.lua');
+
+    const plugin = await app.importPluginFromPath(pluginPath);
+    expect(plugin).not.toBeNull();
+    httpPluginId = plugin?.id ?? null;
+
+    const approved = (await app.getPluginPermissions(plugin!.id)).filter(perm => perm.type === 'network');
+    expect(approved.map(perm => perm.path)).toEqual(['httpbin.org']);
+
+    await app.waitForPluginStorage(plugin!.id, 'http_test_initialized', 'true');
+    const imagePath = await createTempFile(generateTestImage(50, 50), 'png');
+    await app.uploadFile(imagePath);
+    await app.waitForPluginStorageContains(plugin!.id, 'unauthorized_domain_error', 'domain not in allowlist');
+
+    const entries = await app.getPluginNetworkLog(plugin!.id);
+    expect(entries.some(entry => entry.error?.includes('domain not in allowlist'))).toBe(true);
+  });
+
   test('should initialize with correct network permissions from manifest', async ({ app }) => {
     const pluginPath = path.join(TEST_PLUGINS_DIR, 'http-test.lua');
 
@@ -168,8 +168,9 @@ test.describe('Tag Plugin API', () => {
       await app.createTag('clip-delete-tag');
       await app.addTagToClip(filename, 'clip-delete-tag');
 
-      // Delete the clip
+      // Delete the clip and empty the trash
       await app.deleteClip(filename);
+      await app.emptyTrash();
 
       // Tag should be auto-deleted (orphaned)
       const tags = await app.getAllTags();
@@ -48,6 +48,14 @@ test.describe('Plugin UI Extensions', () => {
       expect(actions.lightbox_buttons).toHaveLength(0);
       expect(actions.card_actions).toHaveLength(0);
     });
+
+    test('should grant the declared scopes on import', async ({ app }) => {
+      const plugin = await app.importPluginFromPath(TEST_PLUGIN_PATH);
+      expect(plugin).not.toBeNull();
+
+      const granted = (await app.getPluginPermissions(plugin!.id)).map(perm => perm.type).sort();
+      expect(granted).toEqual(['clips.read', 'clips.write']);
+    });
   });
 
   test.describe('Card Menu', () => {
@@ -127,10 +127,12 @@ test.describe('Tag Clips', () => {
       // Verify tag exists
       await app.expectTagCount(1);
 
-      // Delete the clip
+      // Delete the clip - the trashed clip keeps its tag
       await app.deleteClip(filename);
+      await app.expectTagCount(1);
 
-      // Tag should be auto-deleted since no clips have it
+      // Tag should be auto-deleted once no clips have it
+      await app.emptyTrash();
       await app.expectTagCount(0);
     });
 
@@ -154,8 +156,10 @@ test.describe('Tag Clips', () => {
       await app.selectClip(filename2);
       await app.bulkDelete();
       await app.confirmDialog();
+      await app.expectTagCount(1);
 
-      // Tag should be auto-deleted since no clips remain
+      // Tag should be auto-deleted once no clips remain
+      await app.emptyTrash();
       await app.expectTagCount(0);
     });
 
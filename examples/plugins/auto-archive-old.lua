@@ -13,6 +13,8 @@ Plugin = {
         write = false,
     },
 
+    scopes = {"clips.read", "clips.write"},
+
     events = {"app:startup"},
 
     -- Run every hour
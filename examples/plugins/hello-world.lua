@@ -16,6 +16,9 @@ Plugin = {
         write = false,
     },
 
+    -- Lists clips on startup
+    scopes = {"clips.read"},
+
     -- Subscribe to clip events
     events = {"app:startup", "app:shutdown", "clip:created", "clip:deleted"},
 
@@ -16,6 +16,9 @@ Plugin = {
         write = false,
     },
 
+    -- Creates, renames and deletes its test tags
+    scopes = {"tags.write"},
+
     -- Subscribe to all tag events
     events = {
         "app:startup",
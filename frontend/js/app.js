@@ -6,6 +6,9 @@ const gallery = document.getElementById('gallery');
 const deleteAllTempBtn = document.getElementById('delete-all-temp-btn');
 const toggleArchiveViewBtn = document.getElementById('toggle-archive-view-btn');
 const archiveBtnText = document.getElementById('archive-btn-text');
+const toggleTrashViewBtn = document.getElementById('toggle-trash-view-btn');
+const trashBtnText = document.getElementById('trash-btn-text');
+const emptyTrashBtn = document.getElementById('empty-trash-btn');
 const uploadSection = document.getElementById('upload-section');
 const expirationSelect = document.getElementById('expiration-select');
 const bulkToolbar = document.getElementById('bulk-toolbar');
@@ -48,6 +51,7 @@ const zoomFitBtn = document.getElementById('zoom-fit');
 
 // --- State ---
 let isViewingArchive = false;
+let isViewingTrash = false;
 let selectedIds = new Set();
 let imageClips = []; // Store image clips for lightbox navigation
 let currentLightboxIndex = -1;
@@ -62,6 +66,8 @@ let lastFocusedElement = null; // For confirm dialog
 // Tag state
 let allTags = [];
 let activeTagFilters = [];
+let activeTagQuery = '';
+let activeSavedSearchId = null; // shows a saved search instead of the filters above
 
 // App ready flag for testing
 window.__appReady = false;
@@ -80,6 +86,7 @@ Object.assign(window.__testHelpers, {
   },
   getActiveTagFilters: () => activeTagFilters,
   setViewingArchive: (val) => { isViewingArchive = val; },
+  setViewingTrash: (val) => { isViewingTrash = val; },
   // Expose loadClips function (defined in wails-api.js, but called here)
   loadClips: () => {
     if (typeof loadClips === 'function') {
@@ -128,7 +135,7 @@ document.addEventListener('paste', e => {
     }
 
     if (e.clipboardData.files.length > 0) {
-        handleFiles(e.clipboardData.files);
+        handleFiles(e.clipboardData.files, 'paste');
     } else {
         const text = e.clipboardData.getData('text/plain');
         if (text) {
@@ -140,6 +147,10 @@ document.addEventListener('paste', e => {
 // Toggle Archive View
 toggleArchiveViewBtn.addEventListener('click', toggleViewMode);
 
+// Toggle Trash View
+toggleTrashViewBtn.addEventListener('click', toggleTrashView);
+emptyTrashBtn.addEventListener('click', emptyTrash);
+
 // Confirm Dialog Listeners
 document.getElementById('confirm-yes-btn').addEventListener('click', () => {
     if (confirmCallback) confirmCallback();
@@ -243,8 +254,8 @@ setupConfirmDialogFocusTrap();
 
 // --- Upload Handlers ---
 
-async function handleFiles(files) {
-    if (isViewingArchive) {
+async function handleFiles(files, source = 'file') {
+    if (isViewingArchive || isViewingTrash) {
         showToast('Switch to Active view to upload.');
         return;
     }
@@ -252,7 +263,7 @@ async function handleFiles(files) {
 
     const fileDataArray = [];
     for (let i = 0; i < files.length; i++) {
-        const fileData = await fileToFileData(files[i]);
+        const fileData = await fileToFileData(files[i], source);
         fileDataArray.push(fileData);
     }
 
@@ -261,7 +272,7 @@ async function handleFiles(files) {
 }
 
 async function handleText(text) {
-    if (isViewingArchive) {
+    if (isViewingArchive || isViewingTrash) {
         showToast('Switch to Active view to upload.');
         return;
     }
@@ -271,7 +282,8 @@ async function handleText(text) {
     const fileData = {
         name: 'pasted_text.txt',
         content_type: 'text/plain',
-        data: base64
+        data: base64,
+        source: 'paste'
     };
 
     const expiration = parseInt(expirationSelect.value) || 0;
@@ -286,6 +298,7 @@ window.addEventListener('load', async () => {
         await loadTags();
         await loadClips();
         setupEditorListeners();
+        await checkDatabaseLocked();
     } catch (error) {
         console.error('Error during app initialization:', error);
     }
@@ -298,6 +311,18 @@ window.addEventListener('load', async () => {
                 showToast(data.message, data.type || 'info');
             }
         });
+
+        // Refresh when plugins or the folder watcher change clips or tags
+        let clipsChangedTimer = null;
+        window.runtime.EventsOn("clips:changed", () => {
+            clearTimeout(clipsChangedTimer);
+            clipsChangedTimer = setTimeout(() => {
+                loadTags();
+                if (!isViewingWatch) {
+                    loadClips();
+                }
+            }, 150);
+        });
     }
 });
 
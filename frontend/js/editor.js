@@ -574,9 +574,11 @@ function updateUndoRedoButtons() {
     }
 }
 
-// --- Save As ---
+// --- Save ---
 
-async function saveEditorContent() {
+// Saves the edit as a new clip linked to the original, or replaces the
+// original when overwrite is set (its previous version stays in its history)
+async function saveEditorContent(overwrite = false) {
     const filename = document.getElementById('editor-filename').value.trim();
     if (!filename) {
         showToast('Please enter a filename.');
@@ -605,9 +607,9 @@ async function saveEditorContent() {
     };
 
     try {
-        await upload([fileData], 0); // Never expire
+        await window.go.main.App.SaveEditedClip(editorClipId, fileData, overwrite);
 
-        showToast('Saved as new clip!');
+        showToast(overwrite ? 'Clip replaced.' : 'Saved as new clip!');
         closeEditor();
         loadClips(); // Refresh gallery
 
@@ -624,7 +626,8 @@ function setupEditorListeners() {
     document.getElementById('editor-close').addEventListener('click', closeEditor);
 
     // Save button
-    document.getElementById('editor-save').addEventListener('click', saveEditorContent);
+    document.getElementById('editor-save').addEventListener('click', () => saveEditorContent(false));
+    document.getElementById('editor-replace').addEventListener('click', () => saveEditorContent(true));
 
     // Tool buttons
     document.querySelectorAll('.editor-tool-btn').forEach(btn => {
@@ -8,6 +8,17 @@ const importPluginBtn = document.getElementById('import-plugin-btn');
 const pluginsList = document.getElementById('plugins-list');
 const pluginsEmptyState = document.getElementById('plugins-empty-state');
 
+// Returned in place of saved password settings, which stay in the backend
+const SECRET_PLACEHOLDER = '********';
+
+// What each clip and tag scope lets a plugin do
+const SCOPE_LABELS = {
+    'clips.read': 'Read clips',
+    'clips.write': 'Create and change clips',
+    'clips.delete': 'Delete clips',
+    'tags.write': 'Create, change and delete tags',
+};
+
 // State
 let pluginsCache = [];
 let expandedPluginId = null;
@@ -70,6 +81,11 @@ function createPluginCard(plugin) {
         : (plugin.enabled ? 'bg-emerald-500' : 'bg-stone-300');
     const statusTitle = plugin.enabled ? (plugin.status === 'error' ? 'Error' : 'Enabled') : 'Disabled';
 
+    // Scopes and domains the plugin declares but the user hasn't granted,
+    // such as ones added by an upgrade
+    const pendingAccess = (plugin.pending_scopes || []).map(scope => SCOPE_LABELS[scope] || scope)
+        .concat((plugin.pending_domains || []).map(domain => 'connect to ' + domain));
+
     li.innerHTML = `
         <div class="p-4 cursor-pointer" data-action="toggle-expand">
             <div class="flex items-center justify-between">
@@ -80,6 +96,7 @@ function createPluginCard(plugin) {
                         <div class="flex items-center gap-2">
                             <h3 class="text-sm font-medium text-stone-700 truncate">${escapeHTML(plugin.name)}</h3>
                             <span class="text-[10px] text-stone-400 font-mono">v${escapeHTML(plugin.version || '0.0.0')}</span>
+                            ${plugin.signature ? `<span class="text-[10px] ${plugin.signature.startsWith('signed') ? 'text-emerald-600' : 'text-stone-400'}" data-testid="plugin-signature-${plugin.id}">${escapeHTML(plugin.signature)}</span>` : ''}
                         </div>
                         ${plugin.author ? `<p class="text-[11px] text-stone-400 truncate">by ${escapeHTML(plugin.author)}</p>` : ''}
                     </div>
@@ -117,6 +134,12 @@ function createPluginCard(plugin) {
                 </div>
                 ` : ''}
 
+                ${pendingAccess.length > 0 ? `
+                <div class="p-2 bg-amber-50 rounded text-amber-700 text-[11px]" data-testid="plugin-pending-${plugin.id}">
+                    Asks for access you haven't granted: ${escapeHTML(pendingAccess.join(', '))}. Grant it under Permissions.
+                </div>
+                ` : ''}
+
                 <!-- Settings Section (loaded dynamically) -->
                 <div data-settings-placeholder data-plugin-id="${plugin.id}"></div>
 
@@ -231,7 +254,8 @@ function renderSettingField(field, currentValue, pluginId) {
                     <div class="relative">
                         <input type="password"
                                class="block w-full border border-stone-200 rounded-md text-xs bg-white px-2 py-1.5 pr-8 placeholder-stone-400 focus:outline-none focus:border-stone-400 focus:ring-1 focus:ring-stone-400/20 transition-colors"
-                               value="${escapeHTML(currentValue || '')}"
+                               value=""
+                               placeholder="${currentValue === SECRET_PLACEHOLDER ? 'Saved (type to replace)' : ''}"
                                data-plugin-id="${pluginId}"
                                data-setting-key="${escapeHTML(field.key)}"
                                data-setting-type="password">
@@ -316,14 +340,62 @@ async function loadPluginPermissions(pluginId, cardElement) {
     if (!container) return;
 
     try {
-        const permissions = await window.go.main.PluginService.GetPluginPermissions(pluginId);
+        const allPermissions = await window.go.main.PluginService.GetPluginPermissions(pluginId) || [];
+        const permissions = allPermissions.filter(perm =>
+            perm.type !== 'sensitive_clips' && perm.type !== 'network' && !SCOPE_LABELS[perm.type]);
+
+        // Access to sensitive clips is a toggle for plugins that declare it
+        const plugin = pluginsCache.find(p => p.id === pluginId);
+        const sensitiveGrant = allPermissions.find(perm => perm.type === 'sensitive_clips');
+        const sensitiveHTML = plugin && plugin.sensitive_clips ? `
+            <label class="flex items-center gap-2 mb-2 text-stone-600 cursor-pointer">
+                <input type="checkbox" data-action="sensitive-access" data-testid="sensitive-access-${pluginId}"
+                       ${sensitiveGrant && sensitiveGrant.pending_reconfirm !== 'true' ? 'checked' : ''}>
+                <span>Allow access to sensitive clips</span>
+            </label>
+        ` : '';
+
+        // Declared clip and tag scopes can be revoked and granted again
+        const scopesHTML = (plugin && plugin.scopes || []).map(scope => {
+            const grant = allPermissions.find(perm => perm.type === scope);
+            return `
+                <label class="flex items-center gap-2 mb-2 text-stone-600 cursor-pointer">
+                    <input type="checkbox" data-action="scope-access" data-scope="${escapeHTML(scope)}" data-testid="scope-${pluginId}-${escapeHTML(scope)}"
+                           ${grant && grant.pending_reconfirm !== 'true' ? 'checked' : ''}>
+                    <span>${escapeHTML(SCOPE_LABELS[scope] || scope)}${escapeHTML(describeScopeLimits(plugin.scope_limits))}</span>
+                </label>
+            `;
+        }).join('');
 
-        if (!permissions || permissions.length === 0) {
-            container.innerHTML = '<span class="text-stone-400">No filesystem permissions granted</span>';
+        // Declared network domains are approved one by one
+        const domains = Object.keys(plugin && plugin.network || {}).sort();
+        const domainsHTML = domains.map(domain => {
+            const grant = allPermissions.find(perm => perm.type === 'network' && perm.path === domain);
+            return `
+                <label class="flex items-center gap-2 mb-2 text-stone-600 cursor-pointer">
+                    <input type="checkbox" data-action="domain-access" data-domain="${escapeHTML(domain)}" data-testid="domain-${pluginId}-${escapeHTML(domain)}"
+                           ${grant && grant.pending_reconfirm !== 'true' ? 'checked' : ''}>
+                    <span>Connect to <span class="font-mono text-[10px]">${escapeHTML(domain)}</span> (${escapeHTML(plugin.network[domain].join(', '))})</span>
+                </label>
+            `;
+        }).join('') + (domains.length > 0 ? `
+            <button data-action="show-network-log" data-testid="network-log-btn-${pluginId}"
+                    class="text-[10px] text-stone-500 hover:text-stone-700 hover:bg-stone-100 px-2 py-1 mb-2 rounded transition-colors">
+                Show recent requests
+            </button>
+            <div data-network-log class="hidden mb-2"></div>
+        ` : '');
+        const grantsHTML = scopesHTML + domainsHTML + sensitiveHTML;
+
+        if (permissions.length === 0) {
+            container.innerHTML = grantsHTML + '<span class="text-stone-400">No filesystem permissions granted</span>';
+            setupSensitiveAccessToggle(pluginId, container);
+            setupScopeToggles(pluginId, container);
+            setupDomainToggles(pluginId, container);
             return;
         }
 
-        container.innerHTML = `
+        container.innerHTML = grantsHTML + `
             <div class="space-y-1.5">
                 ${permissions.map(perm => `
                     <div class="flex items-center justify-between gap-2 p-2 bg-white rounded border border-stone-200">
@@ -354,12 +426,112 @@ async function loadPluginPermissions(pluginId, cardElement) {
                 await revokePermission(pluginId, type, path);
             });
         });
+        setupSensitiveAccessToggle(pluginId, container);
+        setupScopeToggles(pluginId, container);
+        setupDomainToggles(pluginId, container);
     } catch (error) {
         console.error('Failed to load permissions:', error);
         container.innerHTML = '<span class="text-red-500">Failed to load permissions</span>';
     }
 }
 
+function setupSensitiveAccessToggle(pluginId, container) {
+    const toggle = container.querySelector('[data-action="sensitive-access"]');
+    if (!toggle) return;
+
+    toggle.addEventListener('change', async () => {
+        try {
+            await window.go.main.PluginService.SetPluginSensitiveClipsAccess(pluginId, toggle.checked);
+            showToast(toggle.checked ? 'Access to sensitive clips granted' : 'Access to sensitive clips revoked');
+        } catch (error) {
+            console.error('Failed to update sensitive clip access:', error);
+            showToast('Failed to update permission');
+            toggle.checked = !toggle.checked;
+        }
+    });
+}
+
+function setupScopeToggles(pluginId, container) {
+    container.querySelectorAll('[data-action="scope-access"]').forEach(toggle => {
+        toggle.addEventListener('change', async () => {
+            const scope = toggle.dataset.scope;
+            try {
+                await window.go.main.PluginService.SetPluginScopeGranted(pluginId, scope, toggle.checked);
+                showToast(toggle.checked ? `Granted ${scope}` : `Revoked ${scope}`);
+                await loadPlugins(); // refresh the access the plugin is waiting for
+            } catch (error) {
+                console.error('Failed to update scope:', error);
+                showToast('Failed to update permission');
+                toggle.checked = !toggle.checked;
+            }
+        });
+    });
+}
+
+function setupDomainToggles(pluginId, container) {
+    container.querySelectorAll('[data-action="domain-access"]').forEach(toggle => {
+        toggle.addEventListener('change', async () => {
+            const domain = toggle.dataset.domain;
+            try {
+                await window.go.main.PluginService.SetPluginDomainApproved(pluginId, domain, toggle.checked);
+                showToast(toggle.checked ? `Approved ${domain}` : `Revoked ${domain}`);
+                await loadPlugins(); // refresh the access the plugin is waiting for
+            } catch (error) {
+                console.error('Failed to update domain:', error);
+                showToast('Failed to update permission');
+                toggle.checked = !toggle.checked;
+            }
+        });
+    });
+
+    const logBtn = container.querySelector('[data-action="show-network-log"]');
+    if (logBtn) {
+        logBtn.addEventListener('click', () => loadNetworkLog(pluginId, container.querySelector('[data-network-log]')));
+    }
+}
+
+// --- Network Log ---
+async function loadNetworkLog(pluginId, logEl) {
+    try {
+        const entries = await window.go.main.PluginService.GetPluginNetworkLog(pluginId, 50) || [];
+        logEl.classList.remove('hidden');
+        if (entries.length === 0) {
+            logEl.innerHTML = '<span class="text-stone-400">No requests yet</span>';
+            return;
+        }
+        logEl.innerHTML = `
+            <div class="space-y-1 max-h-40 overflow-y-auto" data-testid="network-log-${pluginId}">
+                ${entries.map(entry => `
+                    <div class="flex items-center gap-2 text-[10px] font-mono ${entry.error ? 'text-red-500' : 'text-stone-500'}"
+                         title="${escapeHTML(entry.error || '')}">
+                        <span class="flex-shrink-0">${escapeHTML(new Date(entry.created_at).toLocaleTimeString())}</span>
+                        <span class="flex-shrink-0">${escapeHTML(entry.method)}</span>
+                        <span class="truncate flex-1">${escapeHTML(entry.host)}</span>
+                        <span class="flex-shrink-0">${entry.error ? 'refused' : entry.status}</span>
+                        <span class="flex-shrink-0">${entry.bytes_out} B out, ${entry.bytes_in} B in</span>
+                    </div>
+                `).join('')}
+            </div>
+        `;
+    } catch (error) {
+        console.error('Failed to load network log:', error);
+        showToast('Failed to load network log');
+    }
+}
+
+// describeScopeLimits summarizes the clips a plugin's scopes are limited to
+function describeScopeLimits(limits) {
+    if (!limits) return '';
+    const parts = [];
+    if (limits.content_types && limits.content_types.length > 0) {
+        parts.push(limits.content_types.join(', '));
+    }
+    if (limits.tags && limits.tags.length > 0) {
+        parts.push('tagged ' + limits.tags.join(', '));
+    }
+    return parts.length > 0 ? ` (${parts.join('; ')})` : '';
+}
+
 // --- Load Plugin Settings ---
 async function loadPluginSettings(pluginId, cardElement) {
     const placeholder = cardElement.querySelector('[data-settings-placeholder]');
@@ -472,15 +644,46 @@ async function togglePluginEnabled(pluginId, enabled) {
 
 // --- Import Plugin ---
 async function importPlugin() {
+    let preview;
     try {
-        const result = await window.go.main.PluginService.ImportPlugin();
-        if (result) {
-            showToast(`Imported: ${result.name}`);
-            await loadPlugins();
-            await loadPluginUIActions();
-            loadClips();
-        }
-        // null means user cancelled, no error
+        preview = await window.go.main.PluginService.ShowImportPluginDialog();
+    } catch (error) {
+        console.error('Failed to import plugin:', error);
+        showToast('Failed to import plugin: ' + (error.message || 'Unknown error'));
+        return;
+    }
+    if (!preview) return; // User cancelled
+
+    // The user agrees to the scopes and network domains a plugin asks for
+    // before it is imported. Domains can be revoked one by one afterwards.
+    const domains = Object.keys(preview.network || {}).sort();
+    if (preview.scopes.length === 0 && domains.length === 0) {
+        await confirmImportPlugin(preview.path, domains);
+        return;
+    }
+    const asks = [];
+    if (preview.scopes.length > 0) {
+        const scopes = preview.scopes.map(scope => SCOPE_LABELS[scope] || scope).join(', ');
+        asks.push(scopes + describeScopeLimits(preview.scope_limits));
+    }
+    if (domains.length > 0) {
+        asks.push('connect to ' + domains.join(', '));
+    }
+    showConfirmDialog(
+        'Import Plugin',
+        `"${preview.name}" asks to: ${asks.join('; ')}.`,
+        () => confirmImportPlugin(preview.path, domains),
+        'Allow',
+    );
+}
+
+async function confirmImportPlugin(path, domains) {
+    try {
+        const result = await window.go.main.PluginService.ConfirmImportPlugin(path, domains);
+        showToast(`Imported: ${result.name}`);
+        await loadPlugins();
+        await loadPluginUIActions();
+        loadClips();
     } catch (error) {
         console.error('Failed to import plugin:', error);
         showToast('Failed to import plugin: ' + (error.message || 'Unknown error'));
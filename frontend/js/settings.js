@@ -7,6 +7,11 @@ const settingsCloseBtn = document.getElementById('settings-close');
 const settingsSaveBtn = document.getElementById('settings-save');
 
 function openSettings() {
+    loadRetentionPolicy();
+    loadTrashRetention();
+    loadSecretPolicy();
+    loadSensitiveExpiration();
+    loadEncryptionStatus();
     settingsModal.classList.remove('opacity-0', 'pointer-events-none');
     settingsModal.classList.add('opacity-100');
     settingsModal.querySelector(':scope > div').classList.remove('scale-95');
@@ -22,11 +27,16 @@ function closeSettings() {
 
 async function saveSettings() {
     try {
+        await window.go.main.App.SetRetentionPolicy(readRetentionPolicy());
+        await window.go.main.App.SetTrashRetentionDays(numberOrZero(trashRetentionDays));
+        await window.go.main.App.SetSecretPolicy(readSecretPolicy());
+        await window.go.main.App.SetSensitiveExpireMinutes(numberOrZero(sensitiveExpireMinutes));
+        await window.go.main.PluginService.SetBlockSensitiveUploads(blockSensitiveUploads.checked);
         showToast('Settings saved');
         closeSettings();
     } catch (error) {
         console.error('Failed to save settings:', error);
-        showToast('Failed to save settings');
+        showToast('Failed to save settings: ' + (error.message || error));
     }
 }
 
@@ -38,6 +48,127 @@ settingsModal.addEventListener('click', (e) => {
     if (e.target === settingsModal) closeSettings();
 });
 
+// --- Retention ---
+
+const retentionMaxAge = document.getElementById('retention-max-age');
+const retentionUntaggedMaxAge = document.getElementById('retention-untagged-max-age');
+const retentionMaxClips = document.getElementById('retention-max-clips');
+const retentionMaxMB = document.getElementById('retention-max-mb');
+const retentionKeepArchived = document.getElementById('retention-keep-archived');
+const retentionTagRules = document.getElementById('retention-tag-rules');
+const retentionAddRuleBtn = document.getElementById('retention-add-rule');
+const retentionPreviewBtn = document.getElementById('retention-preview-btn');
+const retentionPreview = document.getElementById('retention-preview');
+
+const BYTES_PER_MB = 1024 * 1024;
+
+const RETENTION_REASONS = {
+    max_age: 'too old',
+    untagged_max_age: 'untagged',
+    tag_max_age: 'tag rule',
+    max_clips: 'over clip limit',
+    max_bytes: 'over size limit',
+};
+
+function formatMB(bytes) {
+    return (bytes / BYTES_PER_MB).toFixed(1) + ' MB';
+}
+
+// Number inputs are empty when a rule is off
+function numberOrZero(input) {
+    const value = parseInt(input.value, 10);
+    return value > 0 ? value : 0;
+}
+
+function numberOrEmpty(value) {
+    return value > 0 ? String(value) : '';
+}
+
+function addTagRuleRow(rule = { tag: '', max_age_days: 0, keep: false }) {
+    const row = document.createElement('div');
+    row.className = 'retention-tag-rule flex items-center gap-2 text-xs text-stone-600';
+    row.innerHTML = `
+        <input type="text" class="rule-tag flex-1 min-w-0 text-xs border border-stone-200 rounded px-2 py-1 focus:outline-none focus:border-stone-400"
+            placeholder="tag" maxlength="${MAX_TAG_NAME_LENGTH}" value="${escapeHTML(rule.tag)}">
+        <input type="number" min="0" class="rule-days w-16 text-xs border border-stone-200 rounded px-2 py-1 focus:outline-none focus:border-stone-400"
+            placeholder="days" value="${numberOrEmpty(rule.max_age_days)}" ${rule.keep ? 'disabled' : ''}>
+        <label class="flex items-center gap-1 cursor-pointer">
+            <input type="checkbox" class="rule-keep" ${rule.keep ? 'checked' : ''}>
+            <span>Keep</span>
+        </label>
+        <button class="rule-remove p-1 text-stone-400 hover:text-red-600 transition-colors" title="Remove rule">
+            <svg class="w-3.5 h-3.5" fill="none" stroke="currentColor" viewBox="0 0 24 24">
+                <path stroke-linecap="round" stroke-linejoin="round" stroke-width="1.5" d="M6 18L18 6M6 6l12 12"></path>
+            </svg>
+        </button>
+    `;
+    const days = row.querySelector('.rule-days');
+    row.querySelector('.rule-keep').addEventListener('change', (e) => {
+        days.disabled = e.target.checked;
+    });
+    row.querySelector('.rule-remove').addEventListener('click', () => row.remove());
+    retentionTagRules.appendChild(row);
+}
+
+function readRetentionPolicy() {
+    const tagRules = [];
+    retentionTagRules.querySelectorAll('.retention-tag-rule').forEach(row => {
+        const tag = row.querySelector('.rule-tag').value.trim();
+        if (!tag) return;
+        const keep = row.querySelector('.rule-keep').checked;
+        tagRules.push({
+            tag,
+            keep,
+            max_age_days: keep ? 0 : numberOrZero(row.querySelector('.rule-days')),
+        });
+    });
+
+    return {
+        max_age_days: numberOrZero(retentionMaxAge),
+        untagged_max_age_days: numberOrZero(retentionUntaggedMaxAge),
+        max_clips: numberOrZero(retentionMaxClips),
+        max_bytes: numberOrZero(retentionMaxMB) * BYTES_PER_MB,
+        keep_archived: retentionKeepArchived.checked,
+        tag_rules: tagRules,
+    };
+}
+
+async function loadRetentionPolicy() {
+    retentionPreview.textContent = '';
+    try {
+        const policy = await window.go.main.App.GetRetentionPolicy();
+        retentionMaxAge.value = numberOrEmpty(policy.max_age_days);
+        retentionUntaggedMaxAge.value = numberOrEmpty(policy.untagged_max_age_days);
+        retentionMaxClips.value = numberOrEmpty(policy.max_clips);
+        retentionMaxMB.value = numberOrEmpty(Math.round(policy.max_bytes / BYTES_PER_MB));
+        retentionKeepArchived.checked = policy.keep_archived;
+        retentionTagRules.innerHTML = '';
+        (policy.tag_rules || []).forEach(rule => addTagRuleRow(rule));
+    } catch (error) {
+        console.error('Failed to load retention policy:', error);
+    }
+}
+
+async function previewRetention() {
+    try {
+        const preview = await window.go.main.App.PreviewRetention(readRetentionPolicy());
+        if (preview.count === 0) {
+            retentionPreview.textContent = 'No clips would be deleted';
+            return;
+        }
+        const reasons = Object.entries(preview.by_reason)
+            .map(([reason, count]) => `${count} ${RETENTION_REASONS[reason] || reason}`)
+            .join(', ');
+        retentionPreview.textContent =
+            `${preview.count} clips (${formatMB(preview.freed_bytes)}) would be deleted: ${reasons}`;
+    } catch (error) {
+        retentionPreview.textContent = error.message || String(error);
+    }
+}
+
+retentionAddRuleBtn.addEventListener('click', () => addTagRuleRow());
+retentionPreviewBtn.addEventListener('click', previewRetention);
+
 // --- Backup & Restore ---
 
 const createBackupBtn = document.getElementById('create-backup-btn');
@@ -104,6 +235,10 @@ async function selectRestoreBackup() {
                         <span class="w-1.5 h-1.5 rounded-full bg-stone-400"></span>
                         ${manifest.summary.tags} tags
                     </li>
+                    <li class="flex items-center gap-1">
+                        <span class="w-1.5 h-1.5 rounded-full bg-stone-400"></span>
+                        ${manifest.summary.collections || 0} collections
+                    </li>
                     <li class="flex items-center gap-1">
                         <span class="w-1.5 h-1.5 rounded-full bg-stone-400"></span>
                         ${manifest.summary.plugins} plugins
@@ -178,3 +313,323 @@ restoreConfirmYes.addEventListener('click', confirmRestore);
 restoreConfirmDialog.addEventListener('click', (e) => {
     if (e.target === restoreConfirmDialog) hideRestoreConfirmDialog();
 });
+
+// --- Audit Log ---
+
+const exportAuditLogBtn = document.getElementById('export-audit-log-btn');
+
+async function exportAuditLog() {
+    try {
+        const savedPath = await window.go.main.App.ExportAuditLog({});
+        if (savedPath) {
+            showToast('Audit log exported');
+        }
+    } catch (error) {
+        console.error('Failed to export audit log:', error);
+        showToast('Failed to export audit log: ' + error.message);
+    }
+}
+
+exportAuditLogBtn.addEventListener('click', exportAuditLog);
+
+// --- Trash ---
+
+const trashRetentionDays = document.getElementById('trash-retention-days');
+
+async function loadTrashRetention() {
+    try {
+        trashRetentionDays.value = numberOrEmpty(await window.go.main.App.GetTrashRetentionDays());
+    } catch (error) {
+        console.error('Failed to load trash retention:', error);
+    }
+}
+
+// --- Sensitive Clips ---
+
+const sensitiveExpireMinutes = document.getElementById('sensitive-expire-minutes');
+const blockSensitiveUploads = document.getElementById('block-sensitive-uploads');
+
+async function loadSensitiveExpiration() {
+    try {
+        sensitiveExpireMinutes.value = numberOrEmpty(await window.go.main.App.GetSensitiveExpireMinutes());
+        blockSensitiveUploads.checked = await window.go.main.PluginService.GetBlockSensitiveUploads();
+    } catch (error) {
+        console.error('Failed to load sensitive clip expiration:', error);
+    }
+}
+
+// --- Secret Detection ---
+
+const secretAction = document.getElementById('secret-action');
+const secretExpireMinutes = document.getElementById('secret-expire-minutes');
+const secretMaskPreviews = document.getElementById('secret-mask-previews');
+const secretIncludeBackups = document.getElementById('secret-include-backups');
+const secretRulesList = document.getElementById('secret-rules');
+const secretRescanBtn = document.getElementById('secret-rescan-btn');
+const secretRescanResult = document.getElementById('secret-rescan-result');
+
+async function loadSecretPolicy() {
+    secretRescanResult.textContent = '';
+    try {
+        const [policy, rules] = await Promise.all([
+            window.go.main.App.GetSecretPolicy(),
+            window.go.main.App.GetSecretRules(),
+        ]);
+        secretAction.value = policy.action;
+        secretExpireMinutes.value = numberOrEmpty(policy.expire_minutes);
+        secretMaskPreviews.checked = policy.mask_previews;
+        secretIncludeBackups.checked = policy.include_in_backups;
+
+        const disabled = new Set(policy.disabled_rules || []);
+        secretRulesList.innerHTML = '';
+        rules.forEach(rule => {
+            const label = document.createElement('label');
+            label.className = 'flex items-center gap-2 cursor-pointer';
+            label.innerHTML = `
+                <input type="checkbox" class="secret-rule" value="${escapeHTML(rule)}" ${disabled.has(rule) ? '' : 'checked'}>
+                <span>${escapeHTML(secretRuleLabel(rule))}</span>
+            `;
+            secretRulesList.appendChild(label);
+        });
+    } catch (error) {
+        console.error('Failed to load secret policy:', error);
+    }
+}
+
+function readSecretPolicy() {
+    const disabledRules = [];
+    secretRulesList.querySelectorAll('.secret-rule').forEach(input => {
+        if (!input.checked) disabledRules.push(input.value);
+    });
+    return {
+        action: secretAction.value,
+        expire_minutes: numberOrZero(secretExpireMinutes),
+        mask_previews: secretMaskPreviews.checked,
+        include_in_backups: secretIncludeBackups.checked,
+        disabled_rules: disabledRules,
+    };
+}
+
+// Rescans with the policy shown, so it is saved first
+async function rescanSecrets() {
+    try {
+        secretRescanBtn.disabled = true;
+        secretRescanResult.textContent = 'Scanning...';
+        await window.go.main.App.SetSecretPolicy(readSecretPolicy());
+        const flagged = await window.go.main.App.RescanSecrets();
+        secretRescanResult.textContent = flagged === 1 ? '1 clip contains secrets' : `${flagged} clips contain secrets`;
+        loadClips();
+    } catch (error) {
+        secretRescanResult.textContent = error.message || String(error);
+    } finally {
+        secretRescanBtn.disabled = false;
+    }
+}
+
+secretRescanBtn.addEventListener('click', rescanSecrets);
+
+// --- Encryption ---
+
+const encryptionStatusText = document.getElementById('encryption-status');
+const encryptionCurrentRow = document.getElementById('encryption-current-row');
+const encryptionCurrentPassphrase = document.getElementById('encryption-current-passphrase');
+const encryptionNewLabel = document.getElementById('encryption-new-label');
+const encryptionNewPassphrase = document.getElementById('encryption-new-passphrase');
+const encryptionKeyFileBtn = document.getElementById('encryption-key-file-btn');
+const encryptionKeyFile = document.getElementById('encryption-key-file');
+const encryptionEnableBtn = document.getElementById('encryption-enable-btn');
+const encryptionChangeBtn = document.getElementById('encryption-change-btn');
+const encryptionLockBtn = document.getElementById('encryption-lock-btn');
+const encryptionDisableBtn = document.getElementById('encryption-disable-btn');
+
+let encryptionStatus = { enabled: false, locked: false, key_source: '', key_file: '' };
+let newKeyFile = '';
+
+function resetEncryptionInputs() {
+    encryptionCurrentPassphrase.value = '';
+    encryptionNewPassphrase.value = '';
+    newKeyFile = '';
+    encryptionKeyFile.textContent = '';
+}
+
+function renderEncryptionStatus() {
+    const { enabled, locked, key_source } = encryptionStatus;
+    if (!enabled) {
+        encryptionStatusText.textContent =
+            'Clip data is stored unencrypted. Encrypted clips need your passphrase or key file after every restart, and can\'t be found by text search.';
+    } else if (key_source === 'key_file') {
+        encryptionStatusText.textContent = `Clip data is encrypted with the key file ${encryptionStatus.key_file}.`;
+    } else {
+        encryptionStatusText.textContent = 'Clip data is encrypted with a passphrase.';
+    }
+    if (enabled && locked) {
+        encryptionStatusText.textContent += ' Locked.';
+    }
+
+    encryptionCurrentRow.classList.toggle('hidden', !enabled || key_source !== 'passphrase');
+    encryptionNewLabel.textContent = enabled ? 'New passphrase' : 'Passphrase';
+    encryptionEnableBtn.classList.toggle('hidden', enabled);
+    encryptionChangeBtn.classList.toggle('hidden', !enabled);
+    encryptionLockBtn.classList.toggle('hidden', !enabled || locked);
+    encryptionDisableBtn.classList.toggle('hidden', !enabled);
+}
+
+async function loadEncryptionStatus() {
+    resetEncryptionInputs();
+    try {
+        encryptionStatus = await window.go.main.App.GetEncryptionStatus();
+    } catch (error) {
+        console.error('Failed to load encryption status:', error);
+    }
+    renderEncryptionStatus();
+}
+
+// The key that protects the clips now. Key files are read from where they
+// were when encryption was enabled.
+function currentEncryptionKey() {
+    if (encryptionStatus.key_source === 'key_file') {
+        return { passphrase: '', key_file: encryptionStatus.key_file };
+    }
+    return { passphrase: encryptionCurrentPassphrase.value, key_file: '' };
+}
+
+function newEncryptionKey() {
+    if (newKeyFile) {
+        return { passphrase: '', key_file: newKeyFile };
+    }
+    return { passphrase: encryptionNewPassphrase.value, key_file: '' };
+}
+
+async function runEncryptionAction(button, busyText, action, doneText) {
+    const label = button.textContent;
+    try {
+        button.disabled = true;
+        button.textContent = busyText;
+        await action();
+        showToast(doneText);
+        await loadEncryptionStatus();
+        loadClips();
+    } catch (error) {
+        console.error('Encryption action failed:', error);
+        showToast(error.message || String(error), 'error');
+    } finally {
+        button.disabled = false;
+        button.textContent = label;
+    }
+}
+
+async function createKeyFile() {
+    try {
+        const path = await window.go.main.App.CreateKeyFile();
+        if (path) {
+            newKeyFile = path;
+            encryptionNewPassphrase.value = '';
+            encryptionKeyFile.textContent = path;
+        }
+    } catch (error) {
+        console.error('Failed to create key file:', error);
+        showToast('Failed to create key file: ' + (error.message || error), 'error');
+    }
+}
+
+encryptionKeyFileBtn.addEventListener('click', createKeyFile);
+encryptionNewPassphrase.addEventListener('input', () => {
+    newKeyFile = '';
+    encryptionKeyFile.textContent = '';
+});
+encryptionEnableBtn.addEventListener('click', () => {
+    runEncryptionAction(encryptionEnableBtn, 'Encrypting...',
+        () => window.go.main.App.EnableEncryption(newEncryptionKey()), 'Clips encrypted');
+});
+encryptionChangeBtn.addEventListener('click', () => {
+    runEncryptionAction(encryptionChangeBtn, 'Changing...',
+        () => window.go.main.App.ChangeEncryptionKey(currentEncryptionKey(), newEncryptionKey()), 'Encryption key changed');
+});
+encryptionDisableBtn.addEventListener('click', () => {
+    const key = currentEncryptionKey();
+    showConfirmDialog('Decrypt Clips', 'Decrypt all clips and store them unencrypted?', () => {
+        runEncryptionAction(encryptionDisableBtn, 'Decrypting...',
+            () => window.go.main.App.DisableEncryption(key), 'Clips decrypted');
+    });
+});
+encryptionLockBtn.addEventListener('click', async () => {
+    try {
+        await window.go.main.App.LockDatabase();
+        closeSettings();
+        await checkDatabaseLocked();
+    } catch (error) {
+        showToast(error.message || String(error), 'error');
+    }
+});
+
+// --- Unlock ---
+
+const unlockDialog = document.getElementById('unlock-dialog');
+const unlockHint = document.getElementById('unlock-hint');
+const unlockPassphrase = document.getElementById('unlock-passphrase');
+const unlockKeyFileBtn = document.getElementById('unlock-key-file-btn');
+const unlockError = document.getElementById('unlock-error');
+const unlockCancel = document.getElementById('unlock-cancel');
+const unlockBtn = document.getElementById('unlock-btn');
+
+function showUnlockDialog(status) {
+    const keyFile = status.key_source === 'key_file';
+    unlockHint.textContent = keyFile
+        ? `Your clips are encrypted, and the key file ${status.key_file} couldn't be read. Select the key file to read them.`
+        : 'Your clips are encrypted. Enter your passphrase to read them.';
+    unlockPassphrase.classList.toggle('hidden', keyFile);
+    unlockKeyFileBtn.classList.toggle('hidden', !keyFile);
+    unlockBtn.classList.toggle('hidden', keyFile);
+    unlockPassphrase.value = '';
+    unlockError.textContent = '';
+
+    unlockDialog.classList.remove('opacity-0', 'pointer-events-none');
+    unlockDialog.classList.add('opacity-100');
+    unlockDialog.querySelector(':scope > div').classList.remove('scale-95');
+    unlockDialog.querySelector(':scope > div').classList.add('scale-100');
+    if (!keyFile) unlockPassphrase.focus();
+}
+
+function hideUnlockDialog() {
+    unlockDialog.classList.add('opacity-0', 'pointer-events-none');
+    unlockDialog.classList.remove('opacity-100');
+    unlockDialog.querySelector(':scope > div').classList.add('scale-95');
+    unlockDialog.querySelector(':scope > div').classList.remove('scale-100');
+}
+
+// checkDatabaseLocked asks for the key if clip data is encrypted and locked
+async function checkDatabaseLocked() {
+    try {
+        const status = await window.go.main.App.GetEncryptionStatus();
+        if (status.enabled && status.locked) {
+            showUnlockDialog(status);
+        }
+    } catch (error) {
+        console.error('Failed to check encryption status:', error);
+    }
+}
+
+async function unlockDatabase(key) {
+    try {
+        await window.go.main.App.UnlockDatabase(key);
+        hideUnlockDialog();
+        showToast('Clips unlocked');
+        loadClips();
+    } catch (error) {
+        unlockError.textContent = error.message || String(error);
+    }
+}
+
+unlockBtn.addEventListener('click', () => unlockDatabase({ passphrase: unlockPassphrase.value, key_file: '' }));
+unlockPassphrase.addEventListener('keydown', (e) => {
+    if (e.key === 'Enter') unlockDatabase({ passphrase: unlockPassphrase.value, key_file: '' });
+});
+unlockKeyFileBtn.addEventListener('click', async () => {
+    try {
+        const path = await window.go.main.App.SelectKeyFile();
+        if (path) await unlockDatabase({ passphrase: '', key_file: path });
+    } catch (error) {
+        unlockError.textContent = error.message || String(error);
+    }
+});
+unlockCancel.addEventListener('click', hideUnlockDialog);
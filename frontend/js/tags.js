@@ -1,7 +1,7 @@
 // --- Tag UI Management ---
 
 // Constants
-const MAX_TAG_NAME_LENGTH = 50;
+const MAX_TAG_NAME_LENGTH = 100;
 
 // Tag filter dropdown element references
 const tagFilterBtn = document.getElementById('tag-filter-btn');
@@ -10,6 +10,11 @@ const tagFilterList = document.getElementById('tag-filter-list');
 const tagFilterBadge = document.getElementById('tag-filter-badge');
 const activeTagsContainer = document.getElementById('active-tags-container');
 const clearTagFiltersBtn = document.getElementById('clear-tag-filters');
+const tagQueryInput = document.getElementById('tag-query-input');
+const tagQueryError = document.getElementById('tag-query-error');
+const savedSearchList = document.getElementById('saved-search-list');
+const savedSearchNameInput = document.getElementById('saved-search-name');
+const saveSearchBtn = document.getElementById('save-search-btn');
 
 // Bulk tag button
 const bulkTagBtn = document.getElementById('bulk-tag-btn');
@@ -44,16 +49,19 @@ function renderTagFilterDropdown() {
 
     allTags.forEach(tag => {
         const isActive = activeTagFilters.includes(tag.id);
+        // Nested tags ("project/alpha") are indented under their parent
+        const levels = tag.name.split('/');
         const item = document.createElement('label');
         item.className = 'flex items-center gap-2 px-3 py-1.5 hover:bg-stone-100 cursor-pointer transition-colors';
         item.innerHTML = `
             <input type="checkbox"
                    data-testid="tag-checkbox-${tag.name}"
                    class="rounded border-stone-300 text-stone-600 focus:ring-stone-500"
+                   style="margin-left: ${(levels.length - 1) * 12}px"
                    ${isActive ? 'checked' : ''}>
             <span class="inline-flex items-center px-2 py-0.5 rounded text-[10px] font-medium text-white"
-                  style="background-color: ${tag.color}">
-                ${escapeHtml(tag.name)}
+                  style="background-color: ${tag.color}" title="${escapeHtml(tag.name)}">
+                ${escapeHtml(levels[levels.length - 1])}
             </span>
             <span class="text-stone-400 text-[10px] ml-auto">${tag.count}</span>
         `;
@@ -80,8 +88,9 @@ function toggleTagFilter(tagId) {
 function updateActiveTagsDisplay() {
     // Update badge
     if (tagFilterBadge) {
-        if (activeTagFilters.length > 0) {
-            tagFilterBadge.textContent = activeTagFilters.length;
+        const filterCount = activeTagFilters.length + (activeTagQuery ? 1 : 0);
+        if (filterCount > 0) {
+            tagFilterBadge.textContent = filterCount;
             tagFilterBadge.classList.remove('hidden');
         } else {
             tagFilterBadge.classList.add('hidden');
@@ -92,7 +101,7 @@ function updateActiveTagsDisplay() {
     if (activeTagsContainer) {
         activeTagsContainer.innerHTML = '';
 
-        if (activeTagFilters.length === 0) {
+        if (activeTagFilters.length === 0 && !activeTagQuery) {
             activeTagsContainer.classList.add('hidden');
         } else {
             activeTagsContainer.classList.remove('hidden');
@@ -127,6 +136,13 @@ function updateActiveTagsDisplay() {
                 activeTagsContainer.appendChild(pill);
             });
 
+            if (activeTagQuery) {
+                const queryPill = document.createElement('span');
+                queryPill.className = 'inline-flex items-center px-2 py-0.5 rounded text-[10px] font-mono bg-stone-200 text-stone-600';
+                queryPill.textContent = activeTagQuery;
+                activeTagsContainer.appendChild(queryPill);
+            }
+
             // Add clear all button
             const clearBtn = document.createElement('button');
             clearBtn.className = 'text-[10px] text-stone-400 hover:text-stone-600 underline ml-1 transition-colors';
@@ -137,24 +153,116 @@ function updateActiveTagsDisplay() {
 
         // Show/hide clear button in dropdown
         if (clearTagFiltersBtn) {
-            clearTagFiltersBtn.classList.toggle('hidden', activeTagFilters.length === 0);
+            clearTagFiltersBtn.classList.toggle('hidden', activeTagFilters.length === 0 && !activeTagQuery);
         }
     }
 }
 
 function clearAllTagFilters() {
     activeTagFilters = [];
+    activeTagQuery = '';
+    activeSavedSearchId = null;
+    if (tagQueryInput) tagQueryInput.value = '';
     updateActiveTagsDisplay();
     renderTagFilterDropdown();
     loadClips();
 }
 
+function setTagQueryError(message) {
+    if (!tagQueryError) return;
+    tagQueryError.textContent = message;
+    tagQueryError.classList.toggle('hidden', !message);
+}
+
+if (tagQueryInput) {
+    // Apply the query on Enter or when leaving the field
+    tagQueryInput.addEventListener('change', () => {
+        activeTagQuery = tagQueryInput.value.trim();
+        updateActiveTagsDisplay();
+        loadClips();
+    });
+}
+
+// --- Saved Searches ---
+
+// renderSavedSearches lists saved searches with their current match counts
+async function renderSavedSearches() {
+    if (!savedSearchList) return;
+
+    const searches = await getSavedSearches();
+    savedSearchList.innerHTML = '';
+    if (searches.length === 0) {
+        savedSearchList.innerHTML = '<p class="text-stone-400 text-xs px-3 py-1">No saved searches</p>';
+        return;
+    }
+
+    searches.forEach(search => {
+        const isActive = search.id === activeSavedSearchId;
+        const item = document.createElement('div');
+        item.className = `flex items-center gap-2 px-3 py-1.5 hover:bg-stone-100 cursor-pointer transition-colors ${isActive ? 'bg-stone-100 font-medium' : ''}`;
+        item.innerHTML = `
+            <span class="text-xs text-stone-700 truncate">${escapeHtml(search.name)}</span>
+            <span class="text-stone-400 text-[10px] ml-auto">${search.count}</span>
+            <button class="text-stone-400 hover:text-stone-600" aria-label="Delete saved search ${escapeHtml(search.name)}">
+                <svg class="w-3 h-3" fill="none" stroke="currentColor" viewBox="0 0 24 24">
+                    <path stroke-linecap="round" stroke-linejoin="round" stroke-width="2" d="M6 18L18 6M6 6l12 12"/>
+                </svg>
+            </button>
+        `;
+
+        item.addEventListener('click', () => {
+            activeSavedSearchId = isActive ? null : search.id;
+            renderSavedSearches();
+            loadClips();
+        });
+        item.querySelector('button').addEventListener('click', async (e) => {
+            e.stopPropagation();
+            await deleteSavedSearch(search.id);
+            if (activeSavedSearchId === search.id) {
+                activeSavedSearchId = null;
+                loadClips();
+            }
+            renderSavedSearches();
+        });
+
+        savedSearchList.appendChild(item);
+    });
+}
+
+async function saveCurrentSearch() {
+    const name = savedSearchNameInput.value.trim();
+    if (!name) return;
+
+    const search = await createSavedSearch(name, {
+        archived: isViewingArchive,
+        text: searchInput.value.trim(),
+        tag_ids: activeTagFilters,
+        tag_query: activeTagQuery,
+    });
+    if (search) {
+        savedSearchNameInput.value = '';
+        renderSavedSearches();
+    }
+}
+
+if (saveSearchBtn) {
+    saveSearchBtn.addEventListener('click', saveCurrentSearch);
+}
+if (savedSearchNameInput) {
+    savedSearchNameInput.addEventListener('keydown', (e) => {
+        if (e.key === 'Enter') saveCurrentSearch();
+    });
+}
+
 // --- Tag Filter Dropdown Toggle ---
 
 if (tagFilterBtn) {
     tagFilterBtn.addEventListener('click', (e) => {
         e.stopPropagation();
         tagFilterDropdown.classList.toggle('hidden');
+        if (!tagFilterDropdown.classList.contains('hidden')) {
+            renderSavedSearches(); // refresh the counts
+        }
     });
 }
 
@@ -24,6 +24,8 @@ function getMenuIcon(name) {
         'tags': '<path stroke-linecap="round" stroke-linejoin="round" d="M7 7h.01M7 3h5c.512 0 1.024.195 1.414.586l7 7a2 2 0 010 2.828l-7 7a2 2 0 01-2.828 0l-7-7A1.994 1.994 0 013 12V7a4 4 0 014-4z"/>',
         'archive': '<path stroke-linecap="round" stroke-linejoin="round" d="M5 8h14M5 8a2 2 0 110-4h14a2 2 0 110 4M5 8v10a2 2 0 002 2h10a2 2 0 002-2V8m-9 4h4"/>',
         'restore': '<path stroke-linecap="round" stroke-linejoin="round" d="M3 10h10a8 8 0 018 8v2M3 10l6 6m-6-6l6-6"/>',
+        'lock': '<path stroke-linecap="round" stroke-linejoin="round" d="M16.5 10.5V6.75a4.5 4.5 0 10-9 0v3.75m-.75 11.25h10.5a2.25 2.25 0 002.25-2.25v-6.75a2.25 2.25 0 00-2.25-2.25H6.75a2.25 2.25 0 00-2.25 2.25v6.75a2.25 2.25 0 002.25 2.25z"/>',
+        'pin': '<path stroke-linecap="round" stroke-linejoin="round" d="M16 3l5 5-3 1-4 4 1 5-2 2-4-4-5 5-1-1 5-5-4-4 2-2 5 1 4-4 1-3z"/>',
         'delete': '<path stroke-linecap="round" stroke-linejoin="round" d="M19 7l-.867 12.142A2 2 0 0116.138 21H7.862a2 2 0 01-1.995-1.858L5 7m5 4v6m4-6v6m1-10V4a1 1 0 00-1-1h-4a1 1 0 00-1 1v3M4 7h16"/>',
     };
     const path = icons[name];
@@ -49,13 +51,21 @@ function renderCardMenu(clipId, button, clip) {
     ];
 
     // Add edit option for editable types
-    if (isEditableType(clip.content_type)) {
+    if (!isViewingTrash && isEditableType(clip.content_type)) {
         builtInActions.push({ id: 'edit', label: 'Edit', icon: 'edit' });
     }
 
-    builtInActions.push({ id: 'tags', label: 'Tags', icon: 'tags' });
-    builtInActions.push({ id: 'archive', label: isViewingArchive ? 'Restore' : 'Archive', icon: isViewingArchive ? 'restore' : 'archive' });
-    builtInActions.push({ id: 'delete', label: 'Delete', icon: 'delete', danger: true });
+    if (isViewingTrash) {
+        // Trashed clips can't be changed until they are restored
+        builtInActions.push({ id: 'restore', label: 'Restore', icon: 'restore' });
+        builtInActions.push({ id: 'purge', label: 'Delete Forever', icon: 'delete', danger: true });
+    } else {
+        builtInActions.push({ id: 'pin', label: clip.is_pinned ? 'Unpin' : 'Pin', icon: 'pin' });
+        builtInActions.push({ id: 'sensitive', label: clip.is_sensitive ? 'Unmark Sensitive' : 'Mark Sensitive', icon: 'lock' });
+        builtInActions.push({ id: 'tags', label: 'Tags', icon: 'tags' });
+        builtInActions.push({ id: 'archive', label: isViewingArchive ? 'Restore' : 'Archive', icon: isViewingArchive ? 'restore' : 'archive' });
+        builtInActions.push({ id: 'delete', label: 'Delete', icon: 'delete', danger: true });
+    }
 
     // Render built-in actions
     builtInActions.forEach(action => {
@@ -197,12 +207,24 @@ async function handleCardAction(action, clipId, triggerButton) {
                 openTagPopover(id, tagBtn || triggerButton);
             }
             break;
+        case 'pin':
+            setClipPinned(id, !clipPinnedState(id));
+            break;
+        case 'sensitive':
+            setClipsSensitive([id], !clipSensitiveState(id));
+            break;
         case 'archive':
             toggleArchiveClip(id);
             break;
         case 'delete':
             deleteClip(id);
             break;
+        case 'restore':
+            restoreClips([id]);
+            break;
+        case 'purge':
+            purgeClips([id]);
+            break;
     }
 }
 
@@ -212,6 +234,8 @@ async function createClipCard(clip) {
     card.dataset.id = clip.id;
     card.dataset.filename = (clip.filename || '').toLowerCase();
     card.dataset.type = (clip.content_type || '').toLowerCase();
+    card.dataset.pinned = clip.is_pinned ? 'true' : 'false';
+    card.dataset.sensitive = clip.is_sensitive ? 'true' : 'false';
     card.setAttribute('aria-label', `Clip: ${clip.filename || 'Pasted Content'}`);
 
     const checkboxHTML = `
@@ -229,7 +253,14 @@ async function createClipCard(clip) {
 
     let previewHTML = '';
 
-    if (clip.content_type.startsWith('image/')) {
+    if (clip.is_sensitive) {
+        // Sensitive clips stay masked until revealed
+        previewHTML = `<div class="preview-container sensitive-mask aspect-square w-full flex flex-col items-center justify-center bg-stone-100 text-stone-400">
+            <svg class="w-10 h-10" fill="none" stroke="currentColor" stroke-width="1" viewBox="0 0 24 24"><path stroke-linecap="round" stroke-linejoin="round" d="M16.5 10.5V6.75a4.5 4.5 0 10-9 0v3.75m-.75 11.25h10.5a2.25 2.25 0 002.25-2.25v-6.75a2.25 2.25 0 00-2.25-2.25H6.75a2.25 2.25 0 00-2.25 2.25v6.75a2.25 2.25 0 002.25 2.25z"/></svg>
+            <span class="mt-2 text-[9px] font-medium uppercase tracking-wider">Sensitive</span>
+            <span class="text-[9px]">Click to reveal</span>
+        </div>`;
+    } else if (clip.content_type.startsWith('image/')) {
         // For images, show loading placeholder initially
         previewHTML = `<div class="preview-container overflow-hidden aspect-square w-full bg-stone-100 flex items-center justify-center">
             <img data-clip-id="${clip.id}" alt="${escapeHTML(clip.filename) || 'Uploaded image'}" class="h-full w-full object-cover transition-transform duration-300 group-hover:scale-[1.02] hidden">
@@ -258,12 +289,20 @@ async function createClipCard(clip) {
     }
 
     let expirationBadge = '';
-    if (clip.expires_at) {
+    if (clip.is_pinned) {
+        expirationBadge = `<div class="absolute top-2 left-2 bg-stone-900 text-white text-[8px] font-semibold px-1.5 py-0.5 rounded z-20 uppercase tracking-wide">
+            Pinned
+        </div>`;
+    } else if (clip.expires_at) {
         expirationBadge = `<div class="absolute top-2 left-2 bg-stone-700 text-white text-[8px] font-semibold px-1.5 py-0.5 rounded z-20 uppercase tracking-wide">
             Temp
         </div>`;
     }
 
+    const secretBadge = clip.secrets && clip.secrets.length
+        ? `<span class="secret-badge text-[9px] font-medium text-red-600 uppercase tracking-wide" title="Contains: ${escapeHTML(clip.secrets.map(secretRuleLabel).join(', '))}">Secret</span>`
+        : '';
+
     card.innerHTML = `
         ${checkboxHTML}
         <div class="relative cursor-pointer" data-action="open-lightbox">
@@ -277,7 +316,10 @@ async function createClipCard(clip) {
                 ${escapeHTML(clip.filename) || '<span class="text-stone-400 font-normal">Pasted</span>'}
             </p>
             <div class="flex justify-between items-center">
-                <span class="text-[9px] font-medium text-stone-400 uppercase tracking-wide">${getFriendlyFileType(clip.content_type, clip.filename)}</span>
+                <div class="flex items-center gap-2">
+                    <span class="text-[9px] font-medium text-stone-400 uppercase tracking-wide">${getFriendlyFileType(clip.content_type, clip.filename)}</span>
+                    ${secretBadge}
+                </div>
                 <button class="card-menu-trigger p-1 text-stone-400 hover:text-stone-600 hover:bg-stone-100 rounded transition-colors"
                         data-action="menu"
                         data-id="${clip.id}"
@@ -326,8 +368,16 @@ async function createClipCard(clip) {
     // Prevent lightbox trigger if clicking checkbox
     checkbox.addEventListener('click', (e) => e.stopPropagation());
 
-    // Lightbox trigger logic
-    if (clip.content_type.startsWith('image/')) {
+    // Lightbox trigger logic. Sensitive images are left out of the lightbox.
+    if (clip.is_sensitive) {
+        card.querySelector('[data-action="open-lightbox"]').addEventListener('click', () => {
+            if (card.dataset.revealed === 'true' && !isViewingTrash && isEditableType(clip.content_type)) {
+                openEditor(clip.id);
+            } else {
+                revealSensitiveClip(clip, card);
+            }
+        });
+    } else if (clip.content_type.startsWith('image/')) {
         const imageIndex = imageClips.length;
         imageClips.push(clip);
         card.querySelector('[data-action="open-lightbox"]').addEventListener('click', () => openLightbox(imageIndex));
@@ -337,7 +387,7 @@ async function createClipCard(clip) {
     } else {
         // For non-images, clicking opens the editor or shows content
         card.querySelector('[data-action="open-lightbox"]').addEventListener('click', () => {
-            if (isEditableType(clip.content_type)) {
+            if (!isViewingTrash && isEditableType(clip.content_type)) {
                 openEditor(clip.id);
             }
         });
@@ -346,6 +396,28 @@ async function createClipCard(clip) {
     gallery.appendChild(card);
 }
 
+// Ask before showing the content of a sensitive clip on its card
+function revealSensitiveClip(clip, card) {
+    showConfirmDialog('Reveal Sensitive Clip', 'This clip is marked sensitive. Show its content?', async () => {
+        try {
+            const clipData = await revealClip(clip.id);
+            const container = card.querySelector('.preview-container');
+            if (clipData.content_type.startsWith('image/')) {
+                container.innerHTML = `<img src="data:${clipData.content_type};base64,${clipData.data}" alt="${escapeHTML(clip.filename) || 'Uploaded image'}" class="h-full w-full object-cover">`;
+            } else if (clipData.content_type.startsWith('text/') || clipData.content_type === 'application/json') {
+                container.innerHTML = `<pre class="p-3 text-[9px] leading-relaxed overflow-auto h-full w-full text-stone-400 bg-stone-900"><code>${escapeHTML(clipData.data.substring(0, 500))}</code></pre>`;
+            } else {
+                container.querySelector('span:last-child').textContent = getFriendlyFileType(clip.content_type, clip.filename);
+            }
+            container.classList.remove('sensitive-mask');
+            card.dataset.revealed = 'true';
+        } catch (error) {
+            console.error(`Failed to reveal clip ${clip.id}:`, error);
+            showToast('Failed to reveal clip.');
+        }
+    });
+}
+
 // Load image data for a card
 async function loadImageForCard(clipId, card) {
     try {
@@ -392,7 +464,7 @@ function updateBulkToolbar() {
         bulkToolbar.classList.remove('hidden', 'translate-y-4', 'opacity-0', 'pointer-events-none');
         bulkToolbar.classList.add('translate-y-0', 'opacity-100', 'pointer-events-auto');
         selectedCountEl.textContent = `${count} selected`;
-        bulkArchiveText.textContent = isViewingArchive ? 'Restore' : 'Archive';
+        bulkArchiveText.textContent = isViewingArchive || isViewingTrash ? 'Restore' : 'Archive';
 
         // Comparison Logic: Show compare button if 2 items are selected and BOTH are images
         if (count === 2) {
@@ -452,6 +524,11 @@ function cancelSelection() {
 function toggleViewMode() {
     isViewingArchive = !isViewingArchive;
 
+    // Leave the trash if open
+    if (isViewingTrash) {
+        setTrashViewActive(false);
+    }
+
     // Hide watch view if open
     if (isViewingWatch) {
         isViewingWatch = false;
@@ -486,6 +563,56 @@ function toggleViewMode() {
     loadClips();
 }
 
+// Updates the trash button and upload section for entering or leaving the trash
+function setTrashViewActive(active) {
+    isViewingTrash = active;
+    toggleTrashViewBtn.setAttribute('aria-pressed', active);
+    if (active) {
+        trashBtnText.textContent = 'Active';
+        toggleTrashViewBtn.classList.add('bg-stone-800', 'text-white', 'border-stone-800');
+        toggleTrashViewBtn.classList.remove('border-stone-200', 'text-stone-600', 'hover:border-stone-300', 'hover:bg-stone-100');
+        emptyTrashBtn.classList.remove('hidden');
+        uploadSection.classList.add('opacity-50', 'pointer-events-none'); // Disable upload in trash view
+        uploadSection.setAttribute('aria-hidden', 'true');
+    } else {
+        trashBtnText.textContent = 'Trash';
+        toggleTrashViewBtn.classList.remove('bg-stone-800', 'text-white', 'border-stone-800');
+        toggleTrashViewBtn.classList.add('border-stone-200', 'text-stone-600', 'hover:border-stone-300', 'hover:bg-stone-100');
+        emptyTrashBtn.classList.add('hidden');
+        uploadSection.classList.remove('opacity-50', 'pointer-events-none');
+        uploadSection.removeAttribute('aria-hidden');
+    }
+}
+
+function toggleTrashView() {
+    // Leave the archive and watch views if open
+    if (isViewingArchive) {
+        isViewingArchive = false;
+        archiveBtnText.textContent = "Archive";
+        toggleArchiveViewBtn.setAttribute('aria-pressed', 'false');
+        toggleArchiveViewBtn.classList.remove('bg-stone-800', 'text-white', 'border-stone-800');
+        toggleArchiveViewBtn.classList.add('border-stone-200', 'text-stone-600', 'hover:border-stone-300', 'hover:bg-stone-100');
+    }
+    if (isViewingWatch) {
+        isViewingWatch = false;
+        watchBtnText.textContent = 'Watch';
+        toggleWatchViewBtn.classList.remove('bg-stone-800', 'text-white', 'border-stone-800', 'hover:bg-stone-700', 'hover:border-stone-700');
+        toggleWatchViewBtn.classList.add('border-stone-200', 'text-stone-600', 'hover:bg-stone-100', 'hover:border-stone-300');
+        toggleWatchViewBtn.setAttribute('aria-pressed', 'false');
+        watchView.classList.add('hidden');
+        uploadSection.classList.remove('hidden');
+    }
+
+    setTrashViewActive(!isViewingTrash);
+
+    // Ensure main view is visible
+    gallery.parentElement.classList.remove('hidden');
+
+    // Clear image cache when switching views
+    imageCache.clear();
+    loadClips();
+}
+
 // Search Logic
 const searchInput = document.getElementById('search-input');
 searchInput.addEventListener('input', (e) => {
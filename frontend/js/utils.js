@@ -3,7 +3,7 @@ window.__testHelpers = {};
 
 let confirmCallback = null;
 
-function showConfirmDialog(title, message, callback) {
+function showConfirmDialog(title, message, callback, confirmLabel = 'Delete') {
     const dialog = document.getElementById('confirm-dialog');
     const dialogContent = dialog.querySelector('div');
     const titleEl = document.getElementById('confirm-title');
@@ -11,6 +11,7 @@ function showConfirmDialog(title, message, callback) {
 
     titleEl.textContent = title;
     messageEl.textContent = message;
+    document.getElementById('confirm-yes-btn').textContent = confirmLabel;
     confirmCallback = callback;
 
     dialog.classList.remove('opacity-0', 'pointer-events-none');
@@ -89,6 +90,23 @@ function copyToClipboard(text) {
     document.body.removeChild(textArea);
 }
 
+// Friendly names of the secret scanner's rules
+const SECRET_RULE_LABELS = {
+    aws_access_key: 'AWS access keys',
+    aws_secret_key: 'AWS secret keys',
+    github_token: 'GitHub tokens',
+    slack_token: 'Slack tokens',
+    slack_webhook: 'Slack webhooks',
+    private_key: 'Private keys',
+    jwt: 'JSON Web Tokens',
+    credit_card: 'Card numbers',
+    password: 'Passwords and API keys in assignments',
+};
+
+function secretRuleLabel(rule) {
+    return SECRET_RULE_LABELS[rule] || rule;
+}
+
 function escapeHTML(str) {
     if (!str) return '';
     return str.replace(/[&<>"']/g, function (m) {
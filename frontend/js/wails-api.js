@@ -3,7 +3,15 @@
 
 async function loadClips() {
     try {
-        const clips = await window.go.main.App.GetClips(isViewingArchive, activeTagFilters);
+        const clips = activeSavedSearchId && !isViewingTrash
+            ? await window.go.main.App.RunSavedSearch(activeSavedSearchId)
+            : await window.go.main.App.SearchClips({
+                archived: isViewingArchive,
+                trashed: isViewingTrash,
+                tag_ids: activeTagFilters,
+                tag_query: activeTagQuery,
+            });
+        setTagQueryError('');
 
         gallery.innerHTML = ''; // Clear gallery
         selectedIds.clear();
@@ -16,7 +24,11 @@ async function loadClips() {
             }
         } else {
             let emptyMsg;
-            if (activeTagFilters.length > 0) {
+            if (isViewingTrash) {
+                emptyMsg = 'Trash is empty.';
+            } else if (activeSavedSearchId) {
+                emptyMsg = 'No clips match this saved search.';
+            } else if (activeTagFilters.length > 0 || activeTagQuery) {
                 emptyMsg = 'No clips match the selected tags.';
             } else if (isViewingArchive) {
                 emptyMsg = 'No archived clips.';
@@ -27,6 +39,9 @@ async function loadClips() {
         }
     } catch (error) {
         console.error('Error loading clips:', error);
+        if (String(error).includes('invalid tag query')) {
+            setTagQueryError(String(error));
+        }
         gallery.innerHTML = '<p class="text-red-500 col-span-full text-center">Error loading clips.</p>';
     }
 }
@@ -35,20 +50,26 @@ async function upload(files, expiration) {
     try {
         await window.go.main.App.UploadFiles(files, expiration);
         showToast('Upload successful!');
-        if (!isViewingArchive) {
+        if (!isViewingArchive && !isViewingTrash) {
             loadClips(); // Refresh gallery only if looking at active
         }
     } catch (error) {
         console.error('Error uploading:', error);
-        showToast('Upload failed.');
+        const message = error.message || String(error);
+        if (message.includes('contains a secret')) {
+            showToast(message, 'error');
+            loadClips(); // the other files were saved
+        } else {
+            showToast('Upload failed.');
+        }
     }
 }
 
 async function deleteClip(id) {
-    showConfirmDialog('Delete Clip', 'Are you sure you want to delete this clip permanently?', async () => {
+    showConfirmDialog('Delete Clip', 'Move this clip to the trash?', async () => {
         try {
             await window.go.main.App.DeleteClip(id);
-            showToast('Clip deleted.');
+            showToast('Clip moved to trash.');
             loadClips();
         } catch (error) {
             console.error('Error deleting clip:', error);
@@ -107,10 +128,17 @@ async function deleteAllTempFiles() {
 
 async function bulkDelete() {
     if (selectedIds.size === 0) return;
-    showConfirmDialog('Bulk Delete', `Are you sure you want to delete ${selectedIds.size} clips permanently?`, async () => {
+    if (isViewingTrash) {
+        purgeClips(Array.from(selectedIds));
+        return;
+    }
+    showConfirmDialog('Bulk Delete', `Move ${selectedIds.size} clips to the trash?`, async () => {
         try {
-            await window.go.main.App.BulkDelete(Array.from(selectedIds));
-            showToast(`Deleted ${selectedIds.size} clips.`);
+            const kept = await window.go.main.App.BulkDelete(Array.from(selectedIds), false);
+            const deleted = selectedIds.size - kept;
+            showToast(kept > 0
+                ? `Moved ${deleted} clips to trash. Kept ${kept} pinned.`
+                : `Moved ${deleted} clips to trash.`);
             selectedIds.clear();
             loadClips();
         } catch (error) {
@@ -120,8 +148,92 @@ async function bulkDelete() {
     });
 }
 
+async function setClipPinned(id, pinned) {
+    try {
+        await window.go.main.App.SetClipPinned(id, pinned);
+        showToast(pinned ? 'Clip pinned.' : 'Clip unpinned.');
+        loadClips();
+    } catch (error) {
+        console.error('Error pinning clip:', error);
+        showToast('Failed to update pin.');
+    }
+}
+
+async function setClipsSensitive(ids, sensitive) {
+    try {
+        await window.go.main.App.SetClipsSensitive(ids, sensitive);
+        showToast(sensitive ? 'Clip marked sensitive.' : 'Clip no longer sensitive.');
+        loadClips();
+    } catch (error) {
+        console.error('Error marking clip sensitive:', error);
+        showToast('Failed to update clip.');
+    }
+}
+
+// Get the data of a sensitive clip and allow reading it for a short while
+async function revealClip(id) {
+    return await window.go.main.App.RevealClip(id);
+}
+
+async function restoreClips(ids) {
+    try {
+        await window.go.main.App.RestoreClips(ids);
+        showToast(ids.length === 1 ? 'Clip restored.' : `Restored ${ids.length} clips.`);
+        selectedIds.clear();
+        loadClips();
+    } catch (error) {
+        console.error('Error restoring clips:', error);
+        showToast('Failed to restore clips.');
+    }
+}
+
+async function purgeClips(ids) {
+    const message = ids.length === 1
+        ? 'Delete this clip forever? This cannot be undone.'
+        : `Delete ${ids.length} clips forever? This cannot be undone.`;
+    showConfirmDialog('Delete Forever', message, async () => {
+        try {
+            await window.go.main.App.PurgeClips(ids);
+            showToast(ids.length === 1 ? 'Clip deleted.' : `Deleted ${ids.length} clips.`);
+            selectedIds.clear();
+            loadClips();
+        } catch (error) {
+            console.error('Error purging clips:', error);
+            showToast('Failed to delete clips.');
+        }
+    });
+}
+
+async function emptyTrash() {
+    showConfirmDialog('Empty Trash', 'Delete every clip in the trash forever? This cannot be undone.', async () => {
+        try {
+            const deleted = await window.go.main.App.EmptyTrash();
+            showToast(`Deleted ${deleted} clips.`);
+            loadClips();
+        } catch (error) {
+            console.error('Error emptying trash:', error);
+            showToast('Failed to empty trash.');
+        }
+    });
+}
+
+// Reads a clip's pin state from its card in the gallery
+function clipPinnedState(id) {
+    const card = gallery.querySelector(`li[data-id="${id}"]`);
+    return card ? card.dataset.pinned === 'true' : false;
+}
+
+function clipSensitiveState(id) {
+    const card = gallery.querySelector(`li[data-id="${id}"]`);
+    return card ? card.dataset.sensitive === 'true' : false;
+}
+
 async function bulkArchive() {
     if (selectedIds.size === 0) return;
+    if (isViewingTrash) {
+        restoreClips(Array.from(selectedIds));
+        return;
+    }
     try {
         await window.go.main.App.BulkArchive(Array.from(selectedIds));
         showToast(isViewingArchive ? `Restored ${selectedIds.size} clips.` : `Archived ${selectedIds.size} clips.`);
@@ -148,7 +260,8 @@ async function bulkDownload() {
 }
 
 // Helper function to convert File to FileData format
-async function fileToFileData(file) {
+// source is 'file' for picked or dropped files and 'paste' for pasted ones
+async function fileToFileData(file, source = 'file') {
     return new Promise((resolve, reject) => {
         const reader = new FileReader();
         reader.onload = () => {
@@ -157,7 +270,10 @@ async function fileToFileData(file) {
             resolve({
                 name: file.name,
                 content_type: file.type || 'application/octet-stream',
-                data: base64
+                data: base64,
+                source: source,
+                // Pasted files get the paste time, which says nothing about the original
+                last_modified: source === 'file' ? file.lastModified : undefined
             });
         };
         reader.onerror = reject;
@@ -198,6 +314,36 @@ async function getAllTags() {
     }
 }
 
+async function getSavedSearches() {
+    try {
+        return await window.go.main.App.GetSavedSearches();
+    } catch (error) {
+        console.error('Error getting saved searches:', error);
+        return [];
+    }
+}
+
+async function createSavedSearch(name, filter) {
+    try {
+        const search = await window.go.main.App.CreateSavedSearch(name, filter);
+        showToast(`Search "${name}" saved.`);
+        return search;
+    } catch (error) {
+        console.error('Error saving search:', error);
+        showToast(error.message || String(error) || 'Failed to save search.');
+        return null;
+    }
+}
+
+async function deleteSavedSearch(id) {
+    try {
+        await window.go.main.App.DeleteSavedSearch(id);
+    } catch (error) {
+        console.error('Error deleting saved search:', error);
+        showToast(error.message || 'Failed to delete saved search.');
+    }
+}
+
 async function createTag(name) {
     try {
         const tag = await window.go.main.App.CreateTag(name);
@@ -465,10 +465,7 @@ window.runtime.EventsOn('watch:error', (data) => {
 });
 
 window.runtime.EventsOn('watch:import', (filename) => {
-    // Refresh clips if not viewing watch or archive
-    if (!isViewingWatch && !isViewingArchive) {
-        loadClips();
-    }
+    // The gallery is refreshed by the clips:changed event
     showToast(`Imported: ${filename}`);
 });
 
@@ -1,16 +1,21 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 import {main} from '../models';
+import {store} from '../models';
+
+export function AddClipsToCollection(arg1:number,arg2:Array<number>):Promise<void>;
 
 export function AddTagToClip(arg1:number,arg2:number):Promise<void>;
 
 export function AddWatchedFolder(arg1:main.WatchedFolderConfig):Promise<main.WatchedFolder>;
 
+export function ApplyRetention():Promise<main.RetentionPreview>;
+
 export function BulkAddTag(arg1:Array<number>,arg2:number):Promise<void>;
 
 export function BulkArchive(arg1:Array<number>):Promise<void>;
 
-export function BulkDelete(arg1:Array<number>):Promise<void>;
+export function BulkDelete(arg1:Array<number>,arg2:boolean):Promise<number>;
 
 export function BulkDownloadToFile(arg1:Array<number>):Promise<void>;
 
@@ -18,12 +23,20 @@ export function BulkRemoveTag(arg1:Array<number>,arg2:number):Promise<void>;
 
 export function CancelExpiration(arg1:number):Promise<void>;
 
+export function ChangeEncryptionKey(arg1:store.MasterKey,arg2:store.MasterKey):Promise<void>;
+
 export function ConfirmRestoreBackup(arg1:string):Promise<void>;
 
 export function CopyToClipboard(arg1:string):Promise<void>;
 
 export function CreateBackup(arg1:string):Promise<void>;
 
+export function CreateCollection(arg1:string,arg2:string):Promise<main.Collection>;
+
+export function CreateKeyFile():Promise<string>;
+
+export function CreateSavedSearch(arg1:string,arg2:store.Filter):Promise<main.SavedSearch>;
+
 export function CreateTag(arg1:string):Promise<main.Tag>;
 
 export function CreateTempFile(arg1:number):Promise<string>;
@@ -32,10 +45,32 @@ export function DeleteAllTempFiles():Promise<void>;
 
 export function DeleteClip(arg1:number):Promise<void>;
 
+export function DeleteCollection(arg1:number):Promise<void>;
+
+export function DeleteSavedSearch(arg1:number):Promise<void>;
+
 export function DeleteTag(arg1:number):Promise<void>;
 
+export function DiffClipRevisions(arg1:number,arg2:number,arg3:number):Promise<Array<main.DiffLine>>;
+
+export function DisableEncryption(arg1:store.MasterKey):Promise<void>;
+
+export function EmptyTrash():Promise<number>;
+
+export function EnableEncryption(arg1:store.MasterKey):Promise<void>;
+
+export function ExportAuditLog(arg1:store.AuditFilter):Promise<string>;
+
+export function GetAuditLog(arg1:store.AuditFilter):Promise<Array<store.AuditEntry>>;
+
+export function GetClipCollections(arg1:number):Promise<Array<main.Collection>>;
+
 export function GetClipData(arg1:number):Promise<main.ClipData>;
 
+export function GetClipRevisionData(arg1:number,arg2:number):Promise<main.ClipData>;
+
+export function GetClipRevisions(arg1:number):Promise<Array<main.ClipRevision>>;
+
 export function GetClipTags(arg1:number):Promise<Array<main.Tag>>;
 
 export function GetClipboardImage():Promise<string>;
@@ -44,12 +79,32 @@ export function GetClipboardText():Promise<string>;
 
 export function GetClips(arg1:boolean,arg2:Array<number>):Promise<Array<main.ClipPreview>>;
 
+export function GetCollections():Promise<Array<main.Collection>>;
+
+export function GetDerivedClips(arg1:number):Promise<Array<number>>;
+
+export function GetEncryptionStatus():Promise<store.EncryptionStatus>;
+
 export function GetGlobalWatchPaused():Promise<boolean>;
 
+export function GetRetentionPolicy():Promise<store.RetentionPolicy>;
+
+export function GetSavedSearches():Promise<Array<main.SavedSearch>>;
+
+export function GetSecretPolicy():Promise<store.SecretPolicy>;
+
+export function GetSecretRules():Promise<Array<string>>;
+
+export function GetSensitiveExpireMinutes():Promise<number>;
+
 export function GetSetting(arg1:string):Promise<string>;
 
 export function GetTags():Promise<Array<main.Tag>>;
 
+export function GetTrash():Promise<Array<main.ClipPreview>>;
+
+export function GetTrashRetentionDays():Promise<number>;
+
 export function GetWatchStatus():Promise<main.WatchStatus>;
 
 export function GetWatchedFolderByID(arg1:number):Promise<main.WatchedFolder>;
@@ -58,34 +113,80 @@ export function GetWatchedFolders():Promise<Array<main.WatchedFolder>>;
 
 export function IsDirectory(arg1:string):Promise<boolean>;
 
+export function LockDatabase():Promise<void>;
+
+export function PreviewRetention(arg1:store.RetentionPolicy):Promise<main.RetentionPreview>;
+
 export function ProcessExistingFilesInFolder(arg1:number):Promise<void>;
 
+export function PurgeClips(arg1:Array<number>):Promise<void>;
+
 export function ReadFileFromPath(arg1:string):Promise<main.FileData>;
 
 export function RefreshWatches():Promise<void>;
 
+export function RemoveClipsFromCollection(arg1:number,arg2:Array<number>):Promise<void>;
+
 export function RemoveTagFromClip(arg1:number,arg2:number):Promise<void>;
 
 export function RemoveWatchedFolder(arg1:number):Promise<void>;
 
+export function ReorderClips(arg1:Array<number>):Promise<void>;
+
+export function ReorderCollection(arg1:number,arg2:Array<number>):Promise<void>;
+
+export function RescanSecrets():Promise<number>;
+
 export function RestoreBackup(arg1:string):Promise<void>;
 
+export function RestoreClipRevision(arg1:number,arg2:number):Promise<void>;
+
+export function RestoreClips(arg1:Array<number>):Promise<void>;
+
+export function RevealClip(arg1:number):Promise<main.ClipData>;
+
+export function RunSavedSearch(arg1:number):Promise<Array<main.ClipPreview>>;
+
 export function SaveClipToFile(arg1:number):Promise<void>;
 
+export function SaveEditedClip(arg1:number,arg2:main.FileData,arg3:boolean):Promise<number>;
+
+export function SearchClips(arg1:store.Filter):Promise<Array<main.ClipPreview>>;
+
 export function SelectFolder():Promise<string>;
 
+export function SelectKeyFile():Promise<string>;
+
+export function SetClipPinned(arg1:number,arg2:boolean):Promise<void>;
+
+export function SetClipsSensitive(arg1:Array<number>,arg2:boolean):Promise<void>;
+
 export function SetFolderPaused(arg1:number,arg2:boolean):Promise<void>;
 
 export function SetGlobalWatchPaused(arg1:boolean):Promise<void>;
 
+export function SetRetentionPolicy(arg1:store.RetentionPolicy):Promise<void>;
+
+export function SetSecretPolicy(arg1:store.SecretPolicy):Promise<void>;
+
+export function SetSensitiveExpireMinutes(arg1:number):Promise<void>;
+
 export function SetSetting(arg1:string,arg2:string):Promise<void>;
 
+export function SetTrashRetentionDays(arg1:number):Promise<void>;
+
 export function ShowCreateBackupDialog():Promise<string>;
 
 export function ShowRestoreBackupDialog():Promise<main.BackupManifest>;
 
 export function ToggleArchive(arg1:number):Promise<void>;
 
+export function UnlockDatabase(arg1:store.MasterKey):Promise<void>;
+
+export function UpdateCollection(arg1:number,arg2:string,arg3:string):Promise<void>;
+
+export function UpdateSavedSearch(arg1:number,arg2:string,arg3:store.Filter):Promise<void>;
+
 export function UpdateTag(arg1:number,arg2:string,arg3:string):Promise<void>;
 
 export function UpdateWatchedFolder(arg1:number,arg2:main.WatchedFolderConfig):Promise<void>;
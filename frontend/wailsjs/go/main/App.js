@@ -2,6 +2,10 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
+export function AddClipsToCollection(arg1, arg2) {
+  return window['go']['main']['App']['AddClipsToCollection'](arg1, arg2);
+}
+
 export function AddTagToClip(arg1, arg2) {
   return window['go']['main']['App']['AddTagToClip'](arg1, arg2);
 }
@@ -10,6 +14,10 @@ export function AddWatchedFolder(arg1) {
   return window['go']['main']['App']['AddWatchedFolder'](arg1);
 }
 
+export function ApplyRetention() {
+  return window['go']['main']['App']['ApplyRetention']();
+}
+
 export function BulkAddTag(arg1, arg2) {
   return window['go']['main']['App']['BulkAddTag'](arg1, arg2);
 }
@@ -18,8 +26,8 @@ export function BulkArchive(arg1) {
   return window['go']['main']['App']['BulkArchive'](arg1);
 }
 
-export function BulkDelete(arg1) {
-  return window['go']['main']['App']['BulkDelete'](arg1);
+export function BulkDelete(arg1, arg2) {
+  return window['go']['main']['App']['BulkDelete'](arg1, arg2);
 }
 
 export function BulkDownloadToFile(arg1) {
@@ -34,6 +42,10 @@ export function CancelExpiration(arg1) {
   return window['go']['main']['App']['CancelExpiration'](arg1);
 }
 
+export function ChangeEncryptionKey(arg1, arg2) {
+  return window['go']['main']['App']['ChangeEncryptionKey'](arg1, arg2);
+}
+
 export function ConfirmRestoreBackup(arg1) {
   return window['go']['main']['App']['ConfirmRestoreBackup'](arg1);
 }
@@ -46,6 +58,18 @@ export function CreateBackup(arg1) {
   return window['go']['main']['App']['CreateBackup'](arg1);
 }
 
+export function CreateCollection(arg1, arg2) {
+  return window['go']['main']['App']['CreateCollection'](arg1, arg2);
+}
+
+export function CreateKeyFile() {
+  return window['go']['main']['App']['CreateKeyFile']();
+}
+
+export function CreateSavedSearch(arg1, arg2) {
+  return window['go']['main']['App']['CreateSavedSearch'](arg1, arg2);
+}
+
 export function CreateTag(arg1) {
   return window['go']['main']['App']['CreateTag'](arg1);
 }
@@ -62,14 +86,58 @@ export function DeleteClip(arg1) {
   return window['go']['main']['App']['DeleteClip'](arg1);
 }
 
+export function DeleteCollection(arg1) {
+  return window['go']['main']['App']['DeleteCollection'](arg1);
+}
+
+export function DeleteSavedSearch(arg1) {
+  return window['go']['main']['App']['DeleteSavedSearch'](arg1);
+}
+
 export function DeleteTag(arg1) {
   return window['go']['main']['App']['DeleteTag'](arg1);
 }
 
+export function DiffClipRevisions(arg1, arg2, arg3) {
+  return window['go']['main']['App']['DiffClipRevisions'](arg1, arg2, arg3);
+}
+
+export function DisableEncryption(arg1) {
+  return window['go']['main']['App']['DisableEncryption'](arg1);
+}
+
+export function EmptyTrash() {
+  return window['go']['main']['App']['EmptyTrash']();
+}
+
+export function EnableEncryption(arg1) {
+  return window['go']['main']['App']['EnableEncryption'](arg1);
+}
+
+export function ExportAuditLog(arg1) {
+  return window['go']['main']['App']['ExportAuditLog'](arg1);
+}
+
+export function GetAuditLog(arg1) {
+  return window['go']['main']['App']['GetAuditLog'](arg1);
+}
+
+export function GetClipCollections(arg1) {
+  return window['go']['main']['App']['GetClipCollections'](arg1);
+}
+
 export function GetClipData(arg1) {
   return window['go']['main']['App']['GetClipData'](arg1);
 }
 
+export function GetClipRevisionData(arg1, arg2) {
+  return window['go']['main']['App']['GetClipRevisionData'](arg1, arg2);
+}
+
+export function GetClipRevisions(arg1) {
+  return window['go']['main']['App']['GetClipRevisions'](arg1);
+}
+
 export function GetClipTags(arg1) {
   return window['go']['main']['App']['GetClipTags'](arg1);
 }
@@ -86,10 +154,42 @@ export function GetClips(arg1, arg2) {
   return window['go']['main']['App']['GetClips'](arg1, arg2);
 }
 
+export function GetCollections() {
+  return window['go']['main']['App']['GetCollections']();
+}
+
+export function GetDerivedClips(arg1) {
+  return window['go']['main']['App']['GetDerivedClips'](arg1);
+}
+
+export function GetEncryptionStatus() {
+  return window['go']['main']['App']['GetEncryptionStatus']();
+}
+
 export function GetGlobalWatchPaused() {
   return window['go']['main']['App']['GetGlobalWatchPaused']();
 }
 
+export function GetRetentionPolicy() {
+  return window['go']['main']['App']['GetRetentionPolicy']();
+}
+
+export function GetSavedSearches() {
+  return window['go']['main']['App']['GetSavedSearches']();
+}
+
+export function GetSecretPolicy() {
+  return window['go']['main']['App']['GetSecretPolicy']();
+}
+
+export function GetSecretRules() {
+  return window['go']['main']['App']['GetSecretRules']();
+}
+
+export function GetSensitiveExpireMinutes() {
+  return window['go']['main']['App']['GetSensitiveExpireMinutes']();
+}
+
 export function GetSetting(arg1) {
   return window['go']['main']['App']['GetSetting'](arg1);
 }
@@ -98,6 +198,14 @@ export function GetTags() {
   return window['go']['main']['App']['GetTags']();
 }
 
+export function GetTrash() {
+  return window['go']['main']['App']['GetTrash']();
+}
+
+export function GetTrashRetentionDays() {
+  return window['go']['main']['App']['GetTrashRetentionDays']();
+}
+
 export function GetWatchStatus() {
   return window['go']['main']['App']['GetWatchStatus']();
 }
@@ -114,10 +222,22 @@ export function IsDirectory(arg1) {
   return window['go']['main']['App']['IsDirectory'](arg1);
 }
 
+export function LockDatabase() {
+  return window['go']['main']['App']['LockDatabase']();
+}
+
+export function PreviewRetention(arg1) {
+  return window['go']['main']['App']['PreviewRetention'](arg1);
+}
+
 export function ProcessExistingFilesInFolder(arg1) {
   return window['go']['main']['App']['ProcessExistingFilesInFolder'](arg1);
 }
 
+export function PurgeClips(arg1) {
+  return window['go']['main']['App']['PurgeClips'](arg1);
+}
+
 export function ReadFileFromPath(arg1) {
   return window['go']['main']['App']['ReadFileFromPath'](arg1);
 }
@@ -126,6 +246,10 @@ export function RefreshWatches() {
   return window['go']['main']['App']['RefreshWatches']();
 }
 
+export function RemoveClipsFromCollection(arg1, arg2) {
+  return window['go']['main']['App']['RemoveClipsFromCollection'](arg1, arg2);
+}
+
 export function RemoveTagFromClip(arg1, arg2) {
   return window['go']['main']['App']['RemoveTagFromClip'](arg1, arg2);
 }
@@ -134,18 +258,66 @@ export function RemoveWatchedFolder(arg1) {
   return window['go']['main']['App']['RemoveWatchedFolder'](arg1);
 }
 
+export function ReorderClips(arg1) {
+  return window['go']['main']['App']['ReorderClips'](arg1);
+}
+
+export function ReorderCollection(arg1, arg2) {
+  return window['go']['main']['App']['ReorderCollection'](arg1, arg2);
+}
+
+export function RescanSecrets() {
+  return window['go']['main']['App']['RescanSecrets']();
+}
+
 export function RestoreBackup(arg1) {
   return window['go']['main']['App']['RestoreBackup'](arg1);
 }
 
+export function RestoreClipRevision(arg1, arg2) {
+  return window['go']['main']['App']['RestoreClipRevision'](arg1, arg2);
+}
+
+export function RestoreClips(arg1) {
+  return window['go']['main']['App']['RestoreClips'](arg1);
+}
+
+export function RevealClip(arg1) {
+  return window['go']['main']['App']['RevealClip'](arg1);
+}
+
+export function RunSavedSearch(arg1) {
+  return window['go']['main']['App']['RunSavedSearch'](arg1);
+}
+
 export function SaveClipToFile(arg1) {
   return window['go']['main']['App']['SaveClipToFile'](arg1);
 }
 
+export function SaveEditedClip(arg1, arg2, arg3) {
+  return window['go']['main']['App']['SaveEditedClip'](arg1, arg2, arg3);
+}
+
+export function SearchClips(arg1) {
+  return window['go']['main']['App']['SearchClips'](arg1);
+}
+
 export function SelectFolder() {
   return window['go']['main']['App']['SelectFolder']();
 }
 
+export function SelectKeyFile() {
+  return window['go']['main']['App']['SelectKeyFile']();
+}
+
+export function SetClipPinned(arg1, arg2) {
+  return window['go']['main']['App']['SetClipPinned'](arg1, arg2);
+}
+
+export function SetClipsSensitive(arg1, arg2) {
+  return window['go']['main']['App']['SetClipsSensitive'](arg1, arg2);
+}
+
 export function SetFolderPaused(arg1, arg2) {
   return window['go']['main']['App']['SetFolderPaused'](arg1, arg2);
 }
@@ -154,10 +326,26 @@ export function SetGlobalWatchPaused(arg1) {
   return window['go']['main']['App']['SetGlobalWatchPaused'](arg1);
 }
 
+export function SetRetentionPolicy(arg1) {
+  return window['go']['main']['App']['SetRetentionPolicy'](arg1);
+}
+
+export function SetSecretPolicy(arg1) {
+  return window['go']['main']['App']['SetSecretPolicy'](arg1);
+}
+
+export function SetSensitiveExpireMinutes(arg1) {
+  return window['go']['main']['App']['SetSensitiveExpireMinutes'](arg1);
+}
+
 export function SetSetting(arg1, arg2) {
   return window['go']['main']['App']['SetSetting'](arg1, arg2);
 }
 
+export function SetTrashRetentionDays(arg1) {
+  return window['go']['main']['App']['SetTrashRetentionDays'](arg1);
+}
+
 export function ShowCreateBackupDialog() {
   return window['go']['main']['App']['ShowCreateBackupDialog']();
 }
@@ -170,6 +358,18 @@ export function ToggleArchive(arg1) {
   return window['go']['main']['App']['ToggleArchive'](arg1);
 }
 
+export function UnlockDatabase(arg1) {
+  return window['go']['main']['App']['UnlockDatabase'](arg1);
+}
+
+export function UpdateCollection(arg1, arg2, arg3) {
+  return window['go']['main']['App']['UpdateCollection'](arg1, arg2, arg3);
+}
+
+export function UpdateSavedSearch(arg1, arg2, arg3) {
+  return window['go']['main']['App']['UpdateSavedSearch'](arg1, arg2, arg3);
+}
+
 export function UpdateTag(arg1, arg2, arg3) {
   return window['go']['main']['App']['UpdateTag'](arg1, arg2, arg3);
 }
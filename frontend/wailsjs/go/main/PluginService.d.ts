@@ -3,6 +3,12 @@
 import {plugin} from '../models';
 import {main} from '../models';
 
+export function AddTrustedKey(arg1:string,arg2:string):Promise<void>;
+
+export function CheckPluginUpdates():Promise<Array<plugin.PluginUpdate>>;
+
+export function ConfirmImportPlugin(arg1:string,arg2:Array<string>):Promise<main.PluginInfo>;
+
 export function DisablePlugin(arg1:number):Promise<void>;
 
 export function EnablePlugin(arg1:number):Promise<void>;
@@ -11,20 +17,48 @@ export function ExecutePluginAction(arg1:number,arg2:string,arg3:Array<number>,a
 
 export function GetAllPluginStorage(arg1:number):Promise<Record<string, string>>;
 
+export function GetBlockSensitiveUploads():Promise<boolean>;
+
+export function GetPluginNetworkLog(arg1:number,arg2:number):Promise<Array<plugin.NetworkLogEntry>>;
+
 export function GetPluginPermissions(arg1:number):Promise<Array<Record<string, string>>>;
 
+export function GetPluginRegistry():Promise<string>;
+
+export function GetPluginSchedules(arg1:number):Promise<Array<plugin.TaskStatus>>;
+
 export function GetPluginStorage(arg1:number,arg2:string):Promise<string>;
 
 export function GetPluginUIActions():Promise<main.UIActionsResponse>;
 
 export function GetPlugins():Promise<Array<main.PluginInfo>>;
 
-export function ImportPlugin():Promise<main.PluginInfo>;
+export function GetRequireSignedPlugins():Promise<boolean>;
+
+export function GetTrustedKeys():Promise<Array<plugin.TrustedKey>>;
 
 export function ImportPluginFromPath(arg1:string):Promise<main.PluginInfo>;
 
 export function RemovePlugin(arg1:number):Promise<void>;
 
+export function RemoveTrustedKey(arg1:string):Promise<void>;
+
 export function RevokePluginPermission(arg1:number,arg2:string,arg3:string):Promise<void>;
 
+export function SetBlockSensitiveUploads(arg1:boolean):Promise<void>;
+
+export function SetPluginDomainApproved(arg1:number,arg2:string,arg3:boolean):Promise<void>;
+
+export function SetPluginRegistry(arg1:string):Promise<void>;
+
+export function SetPluginScopeGranted(arg1:number,arg2:string,arg3:boolean):Promise<void>;
+
+export function SetPluginSensitiveClipsAccess(arg1:number,arg2:boolean):Promise<void>;
+
 export function SetPluginStorage(arg1:number,arg2:string,arg3:string):Promise<void>;
+
+export function SetRequireSignedPlugins(arg1:boolean):Promise<void>;
+
+export function ShowImportPluginDialog():Promise<main.PluginImportPreview>;
+
+export function UpgradePlugin(arg1:number,arg2:boolean):Promise<main.PluginInfo>;
@@ -2,6 +2,18 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
+export function AddTrustedKey(arg1, arg2) {
+  return window['go']['main']['PluginService']['AddTrustedKey'](arg1, arg2);
+}
+
+export function CheckPluginUpdates() {
+  return window['go']['main']['PluginService']['CheckPluginUpdates']();
+}
+
+export function ConfirmImportPlugin(arg1, arg2) {
+  return window['go']['main']['PluginService']['ConfirmImportPlugin'](arg1, arg2);
+}
+
 export function DisablePlugin(arg1) {
   return window['go']['main']['PluginService']['DisablePlugin'](arg1);
 }
@@ -18,10 +30,26 @@ export function GetAllPluginStorage(arg1) {
   return window['go']['main']['PluginService']['GetAllPluginStorage'](arg1);
 }
 
+export function GetBlockSensitiveUploads() {
+  return window['go']['main']['PluginService']['GetBlockSensitiveUploads']();
+}
+
+export function GetPluginNetworkLog(arg1, arg2) {
+  return window['go']['main']['PluginService']['GetPluginNetworkLog'](arg1, arg2);
+}
+
 export function GetPluginPermissions(arg1) {
   return window['go']['main']['PluginService']['GetPluginPermissions'](arg1);
 }
 
+export function GetPluginRegistry() {
+  return window['go']['main']['PluginService']['GetPluginRegistry']();
+}
+
+export function GetPluginSchedules(arg1) {
+  return window['go']['main']['PluginService']['GetPluginSchedules'](arg1);
+}
+
 export function GetPluginStorage(arg1, arg2) {
   return window['go']['main']['PluginService']['GetPluginStorage'](arg1, arg2);
 }
@@ -34,8 +62,12 @@ export function GetPlugins() {
   return window['go']['main']['PluginService']['GetPlugins']();
 }
 
-export function ImportPlugin() {
-  return window['go']['main']['PluginService']['ImportPlugin']();
+export function GetRequireSignedPlugins() {
+  return window['go']['main']['PluginService']['GetRequireSignedPlugins']();
+}
+
+export function GetTrustedKeys() {
+  return window['go']['main']['PluginService']['GetTrustedKeys']();
 }
 
 export function ImportPluginFromPath(arg1) {
@@ -46,10 +78,46 @@ export function RemovePlugin(arg1) {
   return window['go']['main']['PluginService']['RemovePlugin'](arg1);
 }
 
+export function RemoveTrustedKey(arg1) {
+  return window['go']['main']['PluginService']['RemoveTrustedKey'](arg1);
+}
+
 export function RevokePluginPermission(arg1, arg2, arg3) {
   return window['go']['main']['PluginService']['RevokePluginPermission'](arg1, arg2, arg3);
 }
 
+export function SetBlockSensitiveUploads(arg1) {
+  return window['go']['main']['PluginService']['SetBlockSensitiveUploads'](arg1);
+}
+
+export function SetPluginDomainApproved(arg1, arg2, arg3) {
+  return window['go']['main']['PluginService']['SetPluginDomainApproved'](arg1, arg2, arg3);
+}
+
+export function SetPluginRegistry(arg1) {
+  return window['go']['main']['PluginService']['SetPluginRegistry'](arg1);
+}
+
+export function SetPluginScopeGranted(arg1, arg2, arg3) {
+  return window['go']['main']['PluginService']['SetPluginScopeGranted'](arg1, arg2, arg3);
+}
+
+export function SetPluginSensitiveClipsAccess(arg1, arg2) {
+  return window['go']['main']['PluginService']['SetPluginSensitiveClipsAccess'](arg1, arg2);
+}
+
 export function SetPluginStorage(arg1, arg2, arg3) {
   return window['go']['main']['PluginService']['SetPluginStorage'](arg1, arg2, arg3);
 }
+
+export function SetRequireSignedPlugins(arg1) {
+  return window['go']['main']['PluginService']['SetRequireSignedPlugins'](arg1);
+}
+
+export function ShowImportPluginDialog() {
+  return window['go']['main']['PluginService']['ShowImportPluginDialog']();
+}
+
+export function UpgradePlugin(arg1, arg2) {
+  return window['go']['main']['PluginService']['UpgradePlugin'](arg1, arg2);
+}
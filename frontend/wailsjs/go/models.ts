@@ -3,8 +3,10 @@ export namespace main {
 	export class BackupSummary {
 	    clips: number;
 	    tags: number;
+	    collections: number;
 	    plugins: number;
 	    watch_folders: number;
+	    secret_clips: number;
 	
 	    static createFrom(source: any = {}) {
 	        return new BackupSummary(source);
@@ -14,8 +16,10 @@ export namespace main {
 	        if ('string' === typeof source) source = JSON.parse(source);
 	        this.clips = source["clips"];
 	        this.tags = source["tags"];
+	        this.collections = source["collections"];
 	        this.plugins = source["plugins"];
 	        this.watch_folders = source["watch_folders"];
+	        this.secret_clips = source["secret_clips"];
 	    }
 	}
 	export class BackupManifest {
@@ -60,11 +64,58 @@ export namespace main {
 		}
 	}
 	
+	export class ClipSource {
+	    kind: string;
+	    path?: string;
+	    url?: string;
+	    plugin_id?: number;
+	    action?: string;
+	    // Go type: time
+	    modified_at?: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new ClipSource(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.kind = source["kind"];
+	        this.path = source["path"];
+	        this.url = source["url"];
+	        this.plugin_id = source["plugin_id"];
+	        this.action = source["action"];
+	        this.modified_at = this.convertValues(source["modified_at"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
 	export class ClipData {
 	    id: number;
 	    content_type: string;
 	    data: string;
 	    filename: string;
+	    parent_id?: number;
+	    source?: ClipSource;
+	    width?: number;
+	    height?: number;
+	    line_count?: number;
+	    language?: string;
 	
 	    static createFrom(source: any = {}) {
 	        return new ClipData(source);
@@ -76,7 +127,31 @@ export namespace main {
 	        this.content_type = source["content_type"];
 	        this.data = source["data"];
 	        this.filename = source["filename"];
+	        this.parent_id = source["parent_id"];
+	        this.source = this.convertValues(source["source"], ClipSource);
+	        this.width = source["width"];
+	        this.height = source["height"];
+	        this.line_count = source["line_count"];
+	        this.language = source["language"];
 	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
 	}
 	export class Tag {
 	    id: number;
@@ -106,7 +181,13 @@ export namespace main {
 	    expires_at?: any;
 	    preview: string;
 	    is_archived: boolean;
+	    is_pinned: boolean;
 	    tags: Tag[];
+	    source_kind: string;
+	    // Go type: time
+	    deleted_at?: any;
+	    secrets?: string[];
+	    is_sensitive: boolean;
 	
 	    static createFrom(source: any = {}) {
 	        return new ClipPreview(source);
@@ -121,7 +202,51 @@ export namespace main {
 	        this.expires_at = this.convertValues(source["expires_at"], null);
 	        this.preview = source["preview"];
 	        this.is_archived = source["is_archived"];
+	        this.is_pinned = source["is_pinned"];
 	        this.tags = this.convertValues(source["tags"], Tag);
+	        this.source_kind = source["source_kind"];
+	        this.deleted_at = this.convertValues(source["deleted_at"], null);
+	        this.secrets = source["secrets"];
+	        this.is_sensitive = source["is_sensitive"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ClipRevision {
+	    id: number;
+	    content_type: string;
+	    filename: string;
+	    size: number;
+	    // Go type: time
+	    created_at: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new ClipRevision(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.content_type = source["content_type"];
+	        this.filename = source["filename"];
+	        this.size = source["size"];
+	        this.created_at = this.convertValues(source["created_at"], null);
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
@@ -142,10 +267,67 @@ export namespace main {
 		    return a;
 		}
 	}
+	
+	export class Collection {
+	    id: number;
+	    name: string;
+	    description: string;
+	    count: number;
+	    // Go type: time
+	    created_at: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new Collection(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.description = source["description"];
+	        this.count = source["count"];
+	        this.created_at = this.convertValues(source["created_at"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class DiffLine {
+	    op: string;
+	    text: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new DiffLine(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.op = source["op"];
+	        this.text = source["text"];
+	    }
+	}
 	export class FileData {
 	    name: string;
 	    content_type: string;
 	    data: string;
+	    source?: string;
+	    path?: string;
+	    last_modified?: number;
 	
 	    static createFrom(source: any = {}) {
 	        return new FileData(source);
@@ -156,7 +338,54 @@ export namespace main {
 	        this.name = source["name"];
 	        this.content_type = source["content_type"];
 	        this.data = source["data"];
+	        this.source = source["source"];
+	        this.path = source["path"];
+	        this.last_modified = source["last_modified"];
+	    }
+	}
+	export class PluginImportPreview {
+	    path: string;
+	    name: string;
+	    version: string;
+	    description: string;
+	    author: string;
+	    scopes: string[];
+	    scope_limits: plugin.ScopeLimits;
+	    network: Record<string, Array<string>>;
+	
+	    static createFrom(source: any = {}) {
+	        return new PluginImportPreview(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.path = source["path"];
+	        this.name = source["name"];
+	        this.version = source["version"];
+	        this.description = source["description"];
+	        this.author = source["author"];
+	        this.scopes = source["scopes"];
+	        this.scope_limits = this.convertValues(source["scope_limits"], plugin.ScopeLimits);
+	        this.network = source["network"];
 	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
 	}
 	export class PluginInfo {
 	    id: number;
@@ -166,8 +395,15 @@ export namespace main {
 	    author: string;
 	    enabled: boolean;
 	    status: string;
+	    signature: string;
 	    events: string[];
 	    settings: plugin.SettingField[];
+	    sensitive_clips: boolean;
+	    scopes: string[];
+	    scope_limits: plugin.ScopeLimits;
+	    pending_scopes: string[];
+	    network: Record<string, Array<string>>;
+	    pending_domains: string[];
 	
 	    static createFrom(source: any = {}) {
 	        return new PluginInfo(source);
@@ -182,8 +418,15 @@ export namespace main {
 	        this.author = source["author"];
 	        this.enabled = source["enabled"];
 	        this.status = source["status"];
+	        this.signature = source["signature"];
 	        this.events = source["events"];
 	        this.settings = this.convertValues(source["settings"], plugin.SettingField);
+	        this.sensitive_clips = source["sensitive_clips"];
+	        this.scopes = source["scopes"];
+	        this.scope_limits = this.convertValues(source["scope_limits"], plugin.ScopeLimits);
+	        this.pending_scopes = source["pending_scopes"];
+	        this.network = source["network"];
+	        this.pending_domains = source["pending_domains"];
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
@@ -246,6 +489,122 @@ export namespace main {
 		    return a;
 		}
 	}
+	export class RetentionClip {
+	    id: number;
+	    content_type: string;
+	    filename: string;
+	    size: number;
+	    // Go type: time
+	    created_at: any;
+	    reason: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new RetentionClip(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.content_type = source["content_type"];
+	        this.filename = source["filename"];
+	        this.size = source["size"];
+	        this.created_at = this.convertValues(source["created_at"], null);
+	        this.reason = source["reason"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class RetentionPreview {
+	    count: number;
+	    freed_bytes: number;
+	    by_reason: Record<string, number>;
+	    clips: RetentionClip[];
+	
+	    static createFrom(source: any = {}) {
+	        return new RetentionPreview(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.count = source["count"];
+	        this.freed_bytes = source["freed_bytes"];
+	        this.by_reason = source["by_reason"];
+	        this.clips = this.convertValues(source["clips"], RetentionClip);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class SavedSearch {
+	    id: number;
+	    name: string;
+	    filter: store.Filter;
+	    count: number;
+	    // Go type: time
+	    created_at: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new SavedSearch(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.filter = this.convertValues(source["filter"], store.Filter);
+	        this.count = source["count"];
+	        this.created_at = this.convertValues(source["created_at"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
 	
 	export class UIActionsResponse {
 	    lightbox_buttons: PluginUIAction[];
@@ -453,6 +812,87 @@ export namespace plugin {
 		    return a;
 		}
 	}
+	export class NetworkLogEntry {
+	    id: number;
+	    plugin_id: number;
+	    // Go type: time
+	    created_at: any;
+	    method: string;
+	    host: string;
+	    status: number;
+	    bytes_out: number;
+	    bytes_in: number;
+	    error?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new NetworkLogEntry(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.plugin_id = source["plugin_id"];
+	        this.created_at = this.convertValues(source["created_at"], null);
+	        this.method = source["method"];
+	        this.host = source["host"];
+	        this.status = source["status"];
+	        this.bytes_out = source["bytes_out"];
+	        this.bytes_in = source["bytes_in"];
+	        this.error = source["error"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class PluginUpdate {
+	    plugin_id: number;
+	    name: string;
+	    current_version: string;
+	    latest_version: string;
+	    description?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new PluginUpdate(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.plugin_id = source["plugin_id"];
+	        this.name = source["name"];
+	        this.current_version = source["current_version"];
+	        this.latest_version = source["latest_version"];
+	        this.description = source["description"];
+	    }
+	}
+	export class ScopeLimits {
+	    content_types?: string[];
+	    tags?: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new ScopeLimits(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.content_types = source["content_types"];
+	        this.tags = source["tags"];
+	    }
+	}
 	export class SettingField {
 	    key: string;
 	    type: string;
@@ -475,6 +915,276 @@ export namespace plugin {
 	        this.options = source["options"];
 	    }
 	}
+	export class TaskStatus {
+	    name: string;
+	    interval?: number;
+	    cron?: string;
+	    timezone?: string;
+	    next_run?: string;
+	    last_run?: string;
+	    last_result?: string;
+	    last_error?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new TaskStatus(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.interval = source["interval"];
+	        this.cron = source["cron"];
+	        this.timezone = source["timezone"];
+	        this.next_run = source["next_run"];
+	        this.last_run = source["last_run"];
+	        this.last_result = source["last_result"];
+	        this.last_error = source["last_error"];
+	    }
+	}
+	export class TrustedKey {
+	    name: string;
+	    public_key: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new TrustedKey(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.public_key = source["public_key"];
+	    }
+	}
+
+}
+
+export namespace store {
+	
+	export class AuditEntry {
+	    id: number;
+	    // Go type: time
+	    created_at: any;
+	    action: string;
+	    actor: string;
+	    plugin_id?: number;
+	    job?: string;
+	    target_type?: string;
+	    target_id?: number;
+	    details?: Record<string, any>;
+	
+	    static createFrom(source: any = {}) {
+	        return new AuditEntry(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.created_at = this.convertValues(source["created_at"], null);
+	        this.action = source["action"];
+	        this.actor = source["actor"];
+	        this.plugin_id = source["plugin_id"];
+	        this.job = source["job"];
+	        this.target_type = source["target_type"];
+	        this.target_id = source["target_id"];
+	        this.details = source["details"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class AuditFilter {
+	    action: string;
+	    actor: string;
+	    plugin_id: number;
+	    target_type: string;
+	    target_id: number;
+	    since: number;
+	    until: number;
+	    limit: number;
+	    offset: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new AuditFilter(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.action = source["action"];
+	        this.actor = source["actor"];
+	        this.plugin_id = source["plugin_id"];
+	        this.target_type = source["target_type"];
+	        this.target_id = source["target_id"];
+	        this.since = source["since"];
+	        this.until = source["until"];
+	        this.limit = source["limit"];
+	        this.offset = source["offset"];
+	    }
+	}
+	export class EncryptionStatus {
+	    enabled: boolean;
+	    locked: boolean;
+	    key_source: string;
+	    key_file: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new EncryptionStatus(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.enabled = source["enabled"];
+	        this.locked = source["locked"];
+	        this.key_source = source["key_source"];
+	        this.key_file = source["key_file"];
+	    }
+	}
+	export class Filter {
+	    archived: boolean;
+	    trashed: boolean;
+	    text: string;
+	    tag_ids: number[];
+	    tag_query: string;
+	    content_type: string;
+	    created_after: number;
+	    created_before: number;
+	    within_days: number;
+	    min_size: number;
+	    max_size: number;
+	    source_kind: string;
+	    source_path: string;
+	    plugin_id: number;
+	    language: string;
+	    collection_id: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Filter(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.archived = source["archived"];
+	        this.trashed = source["trashed"];
+	        this.text = source["text"];
+	        this.tag_ids = source["tag_ids"];
+	        this.tag_query = source["tag_query"];
+	        this.content_type = source["content_type"];
+	        this.created_after = source["created_after"];
+	        this.created_before = source["created_before"];
+	        this.within_days = source["within_days"];
+	        this.min_size = source["min_size"];
+	        this.max_size = source["max_size"];
+	        this.source_kind = source["source_kind"];
+	        this.source_path = source["source_path"];
+	        this.plugin_id = source["plugin_id"];
+	        this.language = source["language"];
+	        this.collection_id = source["collection_id"];
+	    }
+	}
+	export class MasterKey {
+	    passphrase: string;
+	    key_file: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new MasterKey(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.passphrase = source["passphrase"];
+	        this.key_file = source["key_file"];
+	    }
+	}
+	export class TagRetentionRule {
+	    tag: string;
+	    max_age_days: number;
+	    keep: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new TagRetentionRule(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.tag = source["tag"];
+	        this.max_age_days = source["max_age_days"];
+	        this.keep = source["keep"];
+	    }
+	}
+	export class RetentionPolicy {
+	    max_age_days: number;
+	    untagged_max_age_days: number;
+	    max_clips: number;
+	    max_bytes: number;
+	    keep_archived: boolean;
+	    tag_rules: TagRetentionRule[];
+	
+	    static createFrom(source: any = {}) {
+	        return new RetentionPolicy(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.max_age_days = source["max_age_days"];
+	        this.untagged_max_age_days = source["untagged_max_age_days"];
+	        this.max_clips = source["max_clips"];
+	        this.max_bytes = source["max_bytes"];
+	        this.keep_archived = source["keep_archived"];
+	        this.tag_rules = this.convertValues(source["tag_rules"], TagRetentionRule);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class SecretPolicy {
+	    action: string;
+	    mask_previews: boolean;
+	    expire_minutes: number;
+	    include_in_backups: boolean;
+	    disabled_rules: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new SecretPolicy(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.action = source["action"];
+	        this.mask_previews = source["mask_previews"];
+	        this.expire_minutes = source["expire_minutes"];
+	        this.include_in_backups = source["include_in_backups"];
+	        this.disabled_rules = source["disabled_rules"];
+	    }
+	}
 
 }
 
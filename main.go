@@ -3,6 +3,7 @@ package main
 import (
 	"embed"
 	"log"
+	"os"
 
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
@@ -16,6 +17,11 @@ var assets embed.FS
 func main() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 
+	// Command line tools run without the app
+	if len(os.Args) > 1 && os.Args[1] == "plugin" {
+		os.Exit(runPluginCommand(os.Args[2:]))
+	}
+
 	// Create an instance of the app structure
 	app := NewApp()
 
@@ -0,0 +1,78 @@
+package plugin
+
+import (
+	lua "github.com/yuin/gopher-lua"
+)
+
+// BusAPI provides plugin-to-plugin messaging through the Manager
+type BusAPI struct {
+	manager  *Manager
+	pluginID int64
+	manifest *Manifest
+}
+
+// NewBusAPI creates a new bus API instance
+func NewBusAPI(manager *Manager, pluginID int64, manifest *Manifest) *BusAPI {
+	return &BusAPI{
+		manager:  manager,
+		pluginID: pluginID,
+		manifest: manifest,
+	}
+}
+
+// Register adds the bus module to the Lua state
+func (b *BusAPI) Register(L *lua.LState) {
+	busMod := L.NewTable()
+	busMod.RawSetString("publish", L.NewFunction(b.publish))
+	busMod.RawSetString("subscribe", L.NewFunction(b.subscribe))
+	busMod.RawSetString("call", L.NewFunction(b.call))
+	L.SetGlobal("bus", busMod)
+}
+
+// publish(topic, payload) -> true or nil, error
+func (b *BusAPI) publish(L *lua.LState) int {
+	topic := L.CheckString(1)
+	payload := luaToGo(L.Get(2))
+
+	if err := b.manager.Publish(b.pluginID, b.manifest, topic, payload); err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	L.Push(lua.LTrue)
+	return 1
+}
+
+// subscribe(topic) -> true or nil, error
+// Messages arrive in on_bus_message(topic, payload, sender)
+func (b *BusAPI) subscribe(L *lua.LState) int {
+	topic := L.CheckString(1)
+
+	if err := b.manager.Subscribe(b.pluginID, b.manifest, topic); err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	L.Push(lua.LTrue)
+	return 1
+}
+
+// call(plugin, method, args) -> result or nil, error
+// The target handles it in on_bus_call(method, args, caller)
+func (b *BusAPI) call(L *lua.LState) int {
+	target := L.CheckString(1)
+	method := L.CheckString(2)
+	args := luaToGo(L.Get(3))
+
+	result, err := b.manager.Call(b.pluginID, b.manifest, target, method, args)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	L.Push(goToLua(L, result))
+	return 1
+}
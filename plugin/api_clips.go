@@ -9,35 +9,50 @@ import (
 	"net/http"
 	"net/url"
 	"path"
-	"regexp"
 	"strings"
 	"time"
 
+	"go-clipboard/store"
+
 	lua "github.com/yuin/gopher-lua"
 )
 
-// maxContentTypeLength is the maximum length for a MIME content type string
-const maxContentTypeLength = 256
-
-// validMIMEType matches standard MIME type format (e.g. "application/json", "image/png")
-var validMIMEType = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9!#$&\-^_.+]*\/[a-zA-Z0-9][a-zA-Z0-9!#$&\-^_.+]*$`)
-
 const (
 	// MaxClipDataSize is the maximum size of data a plugin can create (10MB)
 	MaxClipDataSize = 10 * 1024 * 1024
 	// URLFetchTimeout is the timeout for downloading content from URLs
 	URLFetchTimeout = 60 * time.Second
+
+	// PermissionSensitiveClips is the plugin_permissions type granting a
+	// plugin access to clips marked sensitive
+	PermissionSensitiveClips = "sensitive_clips"
 )
 
-// ClipsAPI provides clip CRUD operations to plugins
+// ClipsAPI provides clip CRUD operations to plugins. Reads query the database
+// directly; changes go through the store so events fire as for the user's changes.
 type ClipsAPI struct {
 	db             *sql.DB
+	store          *store.Service
+	sandbox        *Sandbox // records which action created a clip
+	actor          store.Actor
+	scopes         *scopeChecker
 	allowedDomains map[string][]string // domain -> allowed methods (from manifest)
+	network        *networkGuard       // approves and records create_from_url downloads
 }
 
-// NewClipsAPI creates a new clips API instance
-func NewClipsAPI(db *sql.DB, allowedDomains map[string][]string) *ClipsAPI {
-	return &ClipsAPI{db: db, allowedDomains: allowedDomains}
+// NewClipsAPI creates a new clips API instance for the plugin running in
+// sandbox. Downloads are sent with transport, or the default one if nil, and
+// checked against the data of sensitive clips cached in sensitive.
+func NewClipsAPI(db *sql.DB, st *store.Service, sandbox *Sandbox, allowedDomains map[string][]string, transport http.RoundTripper, sensitive *sensitiveData) *ClipsAPI {
+	return &ClipsAPI{
+		db:             db,
+		store:          st,
+		sandbox:        sandbox,
+		actor:          store.Plugin(sandbox.GetPluginID()),
+		scopes:         newScopeChecker(db, sandbox.GetPluginID(), sandbox.GetManifest()),
+		allowedDomains: allowedDomains,
+		network:        newNetworkGuard(db, st, sandbox.GetPluginID(), allowedDomains, transport, sensitive),
+	}
 }
 
 // Register adds the clips module to the Lua state
@@ -52,15 +67,25 @@ func (c *ClipsAPI) Register(L *lua.LState) {
 	clipsMod.RawSetString("update", L.NewFunction(c.update))
 	clipsMod.RawSetString("delete", L.NewFunction(c.deleteClip))
 	clipsMod.RawSetString("delete_many", L.NewFunction(c.deleteMany))
+	clipsMod.RawSetString("restore", L.NewFunction(c.restore))
 	clipsMod.RawSetString("archive", L.NewFunction(c.archive))
 	clipsMod.RawSetString("unarchive", L.NewFunction(c.unarchive))
+	clipsMod.RawSetString("pin", L.NewFunction(c.pin))
+	clipsMod.RawSetString("unpin", L.NewFunction(c.unpin))
+	clipsMod.RawSetString("reorder", L.NewFunction(c.reorder))
 
 	L.SetGlobal("clips", clipsMod)
 }
 
 func (c *ClipsAPI) list(L *lua.LState) int {
-	// Optional filter table with content_type, limit, and offset
-	var contentTypeFilter string
+	if err := c.scopes.require(ScopeClipsRead); err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	// Optional filter table with content_type, tag_query, limit, and offset
+	var contentTypeFilter, tagQueryFilter string
 	limit := 100 // default limit
 	offset := 0  // default offset
 
@@ -69,6 +94,9 @@ func (c *ClipsAPI) list(L *lua.LState) int {
 			if ct := filter.RawGetString("content_type"); ct != lua.LNil {
 				contentTypeFilter = ct.String()
 			}
+			if tq := filter.RawGetString("tag_query"); tq != lua.LNil {
+				tagQueryFilter = tq.String()
+			}
 			if lim := filter.RawGetString("limit"); lim != lua.LNil {
 				if limNum, ok := lim.(lua.LNumber); ok {
 					limit = int(limNum)
@@ -91,14 +119,35 @@ func (c *ClipsAPI) list(L *lua.LState) int {
 		}
 	}
 
-	query := `SELECT id, content_type, filename, created_at, is_archived
-	          FROM clips WHERE (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)`
+	query := `SELECT id, content_type, filename, created_at, is_archived, is_pinned, is_sensitive
+	          FROM clips WHERE deleted_at IS NULL AND (is_pinned = 1 OR expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)`
 	args := []interface{}{}
 
+	if !c.canReadSensitive() {
+		query += " AND is_sensitive = 0"
+	}
+
+	// Clips outside the plugin's scope limits are left out
+	if condition, scopeArgs := c.scopes.clipCondition(); condition != "" {
+		query += " AND " + condition
+		args = append(args, scopeArgs...)
+	}
+
 	if contentTypeFilter != "" {
 		query += " AND content_type = ?"
 		args = append(args, contentTypeFilter)
 	}
+	tagQuery, err := store.ParseTagQuery(tagQueryFilter)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	if tagQuery != nil {
+		condition, tagArgs := tagQuery.Condition("id")
+		query += " AND " + condition
+		args = append(args, tagArgs...)
+	}
 	query += " ORDER BY created_at DESC LIMIT ? OFFSET ?"
 	args = append(args, limit, offset)
 
@@ -116,9 +165,9 @@ func (c *ClipsAPI) list(L *lua.LState) int {
 		var contentType string
 		var filename sql.NullString
 		var createdAt time.Time
-		var isArchived int
+		var isArchived, isPinned, isSensitive int
 
-		if err := rows.Scan(&id, &contentType, &filename, &createdAt, &isArchived); err != nil {
+		if err := rows.Scan(&id, &contentType, &filename, &createdAt, &isArchived, &isPinned, &isSensitive); err != nil {
 			log.Printf("clips.list: failed to scan row: %v", err)
 			continue
 		}
@@ -129,6 +178,8 @@ func (c *ClipsAPI) list(L *lua.LState) int {
 		clip.RawSetString("filename", lua.LString(filename.String))
 		clip.RawSetString("created_at", lua.LNumber(createdAt.Unix()))
 		clip.RawSetString("is_archived", lua.LBool(isArchived == 1))
+		clip.RawSetString("is_pinned", lua.LBool(isPinned == 1))
+		clip.RawSetString("is_sensitive", lua.LBool(isSensitive == 1))
 
 		result.Append(clip)
 	}
@@ -140,21 +191,41 @@ func (c *ClipsAPI) list(L *lua.LState) int {
 func (c *ClipsAPI) get(L *lua.LState) int {
 	id := L.CheckInt64(1)
 
+	if err := c.scopes.require(ScopeClipsRead); err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
 	var contentType string
 	var data []byte
+	var encrypted bool
 	var filename sql.NullString
 	var createdAt time.Time
-	var isArchived int
+	var isArchived, isPinned, isSensitive int
+	var parentID sql.NullInt64
 
 	err := c.db.QueryRow(`
-		SELECT content_type, data, filename, created_at, is_archived
-		FROM clips WHERE id = ?
-	`, id).Scan(&contentType, &data, &filename, &createdAt, &isArchived)
+		SELECT content_type, data, is_encrypted, filename, created_at, is_archived, is_pinned, is_sensitive, parent_id
+		FROM clips WHERE id = ? AND deleted_at IS NULL
+	`, id).Scan(&contentType, &data, &encrypted, &filename, &createdAt, &isArchived, &isPinned, &isSensitive, &parentID)
 
-	if err == sql.ErrNoRows {
+	// Sensitive clips look missing without the permission, and clips
+	// outside the scope limits always do
+	if err == sql.ErrNoRows || (err == nil && isSensitive == 1 && !c.canReadSensitive()) {
 		L.Push(lua.LNil)
 		return 1
 	}
+	if err == nil {
+		var inScope bool
+		if inScope, err = c.scopes.inLimits(id); err == nil && !inScope {
+			L.Push(lua.LNil)
+			return 1
+		}
+	}
+	if err == nil {
+		data, err = c.store.DecryptData(data, encrypted)
+	}
 	if err != nil {
 		L.Push(lua.LNil)
 		L.Push(lua.LString(err.Error()))
@@ -167,6 +238,11 @@ func (c *ClipsAPI) get(L *lua.LState) int {
 	clip.RawSetString("filename", lua.LString(filename.String))
 	clip.RawSetString("created_at", lua.LNumber(createdAt.Unix()))
 	clip.RawSetString("is_archived", lua.LBool(isArchived == 1))
+	clip.RawSetString("is_pinned", lua.LBool(isPinned == 1))
+	clip.RawSetString("is_sensitive", lua.LBool(isSensitive == 1))
+	if parentID.Valid {
+		clip.RawSetString("parent_id", lua.LNumber(parentID.Int64))
+	}
 
 	// For text content, return as-is; for binary, base64 encode
 	if strings.HasPrefix(contentType, "text/") || contentType == "application/json" {
@@ -176,27 +252,66 @@ func (c *ClipsAPI) get(L *lua.LState) int {
 		clip.RawSetString("data_encoding", lua.LString("base64"))
 	}
 
+	metadata, err := c.store.GetMetadata(id)
+	if err != nil {
+		log.Printf("clips.get: failed to load metadata for clip %d: %v", id, err)
+	}
+	metaTable := L.NewTable()
+	for key, value := range metadata {
+		metaTable.RawSetString(key, lua.LString(value))
+	}
+	clip.RawSetString("metadata", metaTable)
+
+	if info, err := c.store.GetClipInfo(id); err != nil {
+		log.Printf("clips.get: failed to load info for clip %d: %v", id, err)
+	} else {
+		clip.RawSetString("source", sourceToLua(L, info.Source))
+		if info.Width > 0 {
+			clip.RawSetString("width", lua.LNumber(info.Width))
+			clip.RawSetString("height", lua.LNumber(info.Height))
+		}
+		if info.LineCount > 0 {
+			clip.RawSetString("line_count", lua.LNumber(info.LineCount))
+		}
+		if info.Language != "" {
+			clip.RawSetString("language", lua.LString(info.Language))
+		}
+	}
+
 	L.Push(clip)
 	return 1
 }
 
+// sourceToLua converts a clip's provenance to a table, leaving out unknown fields
+func sourceToLua(L *lua.LState, src store.Source) *lua.LTable {
+	t := L.NewTable()
+	if src.Kind != "" {
+		t.RawSetString("kind", lua.LString(src.Kind))
+	}
+	if src.Path != "" {
+		t.RawSetString("path", lua.LString(src.Path))
+	}
+	if src.URL != "" {
+		t.RawSetString("url", lua.LString(src.URL))
+	}
+	if src.PluginID != 0 {
+		t.RawSetString("plugin_id", lua.LNumber(src.PluginID))
+	}
+	if src.Action != "" {
+		t.RawSetString("action", lua.LString(src.Action))
+	}
+	if src.ModTime != nil {
+		t.RawSetString("modified_at", lua.LNumber(src.ModTime.Unix()))
+	}
+	return t
+}
+
 // getData returns raw clip data (base64 for binary, plain for text)
 // Returns: data, mime_type or nil, error
 func (c *ClipsAPI) getData(L *lua.LState) int {
 	id := L.CheckInt64(1)
 
-	var contentType string
-	var data []byte
-
-	err := c.db.QueryRow(`
-		SELECT content_type, data FROM clips WHERE id = ?
-	`, id).Scan(&contentType, &data)
-
-	if err == sql.ErrNoRows {
-		L.Push(lua.LNil)
-		L.Push(lua.LString("clip not found"))
-		return 2
-	}
+	contentType, _, data, err := c.readClip(id)
 	if err != nil {
 		L.Push(lua.LNil)
 		L.Push(lua.LString(err.Error()))
@@ -213,100 +328,194 @@ func (c *ClipsAPI) getData(L *lua.LState) int {
 	return 2
 }
 
+// readClip returns a clip's content type, filename and decrypted data. It
+// requires clips.read, and clips the plugin can't see look missing.
+func (c *ClipsAPI) readClip(id int64) (string, string, []byte, error) {
+	if err := c.scopes.require(ScopeClipsRead); err != nil {
+		return "", "", nil, err
+	}
+
+	var contentType string
+	var filename sql.NullString
+	var data []byte
+	var encrypted, sensitive bool
+
+	err := c.db.QueryRow(`
+		SELECT content_type, filename, data, is_encrypted, is_sensitive FROM clips WHERE id = ? AND deleted_at IS NULL
+	`, id).Scan(&contentType, &filename, &data, &encrypted, &sensitive)
+
+	if err == sql.ErrNoRows || (err == nil && sensitive && !c.canReadSensitive()) {
+		return "", "", nil, fmt.Errorf("clip not found")
+	}
+	if err != nil {
+		return "", "", nil, err
+	}
+	inScope, err := c.scopes.inLimits(id)
+	if err != nil {
+		return "", "", nil, err
+	}
+	if !inScope {
+		return "", "", nil, fmt.Errorf("clip not found")
+	}
+	data, err = c.store.DecryptData(data, encrypted)
+	if err != nil {
+		return "", "", nil, err
+	}
+	return contentType, filename.String, data, nil
+}
+
+// checkParent fails unless the plugin can read the clip a new clip is
+// derived from. Like in clips.get, clips it can't see look missing.
+func (c *ClipsAPI) checkParent(id int64) error {
+	if err := c.scopes.require(ScopeClipsRead); err != nil {
+		return err
+	}
+
+	var sensitive bool
+	err := c.db.QueryRow("SELECT is_sensitive FROM clips WHERE id = ? AND deleted_at IS NULL", id).Scan(&sensitive)
+	if err == sql.ErrNoRows || (err == nil && sensitive && !c.canReadSensitive()) {
+		return fmt.Errorf("parent clip %d not found", id)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to query parent clip: %w", err)
+	}
+	inScope, err := c.scopes.inLimits(id)
+	if err != nil {
+		return err
+	}
+	if !inScope {
+		return fmt.Errorf("parent clip %d not found", id)
+	}
+	return nil
+}
+
+// canReadSensitive reports whether the plugin declared the sensitive_clips
+// permission and the user granted it
+func (c *ClipsAPI) canReadSensitive() bool {
+	return c.scopes.canReadSensitive()
+}
+
 func (c *ClipsAPI) create(L *lua.LState) int {
 	opts := L.CheckTable(1)
 
-	dataVal := opts.RawGetString("data")
-	if dataVal == lua.LNil {
+	if err := c.scopes.require(ScopeClipsWrite); err != nil {
 		L.Push(lua.LNil)
-		L.Push(lua.LString("data is required"))
+		L.Push(lua.LString(err.Error()))
 		return 2
 	}
-	dataStr := dataVal.String()
 
-	// Check size before processing
-	if len(dataStr) > MaxClipDataSize {
+	dataVal := opts.RawGetString("data")
+	if dataVal == lua.LNil {
 		L.Push(lua.LNil)
-		L.Push(lua.LString(fmt.Sprintf("data too large: %d bytes (max %d)", len(dataStr), MaxClipDataSize)))
+		L.Push(lua.LString("data is required"))
 		return 2
 	}
 
 	// Support both content_type and mime_type for flexibility
 	contentType := "application/octet-stream"
-	if ct := opts.RawGetString("content_type"); ct != lua.LNil {
-		contentType = ct.String()
-	} else if mt := opts.RawGetString("mime_type"); mt != lua.LNil {
-		contentType = mt.String()
+	if ct, ok := optContentType(opts); ok {
+		contentType = ct
+	}
+	if err := c.scopes.checkContentType(contentType); err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
 	}
-
-	// Validate content type format
-	contentType = validateContentType(contentType)
 
 	// Support both filename and name for flexibility
-	var filename string
-	if fn := opts.RawGetString("filename"); fn != lua.LNil {
-		filename = fn.String()
-	} else if nm := opts.RawGetString("name"); nm != lua.LNil {
-		filename = nm.String()
-	}
+	filename, _ := optFilename(opts)
 
-	// Determine if data is base64 encoded
-	// Check explicit encoding flag or auto-detect for binary content types
-	isBase64 := false
-	if enc := opts.RawGetString("data_encoding"); enc != lua.LNil && enc.String() == "base64" {
-		isBase64 = true
-	} else if !strings.HasPrefix(contentType, "text/") && contentType != "application/json" {
-		// For binary content types, assume base64 if not explicitly text
-		isBase64 = true
+	data, err := decodeClipData(dataVal.String(), contentType, opts)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
 	}
 
-	var data []byte
-	if isBase64 {
-		var err error
-		data, err = base64.StdEncoding.DecodeString(dataStr)
-		if err != nil {
-			L.Push(lua.LNil)
-			L.Push(lua.LString("invalid base64 data: " + err.Error()))
-			return 2
-		}
-		// Check decoded size as well
-		if len(data) > MaxClipDataSize {
+	// parent_id links the new clip to the clip it was derived from
+	var parentID int64
+	if pv, ok := opts.RawGetString("parent_id").(lua.LNumber); ok {
+		parentID = int64(pv)
+		if err := c.checkParent(parentID); err != nil {
 			L.Push(lua.LNil)
-			L.Push(lua.LString(fmt.Sprintf("decoded data too large: %d bytes (max %d)", len(data), MaxClipDataSize)))
+			L.Push(lua.LString(err.Error()))
 			return 2
 		}
-	} else {
-		data = []byte(dataStr)
 	}
 
-	result, err := c.db.Exec(
-		"INSERT INTO clips (content_type, data, filename) VALUES (?, ?, ?)",
-		contentType, data, filename,
-	)
+	created, err := c.store.CreateClip(c.actor, store.NewClip{
+		ContentType: contentType,
+		Data:        data,
+		Filename:    filename,
+		ParentID:    parentID,
+		Source:      store.Source{Action: c.sandbox.Action()},
+	})
 	if err != nil {
 		L.Push(lua.LNil)
 		L.Push(lua.LString(err.Error()))
 		return 2
 	}
 
-	id, _ := result.LastInsertId()
-
 	// Return a table with clip info (matching design spec)
 	clip := L.NewTable()
-	clip.RawSetString("id", lua.LNumber(id))
+	clip.RawSetString("id", lua.LNumber(created.ID))
 	L.Push(clip)
 	return 1
 }
 
-// validateContentType checks that a content type string is a valid MIME type
-func validateContentType(ct string) string {
-	if len(ct) > maxContentTypeLength {
-		return "application/octet-stream"
+// optContentType reads content_type (or mime_type) from an options table
+func optContentType(opts *lua.LTable) (string, bool) {
+	if ct := opts.RawGetString("content_type"); ct != lua.LNil {
+		return store.ValidateContentType(ct.String()), true
 	}
-	if !validMIMEType.MatchString(ct) {
-		return "application/octet-stream"
+	if mt := opts.RawGetString("mime_type"); mt != lua.LNil {
+		return store.ValidateContentType(mt.String()), true
 	}
-	return ct
+	return "", false
+}
+
+// optFilename reads filename (or name) from an options table
+func optFilename(opts *lua.LTable) (string, bool) {
+	if fn := opts.RawGetString("filename"); fn != lua.LNil {
+		return fn.String(), true
+	}
+	if nm := opts.RawGetString("name"); nm != lua.LNil {
+		return nm.String(), true
+	}
+	return "", false
+}
+
+// decodeClipData converts data passed by a plugin to bytes, enforcing the size limit.
+// Data is base64 if data_encoding says so or the content type is binary.
+func decodeClipData(dataStr, contentType string, opts *lua.LTable) ([]byte, error) {
+	// Check size before processing
+	if len(dataStr) > MaxClipDataSize {
+		return nil, fmt.Errorf("data too large: %d bytes (max %d)", len(dataStr), MaxClipDataSize)
+	}
+
+	// Determine if data is base64 encoded
+	// Check explicit encoding flag or auto-detect for binary content types
+	isBase64 := false
+	if enc := opts.RawGetString("data_encoding"); enc != lua.LNil && enc.String() == "base64" {
+		isBase64 = true
+	} else if !strings.HasPrefix(contentType, "text/") && contentType != "application/json" {
+		// For binary content types, assume base64 if not explicitly text
+		isBase64 = true
+	}
+
+	if !isBase64 {
+		return []byte(dataStr), nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(dataStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 data: %w", err)
+	}
+	// Check decoded size as well
+	if len(data) > MaxClipDataSize {
+		return nil, fmt.Errorf("decoded data too large: %d bytes (max %d)", len(data), MaxClipDataSize)
+	}
+	return data, nil
 }
 
 // checkURLDomain validates the URL domain against the plugin's network permissions
@@ -322,30 +531,20 @@ func (c *ClipsAPI) checkURLDomain(urlStr string) error {
 		return fmt.Errorf("no network permissions: plugin must declare network permissions to fetch URLs (domain: %s)", domain)
 	}
 
-	allowedMethods, ok := FindAllowedMethods(c.allowedDomains, domain)
-	if !ok {
-		return fmt.Errorf("domain not in allowlist: %s", domain)
-	}
-
-	// Check if GET is allowed for this domain
-	getAllowed := false
-	for _, m := range allowedMethods {
-		if strings.EqualFold(m, "GET") {
-			getAllowed = true
-			break
-		}
-	}
-	if !getAllowed {
-		return fmt.Errorf("GET not allowed for domain %s (allowed: [%s])", domain, strings.Join(allowedMethods, ", "))
-	}
-
-	return nil
+	// The domain must be approved by the user and allow GET
+	return c.network.checkURL(urlStr, "GET")
 }
 
 // createFromURL downloads content from a URL and creates a clip
 func (c *ClipsAPI) createFromURL(L *lua.LState) int {
 	rawURL := L.CheckString(1)
 
+	if err := c.scopes.require(ScopeClipsWrite); err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
 	// Validate URL scheme - only allow http and https
 	if !strings.HasPrefix(rawURL, "http://") && !strings.HasPrefix(rawURL, "https://") {
 		L.Push(lua.LNil)
@@ -353,29 +552,41 @@ func (c *ClipsAPI) createFromURL(L *lua.LState) int {
 		return 2
 	}
 
-	// Validate domain against plugin's network permissions
-	if err := c.checkURLDomain(rawURL); err != nil {
+	// Every download attempt is recorded in the network log
+	entry := NetworkLogEntry{Method: "GET", Host: hostOf(rawURL)}
+	fail := func(msg string) int {
+		entry.Error = msg
+		c.network.record(entry)
 		L.Push(lua.LNil)
-		L.Push(lua.LString(err.Error()))
+		L.Push(lua.LString(msg))
 		return 2
 	}
 
+	// Validate domain against plugin's network permissions
+	if err := c.checkURLDomain(rawURL); err != nil {
+		return fail(err.Error())
+	}
+	if err := c.network.checkSensitive(rawURL, nil); err != nil {
+		return fail(err.Error())
+	}
+
 	var opts *lua.LTable
 	if L.GetTop() >= 2 {
 		opts = L.OptTable(2, nil)
 	}
 
 	// Create HTTP client with timeout and redirect limits
-	allowedDomains := c.allowedDomains
+	network := c.network
 	client := &http.Client{
-		Timeout: URLFetchTimeout,
+		Transport: network.transport,
+		Timeout:   URLFetchTimeout,
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			if len(via) >= 5 {
 				return fmt.Errorf("too many redirects")
 			}
 			// Validate redirect domain against allowlist
 			domain := req.URL.Hostname()
-			if _, ok := FindAllowedMethods(allowedDomains, domain); !ok {
+			if _, err := network.checkApproved(domain); err != nil {
 				return fmt.Errorf("redirect to unauthorized domain: %s", domain)
 			}
 			return nil
@@ -384,26 +595,23 @@ func (c *ClipsAPI) createFromURL(L *lua.LState) int {
 
 	resp, err := client.Get(rawURL)
 	if err != nil {
-		L.Push(lua.LNil)
-		L.Push(lua.LString("failed to fetch URL: " + err.Error()))
-		return 2
+		return fail("failed to fetch URL: " + err.Error())
 	}
 	defer resp.Body.Close()
+	entry.Status = resp.StatusCode
 
 	if resp.StatusCode != http.StatusOK {
-		L.Push(lua.LNil)
-		L.Push(lua.LString(fmt.Sprintf("HTTP error: %d %s", resp.StatusCode, resp.Status)))
-		return 2
+		return fail(fmt.Sprintf("HTTP error: %d %s", resp.StatusCode, resp.Status))
 	}
 
 	// Read body with size limit
 	limitedReader := io.LimitReader(resp.Body, MaxClipDataSize+1)
 	data, err := io.ReadAll(limitedReader)
+	entry.BytesIn = int64(len(data))
 	if err != nil {
-		L.Push(lua.LNil)
-		L.Push(lua.LString("failed to read response: " + err.Error()))
-		return 2
+		return fail("failed to read response: " + err.Error())
 	}
+	c.network.record(entry)
 
 	if len(data) > MaxClipDataSize {
 		L.Push(lua.LNil)
@@ -411,8 +619,27 @@ func (c *ClipsAPI) createFromURL(L *lua.LState) int {
 		return 2
 	}
 
+	id, err := c.saveDownload(data, resp.Header.Get("Content-Type"), rawURL, opts)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	// Return a table with clip info
+	clip := L.NewTable()
+	clip.RawSetString("id", lua.LNumber(id))
+	L.Push(clip)
+	return 1
+}
+
+// saveDownload creates a clip from data downloaded from rawURL. opts can set
+// content_type (or mime_type), name (or filename) and parent_id; by default
+// the content type is the response's and the filename the URL's last path
+// element.
+func (c *ClipsAPI) saveDownload(data []byte, responseType, rawURL string, opts *lua.LTable) (int64, error) {
 	// Determine content type
-	contentType := resp.Header.Get("Content-Type")
+	contentType := responseType
 	if contentType == "" {
 		contentType = "application/octet-stream"
 	}
@@ -430,8 +657,9 @@ func (c *ClipsAPI) createFromURL(L *lua.LState) int {
 		}
 	}
 
-	// Validate content type format
-	contentType = validateContentType(contentType)
+	if err := c.scopes.checkContentType(contentType); err != nil {
+		return 0, err
+	}
 
 	// Determine filename
 	filename := ""
@@ -442,61 +670,139 @@ func (c *ClipsAPI) createFromURL(L *lua.LState) int {
 			filename = fn.String()
 		}
 	}
-	// Fallback to URL path
+	// Fallback to URL path, without the query
 	if filename == "" {
 		filename = path.Base(rawURL)
+		if parsed, err := url.Parse(rawURL); err == nil {
+			filename = path.Base(parsed.Path)
+		}
 		if filename == "." || filename == "/" {
 			filename = "downloaded"
 		}
 	}
 
-	// Insert into database
-	result, err := c.db.Exec(
-		"INSERT INTO clips (content_type, data, filename) VALUES (?, ?, ?)",
-		contentType, data, filename,
-	)
-	if err != nil {
-		L.Push(lua.LNil)
-		L.Push(lua.LString(err.Error()))
-		return 2
+	// parent_id links the new clip to the clip it was derived from
+	var parentID int64
+	if opts != nil {
+		if pv, ok := opts.RawGetString("parent_id").(lua.LNumber); ok {
+			parentID = int64(pv)
+			if err := c.checkParent(parentID); err != nil {
+				return 0, err
+			}
+		}
 	}
 
-	id, _ := result.LastInsertId()
-
-	// Return a table with clip info
-	clip := L.NewTable()
-	clip.RawSetString("id", lua.LNumber(id))
-	L.Push(clip)
-	return 1
+	created, err := c.store.CreateClip(c.actor, store.NewClip{
+		ContentType: contentType,
+		Data:        data,
+		Filename:    filename,
+		ParentID:    parentID,
+		Source:      store.Source{Kind: store.SourceURL, URL: rawURL, Action: c.sandbox.Action()},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return created.ID, nil
 }
 
+// update changes a clip's data, content type, filename, expiration,
+// metadata or archive state
 func (c *ClipsAPI) update(L *lua.LState) int {
 	id := L.CheckInt64(1)
 	opts := L.CheckTable(2)
 
-	// Only allow updating is_archived for now
-	if archived := opts.RawGetString("is_archived"); archived != lua.LNil {
-		archivedInt := 0
-		if archived == lua.LTrue {
-			archivedInt = 1
+	if err := c.scopes.checkClips(ScopeClipsWrite, id); err != nil {
+		L.Push(lua.LFalse)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	var u store.ClipUpdate
+
+	if ct, ok := optContentType(opts); ok {
+		if err := c.scopes.checkContentType(ct); err != nil {
+			L.Push(lua.LFalse)
+			L.Push(lua.LString(err.Error()))
+			return 2
 		}
-		_, err := c.db.Exec("UPDATE clips SET is_archived = ? WHERE id = ?", archivedInt, id)
+		u.ContentType = &ct
+	}
+	if fn, ok := optFilename(opts); ok {
+		u.Filename = &fn
+	}
+
+	if dataVal := opts.RawGetString("data"); dataVal != lua.LNil {
+		// Binary detection uses the new content type, or the current one
+		contentType := ""
+		if u.ContentType != nil {
+			contentType = *u.ContentType
+		} else if err := c.db.QueryRow("SELECT content_type FROM clips WHERE id = ?", id).Scan(&contentType); err != nil {
+			L.Push(lua.LFalse)
+			L.Push(lua.LString("clip not found"))
+			return 2
+		}
+
+		data, err := decodeClipData(dataVal.String(), contentType, opts)
 		if err != nil {
 			L.Push(lua.LFalse)
 			L.Push(lua.LString(err.Error()))
 			return 2
 		}
+		u.Data = data
+	}
+
+	// expires_at is a Unix timestamp; false clears the expiration
+	switch exp := opts.RawGetString("expires_at").(type) {
+	case lua.LNumber:
+		t := time.Unix(int64(exp), 0)
+		u.ExpiresAt = &t
+	case lua.LBool:
+		u.ClearExpiration = !bool(exp)
+	}
+
+	if meta, ok := opts.RawGetString("metadata").(*lua.LTable); ok {
+		u.Metadata = make(map[string]string)
+		meta.ForEach(func(k, v lua.LValue) {
+			if v == lua.LFalse {
+				u.Metadata[k.String()] = ""
+			} else {
+				u.Metadata[k.String()] = v.String()
+			}
+		})
+	}
+
+	if err := c.store.UpdateClip(c.actor, id, u); err != nil {
+		L.Push(lua.LFalse)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	if archived := opts.RawGetString("is_archived"); archived != lua.LNil {
+		if err := c.store.SetArchived(c.actor, id, archived == lua.LTrue); err != nil {
+			L.Push(lua.LFalse)
+			L.Push(lua.LString(err.Error()))
+			return 2
+		}
+	}
+	if pinned := opts.RawGetString("is_pinned"); pinned != lua.LNil {
+		if err := c.store.SetPinned(c.actor, id, pinned == lua.LTrue); err != nil {
+			L.Push(lua.LFalse)
+			L.Push(lua.LString(err.Error()))
+			return 2
+		}
 	}
 
 	L.Push(lua.LTrue)
 	return 1
 }
 
+// deleteClip moves a clip to the trash, or deletes it for good if
+// opts.permanent is set
 func (c *ClipsAPI) deleteClip(L *lua.LState) int {
 	id := L.CheckInt64(1)
+	opts := L.OptTable(2, L.NewTable())
 
-	_, err := c.db.Exec("DELETE FROM clips WHERE id = ?", id)
-	if err != nil {
+	if err := c.removeClips(opts, []int64{id}); err != nil {
 		L.Push(lua.LFalse)
 		L.Push(lua.LString(err.Error()))
 		return 2
@@ -506,32 +812,59 @@ func (c *ClipsAPI) deleteClip(L *lua.LState) int {
 	return 1
 }
 
+// deleteMany moves clips to the trash, or deletes them for good if
+// opts.permanent is set. Pinned clips are kept unless opts.force is set.
 func (c *ClipsAPI) deleteMany(L *lua.LState) int {
-	ids := L.CheckTable(1)
+	idList := luaIDList(L.CheckTable(1))
+	opts := L.OptTable(2, L.NewTable())
 
-	var idList []int64
-	ids.ForEach(func(_, v lua.LValue) {
-		if num, ok := v.(lua.LNumber); ok {
-			idList = append(idList, int64(num))
+	if opts.RawGetString("force") != lua.LTrue {
+		unpinned, _, err := c.store.SplitPinned(idList)
+		if err != nil {
+			L.Push(lua.LFalse)
+			L.Push(lua.LString(err.Error()))
+			return 2
 		}
-	})
+		idList = unpinned
+	}
 
 	if len(idList) == 0 {
 		L.Push(lua.LTrue)
 		return 1
 	}
 
-	// Build query with placeholders
-	placeholders := make([]string, len(idList))
-	args := make([]interface{}, len(idList))
-	for i, id := range idList {
-		placeholders[i] = "?"
-		args[i] = id
+	if err := c.removeClips(opts, idList); err != nil {
+		L.Push(lua.LFalse)
+		L.Push(lua.LString(err.Error()))
+		return 2
 	}
 
-	query := "DELETE FROM clips WHERE id IN (" + strings.Join(placeholders, ",") + ")"
-	_, err := c.db.Exec(query, args...)
-	if err != nil {
+	L.Push(lua.LTrue)
+	return 1
+}
+
+// removeClips trashes clips, or deletes them if opts.permanent is set
+func (c *ClipsAPI) removeClips(opts *lua.LTable, ids []int64) error {
+	if err := c.scopes.checkClips(ScopeClipsDelete, ids...); err != nil {
+		return err
+	}
+	if opts.RawGetString("permanent") == lua.LTrue {
+		return c.store.DeleteClips(c.actor, ids)
+	}
+	return c.store.TrashClips(c.actor, ids)
+}
+
+// restore moves clips out of the trash
+func (c *ClipsAPI) restore(L *lua.LState) int {
+	idList := luaIDList(L.CheckTable(1))
+
+	if err := c.scopes.checkClips(ScopeClipsDelete, idList...); err != nil {
+		L.Push(lua.LFalse)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	if err := c.store.RestoreClips(c.actor, idList); err != nil {
 		L.Push(lua.LFalse)
 		L.Push(lua.LString(err.Error()))
 		return 2
@@ -544,8 +877,13 @@ func (c *ClipsAPI) deleteMany(L *lua.LState) int {
 func (c *ClipsAPI) archive(L *lua.LState) int {
 	id := L.CheckInt64(1)
 
-	_, err := c.db.Exec("UPDATE clips SET is_archived = 1 WHERE id = ?", id)
-	if err != nil {
+	if err := c.scopes.checkClips(ScopeClipsWrite, id); err != nil {
+		L.Push(lua.LFalse)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	if err := c.store.SetArchived(c.actor, id, true); err != nil {
 		L.Push(lua.LFalse)
 		L.Push(lua.LString(err.Error()))
 		return 2
@@ -558,8 +896,51 @@ func (c *ClipsAPI) archive(L *lua.LState) int {
 func (c *ClipsAPI) unarchive(L *lua.LState) int {
 	id := L.CheckInt64(1)
 
-	_, err := c.db.Exec("UPDATE clips SET is_archived = 0 WHERE id = ?", id)
-	if err != nil {
+	if err := c.scopes.checkClips(ScopeClipsWrite, id); err != nil {
+		L.Push(lua.LFalse)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	if err := c.store.SetArchived(c.actor, id, false); err != nil {
+		L.Push(lua.LFalse)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	L.Push(lua.LTrue)
+	return 1
+}
+
+func (c *ClipsAPI) pin(L *lua.LState) int {
+	id := L.CheckInt64(1)
+
+	if err := c.scopes.checkClips(ScopeClipsWrite, id); err != nil {
+		L.Push(lua.LFalse)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	if err := c.store.SetPinned(c.actor, id, true); err != nil {
+		L.Push(lua.LFalse)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	L.Push(lua.LTrue)
+	return 1
+}
+
+func (c *ClipsAPI) unpin(L *lua.LState) int {
+	id := L.CheckInt64(1)
+
+	if err := c.scopes.checkClips(ScopeClipsWrite, id); err != nil {
+		L.Push(lua.LFalse)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	if err := c.store.SetPinned(c.actor, id, false); err != nil {
 		L.Push(lua.LFalse)
 		L.Push(lua.LString(err.Error()))
 		return 2
@@ -568,3 +949,34 @@ func (c *ClipsAPI) unarchive(L *lua.LState) int {
 	L.Push(lua.LTrue)
 	return 1
 }
+
+// reorder sets the manual order of clips, see store.ReorderClips
+func (c *ClipsAPI) reorder(L *lua.LState) int {
+	idList := luaIDList(L.CheckTable(1))
+
+	if err := c.scopes.checkClips(ScopeClipsWrite, idList...); err != nil {
+		L.Push(lua.LFalse)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	if err := c.store.ReorderClips(c.actor, idList); err != nil {
+		L.Push(lua.LFalse)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	L.Push(lua.LTrue)
+	return 1
+}
+
+// luaIDList collects the numbers in an array of clip IDs
+func luaIDList(ids *lua.LTable) []int64 {
+	var idList []int64
+	ids.ForEach(func(_, v lua.LValue) {
+		if num, ok := v.(lua.LNumber); ok {
+			idList = append(idList, int64(num))
+		}
+	})
+	return idList
+}
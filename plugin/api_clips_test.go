@@ -0,0 +1,384 @@
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go-clipboard/store"
+)
+
+func TestClipsAPI_CreateNotifiesOtherPlugins(t *testing.T) {
+	m := newTestManager(t)
+
+	watcher := importTestPlugin(t, m, "watcher.lua", `
+Plugin = { name = "Watcher", events = {"clip:created"} }
+function on_clip_created(clip)
+    storage.set("seen", clip.content_type .. ":" .. clip.filename)
+end
+`)
+	creator := importTestPlugin(t, m, "creator.lua", `
+Plugin = { name = "Creator", events = {"clip:created"}, scopes = {"clips.write"} }
+function on_clip_created(clip)
+    storage.set("own_event", "received")
+end
+clips.create({ data = '{"a": 1}', content_type = "text/plain", filename = "data" })
+storage.set("created", "yes")
+`)
+
+	if got := waitForStorage(t, m, watcher.ID, "seen"); got != "application/json:data" {
+		t.Errorf("Unexpected clip:created data: %s", got)
+	}
+	waitForStorage(t, m, creator.ID, "created")
+
+	// Give the event queue time to (wrongly) deliver the event back to its creator
+	time.Sleep(100 * time.Millisecond)
+	var count int
+	m.db.QueryRow("SELECT COUNT(*) FROM plugin_storage WHERE plugin_id = ? AND key = 'own_event'", creator.ID).Scan(&count)
+	if count != 0 {
+		t.Error("Plugin received the event for its own change")
+	}
+}
+
+func TestClipsAPI_EventChainIsBounded(t *testing.T) {
+	m := newTestManager(t)
+
+	// Two plugins that create a clip whenever the other one does
+	source := `
+Plugin = { name = "%s", events = {"clip:created"}, scopes = {"clips.write"} }
+function on_clip_created(clip)
+    clips.create({ data = "echo", content_type = "text/plain" })
+end
+`
+	importTestPlugin(t, m, "ping.lua", fmt.Sprintf(source, "Ping"))
+	importTestPlugin(t, m, "pong.lua", fmt.Sprintf(source, "Pong"))
+
+	if _, err := m.store.CreateClip(store.User, store.NewClip{Data: []byte("start")}); err != nil {
+		t.Fatalf("CreateClip failed: %v", err)
+	}
+
+	// Both plugins answer the user's clip, and each answer starts a chain that
+	// is cut off after MaxEventDepth further answers
+	want := 1 + 2*(MaxEventDepth+1)
+	deadline := time.Now().Add(5 * time.Second)
+	var count int
+	for time.Now().Before(deadline) {
+		m.db.QueryRow("SELECT COUNT(*) FROM clips").Scan(&count)
+		if count >= want {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(200 * time.Millisecond)
+	m.db.QueryRow("SELECT COUNT(*) FROM clips").Scan(&count)
+	if count != want {
+		t.Errorf("Expected %d clips, got %d", want, count)
+	}
+}
+
+func TestClipsAPI_DeleteCleansUpOrphanedTags(t *testing.T) {
+	m := newTestManager(t)
+
+	clip, _ := m.store.CreateClip(store.User, store.NewClip{Data: []byte("a")})
+	tag, _ := m.store.CreateTag(store.User, "lonely")
+	if err := m.store.AddTagToClip(store.User, clip.ID, tag.ID); err != nil {
+		t.Fatalf("AddTagToClip failed: %v", err)
+	}
+
+	p := importTestPlugin(t, m, "cleaner.lua", fmt.Sprintf(`
+Plugin = { name = "Cleaner", scopes = {"clips.delete"} }
+local ok = clips.delete(%d, { permanent = true })
+storage.set("deleted", tostring(ok))
+`, clip.ID))
+
+	if got := waitForStorage(t, m, p.ID, "deleted"); got != "true" {
+		t.Fatalf("clips.delete returned %s", got)
+	}
+	var count int
+	m.db.QueryRow("SELECT COUNT(*) FROM tags").Scan(&count)
+	if count != 0 {
+		t.Error("Orphaned tag should be deleted with the clip")
+	}
+}
+
+func TestClipsAPI_DeleteMovesToTrash(t *testing.T) {
+	m := newTestManager(t)
+
+	clip, _ := m.store.CreateClip(store.User, store.NewClip{Data: []byte("a")})
+
+	p := importTestPlugin(t, m, "trasher.lua", fmt.Sprintf(`
+Plugin = { name = "Trasher", scopes = {"clips.read", "clips.delete"} }
+clips.delete(%d)
+storage.set("after_delete", tostring(clips.get(%d) == nil) .. "," .. #clips.list())
+clips.restore({ %d })
+storage.set("after_restore", tostring(clips.get(%d) ~= nil) .. "," .. #clips.list())
+`, clip.ID, clip.ID, clip.ID, clip.ID))
+
+	if got := waitForStorage(t, m, p.ID, "after_delete"); got != "true,0" {
+		t.Errorf("Expected the trashed clip to be hidden, got %s", got)
+	}
+	if got := waitForStorage(t, m, p.ID, "after_restore"); got != "true,1" {
+		t.Errorf("Expected the restored clip to be visible, got %s", got)
+	}
+}
+
+func TestClipsAPI_Update(t *testing.T) {
+	m := newTestManager(t)
+
+	clip, _ := m.store.CreateClip(store.User, store.NewClip{ContentType: "image/png", Data: []byte("old"), Filename: "a.png"})
+
+	p := importTestPlugin(t, m, "editor.lua", fmt.Sprintf(`
+Plugin = { name = "Editor", scopes = {"clips.read", "clips.write"} }
+local ok, err = clips.update(%d, {
+    data = "bmV3",  -- "new", base64 because the clip is binary
+    name = "b.png",
+    expires_at = 4102444800,
+    metadata = { model = "upscale-2x", scale = 2 },
+})
+storage.set("ok", tostring(ok) .. ":" .. tostring(err))
+
+local clip = clips.get(%d)
+storage.set("meta", clip.metadata.model .. ":" .. clip.metadata.scale)
+
+local bad, bad_err = clips.update(%d, { data = "not base64!" })
+storage.set("bad", tostring(bad) .. ":" .. bad_err)
+`, clip.ID, clip.ID, clip.ID))
+
+	if got := waitForStorage(t, m, p.ID, "ok"); got != "true:nil" {
+		t.Fatalf("clips.update returned %s", got)
+	}
+	if got := waitForStorage(t, m, p.ID, "meta"); got != "upscale-2x:2" {
+		t.Errorf("Unexpected metadata: %s", got)
+	}
+	if got := waitForStorage(t, m, p.ID, "bad"); got[:6] != "false:" {
+		t.Errorf("Invalid base64 should fail, got %s", got)
+	}
+
+	var data, filename string
+	var expiresAt time.Time
+	m.db.QueryRow("SELECT data, filename, expires_at FROM clips WHERE id = ?", clip.ID).Scan(&data, &filename, &expiresAt)
+	if data != "new" || filename != "b.png" || expiresAt.Year() != 2100 {
+		t.Errorf("Clip not updated: %s %s %v", data, filename, expiresAt)
+	}
+}
+
+func TestClipsAPI_GetDecryptsData(t *testing.T) {
+	m := newTestManager(t)
+
+	clip, _ := m.store.CreateClip(store.User, store.NewClip{ContentType: "text/plain", Data: []byte("secret")})
+	keyFile := filepath.Join(t.TempDir(), "mahpastes.key")
+	if err := store.GenerateKeyFile(keyFile); err != nil {
+		t.Fatalf("GenerateKeyFile failed: %v", err)
+	}
+	if err := m.store.EnableEncryption(store.MasterKey{KeyFile: keyFile}); err != nil {
+		t.Fatalf("EnableEncryption failed: %v", err)
+	}
+
+	p := importTestPlugin(t, m, "reader.lua", fmt.Sprintf(`
+Plugin = { name = "Reader", scopes = {"clips.read"} }
+storage.set("data", clips.get(%d).data)
+`, clip.ID))
+	if got := waitForStorage(t, m, p.ID, "data"); got != "secret" {
+		t.Errorf("Expected decrypted data, got %q", got)
+	}
+
+	m.store.Lock()
+	locked := importTestPlugin(t, m, "locked.lua", fmt.Sprintf(`
+Plugin = { name = "Locked", scopes = {"clips.read"} }
+local clip, err = clips.get(%d)
+storage.set("result", tostring(clip) .. ":" .. tostring(err))
+`, clip.ID))
+	if got := waitForStorage(t, m, locked.ID, "result"); got != "nil:"+store.ErrLocked.Error() {
+		t.Errorf("Expected clips.get to fail while locked, got %q", got)
+	}
+}
+
+func TestExecuteUIAction_LinksResultClip(t *testing.T) {
+	m := newTestManager(t)
+
+	source, _ := m.store.CreateClip(store.User, store.NewClip{Data: []byte("hello")})
+
+	p := importTestPlugin(t, m, "upper.lua", `
+Plugin = {
+    name = "Upper",
+    scopes = {"clips.read", "clips.write"},
+    ui = { lightbox_buttons = { { id = "upper", label = "Uppercase" } } },
+}
+function on_ui_action(action, clip_ids)
+    local clip = clips.get(clip_ids[1])
+    local created = clips.create({ data = clip.data:upper(), content_type = "text/plain" })
+    return { success = true, result_clip_id = created.id }
+end
+`)
+
+	result, err := m.ExecuteUIAction(p.ID, "upper", []int64{source.ID}, nil)
+	if err != nil {
+		t.Fatalf("ExecuteUIAction failed: %v", err)
+	}
+	if result.ResultClipID == 0 {
+		t.Fatal("Expected a result clip")
+	}
+
+	derived, _ := m.store.DerivedClips(source.ID)
+	if len(derived) != 1 || derived[0] != result.ResultClipID {
+		t.Errorf("Result clip not linked to its source: %v", derived)
+	}
+	info, _ := m.store.GetClipInfo(result.ResultClipID)
+	if info.Source.Kind != store.SourcePlugin || info.Source.PluginID != p.ID || info.Source.Action != "upper" {
+		t.Errorf("Unexpected source: %+v", info.Source)
+	}
+}
+
+func TestClipsAPI_DeleteManyKeepsPinned(t *testing.T) {
+	m := newTestManager(t)
+
+	a, _ := m.store.CreateClip(store.User, store.NewClip{Data: []byte("a")})
+	b, _ := m.store.CreateClip(store.User, store.NewClip{Data: []byte("b")})
+
+	p := importTestPlugin(t, m, "cleaner.lua", fmt.Sprintf(`
+Plugin = { name = "Cleaner", scopes = {"clips.read", "clips.write", "clips.delete"} }
+clips.pin(%d)
+local clip = clips.get(%d)
+storage.set("pinned", tostring(clip.is_pinned))
+clips.delete_many({ %d, %d })
+storage.set("done", "yes")
+`, b.ID, b.ID, a.ID, b.ID))
+
+	waitForStorage(t, m, p.ID, "done")
+	if got := waitForStorage(t, m, p.ID, "pinned"); got != "true" {
+		t.Errorf("Expected clip to be pinned, got %s", got)
+	}
+
+	var remaining []int64
+	rows, _ := m.db.Query("SELECT id FROM clips WHERE deleted_at IS NULL")
+	for rows.Next() {
+		var id int64
+		rows.Scan(&id)
+		remaining = append(remaining, id)
+	}
+	rows.Close()
+	if len(remaining) != 1 || remaining[0] != b.ID {
+		t.Errorf("Expected only the pinned clip to remain, got %v", remaining)
+	}
+}
+
+func TestClipsAPI_HidesSensitiveClips(t *testing.T) {
+	m := newTestManager(t)
+
+	plain, _ := m.store.CreateClip(store.User, store.NewClip{ContentType: "text/plain", Data: []byte("plain")})
+	private, _ := m.store.CreateClip(store.User, store.NewClip{ContentType: "text/plain", Data: []byte("private")})
+	m.store.SetSensitive(store.User, []int64{private.ID}, true, 0)
+	board, _ := m.store.CreateCollection(store.User, "Board", "")
+	m.store.AddToCollection(store.User, board.ID, []int64{plain.ID, private.ID})
+
+	source := fmt.Sprintf(`
+Plugin = {
+    name = "%%s",
+    scopes = {"clips.read"},
+    permissions = { sensitive_clips = %%t },
+    ui = { lightbox_buttons = { { id = "read", label = "Read" } } },
+}
+function on_ui_action(action, clip_ids)
+    local data, err = clips.get_data(%d)
+    local in_board = #collections.get(%d).clip_ids .. "/" .. #collections.get_for_clip(%d)
+    storage.set("result", #clips.list() .. ":" .. tostring(clips.get(%d) ~= nil) .. ":" .. tostring(data or err) .. ":" .. in_board)
+    return { success = true }
+end
+`, private.ID, board.ID, private.ID, private.ID)
+	read := func(p *Plugin) string {
+		t.Helper()
+		if _, err := m.ExecuteUIAction(p.ID, "read", []int64{plain.ID}, nil); err != nil {
+			t.Fatalf("ExecuteUIAction failed: %v", err)
+		}
+		return waitForStorage(t, m, p.ID, "result")
+	}
+
+	undeclared := importTestPlugin(t, m, "undeclared.lua", fmt.Sprintf(source, "Undeclared", false))
+	if got := read(undeclared); got != "1:false:clip not found:1/0" {
+		t.Errorf("Expected the sensitive clip to be hidden, got %q", got)
+	}
+	if err := m.SetSensitiveClipsAccess(undeclared.ID, true); err == nil {
+		t.Error("Expected access to be refused for a plugin that didn't declare it")
+	}
+
+	declared := importTestPlugin(t, m, "declared.lua", fmt.Sprintf(source, "Declared", true))
+	if got := read(declared); got != "1:false:clip not found:1/0" {
+		t.Errorf("Expected the sensitive clip to be hidden until granted, got %q", got)
+	}
+	if err := m.SetSensitiveClipsAccess(declared.ID, true); err != nil {
+		t.Fatalf("SetSensitiveClipsAccess failed: %v", err)
+	}
+	if got := read(declared); got != "2:true:private:2/1" {
+		t.Errorf("Expected the sensitive clip to be readable once granted, got %q", got)
+	}
+
+	// Grants restored from a backup need to be confirmed again
+	m.db.Exec("UPDATE plugin_permissions SET pending_reconfirm = 1 WHERE permission_type = ?", PermissionSensitiveClips)
+	if got := read(declared); got != "1:false:clip not found:1/0" {
+		t.Errorf("Expected a restored grant to be ignored, got %q", got)
+	}
+}
+
+func TestClipsAPI_CreateChecksParent(t *testing.T) {
+	m := newTestManager(t)
+	m.SetTransport(NewReplayTransport(&HTTPFixtures{Interactions: []HTTPInteraction{{
+		Request:  FixtureRequest{Method: "GET", URL: "https://api.example.com/file.txt"},
+		Response: FixtureResponse{Headers: map[string][]string{"Content-Type": {"text/plain"}}, Body: "downloaded"},
+		Repeat:   true,
+	}}}))
+
+	visible, _ := m.store.CreateClip(store.User, store.NewClip{ContentType: "text/plain", Data: []byte("visible")})
+	private, _ := m.store.CreateClip(store.User, store.NewClip{ContentType: "text/plain", Data: []byte("private")})
+	m.store.SetSensitive(store.User, []int64{private.ID}, true, 0)
+	image, _ := m.store.CreateClip(store.User, store.NewClip{ContentType: "image/png", Data: []byte("png")})
+	trashed, _ := m.store.CreateClip(store.User, store.NewClip{ContentType: "text/plain", Data: []byte("trashed")})
+	m.store.TrashClips(store.User, []int64{trashed.ID})
+
+	// Only clips the plugin could read with clips.get can be parents
+	path := filepath.Join(t.TempDir(), "deriver.lua")
+	source := fmt.Sprintf(`
+Plugin = {
+    name = "Deriver",
+    scopes = {"clips.read", "clips.write"},
+    scope_limits = { content_types = {"text/*"} },
+    network = { ["api.example.com"] = {"GET"} },
+}
+local results = {}
+for _, parent in ipairs({ %d, %d, %d, %d }) do
+    local created, err = clips.create({ data = "child", content_type = "text/plain", parent_id = parent })
+    table.insert(results, created and "ok" or err)
+end
+for _, parent in ipairs({ %d, %d }) do
+    local created, err = clips.create_from_url("https://api.example.com/file.txt", { parent_id = parent })
+    table.insert(results, created and "ok" or err)
+end
+storage.set("result", table.concat(results, "|"))
+`, visible.ID, private.ID, image.ID, trashed.ID, visible.ID, private.ID)
+	if err := os.WriteFile(path, []byte(source), 0644); err != nil {
+		t.Fatalf("failed to write plugin: %v", err)
+	}
+	p, err := m.ImportPlugin(path, []string{"api.example.com"})
+	if err != nil {
+		t.Fatalf("ImportPlugin failed: %v", err)
+	}
+
+	want := strings.Join([]string{
+		"ok",
+		fmt.Sprintf("parent clip %d not found", private.ID),
+		fmt.Sprintf("parent clip %d not found", image.ID),
+		fmt.Sprintf("parent clip %d not found", trashed.ID),
+		"ok",
+		fmt.Sprintf("parent clip %d not found", private.ID),
+	}, "|")
+	if got := waitForStorage(t, m, p.ID, "result"); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	var children int
+	m.db.QueryRow("SELECT COUNT(*) FROM clips WHERE parent_id IS NOT NULL AND parent_id != ?", visible.ID).Scan(&children)
+	if children != 0 {
+		t.Errorf("Expected no clips linked to hidden parents, got %d", children)
+	}
+}
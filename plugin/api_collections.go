@@ -0,0 +1,291 @@
+package plugin
+
+import (
+	"errors"
+
+	"go-clipboard/store"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// CollectionsAPI provides collection operations to plugins. Changes go through
+// the store so events fire as for the user's changes.
+type CollectionsAPI struct {
+	store  *store.Service
+	actor  store.Actor
+	scopes *scopeChecker
+}
+
+// NewCollectionsAPI creates a new collections API instance. Reading the clips
+// of a collection needs clips.read and changing them clips.write, within the
+// plugin's scope limits like in the clips API.
+func NewCollectionsAPI(st *store.Service, pluginID int64, scopes *scopeChecker) *CollectionsAPI {
+	return &CollectionsAPI{store: st, actor: store.Plugin(pluginID), scopes: scopes}
+}
+
+// Register adds the collections module to the Lua state
+func (c *CollectionsAPI) Register(L *lua.LState) {
+	collectionsMod := L.NewTable()
+
+	collectionsMod.RawSetString("list", L.NewFunction(c.list))
+	collectionsMod.RawSetString("get", L.NewFunction(c.get))
+	collectionsMod.RawSetString("create", L.NewFunction(c.create))
+	collectionsMod.RawSetString("update", L.NewFunction(c.update))
+	collectionsMod.RawSetString("delete", L.NewFunction(c.deleteCollection))
+	collectionsMod.RawSetString("add_clips", L.NewFunction(c.addClips))
+	collectionsMod.RawSetString("remove_clips", L.NewFunction(c.removeClips))
+	collectionsMod.RawSetString("reorder", L.NewFunction(c.reorder))
+	collectionsMod.RawSetString("get_for_clip", L.NewFunction(c.getForClip))
+
+	L.SetGlobal("collections", collectionsMod)
+}
+
+// collectionToLua converts a collection to a Lua table
+func collectionToLua(L *lua.LState, coll store.Collection) *lua.LTable {
+	t := L.NewTable()
+	t.RawSetString("id", lua.LNumber(coll.ID))
+	t.RawSetString("name", lua.LString(coll.Name))
+	t.RawSetString("description", lua.LString(coll.Description))
+	t.RawSetString("count", lua.LNumber(coll.Count))
+	t.RawSetString("created_at", lua.LNumber(coll.CreatedAt.Unix()))
+	return t
+}
+
+// list returns all collections with clip counts
+func (c *CollectionsAPI) list(L *lua.LState) int {
+	if err := c.scopes.require(ScopeClipsRead); err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	collections, err := c.store.ListCollections()
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	result := L.NewTable()
+	for _, coll := range collections {
+		result.Append(collectionToLua(L, coll))
+	}
+	L.Push(result)
+	return 1
+}
+
+// get returns a collection with the IDs of its clips in order, leaving out
+// clips outside the plugin's scope
+func (c *CollectionsAPI) get(L *lua.LState) int {
+	id := L.CheckInt64(1)
+
+	if err := c.scopes.require(ScopeClipsRead); err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	coll, err := c.store.GetCollection(id)
+	if errors.Is(err, store.ErrCollectionNotFound) {
+		L.Push(lua.LNil)
+		return 1
+	}
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	clipIDs, err := c.store.CollectionClips(id)
+	if err == nil {
+		clipIDs, err = c.visibleClips(clipIDs)
+	}
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	t := collectionToLua(L, *coll)
+	ids := L.NewTable()
+	for _, clipID := range clipIDs {
+		ids.Append(lua.LNumber(clipID))
+	}
+	t.RawSetString("clip_ids", ids)
+	t.RawSetString("count", lua.LNumber(len(clipIDs)))
+
+	L.Push(t)
+	return 1
+}
+
+// create creates an empty collection
+func (c *CollectionsAPI) create(L *lua.LState) int {
+	name := L.CheckString(1)
+	description := L.OptString(2, "")
+
+	coll, err := c.store.CreateCollection(c.actor, name, description)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	L.Push(collectionToLua(L, *coll))
+	return 1
+}
+
+// update updates a collection's name and/or description
+func (c *CollectionsAPI) update(L *lua.LState) int {
+	id := L.CheckInt64(1)
+	opts := L.CheckTable(2)
+
+	coll, err := c.store.GetCollection(id)
+	if err != nil {
+		L.Push(lua.LFalse)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	name := coll.Name
+	description := coll.Description
+	if nameVal := opts.RawGetString("name"); nameVal != lua.LNil {
+		name = nameVal.String()
+	}
+	if descVal := opts.RawGetString("description"); descVal != lua.LNil {
+		description = descVal.String()
+	}
+
+	if err := c.store.UpdateCollection(c.actor, id, name, description); err != nil {
+		L.Push(lua.LFalse)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	L.Push(lua.LTrue)
+	return 1
+}
+
+// deleteCollection deletes a collection, keeping its clips
+func (c *CollectionsAPI) deleteCollection(L *lua.LState) int {
+	id := L.CheckInt64(1)
+
+	if err := c.store.DeleteCollection(c.actor, id); err != nil {
+		L.Push(lua.LFalse)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	L.Push(lua.LTrue)
+	return 1
+}
+
+// visibleClips filters clip IDs down to the ones the plugin may see: within
+// its limits, and not sensitive unless it may read sensitive clips
+func (c *CollectionsAPI) visibleClips(ids []int64) ([]int64, error) {
+	visible := []int64{}
+	for _, id := range ids {
+		ok, err := c.scopes.visible(id)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			visible = append(visible, id)
+		}
+	}
+	return visible, nil
+}
+
+// addClips appends clips to the end of a collection
+func (c *CollectionsAPI) addClips(L *lua.LState) int {
+	id := L.CheckInt64(1)
+	clipIDs := luaIDList(L.CheckTable(2))
+
+	if err := c.scopes.checkClips(ScopeClipsWrite, clipIDs...); err != nil {
+		L.Push(lua.LFalse)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	if err := c.store.AddToCollection(c.actor, id, clipIDs); err != nil {
+		L.Push(lua.LFalse)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	L.Push(lua.LTrue)
+	return 1
+}
+
+// removeClips removes clips from a collection
+func (c *CollectionsAPI) removeClips(L *lua.LState) int {
+	id := L.CheckInt64(1)
+	clipIDs := luaIDList(L.CheckTable(2))
+
+	if err := c.scopes.checkClips(ScopeClipsWrite, clipIDs...); err != nil {
+		L.Push(lua.LFalse)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	if err := c.store.RemoveFromCollection(c.actor, id, clipIDs); err != nil {
+		L.Push(lua.LFalse)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	L.Push(lua.LTrue)
+	return 1
+}
+
+// reorder moves clips to the front of a collection, see store.ReorderCollection
+func (c *CollectionsAPI) reorder(L *lua.LState) int {
+	id := L.CheckInt64(1)
+	clipIDs := luaIDList(L.CheckTable(2))
+
+	if err := c.scopes.checkClips(ScopeClipsWrite, clipIDs...); err != nil {
+		L.Push(lua.LFalse)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	if err := c.store.ReorderCollection(c.actor, id, clipIDs); err != nil {
+		L.Push(lua.LFalse)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	L.Push(lua.LTrue)
+	return 1
+}
+
+// getForClip returns the collections a clip belongs to. Clips outside the
+// plugin's scope belong to none.
+func (c *CollectionsAPI) getForClip(L *lua.LState) int {
+	clipID := L.CheckInt64(1)
+
+	if err := c.scopes.require(ScopeClipsRead); err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	inScope, err := c.scopes.visible(clipID)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	if !inScope {
+		L.Push(L.NewTable())
+		return 1
+	}
+
+	collections, err := c.store.ClipCollections(clipID)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	result := L.NewTable()
+	for _, coll := range collections {
+		result.Append(collectionToLua(L, coll))
+	}
+	L.Push(result)
+	return 1
+}
@@ -3,12 +3,15 @@ package plugin
 import (
 	"database/sql"
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
+	"go-clipboard/store"
+
 	lua "github.com/yuin/gopher-lua"
 )
 
@@ -25,6 +28,7 @@ type PermissionCallback func(pluginName string, permType string, requestedPath s
 // FilesystemAPI provides restricted filesystem access to plugins
 type FilesystemAPI struct {
 	db             *sql.DB
+	store          *store.Service
 	pluginID       int64
 	pluginName     string
 	wantsRead      bool
@@ -37,9 +41,10 @@ type FilesystemAPI struct {
 }
 
 // NewFilesystemAPI creates a new filesystem API
-func NewFilesystemAPI(db *sql.DB, pluginID int64, pluginName string, perms FilesystemPerms, callback PermissionCallback) *FilesystemAPI {
+func NewFilesystemAPI(db *sql.DB, st *store.Service, pluginID int64, pluginName string, perms FilesystemPerms, callback PermissionCallback) *FilesystemAPI {
 	api := &FilesystemAPI{
 		db:            db,
+		store:         st,
 		pluginID:      pluginID,
 		pluginName:    pluginName,
 		wantsRead:     perms.Read,
@@ -135,6 +140,7 @@ func (f *FilesystemAPI) checkPermission(permType, path string) (string, error) {
 
 	approved := f.permCallback(f.pluginName, permType, absPath)
 	if approved == "" {
+		f.audit(store.AuditPermissionDenied, permType, absPath)
 		return "", fmt.Errorf("permission denied for %s", absPath)
 	}
 
@@ -145,6 +151,7 @@ func (f *FilesystemAPI) checkPermission(permType, path string) (string, error) {
 	)
 	if err == nil {
 		f.approvedPaths[permType+":"+approved] = approved
+		f.audit(store.AuditPermissionGranted, permType, approved)
 	}
 
 	// Check if the requested path is under the approved path
@@ -155,6 +162,16 @@ func (f *FilesystemAPI) checkPermission(permType, path string) (string, error) {
 	return absPath, nil
 }
 
+// audit records the user's answer to a permission request
+func (f *FilesystemAPI) audit(action, permType, path string) {
+	if err := f.store.Audit(store.User, action, store.TargetPlugin, f.pluginID, map[string]interface{}{
+		"permission": permType,
+		"path":       path,
+	}); err != nil {
+		log.Printf("Failed to audit %s of plugin %d: %v", action, f.pluginID, err)
+	}
+}
+
 func isSubPath(base, target string) bool {
 	rel, err := filepath.Rel(base, target)
 	if err != nil {
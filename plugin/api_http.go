@@ -1,9 +1,13 @@
 package plugin
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"net/textproto"
 	"net/url"
 	"strings"
 	"sync"
@@ -21,10 +25,13 @@ const (
 	HTTPMaxResponseSize = 10 * 1024 * 1024
 )
 
-// HTTPAPI provides restricted HTTP access to plugins
+// HTTPAPI provides restricted HTTP access to plugins. Requests are limited to
+// the domains the plugin declares and the user approved, and are recorded in
+// the plugin's network log.
 type HTTPAPI struct {
-	allowedDomains map[string][]string // domain -> allowed methods
-	client         *http.Client
+	clips   *ClipsAPI // reads uploaded clips and saves responses, within the plugin's scopes
+	network *networkGuard
+	client  *http.Client
 
 	// Rate limiting
 	mu           sync.Mutex
@@ -32,20 +39,23 @@ type HTTPAPI struct {
 	windowStart  time.Time
 }
 
-// NewHTTPAPI creates a new HTTP API instance with the given allowed domains
-func NewHTTPAPI(allowedDomains map[string][]string) *HTTPAPI {
+// NewHTTPAPI creates a new HTTP API instance for the plugin clips belongs to,
+// sharing its allowed domains
+func NewHTTPAPI(clips *ClipsAPI) *HTTPAPI {
 	api := &HTTPAPI{
-		allowedDomains: allowedDomains,
-		windowStart:    time.Now(),
+		clips:       clips,
+		network:     clips.network,
+		windowStart: time.Now(),
 	}
 
 	// Create client with redirect validation to prevent domain bypass
 	api.client = &http.Client{
-		Timeout: HTTPTimeout,
+		Transport: api.network.transport,
+		Timeout:   HTTPTimeout,
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			// Validate redirect URL against allowlist
 			domain := req.URL.Hostname()
-			if _, ok := FindAllowedMethods(api.allowedDomains, domain); !ok {
+			if _, err := api.network.checkApproved(domain); err != nil {
 				return fmt.Errorf("redirect to unauthorized domain: %s", domain)
 			}
 			// Prevent downgrade to non-HTTPS
@@ -72,6 +82,8 @@ func (h *HTTPAPI) Register(L *lua.LState) {
 	httpMod.RawSetString("put", L.NewFunction(h.makeRequest("PUT")))
 	httpMod.RawSetString("patch", L.NewFunction(h.makeRequest("PATCH")))
 	httpMod.RawSetString("delete", L.NewFunction(h.makeRequest("DELETE")))
+	httpMod.RawSetString("encode_query", L.NewFunction(h.encodeQuery))
+	httpMod.RawSetString("build_url", L.NewFunction(h.buildURL))
 
 	L.SetGlobal("http", httpMod)
 }
@@ -91,48 +103,29 @@ func MatchDomain(pattern, domain string) bool {
 
 // FindAllowedMethods returns the allowed methods for a domain, checking wildcards.
 func FindAllowedMethods(allowedDomains map[string][]string, domain string) ([]string, bool) {
+	_, methods, ok := findDomain(allowedDomains, domain)
+	return methods, ok
+}
+
+// findDomain returns the allowlist entry matching a domain and its methods
+func findDomain(allowedDomains map[string][]string, domain string) (string, []string, bool) {
 	// Exact match first
 	if methods, ok := allowedDomains[domain]; ok {
-		return methods, true
+		return domain, methods, true
 	}
 	// Wildcard match
 	for pattern, methods := range allowedDomains {
 		if MatchDomain(pattern, domain) {
-			return methods, true
+			return pattern, methods, true
 		}
 	}
-	return nil, false
+	return "", nil, false
 }
 
-// checkDomainPermission validates that the URL domain is in the allowlist and method is allowed
+// checkDomainPermission validates that the URL domain is in the allowlist and
+// approved by the user, and that the method is allowed
 func (h *HTTPAPI) checkDomainPermission(urlStr, method string) error {
-	parsed, err := url.Parse(urlStr)
-	if err != nil {
-		return fmt.Errorf("invalid URL: %w", err)
-	}
-
-	// Use url.Hostname() to correctly handle IPv6 addresses and ports
-	domain := parsed.Hostname()
-
-	allowedMethods, ok := FindAllowedMethods(h.allowedDomains, domain)
-	if !ok {
-		return fmt.Errorf("domain not in allowlist: %s", domain)
-	}
-
-	// Check if method is allowed for this domain
-	methodAllowed := false
-	for _, m := range allowedMethods {
-		if strings.EqualFold(m, method) {
-			methodAllowed = true
-			break
-		}
-	}
-
-	if !methodAllowed {
-		return fmt.Errorf("%s not allowed for domain %s (allowed: [%s])", method, domain, strings.Join(allowedMethods, ", "))
-	}
-
-	return nil
+	return h.network.checkURL(urlStr, method)
 }
 
 // checkRateLimit enforces rate limiting
@@ -156,98 +149,360 @@ func (h *HTTPAPI) checkRateLimit() error {
 	return nil
 }
 
+// requestOptions are the options of an http module request
+type requestOptions struct {
+	body       []byte
+	headers    http.Header
+	query      url.Values
+	timeout    time.Duration
+	saveAsClip *lua.LTable // options of the clip the response is saved to, nil to return it
+}
+
+// parseRequestOptions reads the options table of a request. The body is a
+// string, a clip (body_clip_id) or multipart/form-data built from values and
+// clips (multipart).
+func (h *HTTPAPI) parseRequestOptions(L *lua.LState, opts *lua.LTable) (*requestOptions, error) {
+	ro := &requestOptions{headers: make(http.Header), timeout: HTTPTimeout}
+	if opts == nil {
+		return ro, nil
+	}
+
+	// Header values are strings, or lists of strings for repeated headers
+	if headersTable, ok := opts.RawGetString("headers").(*lua.LTable); ok {
+		headersTable.ForEach(func(k, v lua.LValue) {
+			for _, value := range luaStrings(v) {
+				ro.headers.Add(k.String(), value)
+			}
+		})
+	}
+
+	if queryTable, ok := opts.RawGetString("query").(*lua.LTable); ok {
+		ro.query = luaQuery(queryTable)
+	}
+
+	switch timeout := opts.RawGetString("timeout").(type) {
+	case lua.LNumber:
+		if timeout <= 0 {
+			return nil, fmt.Errorf("timeout must be positive")
+		}
+		if d := time.Duration(float64(timeout) * float64(time.Second)); d < HTTPTimeout {
+			ro.timeout = d
+		}
+	case *lua.LNilType:
+	default:
+		return nil, fmt.Errorf("timeout must be a number of seconds")
+	}
+
+	switch save := opts.RawGetString("save_as_clip").(type) {
+	case *lua.LTable:
+		ro.saveAsClip = save
+	case lua.LBool:
+		if save {
+			ro.saveAsClip = L.NewTable()
+		}
+	}
+	if ro.saveAsClip != nil {
+		if err := h.clips.scopes.require(ScopeClipsWrite); err != nil {
+			return nil, err
+		}
+	}
+
+	bodyVal := opts.RawGetString("body")
+	clipVal := opts.RawGetString("body_clip_id")
+	partsVal := opts.RawGetString("multipart")
+	bodies := 0
+	for _, v := range []lua.LValue{bodyVal, clipVal, partsVal} {
+		if v != lua.LNil {
+			bodies++
+		}
+	}
+	if bodies > 1 {
+		return nil, fmt.Errorf("only one of body, body_clip_id and multipart can be set")
+	}
+
+	switch {
+	case bodyVal != lua.LNil:
+		ro.body = []byte(bodyVal.String())
+	case clipVal != lua.LNil:
+		id, ok := clipVal.(lua.LNumber)
+		if !ok {
+			return nil, fmt.Errorf("body_clip_id must be a clip ID")
+		}
+		contentType, _, data, err := h.clips.readClip(int64(id))
+		if err != nil {
+			return nil, err
+		}
+		ro.body = data
+		if ro.headers.Get("Content-Type") == "" {
+			ro.headers.Set("Content-Type", contentType)
+		}
+	case partsVal != lua.LNil:
+		parts, ok := partsVal.(*lua.LTable)
+		if !ok {
+			return nil, fmt.Errorf("multipart must be a list of parts")
+		}
+		body, contentType, err := h.buildMultipart(parts)
+		if err != nil {
+			return nil, err
+		}
+		ro.body = body
+		ro.headers.Set("Content-Type", contentType)
+	}
+
+	return ro, nil
+}
+
+// quoteEscaper escapes quoted strings in Content-Disposition headers, as in
+// multipart.Writer.CreateFormFile
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// buildMultipart encodes a list of parts as multipart/form-data. Each part
+// has a name and either a string value or a clip_id, uploaded as a file
+// named filename (default: the clip's filename) with content_type (default:
+// the clip's content type).
+func (h *HTTPAPI) buildMultipart(parts *lua.LTable) ([]byte, string, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	for i := 1; i <= parts.Len(); i++ {
+		part, ok := parts.RawGetInt(i).(*lua.LTable)
+		if !ok {
+			return nil, "", fmt.Errorf("multipart part %d must be a table", i)
+		}
+		name := lua.LVAsString(part.RawGetString("name"))
+		if name == "" {
+			return nil, "", fmt.Errorf("multipart part %d has no name", i)
+		}
+
+		clipVal := part.RawGetString("clip_id")
+		if clipVal == lua.LNil {
+			if err := writer.WriteField(name, lua.LVAsString(part.RawGetString("value"))); err != nil {
+				return nil, "", err
+			}
+			continue
+		}
+
+		id, ok := clipVal.(lua.LNumber)
+		if !ok {
+			return nil, "", fmt.Errorf("multipart part %s: clip_id must be a clip ID", name)
+		}
+		contentType, filename, data, err := h.clips.readClip(int64(id))
+		if err != nil {
+			return nil, "", fmt.Errorf("multipart part %s: %w", name, err)
+		}
+		if fn := lua.LVAsString(part.RawGetString("filename")); fn != "" {
+			filename = fn
+		}
+		if filename == "" {
+			filename = name
+		}
+		if ct := lua.LVAsString(part.RawGetString("content_type")); ct != "" {
+			contentType = ct
+		}
+
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+			quoteEscaper.Replace(name), quoteEscaper.Replace(filename)))
+		header.Set("Content-Type", contentType)
+		w, err := writer.CreatePart(header)
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err := w.Write(data); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), writer.FormDataContentType(), nil
+}
+
 // makeRequest returns a Lua function that handles HTTP requests for the given method
 func (h *HTTPAPI) makeRequest(method string) lua.LGFunction {
 	return func(L *lua.LState) int {
 		urlStr := L.CheckString(1)
 
-		// Check domain permission
-		if err := h.checkDomainPermission(urlStr, method); err != nil {
+		// Every attempt is recorded, including refused ones
+		entry := NetworkLogEntry{Method: method, Host: hostOf(urlStr)}
+		fail := func(err error) int {
+			entry.Error = err.Error()
+			h.network.record(entry)
 			L.Push(lua.LNil)
 			L.Push(lua.LString(err.Error()))
 			return 2
 		}
 
+		// Parse options
+		var opts *lua.LTable
+		if L.GetTop() >= 2 {
+			opts, _ = L.Get(2).(*lua.LTable)
+		}
+		ro, err := h.parseRequestOptions(L, opts)
+		if err != nil {
+			return fail(err)
+		}
+		entry.BytesOut = int64(len(ro.body))
+
+		if len(ro.query) > 0 {
+			if urlStr, err = addQuery(urlStr, ro.query); err != nil {
+				return fail(err)
+			}
+		}
+
+		// Check domain permission
+		if err := h.checkDomainPermission(urlStr, method); err != nil {
+			return fail(err)
+		}
+
 		// Check rate limit
 		if err := h.checkRateLimit(); err != nil {
-			L.Push(lua.LNil)
-			L.Push(lua.LString(err.Error()))
-			return 2
+			return fail(err)
 		}
 
-		// Parse options
-		var body string
-		headers := make(map[string]string)
-
-		if L.GetTop() >= 2 {
-			if opts, ok := L.Get(2).(*lua.LTable); ok {
-				// Get body
-				if bodyVal := opts.RawGetString("body"); bodyVal != lua.LNil {
-					body = bodyVal.String()
-				}
-
-				// Get headers
-				if headersVal := opts.RawGetString("headers"); headersVal != lua.LNil {
-					if headersTable, ok := headersVal.(*lua.LTable); ok {
-						headersTable.ForEach(func(k, v lua.LValue) {
-							headers[k.String()] = v.String()
-						})
-					}
-				}
-			}
+		// Refuse requests carrying sensitive clips if the user asked to
+		if err := h.network.checkSensitive(urlStr, ro.body); err != nil {
+			return fail(err)
 		}
 
 		// Create request
 		var reqBody io.Reader
-		if body != "" {
-			reqBody = strings.NewReader(body)
+		if len(ro.body) > 0 {
+			reqBody = bytes.NewReader(ro.body)
 		}
 
-		req, err := http.NewRequest(method, urlStr, reqBody)
+		ctx, cancel := context.WithTimeout(context.Background(), ro.timeout)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, method, urlStr, reqBody)
 		if err != nil {
-			L.Push(lua.LNil)
-			L.Push(lua.LString(err.Error()))
-			return 2
-		}
-
-		// Set headers
-		for k, v := range headers {
-			req.Header.Set(k, v)
+			return fail(err)
 		}
+		req.Header = ro.headers
 
 		// Execute request
 		resp, err := h.client.Do(req)
 		if err != nil {
-			L.Push(lua.LNil)
-			L.Push(lua.LString(err.Error()))
-			return 2
+			return fail(err)
 		}
 		defer resp.Body.Close()
-
-		// Read response body with size limit
-		limitedReader := io.LimitReader(resp.Body, HTTPMaxResponseSize)
-		respBody, err := io.ReadAll(limitedReader)
-		if err != nil {
-			L.Push(lua.LNil)
-			L.Push(lua.LString(err.Error()))
-			return 2
-		}
+		entry.Status = resp.StatusCode
 
 		// Build response table
 		result := L.NewTable()
 		result.RawSetString("status", lua.LNumber(resp.StatusCode))
-		result.RawSetString("body", lua.LString(string(respBody)))
 
-		// Build headers table
+		// Build headers tables: the first value of each header, and all of them
 		respHeaders := L.NewTable()
+		headerValues := L.NewTable()
 		for k, v := range resp.Header {
 			if len(v) > 0 {
 				respHeaders.RawSetString(k, lua.LString(v[0]))
 			}
+			values := L.NewTable()
+			for _, value := range v {
+				values.Append(lua.LString(value))
+			}
+			headerValues.RawSetString(k, values)
 		}
 		result.RawSetString("headers", respHeaders)
+		result.RawSetString("header_values", headerValues)
+
+		// Successful responses can go straight into a new clip
+		if ro.saveAsClip != nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			data, err := io.ReadAll(io.LimitReader(resp.Body, MaxClipDataSize+1))
+			entry.BytesIn = int64(len(data))
+			if err != nil {
+				return fail(err)
+			}
+			if len(data) > MaxClipDataSize {
+				return fail(fmt.Errorf("response too large: exceeds %d bytes", MaxClipDataSize))
+			}
+			h.network.record(entry)
+
+			id, err := h.clips.saveDownload(data, resp.Header.Get("Content-Type"), urlStr, ro.saveAsClip)
+			if err != nil {
+				L.Push(lua.LNil)
+				L.Push(lua.LString(err.Error()))
+				return 2
+			}
+			result.RawSetString("body", lua.LString(""))
+			result.RawSetString("clip_id", lua.LNumber(id))
+			L.Push(result)
+			return 1
+		}
+
+		// Read response body with size limit
+		limitedReader := io.LimitReader(resp.Body, HTTPMaxResponseSize)
+		respBody, err := io.ReadAll(limitedReader)
+		entry.BytesIn = int64(len(respBody))
+		if err != nil {
+			return fail(err)
+		}
+		h.network.record(entry)
+
+		result.RawSetString("body", lua.LString(string(respBody)))
 
 		L.Push(result)
 		return 1
 	}
 }
+
+// encodeQuery encodes a table as a query string, sorted by key
+func (h *HTTPAPI) encodeQuery(L *lua.LState) int {
+	L.Push(lua.LString(luaQuery(L.CheckTable(1)).Encode()))
+	return 1
+}
+
+// buildURL adds query parameters to a URL, keeping the ones it has
+func (h *HTTPAPI) buildURL(L *lua.LState) int {
+	built, err := addQuery(L.CheckString(1), luaQuery(L.CheckTable(2)))
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	L.Push(lua.LString(built))
+	return 1
+}
+
+// addQuery adds query parameters to a URL
+func addQuery(urlStr string, query url.Values) (string, error) {
+	parsed, err := url.Parse(urlStr)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %w", err)
+	}
+	values := parsed.Query()
+	for key, list := range query {
+		for _, value := range list {
+			values.Add(key, value)
+		}
+	}
+	parsed.RawQuery = values.Encode()
+	return parsed.String(), nil
+}
+
+// luaQuery converts a table of query parameters to url.Values. Values are
+// strings, numbers or booleans, or lists of them for repeated parameters.
+func luaQuery(t *lua.LTable) url.Values {
+	values := url.Values{}
+	t.ForEach(func(k, v lua.LValue) {
+		for _, value := range luaStrings(v) {
+			values.Add(k.String(), value)
+		}
+	})
+	return values
+}
+
+// luaStrings returns a value as strings: the elements of a list, or the value itself
+func luaStrings(v lua.LValue) []string {
+	list, ok := v.(*lua.LTable)
+	if !ok {
+		return []string{v.String()}
+	}
+	var values []string
+	for i := 1; i <= list.Len(); i++ {
+		values = append(values, list.RawGetInt(i).String())
+	}
+	return values
+}
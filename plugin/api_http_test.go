@@ -0,0 +1,151 @@
+package plugin
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go-clipboard/store"
+)
+
+// importHTTPTestPlugin imports a plugin allowed to reach 127.0.0.1 with
+// every method and runs source as its on_ui_action handler
+func importHTTPTestPlugin(t *testing.T, m *Manager, source string) *Plugin {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "http.lua")
+	manifest := `
+Plugin = {
+    name = "HTTP",
+    scopes = {"clips.read", "clips.write"},
+    network = { ["127.0.0.1"] = {"GET", "POST", "PUT"} },
+    ui = { card_actions = { { id = "run", label = "Run" } } },
+}
+function on_ui_action(action, clip_ids, options)
+` + source + `
+    return { success = true }
+end
+`
+	if err := os.WriteFile(path, []byte(manifest), 0644); err != nil {
+		t.Fatalf("failed to write plugin: %v", err)
+	}
+	p, err := m.ImportPlugin(path, []string{"127.0.0.1"})
+	if err != nil {
+		t.Fatalf("ImportPlugin failed: %v", err)
+	}
+	if _, err := m.ExecuteUIAction(p.ID, "run", nil, nil); err != nil {
+		t.Fatalf("ExecuteUIAction failed: %v", err)
+	}
+	return p
+}
+
+func TestHTTPAPI_MultipartFromClips(t *testing.T) {
+	m := newTestManager(t)
+
+	image := []byte{0x89, 'P', 'N', 'G', 0x00, 0xff, 0x10}
+	clip, _ := m.store.CreateClip(store.User, store.NewClip{ContentType: "image/png", Data: image, Filename: "cat.png"})
+
+	received := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			received <- "error: " + err.Error()
+			return
+		}
+		file, header, err := r.FormFile("image")
+		if err != nil {
+			received <- "error: " + err.Error()
+			return
+		}
+		data, _ := io.ReadAll(file)
+		received <- fmt.Sprintf("%s|%s|%s|%v", r.FormValue("prompt"), header.Filename, header.Header.Get("Content-Type"), string(data) == string(image))
+	}))
+	defer server.Close()
+
+	p := importHTTPTestPlugin(t, m, fmt.Sprintf(`
+    local resp, err = http.post(%q, { multipart = {
+        { name = "prompt", value = "a cat" },
+        { name = "image", clip_id = %d },
+    } })
+    storage.set("result", resp and tostring(resp.status) or err)
+`, server.URL, clip.ID))
+
+	if got := waitForStorage(t, m, p.ID, "result"); got != "200" {
+		t.Fatalf("Expected the upload to succeed, got %s", got)
+	}
+	if got := <-received; got != "a cat|cat.png|image/png|true" {
+		t.Errorf("Unexpected upload: %s", got)
+	}
+}
+
+func TestHTTPAPI_SaveResponseAsClip(t *testing.T) {
+	m := newTestManager(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Add("X-Tag", "one")
+		w.Header().Add("X-Tag", "two")
+		w.Write([]byte{0x89, 'P', 'N', 'G', 0x00})
+	}))
+	defer server.Close()
+
+	p := importHTTPTestPlugin(t, m, fmt.Sprintf(`
+    local resp, err = http.get(%q, { query = { size = "large" }, save_as_clip = { name = "result.png" } })
+    if not resp then
+        storage.set("result", err)
+        return { success = true }
+    end
+    local tags = resp.header_values["X-Tag"]
+    storage.set("result", resp.clip_id .. "|" .. #resp.body .. "|" .. resp.headers["X-Tag"] .. "|" .. table.concat(tags, ","))
+`, server.URL+"/image"))
+
+	got := waitForStorage(t, m, p.ID, "result")
+	var clipID int64
+	var rest string
+	if _, err := fmt.Sscanf(got, "%d|%s", &clipID, &rest); err != nil || rest != "0|one|one,two" {
+		t.Fatalf("Unexpected result: %s", got)
+	}
+
+	var contentType, filename, source string
+	var data []byte
+	err := m.db.QueryRow("SELECT content_type, filename, data, source_url FROM clips WHERE id = ?", clipID).
+		Scan(&contentType, &filename, &data, &source)
+	if err != nil {
+		t.Fatalf("Failed to read saved clip: %v", err)
+	}
+	if contentType != "image/png" || filename != "result.png" || len(data) != 5 || !strings.HasSuffix(source, "/image?size=large") {
+		t.Errorf("Unexpected clip: %s %s %d bytes from %s", contentType, filename, len(data), source)
+	}
+}
+
+func TestHTTPAPI_QueryHeadersAndTimeout(t *testing.T) {
+	m := newTestManager(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(500 * time.Millisecond)
+		}
+		fmt.Fprintf(w, "%s|%s", r.URL.RawQuery, strings.Join(r.Header.Values("Accept"), ","))
+	}))
+	defer server.Close()
+
+	p := importHTTPTestPlugin(t, m, fmt.Sprintf(`
+    local url = http.build_url(%q, { b = "2", a = { "x y", "z" } })
+    local resp = http.get(url, { query = { c = 3 }, headers = { Accept = { "text/plain", "application/json" } } })
+    storage.set("query", http.encode_query({ q = "a&b" }) .. "|" .. resp.body)
+    local slow, err = http.get(%q, { timeout = 0.1 })
+    storage.set("timeout", tostring(slow == nil) .. "|" .. tostring(err))
+`, server.URL+"/echo?keep=1", server.URL+"/slow"))
+
+	if got := waitForStorage(t, m, p.ID, "query"); got != "q=a%26b|a=x+y&a=z&b=2&c=3&keep=1|text/plain,application/json" {
+		t.Errorf("Unexpected query and headers: %s", got)
+	}
+	if got := waitForStorage(t, m, p.ID, "timeout"); !strings.HasPrefix(got, "true|") || !strings.Contains(got, "deadline exceeded") {
+		t.Errorf("Expected the request to time out, got %s", got)
+	}
+}
@@ -0,0 +1,104 @@
+package plugin
+
+import (
+	"bytes"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// PackageAPI provides a sandboxed require and read access to bundled assets.
+// Modules and assets are resolved only inside the plugin's own package.
+type PackageAPI struct {
+	pkg     *Package
+	loaded  map[string]lua.LValue // module path -> cached return value
+	loading map[string]bool       // module paths currently being loaded (cycle detection)
+}
+
+// NewPackageAPI creates a new package API instance
+func NewPackageAPI(pkg *Package) *PackageAPI {
+	return &PackageAPI{
+		pkg:     pkg,
+		loaded:  make(map[string]lua.LValue),
+		loading: make(map[string]bool),
+	}
+}
+
+// Register adds require and the assets module to the Lua state
+func (p *PackageAPI) Register(L *lua.LState) {
+	L.SetGlobal("require", L.NewFunction(p.require))
+
+	assetsMod := L.NewTable()
+	assetsMod.RawSetString("read", L.NewFunction(p.readAsset))
+	assetsMod.RawSetString("list", L.NewFunction(p.listAssets))
+	L.SetGlobal("assets", assetsMod)
+}
+
+// require loads a Lua module from the package, caching its return value.
+// Errors are raised like the standard require so callers can pcall it.
+func (p *PackageAPI) require(L *lua.LState) int {
+	name := L.CheckString(1)
+
+	modPath, ok := p.pkg.resolveModule(name)
+	if !ok {
+		L.RaiseError("module '%s' not found in plugin package", name)
+		return 0
+	}
+
+	if val, ok := p.loaded[modPath]; ok {
+		L.Push(val)
+		return 1
+	}
+	if p.loading[modPath] {
+		L.RaiseError("loop while loading module '%s'", name)
+		return 0
+	}
+
+	fn, err := L.Load(bytes.NewReader(p.pkg.files[modPath]), "@"+modPath)
+	if err != nil {
+		L.RaiseError("error loading module '%s': %s", name, err.Error())
+		return 0
+	}
+
+	p.loading[modPath] = true
+	defer delete(p.loading, modPath)
+
+	L.Push(fn)
+	L.Push(lua.LString(name))
+	L.Call(1, 1)
+
+	val := L.Get(-1)
+	L.Pop(1)
+	if val == lua.LNil {
+		val = lua.LTrue
+	}
+	p.loaded[modPath] = val
+
+	L.Push(val)
+	return 1
+}
+
+// readAsset returns the content of a bundled file
+// Returns: content or nil, error
+func (p *PackageAPI) readAsset(L *lua.LState) int {
+	name := L.CheckString(1)
+
+	data, err := p.pkg.ReadFile(name)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	L.Push(lua.LString(string(data)))
+	return 1
+}
+
+// listAssets returns the paths of all files in the package
+func (p *PackageAPI) listAssets(L *lua.LState) int {
+	result := L.NewTable()
+	for _, name := range p.pkg.Files() {
+		result.Append(lua.LString(name))
+	}
+	L.Push(result)
+	return 1
+}
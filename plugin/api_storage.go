@@ -2,6 +2,7 @@ package plugin
 
 import (
 	"database/sql"
+	"fmt"
 	"log"
 
 	lua "github.com/yuin/gopher-lua"
@@ -9,16 +10,23 @@ import (
 
 // StorageAPI provides plugin-local key-value storage
 type StorageAPI struct {
-	db       *sql.DB
-	pluginID int64
+	db           *sql.DB
+	pluginID     int64
+	passwordKeys map[string]bool
 }
 
-// NewStorageAPI creates a new storage API instance
-func NewStorageAPI(db *sql.DB, pluginID int64) *StorageAPI {
-	return &StorageAPI{
-		db:       db,
-		pluginID: pluginID,
+// NewStorageAPI creates a new storage API instance. Keys of password settings
+// can't be written, their values live in the secrets store.
+func NewStorageAPI(db *sql.DB, pluginID int64, passwordKeys []string) *StorageAPI {
+	s := &StorageAPI{
+		db:           db,
+		pluginID:     pluginID,
+		passwordKeys: make(map[string]bool),
 	}
+	for _, key := range passwordKeys {
+		s.passwordKeys[key] = true
+	}
+	return s
 }
 
 // Register adds the storage module to the Lua state
@@ -60,6 +68,12 @@ func (s *StorageAPI) set(L *lua.LState) int {
 	key := L.CheckString(1)
 	value := L.CheckString(2)
 
+	if s.passwordKeys[key] {
+		L.Push(lua.LFalse)
+		L.Push(lua.LString(fmt.Sprintf("%s is a password setting, read it with secrets.get", key)))
+		return 2
+	}
+
 	_, err := s.db.Exec(`
 		INSERT INTO plugin_storage (plugin_id, key, value)
 		VALUES (?, ?, ?)
@@ -3,34 +3,30 @@ package plugin
 import (
 	"database/sql"
 	"log"
-	"strings"
+
+	"go-clipboard/store"
 
 	lua "github.com/yuin/gopher-lua"
 )
 
-// Tag color palette - MUST stay in sync with app.go:tagColors
-// Both copies exist because they're in different packages and this is a simple slice
-var tagColors = []string{
-	"#78716C", // stone
-	"#EF4444", // red
-	"#F59E0B", // amber
-	"#22C55E", // green
-	"#3B82F6", // blue
-	"#8B5CF6", // violet
-	"#EC4899", // pink
-	"#06B6D4", // cyan
-}
-
-const maxTagNameLength = 50
-
-// TagsAPI provides tag operations to plugins
+// TagsAPI provides tag operations to plugins. Reads query the database
+// directly; changes go through the store so events fire as for the user's changes.
 type TagsAPI struct {
-	db *sql.DB
+	db     *sql.DB
+	store  *store.Service
+	actor  store.Actor
+	scopes *scopeChecker
 }
 
-// NewTagsAPI creates a new tags API instance
-func NewTagsAPI(db *sql.DB) *TagsAPI {
-	return &TagsAPI{db: db}
+// NewTagsAPI creates a new tags API instance. Changes need the tags.write
+// scope from the plugin's manifest.
+func NewTagsAPI(db *sql.DB, st *store.Service, pluginID int64, manifest *Manifest) *TagsAPI {
+	return &TagsAPI{
+		db:     db,
+		store:  st,
+		actor:  store.Plugin(pluginID),
+		scopes: newScopeChecker(db, pluginID, manifest),
+	}
 }
 
 // Register adds the tags module to the Lua state
@@ -126,61 +122,25 @@ func (t *TagsAPI) get(L *lua.LState) int {
 
 // create creates a new tag with auto-assigned color
 func (t *TagsAPI) create(L *lua.LState) int {
-	name := strings.TrimSpace(L.CheckString(1))
-
-	if name == "" {
-		L.Push(lua.LNil)
-		L.Push(lua.LString("tag name cannot be empty"))
-		return 2
-	}
-	if len(name) > maxTagNameLength {
-		L.Push(lua.LNil)
-		L.Push(lua.LString("tag name too long"))
-		return 2
-	}
-
-	// Use transaction to prevent race condition in color assignment
-	tx, err := t.db.Begin()
-	if err != nil {
-		L.Push(lua.LNil)
-		L.Push(lua.LString(err.Error()))
-		return 2
-	}
-	defer tx.Rollback()
+	name := L.CheckString(1)
 
-	// Get count of existing tags to determine color
-	var count int
-	if err := tx.QueryRow("SELECT COUNT(*) FROM tags").Scan(&count); err != nil {
+	if err := t.scopes.checkTag(name); err != nil {
 		L.Push(lua.LNil)
 		L.Push(lua.LString(err.Error()))
 		return 2
 	}
-	color := tagColors[count%len(tagColors)]
 
-	result, err := tx.Exec("INSERT INTO tags (name, color) VALUES (?, ?)", name, color)
+	created, err := t.store.CreateTag(t.actor, name)
 	if err != nil {
-		if strings.Contains(err.Error(), "UNIQUE") {
-			L.Push(lua.LNil)
-			L.Push(lua.LString("tag already exists: " + name))
-			return 2
-		}
-		L.Push(lua.LNil)
-		L.Push(lua.LString(err.Error()))
-		return 2
-	}
-
-	id, _ := result.LastInsertId()
-
-	if err := tx.Commit(); err != nil {
 		L.Push(lua.LNil)
 		L.Push(lua.LString(err.Error()))
 		return 2
 	}
 
 	tag := L.NewTable()
-	tag.RawSetString("id", lua.LNumber(id))
-	tag.RawSetString("name", lua.LString(name))
-	tag.RawSetString("color", lua.LString(color))
+	tag.RawSetString("id", lua.LNumber(created.ID))
+	tag.RawSetString("name", lua.LString(created.Name))
+	tag.RawSetString("color", lua.LString(created.Color))
 	tag.RawSetString("count", lua.LNumber(0))
 
 	L.Push(tag)
@@ -211,30 +171,21 @@ func (t *TagsAPI) update(L *lua.LState) int {
 	color := currentColor
 
 	if nameVal := opts.RawGetString("name"); nameVal != lua.LNil {
-		name = strings.TrimSpace(nameVal.String())
-		if name == "" {
-			L.Push(lua.LFalse)
-			L.Push(lua.LString("tag name cannot be empty"))
-			return 2
-		}
-		if len(name) > maxTagNameLength {
-			L.Push(lua.LFalse)
-			L.Push(lua.LString("tag name too long"))
-			return 2
-		}
+		name = nameVal.String()
 	}
-
 	if colorVal := opts.RawGetString("color"); colorVal != lua.LNil {
 		color = colorVal.String()
 	}
 
-	_, err = t.db.Exec("UPDATE tags SET name = ?, color = ? WHERE id = ?", name, color, id)
-	if err != nil {
-		if strings.Contains(err.Error(), "UNIQUE") {
+	for _, tagName := range []string{currentName, name} {
+		if err := t.scopes.checkTag(tagName); err != nil {
 			L.Push(lua.LFalse)
-			L.Push(lua.LString("tag name already exists: " + name))
+			L.Push(lua.LString(err.Error()))
 			return 2
 		}
+	}
+
+	if err := t.store.UpdateTag(t.actor, id, name, color); err != nil {
 		L.Push(lua.LFalse)
 		L.Push(lua.LString(err.Error()))
 		return 2
@@ -248,8 +199,13 @@ func (t *TagsAPI) update(L *lua.LState) int {
 func (t *TagsAPI) deleteTag(L *lua.LState) int {
 	id := L.CheckInt64(1)
 
-	_, err := t.db.Exec("DELETE FROM tags WHERE id = ?", id)
-	if err != nil {
+	if err := t.scopes.checkTagID(id); err != nil {
+		L.Push(lua.LFalse)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	if err := t.store.DeleteTag(t.actor, id); err != nil {
 		L.Push(lua.LFalse)
 		L.Push(lua.LString(err.Error()))
 		return 2
@@ -264,24 +220,13 @@ func (t *TagsAPI) addToClip(L *lua.LState) int {
 	tagID := L.CheckInt64(1)
 	clipID := L.CheckInt64(2)
 
-	// Validate tag exists
-	var tagExists int
-	if err := t.db.QueryRow("SELECT 1 FROM tags WHERE id = ?", tagID).Scan(&tagExists); err == sql.ErrNoRows {
+	if err := t.checkTagging(tagID, clipID); err != nil {
 		L.Push(lua.LFalse)
-		L.Push(lua.LString("tag not found"))
-		return 2
-	}
-
-	// Validate clip exists
-	var clipExists int
-	if err := t.db.QueryRow("SELECT 1 FROM clips WHERE id = ?", clipID).Scan(&clipExists); err == sql.ErrNoRows {
-		L.Push(lua.LFalse)
-		L.Push(lua.LString("clip not found"))
+		L.Push(lua.LString(err.Error()))
 		return 2
 	}
 
-	_, err := t.db.Exec("INSERT OR IGNORE INTO clip_tags (clip_id, tag_id) VALUES (?, ?)", clipID, tagID)
-	if err != nil {
+	if err := t.store.AddTagToClip(t.actor, clipID, tagID); err != nil {
 		L.Push(lua.LFalse)
 		L.Push(lua.LString(err.Error()))
 		return 2
@@ -296,24 +241,13 @@ func (t *TagsAPI) removeFromClip(L *lua.LState) int {
 	tagID := L.CheckInt64(1)
 	clipID := L.CheckInt64(2)
 
-	// Validate tag exists
-	var tagExists int
-	if err := t.db.QueryRow("SELECT 1 FROM tags WHERE id = ?", tagID).Scan(&tagExists); err == sql.ErrNoRows {
+	if err := t.checkTagging(tagID, clipID); err != nil {
 		L.Push(lua.LFalse)
-		L.Push(lua.LString("tag not found"))
-		return 2
-	}
-
-	// Validate clip exists
-	var clipExists int
-	if err := t.db.QueryRow("SELECT 1 FROM clips WHERE id = ?", clipID).Scan(&clipExists); err == sql.ErrNoRows {
-		L.Push(lua.LFalse)
-		L.Push(lua.LString("clip not found"))
+		L.Push(lua.LString(err.Error()))
 		return 2
 	}
 
-	_, err := t.db.Exec("DELETE FROM clip_tags WHERE clip_id = ? AND tag_id = ?", clipID, tagID)
-	if err != nil {
+	if err := t.store.RemoveTagFromClip(t.actor, clipID, tagID); err != nil {
 		L.Push(lua.LFalse)
 		L.Push(lua.LString(err.Error()))
 		return 2
@@ -323,6 +257,14 @@ func (t *TagsAPI) removeFromClip(L *lua.LState) int {
 	return 1
 }
 
+// checkTagging checks that the plugin may change a tag on a clip
+func (t *TagsAPI) checkTagging(tagID, clipID int64) error {
+	if err := t.scopes.checkTagID(tagID); err != nil {
+		return err
+	}
+	return t.scopes.checkClips(ScopeTagsWrite, clipID)
+}
+
 // getForClip returns all tags for a specific clip
 func (t *TagsAPI) getForClip(L *lua.LState) int {
 	clipID := L.CheckInt64(1)
@@ -1,13 +1,11 @@
 package plugin
 
 import (
-	"context"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	lua "github.com/yuin/gopher-lua"
-	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
 const (
@@ -20,7 +18,7 @@ var globalTaskID int64
 
 // TaskAPI provides task queue integration for plugins
 type TaskAPI struct {
-	ctx      context.Context
+	emit     EventEmitter
 	tasks    map[int64]*pluginTask
 	taskMu   sync.RWMutex
 	pluginID int64
@@ -36,9 +34,9 @@ type pluginTask struct {
 }
 
 // NewTaskAPI creates a new task API instance
-func NewTaskAPI(ctx context.Context, pluginID int64) *TaskAPI {
+func NewTaskAPI(emit EventEmitter, pluginID int64) *TaskAPI {
 	return &TaskAPI{
-		ctx:      ctx,
+		emit:     emit,
 		tasks:    make(map[int64]*pluginTask),
 		pluginID: pluginID,
 	}
@@ -75,8 +73,8 @@ func (t *TaskAPI) start(L *lua.LState) int {
 	t.taskMu.Unlock()
 
 	// Emit task started event to frontend
-	if t.ctx != nil {
-		runtime.EventsEmit(t.ctx, "plugin:task:started", map[string]interface{}{
+	if t.emit != nil {
+		t.emit("plugin:task:started", map[string]interface{}{
 			"task_id":   taskID,
 			"plugin_id": t.pluginID,
 			"name":      name,
@@ -106,8 +104,8 @@ func (t *TaskAPI) progress(L *lua.LState) int {
 	}
 
 	// Emit progress event to frontend
-	if t.ctx != nil {
-		runtime.EventsEmit(t.ctx, "plugin:task:progress", map[string]interface{}{
+	if t.emit != nil {
+		t.emit("plugin:task:progress", map[string]interface{}{
 			"task_id":   taskID,
 			"plugin_id": t.pluginID,
 			"current":   current,
@@ -138,8 +136,8 @@ func (t *TaskAPI) complete(L *lua.LState) int {
 	}
 
 	// Emit completion event to frontend
-	if t.ctx != nil {
-		runtime.EventsEmit(t.ctx, "plugin:task:completed", map[string]interface{}{
+	if t.emit != nil {
+		t.emit("plugin:task:completed", map[string]interface{}{
 			"task_id":   taskID,
 			"plugin_id": t.pluginID,
 			"name":      task.Name,
@@ -172,8 +170,8 @@ func (t *TaskAPI) fail(L *lua.LState) int {
 	}
 
 	// Emit failure event to frontend
-	if t.ctx != nil {
-		runtime.EventsEmit(t.ctx, "plugin:task:failed", map[string]interface{}{
+	if t.emit != nil {
+		t.emit("plugin:task:failed", map[string]interface{}{
 			"task_id":   taskID,
 			"plugin_id": t.pluginID,
 			"name":      task.Name,
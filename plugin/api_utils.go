@@ -170,6 +170,12 @@ func goToLua(L *lua.LState, val interface{}) lua.LValue {
 		return lua.LNumber(v)
 	case string:
 		return lua.LString(v)
+	case []int64:
+		tbl := L.NewTable()
+		for _, item := range v {
+			tbl.Append(lua.LNumber(item))
+		}
+		return tbl
 	case []interface{}:
 		tbl := L.NewTable()
 		for i, item := range v {
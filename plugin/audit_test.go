@@ -0,0 +1,48 @@
+package plugin
+
+import (
+	"errors"
+	"testing"
+
+	"go-clipboard/store"
+)
+
+func TestManager_AuditsActionsAndAutoDisable(t *testing.T) {
+	m := newTestManager(t)
+
+	p := importTestPlugin(t, m, "audited.lua", `
+Plugin = {
+    name = "Audited",
+    permissions = { sensitive_clips = true },
+    ui = { card_actions = { { id = "fail", label = "Fail" } } },
+}
+function on_ui_action(action, clip_ids)
+    error("boom")
+end
+`)
+
+	if _, err := m.ExecuteUIAction(p.ID, "fail", []int64{4}, nil); err == nil {
+		t.Fatal("Expected the action to fail")
+	}
+	m.SetSensitiveClipsAccess(p.ID, true)
+	for i := 0; i < MaxConsecutiveErrors; i++ {
+		m.incrementErrorCount(p.ID, errors.New("handler failed"))
+	}
+
+	entries, err := m.store.QueryAudit(store.AuditFilter{PluginID: p.ID})
+	if err != nil {
+		t.Fatalf("QueryAudit failed: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %+v", entries)
+	}
+	if e := entries[0]; e.Action != store.AuditPluginAutoDisabled || e.Actor != store.ActorSystem || e.Details["last_error"] != "handler failed" {
+		t.Errorf("Expected the auto-disable entry, got %+v", e)
+	}
+	if e := entries[1]; e.Action != store.AuditPermissionGranted || e.Details["permission"] != PermissionSensitiveClips {
+		t.Errorf("Expected the grant entry, got %+v", e)
+	}
+	if e := entries[2]; e.Action != store.AuditPluginAction || e.Actor != store.ActorUser || e.Details["action"] != "fail" || e.Details["error"] == nil {
+		t.Errorf("Expected the failed action entry, got %+v", e)
+	}
+}
@@ -0,0 +1,177 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"log"
+)
+
+const (
+	// busQueueSize limits pending published messages; publishing fails when full
+	busQueueSize = 256
+
+	busMessageHandler = "on_bus_message"
+	busCallHandler    = "on_bus_call"
+)
+
+// busMessage is a published message waiting for delivery
+type busMessage struct {
+	from    int64
+	sender  string
+	topic   string
+	payload interface{}
+}
+
+// startBus starts the goroutine delivering published messages in order
+func (m *Manager) startBus() {
+	m.busQueue = make(chan busMessage, busQueueSize)
+	go func() {
+		for msg := range m.busQueue {
+			m.deliverBusMessage(msg)
+			m.pending.Add(-1)
+		}
+	}()
+}
+
+// Subscribe registers a plugin for messages on a topic it declared as consumed
+func (m *Manager) Subscribe(pluginID int64, manifest *Manifest, topic string) error {
+	if !manifest.Bus.CanConsume(topic) {
+		return fmt.Errorf("topic %s is not declared in bus.consumes", topic)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, id := range m.busSubscribers[topic] {
+		if id == pluginID {
+			return nil
+		}
+	}
+	m.busSubscribers[topic] = append(m.busSubscribers[topic], pluginID)
+	return nil
+}
+
+// Publish queues a message for all other plugins subscribed to the topic.
+// Delivery is asynchronous so publishers never wait on (or deadlock with) subscribers.
+func (m *Manager) Publish(pluginID int64, manifest *Manifest, topic string, payload interface{}) error {
+	if !manifest.Bus.CanProvide(topic) {
+		return fmt.Errorf("topic %s is not declared in bus.provides", topic)
+	}
+
+	m.pending.Add(1)
+	select {
+	case m.busQueue <- busMessage{from: pluginID, sender: manifest.Name, topic: topic, payload: payload}:
+		return nil
+	default:
+		m.pending.Add(-1)
+		return fmt.Errorf("message bus is full")
+	}
+}
+
+// deliverBusMessage calls on_bus_message on every subscriber except the sender,
+// with the same timeout and error accounting as events
+func (m *Manager) deliverBusMessage(msg busMessage) {
+	m.mu.RLock()
+	subscribers := make([]int64, len(m.busSubscribers[msg.topic]))
+	copy(subscribers, m.busSubscribers[msg.topic])
+	m.mu.RUnlock()
+
+	for _, pluginID := range subscribers {
+		if pluginID == msg.from {
+			continue
+		}
+
+		m.mu.RLock()
+		p, ok := m.plugins[pluginID]
+		m.mu.RUnlock()
+
+		if !ok || p.Sandbox == nil {
+			continue
+		}
+
+		_, err := p.Sandbox.CallBusHandler(busMessageHandler, MaxExecutionTime, msg.topic, msg.payload, msg.sender)
+		if errors.Is(err, errNoHandler) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Plugin %s bus handler for %s failed: %v", p.Name, msg.topic, err)
+			m.incrementErrorCount(pluginID, err)
+		} else {
+			m.resetErrorCount(pluginID)
+		}
+	}
+}
+
+// Call invokes a method provided by another plugin and returns its result.
+// The caller must declare the method in bus.consumes and the target in bus.provides.
+func (m *Manager) Call(pluginID int64, manifest *Manifest, target, method string, args interface{}) (interface{}, error) {
+	if !manifest.Bus.CanConsume(method) {
+		return nil, fmt.Errorf("method %s is not declared in bus.consumes", method)
+	}
+
+	m.mu.RLock()
+	var p *Plugin
+	for _, candidate := range m.plugins {
+		if candidate.Name == target {
+			p = candidate
+			break
+		}
+	}
+	m.mu.RUnlock()
+
+	if p == nil || p.Sandbox == nil || p.Manifest == nil {
+		return nil, fmt.Errorf("plugin not available: %s", target)
+	}
+	if p.ID == pluginID {
+		return nil, fmt.Errorf("plugin cannot call itself")
+	}
+	if !p.Manifest.Bus.CanProvide(method) {
+		return nil, fmt.Errorf("plugin %s does not provide %s", target, method)
+	}
+
+	// A plugin waiting on its own call can't serve one; refusing here fails
+	// call cycles (A -> B -> A) immediately instead of after the timeout
+	m.busMu.Lock()
+	if m.busCalling[p.ID] > 0 {
+		m.busMu.Unlock()
+		return nil, fmt.Errorf("plugin %s is busy with a bus call", target)
+	}
+	m.busCalling[pluginID]++
+	m.busMu.Unlock()
+
+	defer func() {
+		m.busMu.Lock()
+		m.busCalling[pluginID]--
+		if m.busCalling[pluginID] == 0 {
+			delete(m.busCalling, pluginID)
+		}
+		m.busMu.Unlock()
+	}()
+
+	result, err := p.Sandbox.CallBusHandler(busCallHandler, MaxExecutionTime, method, args, manifest.Name)
+	if errors.Is(err, errNoHandler) {
+		return nil, fmt.Errorf("plugin %s does not implement %s", target, busCallHandler)
+	}
+	if err != nil {
+		log.Printf("Plugin %s bus call %s failed: %v", p.Name, method, err)
+		m.incrementErrorCount(p.ID, err)
+		return nil, err
+	}
+	m.resetErrorCount(p.ID)
+
+	return result, nil
+}
+
+// removeBusSubscriptions drops all topic subscriptions of a plugin.
+// Caller must hold m.mu.
+func (m *Manager) removeBusSubscriptions(pluginID int64) {
+	for topic, subscribers := range m.busSubscribers {
+		newSubscribers := make([]int64, 0, len(subscribers))
+		for _, id := range subscribers {
+			if id != pluginID {
+				newSubscribers = append(newSubscribers, id)
+			}
+		}
+		m.busSubscribers[topic] = newSubscribers
+	}
+}
@@ -0,0 +1,90 @@
+package plugin
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBus_PublishSubscribe(t *testing.T) {
+	m := newTestManager(t)
+
+	receiver := importTestPlugin(t, m, "receiver.lua", `
+Plugin = { name = "Receiver", bus = { consumes = {"ocr.text"} } }
+bus.subscribe("ocr.text")
+function on_bus_message(topic, payload, sender)
+    storage.set("received", topic .. ":" .. payload.text .. ":" .. sender)
+end
+`)
+	publisher := importTestPlugin(t, m, "publisher.lua", `
+Plugin = { name = "Publisher", bus = { provides = {"ocr.text"} } }
+bus.publish("ocr.text", { text = "hello" })
+local ok, err = bus.publish("undeclared", {})
+storage.set("undeclared_error", err)
+`)
+
+	if got := waitForStorage(t, m, receiver.ID, "received"); got != "ocr.text:hello:Publisher" {
+		t.Errorf("Unexpected message: %s", got)
+	}
+	if got := waitForStorage(t, m, publisher.ID, "undeclared_error"); !strings.Contains(got, "bus.provides") {
+		t.Errorf("Expected provides error, got %s", got)
+	}
+}
+
+func TestBus_Call(t *testing.T) {
+	m := newTestManager(t)
+
+	translator := importTestPlugin(t, m, "translator.lua", `
+Plugin = { name = "Translator", bus = { provides = {"translate", "fail"} } }
+function on_bus_call(method, args, caller)
+    if method == "fail" then
+        error("boom")
+    end
+    return { text = string.upper(args.text), caller = caller }
+end
+`)
+	caller := importTestPlugin(t, m, "caller.lua", `
+Plugin = { name = "Caller", bus = { consumes = {"translate", "fail", "missing"} } }
+local result = bus.call("Translator", "translate", { text = "hola" })
+storage.set("result", result.text .. ":" .. result.caller)
+local _, fail_err = bus.call("Translator", "fail", {})
+storage.set("fail_error", fail_err)
+local _, missing_err = bus.call("Translator", "missing", {})
+storage.set("missing_error", missing_err)
+`)
+
+	if got := waitForStorage(t, m, caller.ID, "result"); got != "HOLA:Caller" {
+		t.Errorf("Unexpected call result: %s", got)
+	}
+	if got := waitForStorage(t, m, caller.ID, "fail_error"); !strings.Contains(got, "boom") {
+		t.Errorf("Expected handler error, got %s", got)
+	}
+	if got := waitForStorage(t, m, caller.ID, "missing_error"); !strings.Contains(got, "does not provide") {
+		t.Errorf("Expected provides error, got %s", got)
+	}
+
+	var errorCount int
+	m.db.QueryRow("SELECT error_count FROM plugins WHERE id = ?", translator.ID).Scan(&errorCount)
+	if errorCount != 1 {
+		t.Errorf("Expected failed call to count as an error, got %d", errorCount)
+	}
+}
+
+func TestParseManifest_Bus(t *testing.T) {
+	manifest, err := ParseManifest(`Plugin = {
+    name = "OCR",
+    events = {"clip:created"},
+    bus = {
+        provides = {"ocr.text", "recognize"},
+        consumes = {"translate"},
+    },
+}`)
+	if err != nil {
+		t.Fatalf("ParseManifest failed: %v", err)
+	}
+	if !manifest.Bus.CanProvide("recognize") || !manifest.Bus.CanConsume("translate") {
+		t.Errorf("Unexpected bus manifest: %+v", manifest.Bus)
+	}
+	if manifest.Bus.CanConsume("ocr.text") {
+		t.Errorf("Expected ocr.text to only be provided")
+	}
+}
@@ -0,0 +1,136 @@
+package plugin
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronSchedule_Next(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("failed to load timezone: %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		// Daily at 02:00
+		{"0 2 * * *", time.Date(2026, 3, 10, 1, 30, 0, 0, time.UTC), time.Date(2026, 3, 10, 2, 0, 0, 0, time.UTC)},
+		{"0 2 * * *", time.Date(2026, 3, 10, 2, 0, 0, 0, time.UTC), time.Date(2026, 3, 11, 2, 0, 0, 0, time.UTC)},
+		// Weekdays at 9 (Friday evening -> Monday)
+		{"0 9 * * mon-fri", time.Date(2026, 3, 13, 18, 0, 0, 0, time.UTC), time.Date(2026, 3, 16, 9, 0, 0, 0, time.UTC)},
+		// Every 15 minutes
+		{"*/15 * * * *", time.Date(2026, 3, 10, 10, 7, 30, 0, time.UTC), time.Date(2026, 3, 10, 10, 15, 0, 0, time.UTC)},
+		// First of the month, year rollover
+		{"@monthly", time.Date(2026, 12, 15, 0, 0, 0, 0, time.UTC), time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		// Day of month OR day of week when both are restricted
+		{"0 0 13 * fri", time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 6, 0, 0, 0, 0, time.UTC)},
+		// Sunday as 7
+		{"0 12 * * 7", time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)},
+		// Timezone: 02:30 doesn't exist on the spring-forward day in Berlin
+		{"30 2 * * *", time.Date(2026, 3, 29, 0, 0, 0, 0, berlin), time.Date(2026, 3, 30, 2, 30, 0, 0, berlin)},
+	}
+
+	for _, tt := range tests {
+		cron, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseCron(%q) failed: %v", tt.expr, err)
+		}
+		if got := cron.Next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("%q after %v: got %v, want %v", tt.expr, tt.from, got, tt.want)
+		}
+	}
+}
+
+func TestCronSchedule_Impossible(t *testing.T) {
+	cron, err := ParseCron("0 0 30 2 *")
+	if err != nil {
+		t.Fatalf("ParseCron failed: %v", err)
+	}
+	if next := cron.Next(time.Now()); !next.IsZero() {
+		t.Errorf("Expected no next run for February 30th, got %v", next)
+	}
+}
+
+func TestParseCron_Invalid(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "5-1 * * * *", "*/0 * * * *", "* * * foo *"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("Expected error for %q", expr)
+		}
+	}
+}
+
+func TestScheduledTask_FirstRun(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	daily, _ := ParseCron("0 2 * * *")
+
+	tests := []struct {
+		name string
+		task *ScheduledTask
+		want time.Time
+	}{
+		{
+			name: "never run cron waits for next match",
+			task: &ScheduledTask{cron: daily, loc: time.UTC, schedule: Schedule{CatchUp: true}},
+			want: time.Date(2026, 3, 11, 2, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "missed cron run catches up immediately",
+			task: &ScheduledTask{cron: daily, loc: time.UTC, schedule: Schedule{CatchUp: true}, lastRun: now.Add(-48 * time.Hour)},
+			want: now,
+		},
+		{
+			name: "missed cron run without catch-up skips",
+			task: &ScheduledTask{cron: daily, loc: time.UTC, lastRun: now.Add(-48 * time.Hour)},
+			want: time.Date(2026, 3, 11, 2, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "interval keeps its phase",
+			task: &ScheduledTask{loc: time.UTC, schedule: Schedule{Interval: 3600, CatchUp: true}, lastRun: now.Add(-20 * time.Minute)},
+			want: now.Add(40 * time.Minute),
+		},
+		{
+			name: "missed interval without catch-up keeps its phase",
+			task: &ScheduledTask{loc: time.UTC, schedule: Schedule{Interval: 3600}, lastRun: now.Add(-150 * time.Minute)},
+			want: now.Add(30 * time.Minute),
+		},
+	}
+
+	for _, tt := range tests {
+		if got := tt.task.firstRun(now); !got.Equal(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseManifest_CronSchedule(t *testing.T) {
+	manifest, err := ParseManifest(`Plugin = {
+    name = "Nightly",
+    schedules = {
+        {name = "cleanup", cron = "0 2 * * *", timezone = "Europe/Berlin"},
+        {name = "sync", interval = 300, catch_up = false},
+    },
+}`)
+	if err != nil {
+		t.Fatalf("ParseManifest failed: %v", err)
+	}
+	if len(manifest.Schedules) != 2 {
+		t.Fatalf("Expected 2 schedules, got %d", len(manifest.Schedules))
+	}
+	cleanup := manifest.Schedules[0]
+	if cleanup.Cron != "0 2 * * *" || cleanup.Timezone != "Europe/Berlin" || !cleanup.CatchUp {
+		t.Errorf("Unexpected cron schedule: %+v", cleanup)
+	}
+	if manifest.Schedules[1].CatchUp {
+		t.Errorf("Expected catch_up = false to be parsed")
+	}
+
+	if _, err := ParseManifest(`Plugin = { name = "Bad", schedules = { {name = "x", cron = "0 25 * * *"} } }`); err == nil {
+		t.Error("Expected error for invalid cron expression")
+	}
+	if _, err := ParseManifest(`Plugin = { name = "Bad", schedules = { {name = "x", cron = "@daily", timezone = "Mars/Olympus"} } }`); err == nil {
+		t.Error("Expected error for unknown timezone")
+	}
+}
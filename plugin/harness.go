@@ -0,0 +1,192 @@
+package plugin
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go-clipboard/store"
+)
+
+// HarnessEvent is an event a plugin sent to the frontend, such as a toast
+type HarnessEvent struct {
+	Name string
+	Data interface{}
+}
+
+// Harness runs plugins without the app: a Manager on a database with the app's
+// schema, frontend events captured instead of sent, schedules on a fake clock
+// and HTTP requests answered from fixtures. Every call waits until the events
+// and messages it caused have been handled, so results can be checked right away.
+type Harness struct {
+	Manager   *Manager
+	DB        *sql.DB
+	Store     *store.Service
+	Scheduler *Scheduler
+
+	mu     sync.Mutex
+	events []HarnessEvent
+	replay *ReplayTransport
+}
+
+// NewHarness creates a harness on db, which must have the app's schema.
+// Plugins are installed in dir and the fake clock starts at start.
+func NewHarness(db *sql.DB, dir string, start time.Time) (*Harness, error) {
+	st := store.New(db)
+	m, err := NewManager(context.Background(), db, st, filepath.Join(dir, "plugins"))
+	if err != nil {
+		return nil, err
+	}
+
+	h := &Harness{
+		Manager:   m,
+		DB:        db,
+		Store:     st,
+		Scheduler: NewManualScheduler(db, start),
+	}
+	m.SetEventEmitter(h.capture)
+	m.SetScheduler(h.Scheduler)
+	// Requests fail until fixtures are set, so tests never reach the network
+	h.SetFixtures(&HTTPFixtures{})
+	return h, nil
+}
+
+// capture records an event sent to the frontend
+func (h *Harness) capture(name string, data interface{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, HarnessEvent{Name: name, Data: data})
+}
+
+// SetFixtures answers plugin HTTP requests from fixtures. It applies to
+// plugins loaded afterwards.
+func (h *Harness) SetFixtures(fixtures *HTTPFixtures) *ReplayTransport {
+	h.replay = NewReplayTransport(fixtures)
+	h.Manager.SetTransport(h.replay)
+	return h.replay
+}
+
+// Replay returns the transport answering plugin HTTP requests
+func (h *Harness) Replay() *ReplayTransport {
+	return h.replay
+}
+
+// Load imports a plugin file, granting the scopes and approving the domains
+// it declares
+func (h *Harness) Load(path string) (*Plugin, error) {
+	pkg, err := OpenPackage(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid plugin: %w", err)
+	}
+	manifest, err := ParseManifest(pkg.Main)
+	if err != nil {
+		return nil, fmt.Errorf("invalid plugin: %w", err)
+	}
+
+	p, err := h.Manager.ImportPlugin(path, DeclaredDomains(manifest))
+	if err != nil {
+		return nil, err
+	}
+	return p, h.wait()
+}
+
+// CreateClip adds a clip as the user would, delivering clip:created to plugins
+func (h *Harness) CreateClip(clip store.NewClip) (int64, error) {
+	created, err := h.Store.CreateClip(store.User, clip)
+	if err != nil {
+		return 0, err
+	}
+	return created.ID, h.wait()
+}
+
+// Emit sends an event to subscribed plugins
+func (h *Harness) Emit(event string, data interface{}) error {
+	h.Manager.EmitEvent(event, data)
+	return h.wait()
+}
+
+// RunAction runs a UI action. Async actions are waited for too.
+func (h *Harness) RunAction(pluginID int64, actionID string, clipIDs []int64, options map[string]interface{}) (*ActionResult, error) {
+	result, err := h.Manager.ExecuteUIAction(pluginID, actionID, clipIDs, options)
+	if err != nil {
+		return nil, err
+	}
+	return result, h.wait()
+}
+
+// Advance moves the fake clock forward, running the schedules that come due,
+// and returns the number of runs
+func (h *Harness) Advance(d time.Duration) (int, error) {
+	runs := h.Scheduler.Advance(d)
+	return runs, h.wait()
+}
+
+// Events returns the captured events with the given name, or all of them if
+// name is empty
+func (h *Harness) Events(name string) []HarnessEvent {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var events []HarnessEvent
+	for _, e := range h.events {
+		if name == "" || e.Name == name {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// Toasts returns the messages of the toasts plugins showed, in order
+func (h *Harness) Toasts() []string {
+	var messages []string
+	for _, e := range h.Events("plugin:toast") {
+		if data, ok := e.Data.(map[string]string); ok {
+			messages = append(messages, data["message"])
+		}
+	}
+	return messages
+}
+
+// Storage returns a value a plugin stored, and whether it exists
+func (h *Harness) Storage(pluginID int64, key string) (string, bool) {
+	var value []byte
+	err := h.DB.QueryRow("SELECT value FROM plugin_storage WHERE plugin_id = ? AND key = ?", pluginID, key).Scan(&value)
+	if err != nil {
+		return "", false
+	}
+	return string(value), true
+}
+
+// QueryValue returns the first column of the first row of a query as text,
+// for checking the database after plugins ran
+func (h *Harness) QueryValue(query string, args ...interface{}) (string, error) {
+	var value interface{}
+	if err := h.DB.QueryRow(query, args...).Scan(&value); err != nil {
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("query returned no rows")
+		}
+		return "", err
+	}
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case []byte:
+		return string(v), nil
+	case time.Time:
+		return v.UTC().Format("2006-01-02 15:04:05"), nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+// Close stops the plugins
+func (h *Harness) Close() {
+	h.Manager.Shutdown()
+}
+
+// wait waits until plugins handled the events and messages caused so far
+func (h *Harness) wait() error {
+	return h.Manager.waitIdle(MaxUIActionTime)
+}
@@ -0,0 +1,232 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go-clipboard/store"
+)
+
+// Spec is a plugin test run by `mahpastes plugin test`: a plugin, optional
+// HTTP fixtures and steps run in order on a fresh database.
+//
+//	{
+//	  "plugin": "word-count.lua",
+//	  "steps": [
+//	    {"clip": {"content_type": "text/plain", "data": "one two three"}},
+//	    {"action": "count", "clips": [1]},
+//	    {"expect": {"storage": "last_count", "equals": "3"}}
+//	  ]
+//	}
+type Spec struct {
+	Plugin   string     `json:"plugin"`             // plugin file, relative to the spec
+	Fixtures string     `json:"fixtures,omitempty"` // HTTP fixture file, relative to the spec
+	Start    string     `json:"start,omitempty"`    // RFC3339 time the fake clock starts at
+	Steps    []SpecStep `json:"steps"`
+
+	dir string
+}
+
+// SpecStep is one step of a spec. Exactly one of its fields is set.
+type SpecStep struct {
+	Clip    *SpecClip              `json:"clip,omitempty"`    // creates a clip as the user
+	Emit    string                 `json:"emit,omitempty"`    // sends an event to the plugin
+	Data    map[string]interface{} `json:"data,omitempty"`    // data of the emitted event
+	Action  string                 `json:"action,omitempty"`  // runs a UI action
+	Clips   []int64                `json:"clips,omitempty"`   // clip IDs the action runs on
+	Options map[string]interface{} `json:"options,omitempty"` // options passed to the action
+	Error   string                 `json:"error,omitempty"`   // error the action is expected to fail with
+	Advance string                 `json:"advance,omitempty"` // moves the fake clock, e.g. "1h30m"
+	Expect  *SpecExpect            `json:"expect,omitempty"`  // checks the state
+}
+
+// SpecClip is a clip created by a spec step
+type SpecClip struct {
+	ContentType string `json:"content_type"`
+	Data        string `json:"data"`
+	Filename    string `json:"filename,omitempty"`
+}
+
+// SpecExpect checks the state after the previous steps. SQL and Storage
+// compare a value with Equals; Toast and Event look for a captured event.
+type SpecExpect struct {
+	SQL     string  `json:"sql,omitempty"`     // query returning a single value
+	Storage string  `json:"storage,omitempty"` // key in the plugin's storage
+	Equals  *string `json:"equals,omitempty"`
+	Toast   string  `json:"toast,omitempty"` // text a toast contains
+	Event   string  `json:"event,omitempty"` // name of a frontend event, e.g. "plugin:task:completed"
+}
+
+// StepResult is the outcome of a spec step
+type StepResult struct {
+	Step  int    // 1-based position in the spec
+	Name  string // what the step did
+	Error string // empty if the step passed
+}
+
+// LoadSpec reads a spec file
+func LoadSpec(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spec: %w", err)
+	}
+	var spec Spec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("invalid spec %s: %w", path, err)
+	}
+	if spec.Plugin == "" {
+		return nil, fmt.Errorf("invalid spec %s: no plugin", path)
+	}
+	spec.dir = filepath.Dir(path)
+	return &spec, nil
+}
+
+// path resolves a file named in the spec
+func (s *Spec) path(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(s.dir, name)
+}
+
+// StartTime returns when the spec's fake clock starts, now if not set
+func (s *Spec) StartTime() (time.Time, error) {
+	if s.Start == "" {
+		return time.Now(), nil
+	}
+	start, err := time.Parse(time.RFC3339, s.Start)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid start time: %w", err)
+	}
+	return start, nil
+}
+
+// RunSpec loads the spec's plugin and fixtures and runs its steps. Steps run
+// after a failed expectation, but not after a step that failed to run.
+func (h *Harness) RunSpec(spec *Spec) ([]StepResult, error) {
+	if spec.Fixtures != "" {
+		fixtures, err := LoadHTTPFixtures(spec.path(spec.Fixtures))
+		if err != nil {
+			return nil, err
+		}
+		h.SetFixtures(fixtures)
+	}
+
+	p, err := h.Load(spec.path(spec.Plugin))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load plugin: %w", err)
+	}
+
+	var results []StepResult
+	for i, step := range spec.Steps {
+		name, err := h.runStep(p.ID, step)
+		result := StepResult{Step: i + 1, Name: name}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+		if err != nil && step.Expect == nil {
+			break
+		}
+	}
+	return results, nil
+}
+
+// runStep runs a step and describes it
+func (h *Harness) runStep(pluginID int64, step SpecStep) (string, error) {
+	switch {
+	case step.Clip != nil:
+		id, err := h.CreateClip(store.NewClip{
+			ContentType: step.Clip.ContentType,
+			Data:        []byte(step.Clip.Data),
+			Filename:    step.Clip.Filename,
+		})
+		return fmt.Sprintf("create clip %d", id), err
+
+	case step.Emit != "":
+		return "emit " + step.Emit, h.Emit(step.Emit, step.Data)
+
+	case step.Action != "":
+		name := "action " + step.Action
+		result, err := h.RunAction(pluginID, step.Action, step.Clips, step.Options)
+		if err == nil && result.Error != "" {
+			err = fmt.Errorf("%s", result.Error)
+		}
+		if step.Error == "" {
+			return name, err
+		}
+		if err == nil {
+			return name, fmt.Errorf("expected the action to fail with %q", step.Error)
+		}
+		if !strings.Contains(err.Error(), step.Error) {
+			return name, fmt.Errorf("expected the action to fail with %q, got %q", step.Error, err.Error())
+		}
+		return name, nil
+
+	case step.Advance != "":
+		d, err := time.ParseDuration(step.Advance)
+		if err != nil {
+			return "advance " + step.Advance, fmt.Errorf("invalid duration: %w", err)
+		}
+		runs, err := h.Advance(d)
+		return fmt.Sprintf("advance %s (%d scheduled runs)", step.Advance, runs), err
+
+	case step.Expect != nil:
+		return h.checkExpect(pluginID, step.Expect)
+	}
+	return "empty step", fmt.Errorf("step has nothing to do")
+}
+
+// checkExpect checks an expectation and describes it
+func (h *Harness) checkExpect(pluginID int64, expect *SpecExpect) (string, error) {
+	switch {
+	case expect.SQL != "":
+		name := "expect " + expect.SQL
+		got, err := h.QueryValue(expect.SQL)
+		if err != nil {
+			return name, err
+		}
+		return name, compareExpected(got, expect.Equals)
+
+	case expect.Storage != "":
+		name := "expect storage " + expect.Storage
+		got, ok := h.Storage(pluginID, expect.Storage)
+		if !ok {
+			return name, fmt.Errorf("key %s is not set", expect.Storage)
+		}
+		return name, compareExpected(got, expect.Equals)
+
+	case expect.Toast != "":
+		name := fmt.Sprintf("expect toast %q", expect.Toast)
+		toasts := h.Toasts()
+		for _, message := range toasts {
+			if strings.Contains(message, expect.Toast) {
+				return name, nil
+			}
+		}
+		return name, fmt.Errorf("no toast contains %q (got %q)", expect.Toast, toasts)
+
+	case expect.Event != "":
+		name := "expect event " + expect.Event
+		if len(h.Events(expect.Event)) == 0 {
+			return name, fmt.Errorf("event %s was not sent", expect.Event)
+		}
+		return name, nil
+	}
+	return "empty expect", fmt.Errorf("expect has nothing to check")
+}
+
+// compareExpected fails if got differs from the expected value
+func compareExpected(got string, want *string) error {
+	if want == nil {
+		return fmt.Errorf("expect needs a value in equals")
+	}
+	if got != *want {
+		return fmt.Errorf("expected %q, got %q", *want, got)
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestHarness creates a Harness on a temporary database with its clock at start
+func newTestHarness(t *testing.T, start time.Time) *Harness {
+	t.Helper()
+
+	h, err := NewHarness(newTestDB(t), t.TempDir(), start)
+	if err != nil {
+		t.Fatalf("NewHarness failed: %v", err)
+	}
+	t.Cleanup(h.Close)
+	return h
+}
+
+// writeTestFile writes a file in dir and returns its path
+func writeTestFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestHarness_SchedulesRunOnFakeClock(t *testing.T) {
+	start := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	h := newTestHarness(t, start)
+
+	path := writeTestFile(t, t.TempDir(), "ticker.lua", `
+Plugin = {
+    name = "Ticker",
+    schedules = {
+        {name = "tick", interval = 600},
+        {name = "nightly", cron = "0 2 * * *", timezone = "UTC"},
+    },
+}
+function tick()
+    local count = tonumber(storage.get("ticks") or "0") + 1
+    storage.set("ticks", tostring(count))
+    if count == 6 then
+        toast.show("six ticks", "success")
+    end
+end
+function nightly()
+    storage.set("nightly", storage.get("ticks"))
+end
+`)
+	p, err := h.Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	runs, err := h.Advance(time.Hour)
+	if err != nil || runs != 6 {
+		t.Fatalf("Expected 6 runs in an hour, got %d (%v)", runs, err)
+	}
+	if got, _ := h.Storage(p.ID, "ticks"); got != "6" {
+		t.Errorf("Expected 6 ticks, got %q", got)
+	}
+	if toasts := h.Toasts(); len(toasts) != 1 || toasts[0] != "six ticks" {
+		t.Errorf("Unexpected toasts: %q", toasts)
+	}
+	if !h.Scheduler.Now().Equal(start.Add(time.Hour)) {
+		t.Errorf("Expected the clock at %v, got %v", start.Add(time.Hour), h.Scheduler.Now())
+	}
+
+	// The nightly task runs once at 02:00, before the tick due at the same
+	// time (tasks due together run in order of their names)
+	if _, err := h.Advance(17 * time.Hour); err != nil {
+		t.Fatalf("Advance failed: %v", err)
+	}
+	if got, _ := h.Storage(p.ID, "nightly"); got != "101" {
+		t.Errorf("Expected the nightly task to see 101 ticks, got %q", got)
+	}
+
+	statuses, err := h.Manager.GetTaskStatus(p.ID)
+	if err != nil {
+		t.Fatalf("GetTaskStatus failed: %v", err)
+	}
+	if len(statuses) != 2 || statuses[0].LastRun != "2025-03-02T02:00:00Z" || statuses[1].NextRun != "2025-03-02T03:10:00Z" {
+		t.Errorf("Unexpected task status: %+v", statuses)
+	}
+}
+
+func TestHarness_RunSpec(t *testing.T) {
+	h := newTestHarness(t, time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC))
+
+	dir := t.TempDir()
+	writeTestFile(t, dir, "shout.lua", `
+Plugin = {
+    name = "Shout",
+    events = {"clip:created"},
+    scopes = {"clips.read", "clips.write"},
+    network = { ["api.example.com"] = {"POST"} },
+    ui = { card_actions = {
+        { id = "shout", label = "Shout" },
+        { id = "translate", label = "Translate", async = true },
+    } },
+}
+function on_clip_created(clip)
+    storage.set("last_created", tostring(clip.id))
+end
+function on_ui_action(action, clip_ids, options)
+    local data = clips.get_data(clip_ids[1])
+    if action == "shout" then
+        local created = clips.create({ data = string.upper(data) .. (options.suffix or ""), content_type = "text/plain" })
+        return { success = true, result_clip_id = created.id }
+    end
+    local task_id = task.start("Translating", 1)
+    local resp, err = http.post("https://api.example.com/translate", { body = data })
+    if not resp then
+        task.fail(task_id, err)
+        return { success = false, error = err }
+    end
+    storage.set("translation", resp.body)
+    task.complete(task_id)
+    toast.show("Translated", "success")
+    return { success = true }
+end
+`)
+	writeTestFile(t, dir, "fixtures.json", `{
+  "interactions": [
+    {
+      "request": { "method": "POST", "url": "https://api.example.com/translate", "body_contains": "hello" },
+      "response": { "status": 200, "body": "bonjour" }
+    }
+  ]
+}`)
+	specPath := writeTestFile(t, dir, "shout.test.json", `{
+  "plugin": "shout.lua",
+  "fixtures": "fixtures.json",
+  "steps": [
+    {"clip": {"content_type": "text/plain", "data": "hello"}},
+    {"expect": {"storage": "last_created", "equals": "1"}},
+    {"action": "shout", "clips": [1], "options": {"suffix": "!"}},
+    {"expect": {"sql": "SELECT data FROM clips WHERE parent_id = 1", "equals": "HELLO!"}},
+    {"action": "translate", "clips": [1]},
+    {"expect": {"storage": "translation", "equals": "bonjour"}},
+    {"expect": {"toast": "Translated"}},
+    {"expect": {"event": "plugin:task:completed"}},
+    {"expect": {"sql": "SELECT COUNT(*) FROM clips", "equals": "3"}},
+    {"action": "missing", "error": "unknown action ID"}
+  ]
+}`)
+
+	spec, err := LoadSpec(specPath)
+	if err != nil {
+		t.Fatalf("LoadSpec failed: %v", err)
+	}
+	results, err := h.RunSpec(spec)
+	if err != nil {
+		t.Fatalf("RunSpec failed: %v", err)
+	}
+	if len(results) != len(spec.Steps) {
+		t.Fatalf("Expected %d results, got %+v", len(spec.Steps), results)
+	}
+
+	// Only the deliberately wrong clip count fails, and later steps still run
+	for _, r := range results {
+		if r.Step == 9 {
+			if !strings.Contains(r.Error, `expected "3", got "2"`) {
+				t.Errorf("Expected the clip count to fail, got %+v", r)
+			}
+			continue
+		}
+		if r.Error != "" {
+			t.Errorf("Step %d (%s) failed: %s", r.Step, r.Name, r.Error)
+		}
+	}
+	if unused := h.Replay().Unused(); len(unused) != 0 {
+		t.Errorf("Expected every fixture to be used, got %+v", unused)
+	}
+}
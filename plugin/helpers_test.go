@@ -0,0 +1,75 @@
+package plugin
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-clipboard/schema"
+	"go-clipboard/store"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// newTestDB creates a temporary database with the app's schema
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db")+"?_foreign_keys=on")
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := schema.Migrate(db); err != nil {
+		t.Fatalf("schema.Migrate failed: %v", err)
+	}
+	return db
+}
+
+// newTestManager creates a Manager backed by a temporary database with the app's schema
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+
+	db := newTestDB(t)
+	m, err := NewManager(context.Background(), db, store.New(db), filepath.Join(t.TempDir(), "plugins"))
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	t.Cleanup(m.Shutdown)
+	return m
+}
+
+// importTestPlugin writes a single-file plugin and imports it
+func importTestPlugin(t *testing.T, m *Manager, filename, source string) *Plugin {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), filename)
+	if err := os.WriteFile(path, []byte(source), 0644); err != nil {
+		t.Fatalf("failed to write plugin: %v", err)
+	}
+	p, err := m.ImportPlugin(path, nil)
+	if err != nil {
+		t.Fatalf("ImportPlugin failed: %v", err)
+	}
+	return p
+}
+
+// waitForStorage polls a plugin's storage until the key is set
+func waitForStorage(t *testing.T, m *Manager, pluginID int64, key string) string {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		var value string
+		err := m.db.QueryRow("SELECT value FROM plugin_storage WHERE plugin_id = ? AND key = ?", pluginID, key).Scan(&value)
+		if err == nil {
+			return value
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("storage key %s was never set", key)
+	return ""
+}
@@ -3,16 +3,30 @@ package plugin
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-clipboard/store"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
 const (
 	MaxConsecutiveErrors = 3
+
+	// MaxEventDepth limits chains of plugins reacting to each other's changes
+	MaxEventDepth = 4
+
+	// eventQueueSize limits pending events caused by plugins
+	eventQueueSize = 256
 )
 
 // ActionResult represents the result of a plugin action execution
@@ -22,32 +36,58 @@ type ActionResult struct {
 	ResultClipID int64  `json:"result_clip_id,omitempty"`
 }
 
+// EventEmitter sends an event such as "plugin:toast" to the frontend
+type EventEmitter func(name string, data interface{})
+
 // Plugin represents a loaded plugin
 type Plugin struct {
-	ID       int64
-	Filename string
-	Name     string
-	Version  string
-	Enabled  bool
-	Status   string
-	Manifest *Manifest
-	Sandbox  *Sandbox
+	ID        int64
+	Filename  string
+	Name      string
+	Version   string
+	Enabled   bool
+	Status    string
+	Signature SignatureStatus
+	Manifest  *Manifest
+	Sandbox   *Sandbox
 }
 
 // Manager manages all plugins
 type Manager struct {
 	ctx              context.Context
 	db               *sql.DB
+	store            *store.Service
 	plugins          map[int64]*Plugin
 	eventSubscribers map[string][]int64 // event -> plugin IDs
 	scheduler        *Scheduler
 	permCallback     PermissionCallback
+	transport        http.RoundTripper // nil uses http.DefaultTransport
+	emit             EventEmitter
 	mu               sync.RWMutex
 	pluginsDir       string
+	secrets          *SecretStore
+	sensitive        *sensitiveData // data of sensitive clips, checked in plugin requests
+	eventQueue       chan queuedEvent
+	pending          atomic.Int64 // queued events, bus messages and async actions not yet handled
+
+	// Message bus state
+	busSubscribers map[string][]int64 // topic -> plugin IDs
+	busQueue       chan busMessage
+	busCalling     map[int64]int // plugin ID -> bus calls in progress
+	busMu          sync.Mutex
 }
 
-// NewManager creates a new plugin manager
-func NewManager(ctx context.Context, db *sql.DB, pluginsDir string) (*Manager, error) {
+// queuedEvent is an event caused by a plugin, waiting for delivery
+type queuedEvent struct {
+	origin int64 // plugin that made the change
+	depth  int
+	name   string
+	data   interface{}
+}
+
+// NewManager creates a new plugin manager. Plugins change clips and tags through
+// st, and its change events are delivered to subscribed plugins.
+func NewManager(ctx context.Context, db *sql.DB, st *store.Service, pluginsDir string) (*Manager, error) {
 	// Ensure plugins directory exists
 	if err := os.MkdirAll(pluginsDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create plugins directory: %w", err)
@@ -56,11 +96,21 @@ func NewManager(ctx context.Context, db *sql.DB, pluginsDir string) (*Manager, e
 	m := &Manager{
 		ctx:              ctx,
 		db:               db,
+		store:            st,
 		plugins:          make(map[int64]*Plugin),
 		eventSubscribers: make(map[string][]int64),
-		scheduler:        NewScheduler(),
+		scheduler:        NewScheduler(db),
+		emit:             wailsEmitter(ctx),
 		pluginsDir:       pluginsDir,
+		secrets:          NewSecretStore(db, st, filepath.Join(filepath.Dir(pluginsDir), SecretsKeyFile)),
+		sensitive:        newSensitiveData(),
+		busSubscribers:   make(map[string][]int64),
+		busCalling:       make(map[int64]int),
 	}
+	m.startBus()
+	m.startEventQueue()
+	st.Subscribe(m.handleStoreEvent)
+	st.Subscribe(m.sensitive.handleStoreEvent)
 
 	return m, nil
 }
@@ -70,6 +120,33 @@ func (m *Manager) SetPermissionCallback(callback PermissionCallback) {
 	m.permCallback = callback
 }
 
+// wailsEmitter sends events to the frontend through the Wails runtime
+func wailsEmitter(ctx context.Context) EventEmitter {
+	return func(name string, data interface{}) {
+		runtime.EventsEmit(ctx, name, data)
+	}
+}
+
+// SetEventEmitter replaces the Wails runtime as the receiver of toast and task
+// events, e.g. to capture them in tests. It applies to plugins loaded afterwards.
+func (m *Manager) SetEventEmitter(emit EventEmitter) {
+	m.emit = emit
+}
+
+// SetScheduler replaces the scheduler running plugin schedules, e.g. with a
+// manual one in tests. It applies to plugins loaded afterwards.
+func (m *Manager) SetScheduler(scheduler *Scheduler) {
+	m.scheduler.StopAll()
+	m.scheduler = scheduler
+}
+
+// SetTransport sets the transport plugin HTTP requests are sent with, such as
+// a ReplayTransport in tests. It applies to plugins loaded afterwards; nil
+// restores the default transport.
+func (m *Manager) SetTransport(transport http.RoundTripper) {
+	m.transport = transport
+}
+
 // LoadPlugins loads all enabled plugins from the database
 func (m *Manager) LoadPlugins() error {
 	rows, err := m.db.Query(`
@@ -92,7 +169,7 @@ func (m *Manager) LoadPlugins() error {
 
 		if err := m.loadPlugin(&p); err != nil {
 			log.Printf("Failed to load plugin %s: %v", p.Name, err)
-			m.incrementErrorCount(p.ID)
+			m.incrementErrorCount(p.ID, err)
 			continue
 		}
 	}
@@ -101,51 +178,90 @@ func (m *Manager) LoadPlugins() error {
 }
 
 func (m *Manager) loadPlugin(p *Plugin) error {
-	// Read plugin source
+	// Read plugin source (single .lua file or zipped package)
 	sourcePath := filepath.Join(m.pluginsDir, p.Filename)
-	source, err := os.ReadFile(sourcePath)
+	pkg, err := OpenPackage(sourcePath)
 	if err != nil {
-		return fmt.Errorf("failed to read plugin file: %w", err)
+		return err
+	}
+
+	// Verify the signature on every load so tampered files are caught
+	status, err := CheckTrust(pkg, LoadTrustPolicy(m.db))
+	if err != nil {
+		return fmt.Errorf("signature check failed: %w", err)
+	}
+	p.Signature = status
+
+	// Plugins installed before signers were recorded keep their current one
+	if _, err := m.db.Exec("UPDATE plugins SET signer = ? WHERE id = ? AND signer IS NULL", status.Key, p.ID); err != nil {
+		log.Printf("Failed to record signer of plugin %d: %v", p.ID, err)
 	}
 
 	// Parse manifest
-	manifest, err := ParseManifest(string(source))
+	manifest, err := ParseManifest(pkg.Main)
 	if err != nil {
 		return fmt.Errorf("failed to parse manifest: %w", err)
 	}
 	p.Manifest = manifest
 
+	if err := m.migrateScopes(p.ID, manifest); err != nil {
+		log.Printf("Plugin %s: failed to grant its scopes: %v", manifest.Name, err)
+	}
+	if err := m.migrateDomains(p.ID, manifest); err != nil {
+		log.Printf("Plugin %s: failed to approve its network domains: %v", manifest.Name, err)
+	}
+
+	// Move password settings saved in plaintext by older versions
+	if err := m.secrets.MigrateStorage(p.ID, manifest.PasswordKeys()); err != nil {
+		log.Printf("Plugin %s: failed to move password settings to the secrets store: %v", manifest.Name, err)
+	}
+
 	// Create sandbox
 	sandbox := NewSandbox(manifest, p.ID)
 
 	// Register APIs
-	clipsAPI := NewClipsAPI(m.db, manifest.Network)
+	clipsAPI := NewClipsAPI(m.db, m.store, sandbox, manifest.Network, m.transport, m.sensitive)
 	clipsAPI.Register(sandbox.GetState())
 
-	storageAPI := NewStorageAPI(m.db, p.ID)
+	storageAPI := NewStorageAPI(m.db, p.ID, manifest.PasswordKeys())
 	storageAPI.Register(sandbox.GetState())
 
-	httpAPI := NewHTTPAPI(manifest.Network)
+	secretsAPI := NewSecretsAPI(m.secrets, p.ID)
+	secretsAPI.Register(sandbox.GetState())
+
+	httpAPI := NewHTTPAPI(clipsAPI)
 	httpAPI.Register(sandbox.GetState())
 
-	fsAPI := NewFilesystemAPI(m.db, p.ID, manifest.Name, manifest.Filesystem, m.permCallback)
+	fsAPI := NewFilesystemAPI(m.db, m.store, p.ID, manifest.Name, manifest.Filesystem, m.permCallback)
 	fsAPI.Register(sandbox.GetState())
 
 	utilsAPI := NewUtilsAPI(manifest.Name)
 	utilsAPI.Register(sandbox.GetState())
 
-	tagsAPI := NewTagsAPI(m.db)
+	tagsAPI := NewTagsAPI(m.db, m.store, p.ID, manifest)
 	tagsAPI.Register(sandbox.GetState())
 
-	toastAPI := NewToastAPI(m.ctx, p.ID)
+	collectionsAPI := NewCollectionsAPI(m.store, p.ID, clipsAPI.scopes)
+	collectionsAPI.Register(sandbox.GetState())
+
+	toastAPI := NewToastAPI(m.emit, p.ID)
 	toastAPI.Register(sandbox.GetState())
 
-	taskAPI := NewTaskAPI(m.ctx, p.ID)
+	taskAPI := NewTaskAPI(m.emit, p.ID)
 	taskAPI.Register(sandbox.GetState())
 
+	packageAPI := NewPackageAPI(pkg)
+	packageAPI.Register(sandbox.GetState())
+
+	busAPI := NewBusAPI(m, p.ID, manifest)
+	busAPI.Register(sandbox.GetState())
+
 	// Load the plugin source
-	if err := sandbox.LoadSource(string(source)); err != nil {
+	if err := sandbox.LoadSource(pkg.Main); err != nil {
 		sandbox.Close()
+		m.mu.Lock()
+		m.removeBusSubscriptions(p.ID)
+		m.mu.Unlock()
 		return fmt.Errorf("failed to load source: %w", err)
 	}
 
@@ -166,7 +282,9 @@ func (m *Manager) loadPlugin(p *Plugin) error {
 
 	// Register scheduled tasks
 	for _, sched := range manifest.Schedules {
-		m.scheduler.AddTask(p.ID, sched.Name, sched.Interval, sandbox)
+		if err := m.scheduler.AddTask(p.ID, sched, sandbox); err != nil {
+			log.Printf("Plugin %s: failed to schedule %s: %v", manifest.Name, sched.Name, err)
+		}
 	}
 
 	log.Printf("Loaded plugin: %s v%s", manifest.Name, manifest.Version)
@@ -201,6 +319,7 @@ func (m *Manager) UnloadPlugin(pluginID int64) {
 		}
 		m.eventSubscribers[event] = newSubscribers
 	}
+	m.removeBusSubscriptions(pluginID)
 
 	delete(m.plugins, pluginID)
 	log.Printf("Unloaded plugin: %s", p.Name)
@@ -208,6 +327,56 @@ func (m *Manager) UnloadPlugin(pluginID int64) {
 
 // EmitEvent sends an event to all subscribed plugins
 func (m *Manager) EmitEvent(event string, data interface{}) {
+	m.dispatchEvent(0, 0, event, data)
+}
+
+// handleStoreEvent forwards clip, tag and collection changes to subscribed plugins
+func (m *Manager) handleStoreEvent(e store.Event) {
+	if e.Actor.Kind == store.ActorPlugin {
+		m.emitPluginEvent(e.Actor.PluginID, e.Name, e.Data)
+		return
+	}
+	m.EmitEvent(e.Name, e.Data)
+}
+
+// startEventQueue starts the goroutine delivering events caused by plugins in order
+func (m *Manager) startEventQueue() {
+	m.eventQueue = make(chan queuedEvent, eventQueueSize)
+	go func() {
+		for e := range m.eventQueue {
+			m.dispatchEvent(e.origin, e.depth, e.name, e.data)
+			m.pending.Add(-1)
+		}
+	}()
+}
+
+// emitPluginEvent queues an event caused by a plugin. It is delivered after the
+// plugin's call returns (so plugins never wait on each other), never back to the
+// plugin itself, and dropped once plugins have reacted to each other MaxEventDepth times.
+func (m *Manager) emitPluginEvent(origin int64, event string, data interface{}) {
+	depth := 1
+	m.mu.RLock()
+	if p, ok := m.plugins[origin]; ok && p.Sandbox != nil {
+		depth = p.Sandbox.EventDepth() + 1
+	}
+	m.mu.RUnlock()
+
+	if depth > MaxEventDepth {
+		log.Printf("Dropping %s from plugin %d: more than %d chained events", event, origin, MaxEventDepth)
+		return
+	}
+
+	m.pending.Add(1)
+	select {
+	case m.eventQueue <- queuedEvent{origin: origin, depth: depth, name: event, data: data}:
+	default:
+		m.pending.Add(-1)
+		log.Printf("Event queue full, dropping %s from plugin %d", event, origin)
+	}
+}
+
+// dispatchEvent calls the event handler of every subscriber except origin
+func (m *Manager) dispatchEvent(origin int64, depth int, event string, data interface{}) {
 	m.mu.RLock()
 	// Copy subscriber list to prevent race conditions during iteration
 	subscribers := make([]int64, len(m.eventSubscribers[event]))
@@ -218,6 +387,10 @@ func (m *Manager) EmitEvent(event string, data interface{}) {
 	handlerName := eventToHandler(event)
 
 	for _, pluginID := range subscribers {
+		if pluginID == origin {
+			continue
+		}
+
 		m.mu.RLock()
 		p, ok := m.plugins[pluginID]
 		m.mu.RUnlock()
@@ -227,9 +400,9 @@ func (m *Manager) EmitEvent(event string, data interface{}) {
 		}
 
 		// Call handler with data conversion happening inside the sandbox's mutex
-		if err := p.Sandbox.CallHandlerWithData(handlerName, data); err != nil {
+		if err := p.Sandbox.CallEventHandler(handlerName, data, depth); err != nil {
 			log.Printf("Plugin %s handler %s failed: %v", p.Name, handlerName, err)
-			m.incrementErrorCount(pluginID)
+			m.incrementErrorCount(pluginID, err)
 		} else {
 			m.resetErrorCount(pluginID)
 		}
@@ -258,12 +431,13 @@ func eventToHandler(event string) string {
 	return result
 }
 
-func (m *Manager) incrementErrorCount(pluginID int64) {
-	_, err := m.db.Exec(
+// incrementErrorCount counts a failure of a plugin, caused by err, and
+// disables the plugin after MaxConsecutiveErrors in a row
+func (m *Manager) incrementErrorCount(pluginID int64, err error) {
+	if _, err := m.db.Exec(
 		"UPDATE plugins SET error_count = error_count + 1 WHERE id = ?",
 		pluginID,
-	)
-	if err != nil {
+	); err != nil {
 		return
 	}
 
@@ -278,6 +452,17 @@ func (m *Manager) incrementErrorCount(pluginID int64) {
 		m.db.Exec("UPDATE plugins SET status = 'error' WHERE id = ?", pluginID)
 		m.UnloadPlugin(pluginID)
 		log.Printf("Plugin %d disabled after %d consecutive errors", pluginID, errorCount)
+		m.audit(store.System, store.AuditPluginAutoDisabled, pluginID, map[string]interface{}{
+			"errors":     errorCount,
+			"last_error": err.Error(),
+		})
+	}
+}
+
+// audit records an action concerning a plugin in the audit log
+func (m *Manager) audit(actor store.Actor, action string, pluginID int64, details map[string]interface{}) {
+	if err := m.store.Audit(actor, action, store.TargetPlugin, pluginID, details); err != nil {
+		log.Printf("Failed to audit %s of plugin %d: %v", action, pluginID, err)
 	}
 }
 
@@ -287,48 +472,64 @@ func (m *Manager) resetErrorCount(pluginID int64) {
 	}
 }
 
-// ImportPlugin imports a plugin from a file path
-func (m *Manager) ImportPlugin(sourcePath string) (*Plugin, error) {
-	// Read source
-	source, err := os.ReadFile(sourcePath)
+// ImportPlugin imports a plugin from a .lua file or a zipped plugin package.
+// The user agrees to the clip and tag scopes the plugin declares before
+// importing it, so they are granted before its code first runs.
+// approvedDomains are the declared network domains the user approved; the
+// plugin can't reach the others until they are approved. A plugin with the
+// name or filename of an installed one is refused; it has to be upgraded.
+func (m *Manager) ImportPlugin(sourcePath string, approvedDomains []string) (*Plugin, error) {
+	if !IsPluginFile(sourcePath) {
+		return nil, fmt.Errorf("unsupported plugin file: %s", filepath.Base(sourcePath))
+	}
+
+	// Read and validate the package
+	pkg, err := OpenPackage(sourcePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read plugin file: %w", err)
+		return nil, fmt.Errorf("invalid plugin: %w", err)
 	}
 
 	// Parse manifest to validate
-	manifest, err := ParseManifest(string(source))
+	manifest, err := ParseManifest(pkg.Main)
 	if err != nil {
 		return nil, fmt.Errorf("invalid plugin: %w", err)
 	}
 
-	// Copy to plugins directory
-	filename := filepath.Base(sourcePath)
-	destPath := filepath.Join(m.pluginsDir, filename)
-
-	if err := os.WriteFile(destPath, source, 0644); err != nil {
-		return nil, fmt.Errorf("failed to copy plugin: %w", err)
+	for _, domain := range approvedDomains {
+		if _, ok := manifest.Network[domain]; !ok {
+			return nil, fmt.Errorf("plugin did not declare the network domain %s", domain)
+		}
 	}
 
-	// Insert into database
-	_, err = m.db.Exec(`
-		INSERT INTO plugins (filename, name, version, enabled, status)
-		VALUES (?, ?, ?, 1, 'enabled')
-		ON CONFLICT(filename) DO UPDATE SET
-			name = excluded.name,
-			version = excluded.version,
-			enabled = 1,
-			status = 'enabled',
-			error_count = 0
-	`, filename, manifest.Name, manifest.Version)
+	// Refuse plugins that fail the signing policy before anything is copied
+	signature, err := CheckTrust(pkg, LoadTrustPolicy(m.db))
 	if err != nil {
-		return nil, fmt.Errorf("failed to register plugin: %w", err)
+		return nil, fmt.Errorf("signature check failed: %w", err)
 	}
 
-	// Query for the ID (LastInsertId returns 0 on upsert update)
-	var id int64
-	err = m.db.QueryRow("SELECT id FROM plugins WHERE filename = ?", filename).Scan(&id)
+	// An installed plugin keeps its storage, secrets and grants, so it is only
+	// replaced through UpgradePlugin, which checks the new file's signer
+	filename := filepath.Base(sourcePath)
+	var existing string
+	err = m.db.QueryRow("SELECT name FROM plugins WHERE name = ? OR filename = ?", manifest.Name, filename).Scan(&existing)
+	if err == nil {
+		return nil, fmt.Errorf("plugin %s is already installed, use upgrade instead", existing)
+	}
+	if err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to check installed plugins: %w", err)
+	}
+
+	// Copy to plugins directory (packages are kept zipped)
+	if err := m.installFile(sourcePath, filename); err != nil {
+		return nil, err
+	}
+
+	// Register the plugin with its scopes and approved domains at once, so a
+	// failed import leaves nothing behind that blocks importing it again
+	id, err := m.register(filename, manifest, signature.Key, approvedDomains)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get plugin ID: %w", err)
+		m.removeFile(filename)
+		return nil, err
 	}
 
 	// Load the plugin
@@ -340,14 +541,354 @@ func (m *Manager) ImportPlugin(sourcePath string) (*Plugin, error) {
 		Enabled:  true,
 		Status:   "enabled",
 	}
-
 	if err := m.loadPlugin(p); err != nil {
+		if removeErr := m.RemovePlugin(id); removeErr != nil {
+			log.Printf("Failed to remove plugin %s after a failed import: %v", manifest.Name, removeErr)
+		}
 		return nil, fmt.Errorf("failed to load plugin: %w", err)
 	}
 
+	for _, scope := range manifest.Scopes {
+		m.audit(store.User, store.AuditPermissionGranted, id, map[string]interface{}{
+			"permission": scope,
+		})
+	}
+	for _, domain := range approvedDomains {
+		m.audit(store.User, store.AuditPermissionGranted, id, map[string]interface{}{
+			"permission": PermissionNetwork,
+			"domain":     domain,
+		})
+	}
+
 	return p, nil
 }
 
+// register inserts an imported plugin along with the scopes it declares and
+// the domains the user approved, returning its ID
+func (m *Manager) register(filename string, manifest *Manifest, signer string, approvedDomains []string) (int64, error) {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO plugins (filename, name, version, enabled, status, signer, scopes_migrated, domains_migrated)
+		VALUES (?, ?, ?, 1, 'enabled', ?, 1, 1)
+	`, filename, manifest.Name, manifest.Version, signer)
+	if err != nil {
+		return 0, fmt.Errorf("failed to register plugin: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get plugin ID: %w", err)
+	}
+
+	for _, scope := range manifest.Scopes {
+		if err := insertGrant(tx, id, scope); err != nil {
+			return 0, err
+		}
+	}
+	for _, domain := range approvedDomains {
+		if err := insertApproval(tx, id, domain); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return id, nil
+}
+
+// removeFile deletes a plugin file from the plugins directory along with its
+// signature
+func (m *Manager) removeFile(filename string) {
+	os.Remove(filepath.Join(m.pluginsDir, filepath.Base(filename)))
+	os.Remove(filepath.Join(m.pluginsDir, filepath.Base(filename)) + SignatureExt)
+}
+
+// installFile copies a plugin file into the plugins directory. The detached
+// signature of a single-file plugin is copied along (or a stale one removed).
+func (m *Manager) installFile(sourcePath, filename string) error {
+	destPath := filepath.Join(m.pluginsDir, filename)
+
+	source, err := os.ReadFile(sourcePath)
+	if err != nil {
+		return fmt.Errorf("failed to read plugin file: %w", err)
+	}
+	if err := os.WriteFile(destPath, source, 0644); err != nil {
+		return fmt.Errorf("failed to copy plugin: %w", err)
+	}
+
+	if !strings.EqualFold(filepath.Ext(filename), PackageExt) {
+		if sig, err := os.ReadFile(sourcePath + SignatureExt); err == nil {
+			if err := os.WriteFile(destPath+SignatureExt, sig, 0644); err != nil {
+				return fmt.Errorf("failed to copy plugin signature: %w", err)
+			}
+		} else {
+			os.Remove(destPath + SignatureExt)
+		}
+	}
+
+	return nil
+}
+
+// ErrSignerChanged is returned for an upgrade signed by another key than the
+// installed version, or not signed while the installed version is
+var ErrSignerChanged = errors.New("plugin signer changed")
+
+// UpgradePlugin replaces an installed plugin with a new file of the same plugin.
+// The plugin keeps its ID, so storage, granted permissions and the enabled
+// state survive the upgrade. The new file must be signed by the same key as
+// the installed one (or both unsigned) unless allowSignerChange is set after
+// the user confirmed it.
+func (m *Manager) UpgradePlugin(pluginID int64, sourcePath string, allowSignerChange bool) (*Plugin, error) {
+	if !IsPluginFile(sourcePath) {
+		return nil, fmt.Errorf("unsupported plugin file: %s", filepath.Base(sourcePath))
+	}
+
+	pkg, err := OpenPackage(sourcePath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid plugin: %w", err)
+	}
+	manifest, err := ParseManifest(pkg.Main)
+	if err != nil {
+		return nil, fmt.Errorf("invalid plugin: %w", err)
+	}
+	policy := LoadTrustPolicy(m.db)
+	signature, err := CheckTrust(pkg, policy)
+	if err != nil {
+		return nil, fmt.Errorf("signature check failed: %w", err)
+	}
+
+	p := &Plugin{ID: pluginID}
+	var enabled int
+	var signer sql.NullString
+	err = m.db.QueryRow(`
+		SELECT filename, name, enabled, signer FROM plugins WHERE id = ?
+	`, pluginID).Scan(&p.Filename, &p.Name, &enabled, &signer)
+	if err != nil {
+		return nil, fmt.Errorf("plugin not found: %d", pluginID)
+	}
+	if manifest.Name != p.Name {
+		return nil, fmt.Errorf("plugin name mismatch: expected %s, got %s", p.Name, manifest.Name)
+	}
+
+	// Plugins that were never loaded have no recorded signer; use the one
+	// of the installed file
+	installed, installedErr := OpenPackage(filepath.Join(m.pluginsDir, p.Filename))
+	installedSigner := signer.String
+	if !signer.Valid && installedErr == nil {
+		installedSigner = VerifyPackage(installed, policy.Keys).Key
+	}
+	if signature.Key != installedSigner && !allowSignerChange {
+		return nil, fmt.Errorf("%w: the installed version is %s, the new version is %s",
+			ErrSignerChanged, describeSigner(installedSigner, policy), signature)
+	}
+
+	// Plugins installed before scopes and domain approvals existed keep the
+	// scopes and domains their installed version declares; the ones the new
+	// version adds wait for the user
+	installedManifest := &Manifest{}
+	if installedErr == nil {
+		if parsed, err := ParseManifest(installed.Main); err == nil {
+			installedManifest = parsed
+		}
+	}
+
+	filename := filepath.Base(sourcePath)
+	var conflictID int64
+	err = m.db.QueryRow("SELECT id FROM plugins WHERE filename = ? AND id != ?", filename, pluginID).Scan(&conflictID)
+	if err == nil {
+		return nil, fmt.Errorf("file %s belongs to another plugin", filename)
+	}
+
+	if err := m.migrateScopes(pluginID, installedManifest); err != nil {
+		return nil, err
+	}
+	if err := m.migrateDomains(pluginID, installedManifest); err != nil {
+		return nil, err
+	}
+
+	previousFilename := p.Filename
+	if err := m.installFile(sourcePath, filename); err != nil {
+		return nil, err
+	}
+	m.UnloadPlugin(pluginID)
+
+	p.Enabled = enabled == 1
+	p.Status = "disabled"
+	if p.Enabled {
+		p.Status = "enabled"
+	}
+	p.Filename = filename
+	p.Version = manifest.Version
+
+	_, err = m.db.Exec(`
+		UPDATE plugins SET filename = ?, version = ?, status = ?, error_count = 0, signer = ?
+		WHERE id = ?
+	`, p.Filename, p.Version, p.Status, signature.Key, pluginID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update plugin: %w", err)
+	}
+
+	if previousFilename != filename {
+		os.Remove(filepath.Join(m.pluginsDir, filepath.Base(previousFilename)))
+		os.Remove(filepath.Join(m.pluginsDir, filepath.Base(previousFilename)) + SignatureExt)
+	}
+
+	if p.Enabled {
+		if err := m.loadPlugin(p); err != nil {
+			return nil, fmt.Errorf("failed to load plugin: %w", err)
+		}
+	} else {
+		p.Manifest = manifest
+	}
+
+	log.Printf("Upgraded plugin: %s to v%s", p.Name, p.Version)
+	return p, nil
+}
+
+// describeSigner names the signer recorded for a plugin, like "signed by Alice"
+func describeSigner(key string, policy TrustPolicy) string {
+	if key == "" {
+		return SignatureUnsigned
+	}
+	for _, trusted := range policy.Keys {
+		if trusted.PublicKey == key {
+			return SignatureStatus{State: SignatureSigned, SignedBy: trusted.Name}.String()
+		}
+	}
+	return "signed by a key that is no longer trusted"
+}
+
+// CheckForUpdates compares installed plugins against the latest versions in a registry
+func (m *Manager) CheckForUpdates(reg Registry) ([]PluginUpdate, error) {
+	entries, err := reg.List()
+	if err != nil {
+		return nil, err
+	}
+	latest := LatestEntries(entries)
+
+	rows, err := m.db.Query("SELECT id, name, version FROM plugins ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query plugins: %w", err)
+	}
+	defer rows.Close()
+
+	updates := []PluginUpdate{}
+	for rows.Next() {
+		var id int64
+		var name string
+		var version sql.NullString
+		if err := rows.Scan(&id, &name, &version); err != nil {
+			continue
+		}
+		entry, ok := latest[name]
+		if !ok || CompareVersions(entry.Version, version.String) <= 0 {
+			continue
+		}
+		updates = append(updates, PluginUpdate{
+			PluginID:       id,
+			Name:           name,
+			CurrentVersion: version.String,
+			LatestVersion:  entry.Version,
+			Description:    entry.Description,
+		})
+	}
+
+	return updates, nil
+}
+
+// UpgradeFromRegistry fetches the latest registry version of a plugin and upgrades it in place
+func (m *Manager) UpgradeFromRegistry(reg Registry, pluginID int64, allowSignerChange bool) (*Plugin, error) {
+	var name, version string
+	err := m.db.QueryRow("SELECT name, COALESCE(version, '') FROM plugins WHERE id = ?", pluginID).Scan(&name, &version)
+	if err != nil {
+		return nil, fmt.Errorf("plugin not found: %d", pluginID)
+	}
+
+	entries, err := reg.List()
+	if err != nil {
+		return nil, err
+	}
+	entry, ok := LatestEntries(entries)[name]
+	if !ok {
+		return nil, fmt.Errorf("plugin %s is not in the registry", name)
+	}
+	if CompareVersions(entry.Version, version) <= 0 {
+		return nil, fmt.Errorf("plugin %s is already up to date (v%s)", name, version)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "mahpastes-plugin-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	path, err := reg.Fetch(entry, tmpDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// The download must be the version the registry listed
+	pkg, err := OpenPackage(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid plugin: %w", err)
+	}
+	manifest, err := ParseManifest(pkg.Main)
+	if err != nil {
+		return nil, fmt.Errorf("invalid plugin: %w", err)
+	}
+	if CompareVersions(manifest.Version, entry.Version) != 0 {
+		return nil, fmt.Errorf("registry lists %s v%s but the download is v%s", name, entry.Version, manifest.Version)
+	}
+
+	return m.UpgradePlugin(pluginID, path, allowSignerChange)
+}
+
+// GetTaskStatus returns the scheduled tasks of a plugin with their next and
+// last runs. For plugins that aren't loaded only the persisted last runs are known.
+func (m *Manager) GetTaskStatus(pluginID int64) ([]TaskStatus, error) {
+	m.mu.RLock()
+	_, loaded := m.plugins[pluginID]
+	m.mu.RUnlock()
+
+	if loaded {
+		return m.scheduler.PluginTaskStatus(pluginID), nil
+	}
+
+	rows, err := m.db.Query(`
+		SELECT task_name, last_run, last_result, last_error FROM plugin_schedule_runs
+		WHERE plugin_id = ? ORDER BY task_name
+	`, pluginID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query task runs: %w", err)
+	}
+	defer rows.Close()
+
+	statuses := []TaskStatus{}
+	for rows.Next() {
+		var status TaskStatus
+		if err := rows.Scan(&status.Name, &status.LastRun, &status.LastResult, &status.LastError); err != nil {
+			continue
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// VerifyPluginFile reports the signature status of an installed plugin file
+// without loading it (used for plugins that are disabled or failed to load)
+func (m *Manager) VerifyPluginFile(filename string) SignatureStatus {
+	pkg, err := OpenPackage(filepath.Join(m.pluginsDir, filepath.Base(filename)))
+	if err != nil {
+		return SignatureStatus{State: SignatureInvalid}
+	}
+	return VerifyPackage(pkg, LoadTrustPolicy(m.db).Keys)
+}
+
 // GetPlugins returns all plugins
 func (m *Manager) GetPlugins() []*Plugin {
 	m.mu.RLock()
@@ -400,6 +941,236 @@ func (m *Manager) DisablePlugin(pluginID int64) error {
 	return err
 }
 
+// SetSensitiveClipsAccess grants or revokes a plugin's access to clips marked
+// sensitive. Only plugins that declare the sensitive_clips permission can be
+// granted access.
+func (m *Manager) SetSensitiveClipsAccess(pluginID int64, granted bool) error {
+	return m.setGrant(pluginID, PermissionSensitiveClips, granted, func(manifest *Manifest) bool {
+		return manifest.Permissions.SensitiveClips
+	})
+}
+
+// SetScopeGranted grants or revokes one of a plugin's clip and tag scopes.
+// Only scopes the plugin declares can be granted.
+func (m *Manager) SetScopeGranted(pluginID int64, scope string, granted bool) error {
+	if !IsValidScope(scope) {
+		return fmt.Errorf("unknown scope %s", scope)
+	}
+	return m.setGrant(pluginID, scope, granted, func(manifest *Manifest) bool {
+		return containsString(manifest.Scopes, scope)
+	})
+}
+
+// SetDomainApproved approves or revokes one of the domains in a plugin's
+// network table. Only declared domains can be approved.
+func (m *Manager) SetDomainApproved(pluginID int64, domain string, approved bool) error {
+	if !approved {
+		if _, err := m.db.Exec("DELETE FROM plugin_permissions WHERE plugin_id = ? AND permission_type = ? AND path = ?",
+			pluginID, PermissionNetwork, domain); err != nil {
+			return fmt.Errorf("failed to revoke domain: %w", err)
+		}
+		m.audit(store.User, store.AuditPermissionRevoked, pluginID, map[string]interface{}{
+			"permission": PermissionNetwork,
+			"domain":     domain,
+		})
+		return nil
+	}
+
+	m.mu.RLock()
+	p, ok := m.plugins[pluginID]
+	m.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("plugin %d is not loaded", pluginID)
+	}
+	if p.Manifest == nil {
+		return fmt.Errorf("plugin did not declare the network domain %s", domain)
+	}
+	if _, declared := p.Manifest.Network[domain]; !declared {
+		return fmt.Errorf("plugin did not declare the network domain %s", domain)
+	}
+	return m.approveDomain(store.User, pluginID, domain)
+}
+
+// execer is implemented by *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// approveDomain records that the user approved a network domain, replacing
+// an earlier approval
+func (m *Manager) approveDomain(actor store.Actor, pluginID int64, domain string) error {
+	if err := insertApproval(m.db, pluginID, domain); err != nil {
+		return err
+	}
+	m.audit(actor, store.AuditPermissionGranted, pluginID, map[string]interface{}{
+		"permission": PermissionNetwork,
+		"domain":     domain,
+	})
+	return nil
+}
+
+func insertApproval(db execer, pluginID int64, domain string) error {
+	if _, err := db.Exec("DELETE FROM plugin_permissions WHERE plugin_id = ? AND permission_type = ? AND path = ?",
+		pluginID, PermissionNetwork, domain); err != nil {
+		return fmt.Errorf("failed to approve domain: %w", err)
+	}
+	if _, err := db.Exec("INSERT INTO plugin_permissions (plugin_id, permission_type, path) VALUES (?, ?, ?)",
+		pluginID, PermissionNetwork, domain); err != nil {
+		return fmt.Errorf("failed to approve domain: %w", err)
+	}
+	return nil
+}
+
+// setGrant grants or revokes a permission type that isn't tied to a path.
+// declared checks that the loaded plugin asks for it.
+func (m *Manager) setGrant(pluginID int64, permissionType string, granted bool, declared func(*Manifest) bool) error {
+	if !granted {
+		if _, err := m.db.Exec("DELETE FROM plugin_permissions WHERE plugin_id = ? AND permission_type = ?",
+			pluginID, permissionType); err != nil {
+			return fmt.Errorf("failed to revoke permission: %w", err)
+		}
+		m.audit(store.User, store.AuditPermissionRevoked, pluginID, map[string]interface{}{
+			"permission": permissionType,
+		})
+		return nil
+	}
+
+	m.mu.RLock()
+	p, ok := m.plugins[pluginID]
+	m.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("plugin %d is not loaded", pluginID)
+	}
+	if p.Manifest == nil || !declared(p.Manifest) {
+		return fmt.Errorf("plugin did not declare the %s permission", permissionType)
+	}
+	return m.grant(store.User, pluginID, permissionType)
+}
+
+// grant records that the user granted a permission type that isn't tied to
+// a path, replacing an earlier grant
+func (m *Manager) grant(actor store.Actor, pluginID int64, permissionType string) error {
+	if err := insertGrant(m.db, pluginID, permissionType); err != nil {
+		return err
+	}
+	m.audit(actor, store.AuditPermissionGranted, pluginID, map[string]interface{}{
+		"permission": permissionType,
+	})
+	return nil
+}
+
+func insertGrant(db execer, pluginID int64, permissionType string) error {
+	if _, err := db.Exec("DELETE FROM plugin_permissions WHERE plugin_id = ? AND permission_type = ?",
+		pluginID, permissionType); err != nil {
+		return fmt.Errorf("failed to grant permission: %w", err)
+	}
+	if _, err := db.Exec("INSERT INTO plugin_permissions (plugin_id, permission_type, path) VALUES (?, ?, '')",
+		pluginID, permissionType); err != nil {
+		return fmt.Errorf("failed to grant permission: %w", err)
+	}
+	return nil
+}
+
+// migrateScopes grants a plugin installed before scopes existed the scopes
+// it declares, once. Scopes declared by later upgrades wait for the user.
+func (m *Manager) migrateScopes(pluginID int64, manifest *Manifest) error {
+	var migrated sql.NullInt64
+	if err := m.db.QueryRow("SELECT scopes_migrated FROM plugins WHERE id = ?", pluginID).Scan(&migrated); err != nil {
+		return fmt.Errorf("failed to read plugin: %w", err)
+	}
+	if migrated.Valid {
+		return nil
+	}
+	for _, scope := range manifest.Scopes {
+		if err := m.grant(store.System, pluginID, scope); err != nil {
+			return err
+		}
+	}
+	if _, err := m.db.Exec("UPDATE plugins SET scopes_migrated = 1 WHERE id = ?", pluginID); err != nil {
+		return fmt.Errorf("failed to update plugin: %w", err)
+	}
+	return nil
+}
+
+// migrateDomains approves the network domains a plugin installed before they
+// needed approval declares, once. Domains declared by later upgrades wait
+// for the user.
+func (m *Manager) migrateDomains(pluginID int64, manifest *Manifest) error {
+	var migrated sql.NullInt64
+	if err := m.db.QueryRow("SELECT domains_migrated FROM plugins WHERE id = ?", pluginID).Scan(&migrated); err != nil {
+		return fmt.Errorf("failed to read plugin: %w", err)
+	}
+	if migrated.Valid {
+		return nil
+	}
+	for _, domain := range DeclaredDomains(manifest) {
+		if err := m.approveDomain(store.System, pluginID, domain); err != nil {
+			return err
+		}
+	}
+	if _, err := m.db.Exec("UPDATE plugins SET domains_migrated = 1 WHERE id = ?", pluginID); err != nil {
+		return fmt.Errorf("failed to update plugin: %w", err)
+	}
+	return nil
+}
+
+// PendingDomains returns the network domains a plugin declares that the
+// user hasn't approved, such as the ones added by an upgrade
+func (m *Manager) PendingDomains(pluginID int64, manifest *Manifest) []string {
+	pending := []string{}
+	for _, domain := range DeclaredDomains(manifest) {
+		if !domainApproved(m.db, pluginID, domain) {
+			pending = append(pending, domain)
+		}
+	}
+	return pending
+}
+
+// PendingScopes returns the scopes a plugin declares that the user hasn't
+// granted, such as the ones added by an upgrade
+func (m *Manager) PendingScopes(pluginID int64, manifest *Manifest) []string {
+	pending := []string{}
+	if manifest == nil {
+		return pending
+	}
+	for _, scope := range manifest.Scopes {
+		if !hasGrant(m.db, pluginID, scope) {
+			pending = append(pending, scope)
+		}
+	}
+	return pending
+}
+
+// Secrets returns the store that keeps the values of password settings
+func (m *Manager) Secrets() *SecretStore {
+	return m.secrets
+}
+
+// PasswordKeys returns the keys of a plugin's password settings. Plugins that
+// aren't loaded are read from their file.
+func (m *Manager) PasswordKeys(pluginID int64) ([]string, error) {
+	m.mu.RLock()
+	p, ok := m.plugins[pluginID]
+	m.mu.RUnlock()
+	if ok && p.Manifest != nil {
+		return p.Manifest.PasswordKeys(), nil
+	}
+
+	var filename string
+	if err := m.db.QueryRow("SELECT filename FROM plugins WHERE id = ?", pluginID).Scan(&filename); err != nil {
+		return nil, fmt.Errorf("plugin not found: %w", err)
+	}
+	pkg, err := OpenPackage(filepath.Join(m.pluginsDir, filepath.Base(filename)))
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := ParseManifest(pkg.Main)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	return manifest.PasswordKeys(), nil
+}
+
 // RemovePlugin removes a plugin completely
 func (m *Manager) RemovePlugin(pluginID int64) error {
 	m.mu.RLock()
@@ -420,9 +1191,9 @@ func (m *Manager) RemovePlugin(pluginID int64) error {
 		return err
 	}
 
-	// Delete file
+	// Delete file (a single .lua file or a zipped package) and its signature
 	if filename != "" {
-		os.Remove(filepath.Join(m.pluginsDir, filename))
+		m.removeFile(filename)
 	}
 
 	return nil
@@ -448,6 +1219,20 @@ func (m *Manager) Shutdown() {
 
 	m.plugins = make(map[int64]*Plugin)
 	m.eventSubscribers = make(map[string][]int64)
+	m.busSubscribers = make(map[string][]int64)
+}
+
+// waitIdle waits until queued events, bus messages and async actions are
+// handled, or fails once the timeout passes
+func (m *Manager) waitIdle(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for m.pending.Load() > 0 {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("plugins still busy after %v", timeout)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return nil
 }
 
 // ExecuteUIAction calls a plugin's on_ui_action handler.
@@ -476,15 +1261,26 @@ func (m *Manager) ExecuteUIAction(pluginID int64, actionID string, clipIDs []int
 		return nil, fmt.Errorf("unknown action ID: %s", actionID)
 	}
 
+	details := map[string]interface{}{
+		"action":   actionID,
+		"clip_ids": clipIDs,
+	}
+
 	// Async actions run in a background goroutine with extended timeout
 	if action.Async {
+		m.pending.Add(1)
 		go func() {
+			defer m.pending.Add(-1)
 			luaResult, err := p.Sandbox.CallUIAction(actionID, clipIDs, options, MaxUIActionTime)
+			details["async"] = true
 			if err != nil {
 				log.Printf("Plugin %s async action %s failed: %v", p.Name, actionID, err)
-				m.incrementErrorCount(pluginID)
+				details["error"] = err.Error()
+				m.audit(store.User, store.AuditPluginAction, pluginID, details)
+				m.incrementErrorCount(pluginID, err)
 				return
 			}
+			m.audit(store.User, store.AuditPluginAction, pluginID, details)
 			m.resetErrorCount(pluginID)
 			_ = luaResult // Plugin communicates results via task events and toasts
 		}()
@@ -494,10 +1290,25 @@ func (m *Manager) ExecuteUIAction(pluginID int64, actionID string, clipIDs []int
 	// Synchronous actions block and return the result
 	luaResult, err := p.Sandbox.CallUIAction(actionID, clipIDs, options, MaxExecutionTime)
 	if err != nil {
+		details["error"] = err.Error()
+		m.audit(store.User, store.AuditPluginAction, pluginID, details)
 		return nil, fmt.Errorf("plugin action failed: %w", err)
 	}
 
-	return luaResultToActionResult(luaResult), nil
+	result := luaResultToActionResult(luaResult)
+	if result.Error != "" {
+		details["error"] = result.Error
+	}
+	m.audit(store.User, store.AuditPluginAction, pluginID, details)
+
+	// Link a clip produced from a single clip back to its source
+	if result.ResultClipID != 0 && len(clipIDs) == 1 {
+		if err := m.store.LinkDerived(result.ResultClipID, clipIDs[0]); err != nil {
+			log.Printf("Plugin %s action %s: failed to link result clip: %v", p.Name, actionID, err)
+		}
+	}
+
+	return result, nil
 }
 
 // luaResultToActionResult converts a Lua return table to an ActionResult
@@ -5,6 +5,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Pre-compiled regexes for manifest parsing (only static patterns)
@@ -17,12 +18,17 @@ var (
 	reSchedulesBlock  = regexp.MustCompile(`schedules\s*=\s*\{`)
 	reNameField       = regexp.MustCompile(`name\s*=\s*["']([^"']+)["']`)
 	reIntervalField   = regexp.MustCompile(`interval\s*=\s*(\d+)`)
+	reCronField       = regexp.MustCompile(`cron\s*=\s*["']([^"']+)["']`)
+	reTimezoneField   = regexp.MustCompile(`timezone\s*=\s*["']([^"']+)["']`)
+	reCatchUpField    = regexp.MustCompile(`catch_up\s*=\s*(true|false)`)
 	reSettingsBlock   = regexp.MustCompile(`settings\s*=\s*\{`)
 	reUIBlock         = regexp.MustCompile(`ui\s*=\s*\{`)
 	reOptionsBlock    = regexp.MustCompile(`options\s*=\s*\{`)
 	reRequiredField   = regexp.MustCompile(`required\s*=\s*(true|false)`)
 	reChoicesBlock    = regexp.MustCompile(`choices\s*=\s*\{`)
 	reDefaultBool     = regexp.MustCompile(`default\s*=\s*(true|false)`)
+	reBusBlock        = regexp.MustCompile(`\bbus\s*=\s*\{`)
+	reScopeLimits     = regexp.MustCompile(`\bscope_limits\s*=\s*\{`)
 )
 
 // Manifest represents a parsed plugin manifest
@@ -33,10 +39,32 @@ type Manifest struct {
 	Author      string
 	Network     map[string][]string // domain -> allowed methods
 	Filesystem  FilesystemPerms
+	Permissions Permissions
+	Scopes      []string // clip and tag scopes such as "clips.read"
+	ScopeLimits ScopeLimits
 	Events      []string
 	Schedules   []Schedule
 	Settings    []SettingField
 	UI          *UIManifest
+	Bus         BusManifest
+}
+
+// BusManifest declares the message bus topics a plugin provides and consumes.
+// Topics double as method names for bus.call: a plugin serves the methods it
+// provides and may call the methods it consumes.
+type BusManifest struct {
+	Provides []string `json:"provides,omitempty"`
+	Consumes []string `json:"consumes,omitempty"`
+}
+
+// CanProvide checks if the plugin declared a topic or method as provided
+func (b BusManifest) CanProvide(topic string) bool {
+	return containsString(b.Provides, topic)
+}
+
+// CanConsume checks if the plugin declared a topic or method as consumed
+func (b BusManifest) CanConsume(topic string) bool {
+	return containsString(b.Consumes, topic)
 }
 
 // FilesystemPerms represents filesystem permission requests
@@ -45,10 +73,18 @@ type FilesystemPerms struct {
 	Write bool
 }
 
-// Schedule represents a scheduled task
+// Permissions represents optional permissions the user grants separately
+type Permissions struct {
+	SensitiveClips bool // read clips marked sensitive
+}
+
+// Schedule represents a scheduled task. Cron takes precedence over Interval.
 type Schedule struct {
 	Name     string
-	Interval int // seconds
+	Interval int    // seconds
+	Cron     string // 5-field cron expression or macro like "@daily"
+	Timezone string // IANA timezone for Cron, local time if empty
+	CatchUp  bool   // run once on load if a run was missed while the app was closed
 }
 
 // SettingField represents a plugin setting declaration
@@ -61,6 +97,18 @@ type SettingField struct {
 	Options     []string `json:"options,omitempty"`
 }
 
+// PasswordKeys returns the keys of the plugin's password settings, whose
+// values are kept in the secrets store instead of plugin storage
+func (m *Manifest) PasswordKeys() []string {
+	var keys []string
+	for _, setting := range m.Settings {
+		if setting.Type == "password" {
+			keys = append(keys, setting.Key)
+		}
+	}
+	return keys
+}
+
 // UIManifest represents plugin UI declarations
 type UIManifest struct {
 	LightboxButtons []UIAction `json:"lightbox_buttons,omitempty"`
@@ -123,6 +171,18 @@ func ParseManifest(source string) (*Manifest, error) {
 	manifest.Filesystem.Read = extractBoolField(pluginBlock, "filesystem", "read")
 	manifest.Filesystem.Write = extractBoolField(pluginBlock, "filesystem", "write")
 
+	// Parse optional permissions
+	manifest.Permissions.SensitiveClips = extractBoolField(pluginBlock, "permissions", "sensitive_clips")
+
+	// Parse clip and tag scopes
+	manifest.Scopes = extractStringArray(pluginBlock, "scopes")
+	for _, scope := range manifest.Scopes {
+		if !IsValidScope(scope) {
+			return nil, fmt.Errorf("unknown scope %s", scope)
+		}
+	}
+	manifest.ScopeLimits = extractScopeLimits(pluginBlock)
+
 	// Parse events array
 	manifest.Events = extractStringArray(pluginBlock, "events")
 
@@ -131,6 +191,18 @@ func ParseManifest(source string) (*Manifest, error) {
 
 	// Parse schedules
 	manifest.Schedules = extractSchedules(pluginBlock)
+	for _, sched := range manifest.Schedules {
+		if sched.Cron != "" {
+			if _, err := ParseCron(sched.Cron); err != nil {
+				return nil, fmt.Errorf("schedule %s: %w", sched.Name, err)
+			}
+		}
+		if sched.Timezone != "" {
+			if _, err := time.LoadLocation(sched.Timezone); err != nil {
+				return nil, fmt.Errorf("schedule %s: unknown timezone %s", sched.Name, sched.Timezone)
+			}
+		}
+	}
 
 	// Parse settings
 	manifest.Settings = extractSettings(pluginBlock)
@@ -138,6 +210,9 @@ func ParseManifest(source string) (*Manifest, error) {
 	// Parse UI declarations
 	manifest.UI = extractUI(pluginBlock)
 
+	// Parse message bus declarations
+	manifest.Bus = extractBus(pluginBlock)
+
 	return manifest, nil
 }
 
@@ -377,7 +452,7 @@ func extractSchedules(block string) []Schedule {
 			if depth == 0 && entryStart >= 0 {
 				entry := schedulesBlock[entryStart : i+1]
 				schedule := parseScheduleEntry(entry)
-				if schedule.Name != "" && schedule.Interval > 0 {
+				if schedule.Name != "" && (schedule.Interval > 0 || schedule.Cron != "") {
 					result = append(result, schedule)
 				}
 				entryStart = -1
@@ -389,8 +464,9 @@ func extractSchedules(block string) []Schedule {
 }
 
 // parseScheduleEntry parses a single schedule entry like {name = "task", interval = 3600}
+// or {name = "nightly", cron = "0 2 * * *", timezone = "Europe/Berlin", catch_up = false}
 func parseScheduleEntry(entry string) Schedule {
-	var schedule Schedule
+	schedule := Schedule{CatchUp: true}
 
 	// Extract name
 	nameMatches := reNameField.FindStringSubmatch(entry)
@@ -404,6 +480,17 @@ func parseScheduleEntry(entry string) Schedule {
 		schedule.Interval, _ = strconv.Atoi(intervalMatches[1])
 	}
 
+	// Extract cron expression and timezone
+	if m := reCronField.FindStringSubmatch(entry); len(m) >= 2 {
+		schedule.Cron = m[1]
+	}
+	if m := reTimezoneField.FindStringSubmatch(entry); len(m) >= 2 {
+		schedule.Timezone = m[1]
+	}
+	if m := reCatchUpField.FindStringSubmatch(entry); len(m) >= 2 {
+		schedule.CatchUp = m[1] == "true"
+	}
+
 	return schedule
 }
 
@@ -792,15 +879,70 @@ func toUpperStrings(ss []string) []string {
 	return result
 }
 
+// extractBus extracts message bus declarations
+// Format: bus = { provides = {"ocr.text"}, consumes = {"translate"} }
+func extractBus(block string) BusManifest {
+	var result BusManifest
+
+	loc := reBusBlock.FindStringIndex(block)
+	if loc == nil {
+		return result
+	}
+
+	busBlock := extractNestedBrace(block[loc[1]-1:])
+	if busBlock == "" {
+		return result
+	}
+
+	result.Provides = extractStringArray(busBlock, "provides")
+	result.Consumes = extractStringArray(busBlock, "consumes")
+	return result
+}
+
+// extractScopeLimits extracts the limits of the plugin's clip scopes
+// Format: scope_limits = { content_types = {"image/*"}, tags = {"inbox"} }
+func extractScopeLimits(block string) ScopeLimits {
+	var result ScopeLimits
+
+	loc := reScopeLimits.FindStringIndex(block)
+	if loc == nil {
+		return result
+	}
+
+	limitsBlock := extractNestedBrace(block[loc[1]-1:])
+	if limitsBlock == "" {
+		return result
+	}
+
+	result.ContentTypes = extractStringArray(limitsBlock, "content_types")
+	result.Tags = extractStringArray(limitsBlock, "tags")
+	return result
+}
+
+func containsString(ss []string, s string) bool {
+	for _, item := range ss {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
 // ValidEvents returns the list of valid event names
 func ValidEvents() []string {
 	return []string{
 		"app:startup",
 		"app:shutdown",
 		"clip:created",
+		"clip:updated",
 		"clip:deleted",
+		"clip:trashed",
+		"clip:restored",
 		"clip:archived",
 		"clip:unarchived",
+		"clip:pinned",
+		"clip:unpinned",
+		"clip:reordered",
 		"watch:file_detected",
 		"watch:import_complete",
 		"tag:created",
@@ -808,6 +950,13 @@ func ValidEvents() []string {
 		"tag:deleted",
 		"tag:added_to_clip",
 		"tag:removed_from_clip",
+		"collection:created",
+		"collection:updated",
+		"collection:deleted",
+		"collection:clip_added",
+		"collection:clip_removed",
+		"search:matched",
+		"retention:applied",
 	}
 }
 
@@ -0,0 +1,325 @@
+package plugin
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go-clipboard/store"
+)
+
+const (
+	// PermissionNetwork is the plugin_permissions type approving one of the
+	// domains in a plugin's network table. The path holds the domain as
+	// declared, e.g. "*.fal.media".
+	PermissionNetwork = "network"
+
+	// NetworkLogLimit is how many requests are kept in each plugin's network log
+	NetworkLogLimit = 1000
+
+	// minSensitiveMatch is the shortest sensitive clip looked for in
+	// requests, so short clips don't block unrelated requests
+	minSensitiveMatch = 8
+
+	settingBlockSensitiveUploads = "plugin_block_sensitive_uploads"
+)
+
+// NetworkLogEntry is a request a plugin made or tried to make
+type NetworkLogEntry struct {
+	ID        int64     `json:"id"`
+	PluginID  int64     `json:"plugin_id"`
+	CreatedAt time.Time `json:"created_at"`
+	Method    string    `json:"method"`
+	Host      string    `json:"host"`
+	Status    int       `json:"status"`          // 0 if no response was received
+	BytesOut  int64     `json:"bytes_out"`       // size of the request body
+	BytesIn   int64     `json:"bytes_in"`        // size of the response body
+	Error     string    `json:"error,omitempty"` // why the request was refused or failed
+}
+
+// DeclaredDomains returns the domains in a manifest's network table, sorted
+func DeclaredDomains(manifest *Manifest) []string {
+	domains := []string{}
+	if manifest == nil {
+		return domains
+	}
+	for domain := range manifest.Network {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+	return domains
+}
+
+// LoadBlockSensitiveUploads reads whether plugin requests carrying the data
+// of clips marked sensitive are refused
+func LoadBlockSensitiveUploads(db *sql.DB) bool {
+	var value string
+	if err := db.QueryRow("SELECT value FROM settings WHERE key = ?", settingBlockSensitiveUploads).Scan(&value); err != nil {
+		return false
+	}
+	return value == "true"
+}
+
+// SaveBlockSensitiveUploads stores whether plugin requests carrying the data
+// of clips marked sensitive are refused
+func SaveBlockSensitiveUploads(db *sql.DB, block bool) error {
+	value := "false"
+	if block {
+		value = "true"
+	}
+	_, err := db.Exec(`
+		INSERT INTO settings (key, value) VALUES (?, ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value
+	`, settingBlockSensitiveUploads, value)
+	return err
+}
+
+// QueryNetworkLog returns a plugin's most recent requests, newest first.
+// A limit of 0 returns the whole log.
+func QueryNetworkLog(db *sql.DB, pluginID int64, limit int) ([]NetworkLogEntry, error) {
+	if limit <= 0 {
+		limit = NetworkLogLimit
+	}
+	rows, err := db.Query(`
+		SELECT id, plugin_id, created_at, method, host, status, bytes_out, bytes_in, error
+		FROM plugin_network_log WHERE plugin_id = ?
+		ORDER BY id DESC LIMIT ?
+	`, pluginID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query network log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []NetworkLogEntry{}
+	for rows.Next() {
+		var e NetworkLogEntry
+		if err := rows.Scan(&e.ID, &e.PluginID, &e.CreatedAt, &e.Method, &e.Host, &e.Status, &e.BytesOut, &e.BytesIn, &e.Error); err != nil {
+			return nil, fmt.Errorf("failed to scan network log entry: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// networkGuard checks a plugin's requests against the domains it declares
+// and the user approved, and records them in the network log. Approvals and
+// the sensitive data setting are read on every request so changes take
+// effect immediately.
+type networkGuard struct {
+	db             *sql.DB
+	store          *store.Service
+	pluginID       int64
+	allowedDomains map[string][]string // domain -> allowed methods (from manifest)
+	transport      http.RoundTripper   // nil uses http.DefaultTransport
+	sensitive      *sensitiveData      // shared by all plugins
+}
+
+func newNetworkGuard(db *sql.DB, st *store.Service, pluginID int64, allowedDomains map[string][]string, transport http.RoundTripper, sensitive *sensitiveData) *networkGuard {
+	return &networkGuard{db: db, store: st, pluginID: pluginID, allowedDomains: allowedDomains, transport: transport, sensitive: sensitive}
+}
+
+// checkApproved fails unless the domain matches a declared domain the user
+// approved, and returns the methods allowed for it
+func (g *networkGuard) checkApproved(domain string) ([]string, error) {
+	pattern, methods, ok := findDomain(g.allowedDomains, domain)
+	if !ok {
+		return nil, fmt.Errorf("domain not in allowlist: %s", domain)
+	}
+
+	if !domainApproved(g.db, g.pluginID, pattern) {
+		return nil, fmt.Errorf("domain %s was not approved", pattern)
+	}
+	return methods, nil
+}
+
+// domainApproved checks whether the user approved a declared domain.
+// Approvals restored from a backup must be confirmed again.
+func domainApproved(db *sql.DB, pluginID int64, domain string) bool {
+	var approved int
+	err := db.QueryRow(`SELECT 1 FROM plugin_permissions
+		WHERE plugin_id = ? AND permission_type = ? AND path = ? AND COALESCE(pending_reconfirm, 0) = 0`,
+		pluginID, PermissionNetwork, domain).Scan(&approved)
+	return err == nil
+}
+
+// checkURL fails unless the URL's domain is approved and allows the method
+func (g *networkGuard) checkURL(urlStr, method string) error {
+	parsed, err := url.Parse(urlStr)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+
+	// Use url.Hostname() to correctly handle IPv6 addresses and ports
+	domain := parsed.Hostname()
+
+	allowedMethods, err := g.checkApproved(domain)
+	if err != nil {
+		return err
+	}
+	for _, m := range allowedMethods {
+		if strings.EqualFold(m, method) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s not allowed for domain %s (allowed: [%s])", method, domain, strings.Join(allowedMethods, ", "))
+}
+
+// checkSensitive refuses a request whose URL or body carries the data of a
+// clip marked sensitive or flagged for secrets, if the user turned that on.
+// Clips that can't be decrypted can't be checked, so the request is refused too.
+func (g *networkGuard) checkSensitive(urlStr string, body []byte) error {
+	if !LoadBlockSensitiveUploads(g.db) {
+		return nil
+	}
+	clips, err := g.sensitive.load(g.db, g.store)
+	if err != nil {
+		return fmt.Errorf("failed to check request for sensitive clips: %w", err)
+	}
+
+	payload := append([]byte(urlStr), body...)
+	for _, data := range clips {
+		if containsClipData(payload, data) {
+			return fmt.Errorf("request contains the data of a sensitive clip")
+		}
+	}
+	return nil
+}
+
+// sensitiveData caches the plaintext of the clips requests are checked
+// against, so they aren't decrypted again for every request. Clips are
+// dropped when they change and all of them when the database is locked.
+type sensitiveData struct {
+	mu    sync.Mutex
+	clips map[int64][]byte
+}
+
+func newSensitiveData() *sensitiveData {
+	return &sensitiveData{clips: make(map[int64][]byte)}
+}
+
+// load returns the data of the clips that are marked sensitive or flagged for
+// secrets, reading only the ones not cached yet
+func (c *sensitiveData) load(db *sql.DB, st *store.Service) ([][]byte, error) {
+	rows, err := db.Query(`SELECT id, is_encrypted FROM clips
+		WHERE (is_sensitive = 1 OR COALESCE(secrets, '') != '') AND deleted_at IS NULL`)
+	if err != nil {
+		return nil, err
+	}
+	current := make(map[int64]bool) // clip ID -> encrypted
+	for rows.Next() {
+		var id int64
+		var encrypted bool
+		if err := rows.Scan(&id, &encrypted); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		current[id] = encrypted
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if st.Locked() {
+		clear(c.clips)
+		for _, encrypted := range current {
+			if encrypted {
+				return nil, store.ErrLocked
+			}
+		}
+	}
+	for id := range c.clips {
+		if _, ok := current[id]; !ok {
+			delete(c.clips, id)
+		}
+	}
+
+	clips := make([][]byte, 0, len(current))
+	for id, encrypted := range current {
+		if data, ok := c.clips[id]; ok {
+			clips = append(clips, data)
+			continue
+		}
+		var data []byte
+		err := db.QueryRow("SELECT data, is_encrypted FROM clips WHERE id = ?", id).Scan(&data, &encrypted)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if data, err = st.DecryptData(data, encrypted); err != nil {
+			return nil, err
+		}
+		c.clips[id] = data
+		clips = append(clips, data)
+	}
+	return clips, nil
+}
+
+// handleStoreEvent drops the cached data of clips that may have changed
+func (c *sensitiveData) handleStoreEvent(e store.Event) {
+	if e.Name != "clip:updated" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.clips)
+}
+
+// containsClipData looks for clip data in a request as is, base64 encoded
+// and escaped as in a JSON string
+func containsClipData(payload, data []byte) bool {
+	data = bytes.TrimSpace(data)
+	if len(data) < minSensitiveMatch {
+		return false
+	}
+	if bytes.Contains(payload, data) {
+		return true
+	}
+	if bytes.Contains(payload, []byte(base64.StdEncoding.EncodeToString(data))) {
+		return true
+	}
+	if quoted, err := json.Marshal(string(data)); err == nil && bytes.Contains(payload, quoted[1:len(quoted)-1]) {
+		return true
+	}
+	return false
+}
+
+// record adds a request to the network log, dropping the oldest entries
+// past NetworkLogLimit
+func (g *networkGuard) record(entry NetworkLogEntry) {
+	_, err := g.db.Exec(`INSERT INTO plugin_network_log (plugin_id, created_at, method, host, status, bytes_out, bytes_in, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		g.pluginID, time.Now().UTC().Format("2006-01-02 15:04:05"), entry.Method, entry.Host,
+		entry.Status, entry.BytesOut, entry.BytesIn, entry.Error)
+	if err != nil {
+		log.Printf("Failed to record request of plugin %d: %v", g.pluginID, err)
+		return
+	}
+
+	if _, err := g.db.Exec(`DELETE FROM plugin_network_log WHERE plugin_id = ? AND id <= (
+		SELECT id FROM plugin_network_log WHERE plugin_id = ? ORDER BY id DESC LIMIT 1 OFFSET ?)`,
+		g.pluginID, g.pluginID, NetworkLogLimit); err != nil {
+		log.Printf("Failed to trim network log of plugin %d: %v", g.pluginID, err)
+	}
+}
+
+// hostOf returns the host a request goes to, for the network log
+func hostOf(urlStr string) string {
+	if parsed, err := url.Parse(urlStr); err == nil {
+		return parsed.Hostname()
+	}
+	return ""
+}
@@ -0,0 +1,256 @@
+package plugin
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"go-clipboard/store"
+)
+
+func TestContainsClipData(t *testing.T) {
+	secret := []byte("hunter2-password\n")
+	for payload, want := range map[string]bool{
+		"password=hunter2-password": true,
+		`{"text": "` + base64.StdEncoding.EncodeToString([]byte("hunter2-password")) + `"}`: true,
+		"something else entirely": false,
+	} {
+		if got := containsClipData([]byte(payload), secret); got != want {
+			t.Errorf("containsClipData(%q) = %v, want %v", payload, got, want)
+		}
+	}
+
+	quoted := []byte("line one\nline \"two\"")
+	if !containsClipData([]byte(`{"text":"line one\nline \"two\""}`), quoted) {
+		t.Error("Expected clip data escaped in JSON to be found")
+	}
+	if containsClipData([]byte("the pin is 1234"), []byte("1234")) {
+		t.Error("Expected short clips to be ignored")
+	}
+}
+
+func TestHTTPAPI_RequiresApprovedDomain(t *testing.T) {
+	m := newTestManager(t)
+
+	var received atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+		io.Copy(io.Discard, r.Body)
+		w.Write([]byte("pong"))
+	}))
+	defer server.Close()
+
+	source := fmt.Sprintf(`
+Plugin = {
+    name = "Caller",
+    network = { ["127.0.0.1"] = {"POST"} },
+    ui = { card_actions = { { id = "run", label = "Run" } } },
+}
+function on_ui_action(action, clip_ids, options)
+    local resp, err = http.post(%q, { body = options.body })
+    storage.set("result", resp and (resp.status .. ":" .. resp.body) or err)
+    return { success = true }
+end
+`, server.URL)
+	path := filepath.Join(t.TempDir(), "caller.lua")
+	if err := os.WriteFile(path, []byte(source), 0644); err != nil {
+		t.Fatalf("failed to write plugin: %v", err)
+	}
+
+	if _, err := m.ImportPlugin(path, []string{"example.com"}); err == nil {
+		t.Error("Expected approving an undeclared domain to be refused")
+	}
+	p, err := m.ImportPlugin(path, nil)
+	if err != nil {
+		t.Fatalf("ImportPlugin failed: %v", err)
+	}
+
+	run := func(body string) string {
+		t.Helper()
+		m.db.Exec("DELETE FROM plugin_storage WHERE plugin_id = ?", p.ID)
+		if _, err := m.ExecuteUIAction(p.ID, "run", nil, map[string]interface{}{"body": body}); err != nil {
+			t.Fatalf("ExecuteUIAction failed: %v", err)
+		}
+		return waitForStorage(t, m, p.ID, "result")
+	}
+
+	if got := run("ping"); got != "domain 127.0.0.1 was not approved" {
+		t.Errorf("Expected an unapproved domain to be refused, got %q", got)
+	}
+
+	if err := m.SetDomainApproved(p.ID, "127.0.0.1", true); err != nil {
+		t.Fatalf("SetDomainApproved failed: %v", err)
+	}
+	if got := run("ping"); got != "200:pong" {
+		t.Errorf("Expected the request to succeed once approved, got %q", got)
+	}
+
+	// Requests carrying a sensitive clip are refused once blocking is on
+	clip, _ := m.store.CreateClip(store.User, store.NewClip{ContentType: "text/plain", Data: []byte("correct horse battery")})
+	if err := m.store.SetSensitive(store.User, []int64{clip.ID}, true, 0); err != nil {
+		t.Fatalf("SetSensitive failed: %v", err)
+	}
+	if got := run("text=correct horse battery"); got != "200:pong" {
+		t.Errorf("Expected sensitive data to be sent while blocking is off, got %q", got)
+	}
+	if err := SaveBlockSensitiveUploads(m.db, true); err != nil {
+		t.Fatalf("SaveBlockSensitiveUploads failed: %v", err)
+	}
+	if got := run("text=correct horse battery"); got != "request contains the data of a sensitive clip" {
+		t.Errorf("Expected sensitive data to be refused, got %q", got)
+	}
+	if received.Load() != 2 {
+		t.Errorf("Expected 2 requests to reach the server, got %d", received.Load())
+	}
+
+	entries, err := QueryNetworkLog(m.db, p.ID, 0)
+	if err != nil {
+		t.Fatalf("QueryNetworkLog failed: %v", err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("Expected 4 logged requests, got %+v", entries)
+	}
+	if e := entries[0]; e.Error != "request contains the data of a sensitive clip" || e.Status != 0 {
+		t.Errorf("Unexpected entry for the blocked request: %+v", e)
+	}
+	if e := entries[2]; e.Method != "POST" || e.Host != "127.0.0.1" || e.Status != 200 || e.BytesOut != 4 || e.BytesIn != 4 || e.Error != "" {
+		t.Errorf("Unexpected entry for the approved request: %+v", e)
+	}
+	if e := entries[3]; e.Error != "domain 127.0.0.1 was not approved" {
+		t.Errorf("Unexpected entry for the refused request: %+v", e)
+	}
+
+	// Revoking takes effect without reloading the plugin
+	if err := m.SetDomainApproved(p.ID, "127.0.0.1", false); err != nil {
+		t.Fatalf("SetDomainApproved failed: %v", err)
+	}
+	if got := run("ping"); got != "domain 127.0.0.1 was not approved" {
+		t.Errorf("Expected a revoked domain to be refused, got %q", got)
+	}
+}
+
+func TestNetworkGuard_TrimsLog(t *testing.T) {
+	m := newTestManager(t)
+	p := importTestPlugin(t, m, "quiet.lua", `Plugin = { name = "Quiet" }`)
+
+	guard := newNetworkGuard(m.db, m.store, p.ID, nil, nil, m.sensitive)
+	for i := 0; i < NetworkLogLimit+5; i++ {
+		guard.record(NetworkLogEntry{Method: "GET", Host: fmt.Sprintf("host%d", i)})
+	}
+
+	entries, err := QueryNetworkLog(m.db, p.ID, 0)
+	if err != nil {
+		t.Fatalf("QueryNetworkLog failed: %v", err)
+	}
+	if len(entries) != NetworkLogLimit {
+		t.Fatalf("Expected the log to be trimmed to %d entries, got %d", NetworkLogLimit, len(entries))
+	}
+	if entries[0].Host != fmt.Sprintf("host%d", NetworkLogLimit+4) || entries[len(entries)-1].Host != "host5" {
+		t.Errorf("Expected the oldest entries to be dropped, got %s..%s", entries[0].Host, entries[len(entries)-1].Host)
+	}
+}
+
+func TestNetworkGuard_ChecksSensitiveData(t *testing.T) {
+	m := newTestManager(t)
+	SaveBlockSensitiveUploads(m.db, true)
+	guard := newNetworkGuard(m.db, m.store, 1, nil, nil, m.sensitive)
+	check := func(body string) string {
+		if err := guard.checkSensitive("https://api.example.com/upload", []byte(body)); err != nil {
+			return err.Error()
+		}
+		return "ok"
+	}
+	refused := "request contains the data of a sensitive clip"
+
+	private, _ := m.store.CreateClip(store.User, store.NewClip{ContentType: "text/plain", Data: []byte("correct horse battery")})
+	m.store.SetSensitive(store.User, []int64{private.ID}, true, 0)
+	if got := check("correct horse battery"); got != refused {
+		t.Errorf("Expected a sensitive clip to be refused, got %q", got)
+	}
+
+	// Clips flagged for secrets are looked for too, even when a rescan flags
+	// them without an event
+	token, _ := m.store.CreateClip(store.User, store.NewClip{ContentType: "text/plain", Data: []byte("token=abcdef123456")})
+	if got := check("token=abcdef123456"); got != "ok" {
+		t.Errorf("Expected an unflagged clip to be sent, got %q", got)
+	}
+	m.db.Exec("UPDATE clips SET secrets = 'api_key' WHERE id = ?", token.ID)
+	if got := check("token=abcdef123456"); got != refused {
+		t.Errorf("Expected a clip flagged for secrets to be refused, got %q", got)
+	}
+
+	// Cached data follows edits and the trash
+	m.store.UpdateClip(store.User, private.ID, store.ClipUpdate{Data: []byte("staple battery horse")})
+	if got := check("correct horse battery"); got != "ok" {
+		t.Errorf("Expected the old data of an edited clip to be sent, got %q", got)
+	}
+	if got := check("staple battery horse"); got != refused {
+		t.Errorf("Expected the new data of an edited clip to be refused, got %q", got)
+	}
+	m.store.TrashClips(store.User, []int64{token.ID})
+	if got := check("token=abcdef123456"); got != "ok" {
+		t.Errorf("Expected a trashed clip to be sent, got %q", got)
+	}
+
+	// While locked, encrypted clips can't be checked
+	keyFile := filepath.Join(t.TempDir(), "mahpastes.key")
+	store.GenerateKeyFile(keyFile)
+	if err := m.store.EnableEncryption(store.MasterKey{KeyFile: keyFile}); err != nil {
+		t.Fatalf("EnableEncryption failed: %v", err)
+	}
+	if got := check("staple battery horse"); got != refused {
+		t.Errorf("Expected an encrypted sensitive clip to be refused, got %q", got)
+	}
+	m.store.Lock()
+	if got := check("unrelated"); got != "failed to check request for sensitive clips: "+store.ErrLocked.Error() {
+		t.Errorf("Expected requests to be refused while locked, got %q", got)
+	}
+}
+
+func TestNetworkDomains_LegacyPluginsAndUpgrades(t *testing.T) {
+	m := newTestManager(t)
+	p := importTestPlugin(t, m, "fetcher.lua", `Plugin = { name = "Fetcher", version = "1.0.0", network = { ["api.example.com"] = {"GET"} } }`)
+
+	// A plugin installed before domains needed approval keeps its domains,
+	// once
+	m.UnloadPlugin(p.ID)
+	m.db.Exec("DELETE FROM plugin_permissions WHERE plugin_id = ?", p.ID)
+	m.db.Exec("UPDATE plugins SET domains_migrated = NULL WHERE id = ?", p.ID)
+	if err := m.EnablePlugin(p.ID); err != nil {
+		t.Fatalf("EnablePlugin failed: %v", err)
+	}
+	if !domainApproved(m.db, p.ID, "api.example.com") {
+		t.Error("Expected the legacy plugin's domain to be approved")
+	}
+	m.SetDomainApproved(p.ID, "api.example.com", false)
+	m.UnloadPlugin(p.ID)
+	if err := m.EnablePlugin(p.ID); err != nil {
+		t.Fatalf("EnablePlugin failed: %v", err)
+	}
+	if domainApproved(m.db, p.ID, "api.example.com") {
+		t.Error("Expected a revoked domain to stay revoked after the migration")
+	}
+	m.SetDomainApproved(p.ID, "api.example.com", true)
+
+	// Domains added by an upgrade wait for the user
+	upgraded, err := m.UpgradePlugin(p.ID, writeTestFile(t, t.TempDir(), "fetcher.lua",
+		`Plugin = { name = "Fetcher", version = "1.1.0", network = { ["api.example.com"] = {"GET"}, ["upload.example.com"] = {"POST"} } }`), false)
+	if err != nil {
+		t.Fatalf("UpgradePlugin failed: %v", err)
+	}
+	if pending := m.PendingDomains(p.ID, upgraded.Manifest); len(pending) != 1 || pending[0] != "upload.example.com" {
+		t.Errorf("Expected upload.example.com to be pending, got %v", pending)
+	}
+	if err := m.SetDomainApproved(p.ID, "upload.example.com", true); err != nil {
+		t.Fatalf("SetDomainApproved failed: %v", err)
+	}
+	if pending := m.PendingDomains(p.ID, upgraded.Manifest); len(pending) != 0 {
+		t.Errorf("Expected no pending domains, got %v", pending)
+	}
+}
@@ -0,0 +1,170 @@
+package plugin
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// PackageExt is the file extension of zipped multi-file plugin packages
+	PackageExt = ".zip"
+	// PackageEntry is the entry point inside a package; it must define the Plugin table
+	PackageEntry = "plugin.lua"
+	// MaxPackageSize limits the total uncompressed size of a package (20MB)
+	MaxPackageSize = 20 * 1024 * 1024
+	// MaxPackageFiles limits the number of files in a package
+	MaxPackageFiles = 500
+)
+
+// Package holds the files of a plugin: either a single .lua file or a zipped
+// package with a plugin.lua entry point, additional Lua modules and static assets.
+type Package struct {
+	Main      string            // source of the entry point
+	files     map[string][]byte // slash-separated relative path -> content
+	signature []byte            // detached signature, not part of files
+}
+
+// IsPluginFile reports whether a filename looks like an installable plugin
+func IsPluginFile(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".lua" || ext == PackageExt
+}
+
+// OpenPackage reads a plugin from disk. Single .lua files become a package
+// containing only the entry point.
+func OpenPackage(sourcePath string) (*Package, error) {
+	if !strings.EqualFold(filepath.Ext(sourcePath), PackageExt) {
+		source, err := os.ReadFile(sourcePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read plugin file: %w", err)
+		}
+		// A detached signature may sit next to the file
+		signature, _ := os.ReadFile(sourcePath + SignatureExt)
+		return &Package{
+			Main:      string(source),
+			files:     map[string][]byte{PackageEntry: source},
+			signature: signature,
+		}, nil
+	}
+
+	r, err := zip.OpenReader(sourcePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open plugin package: %w", err)
+	}
+	defer r.Close()
+
+	return readPackage(&r.Reader)
+}
+
+// readPackage loads and validates all files of a zipped package
+func readPackage(r *zip.Reader) (*Package, error) {
+	pkg := &Package{files: make(map[string][]byte)}
+	var total int64
+
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+
+		name, err := cleanPackagePath(f.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(pkg.files) >= MaxPackageFiles {
+			return nil, fmt.Errorf("package has too many files (max %d)", MaxPackageFiles)
+		}
+
+		// Don't trust the declared size; read with a limit instead
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", f.Name, err)
+		}
+		data, err := io.ReadAll(io.LimitReader(rc, MaxPackageSize-total+1))
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", f.Name, err)
+		}
+
+		total += int64(len(data))
+		if total > MaxPackageSize {
+			return nil, fmt.Errorf("package too large (max %d bytes)", MaxPackageSize)
+		}
+
+		if name == SignatureFile {
+			pkg.signature = data
+			continue
+		}
+		pkg.files[name] = data
+	}
+
+	main, ok := pkg.files[PackageEntry]
+	if !ok {
+		return nil, fmt.Errorf("package is missing %s", PackageEntry)
+	}
+	pkg.Main = string(main)
+
+	return pkg, nil
+}
+
+// cleanPackagePath normalizes a path inside a package and rejects anything
+// that would resolve outside of it
+func cleanPackagePath(name string) (string, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(name, "/") {
+		return "", fmt.Errorf("invalid path in package: %s", name)
+	}
+	cleaned := path.Clean(name)
+	if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("invalid path in package: %s", name)
+	}
+	return cleaned, nil
+}
+
+// ReadFile returns the content of a file in the package
+func (p *Package) ReadFile(name string) ([]byte, error) {
+	cleaned, err := cleanPackagePath(name)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := p.files[cleaned]
+	if !ok {
+		return nil, fmt.Errorf("file not found in package: %s", name)
+	}
+	return data, nil
+}
+
+// Files returns the sorted list of file paths in the package
+func (p *Package) Files() []string {
+	names := make([]string, 0, len(p.files))
+	for name := range p.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolveModule maps a module name like "lib.util" to a Lua file in the package.
+// Both "lib/util.lua" and "lib/util/init.lua" are tried.
+func (p *Package) resolveModule(name string) (string, bool) {
+	if name == "" || strings.ContainsAny(name, "\\") {
+		return "", false
+	}
+	base := strings.ReplaceAll(name, ".", "/")
+	for _, candidate := range []string{base + ".lua", base + "/init.lua"} {
+		cleaned, err := cleanPackagePath(candidate)
+		if err != nil || cleaned == PackageEntry {
+			continue
+		}
+		if _, ok := p.files[cleaned]; ok {
+			return cleaned, true
+		}
+	}
+	return "", false
+}
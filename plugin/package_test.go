@@ -0,0 +1,236 @@
+package plugin
+
+import (
+	"archive/zip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// writeTestPackage creates a zipped plugin package with the given files
+func writeTestPackage(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "test-plugin.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("failed to create package: %v", err)
+	}
+	defer f.Close()
+
+	w := zip.NewWriter(f)
+	for name, content := range files {
+		entry, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("failed to create entry %s: %v", name, err)
+		}
+		if _, err := entry.Write([]byte(content)); err != nil {
+			t.Fatalf("failed to write entry %s: %v", name, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to close package: %v", err)
+	}
+	return path
+}
+
+func TestOpenPackage_Zip(t *testing.T) {
+	path := writeTestPackage(t, map[string]string{
+		"plugin.lua":           `Plugin = { name = "Packaged", version = "1.0.0" }`,
+		"lib/util.lua":         `return { answer = 42 }`,
+		"prompts/colorize.txt": "Colorize this image",
+		"lib/helpers/init.lua": `return "helpers"`,
+	})
+
+	pkg, err := OpenPackage(path)
+	if err != nil {
+		t.Fatalf("OpenPackage failed: %v", err)
+	}
+
+	manifest, err := ParseManifest(pkg.Main)
+	if err != nil {
+		t.Fatalf("ParseManifest failed: %v", err)
+	}
+	if manifest.Name != "Packaged" {
+		t.Errorf("Expected name 'Packaged', got '%s'", manifest.Name)
+	}
+	if len(pkg.Files()) != 4 {
+		t.Errorf("Expected 4 files, got %d", len(pkg.Files()))
+	}
+}
+
+func TestOpenPackage_MissingEntry(t *testing.T) {
+	path := writeTestPackage(t, map[string]string{
+		"main.lua": `Plugin = { name = "No Entry" }`,
+	})
+
+	if _, err := OpenPackage(path); err == nil {
+		t.Error("Expected error for package without plugin.lua")
+	}
+}
+
+func TestOpenPackage_PathTraversal(t *testing.T) {
+	path := writeTestPackage(t, map[string]string{
+		"plugin.lua":       `Plugin = { name = "Evil" }`,
+		"../../escape.lua": `return 1`,
+	})
+
+	if _, err := OpenPackage(path); err == nil {
+		t.Error("Expected error for path traversal entry")
+	}
+}
+
+func TestPackageAPI_RequireAndAssets(t *testing.T) {
+	path := writeTestPackage(t, map[string]string{
+		"plugin.lua": `Plugin = { name = "Packaged" }
+local util = require("lib.util")
+local again = require("lib.util")
+helpers = require("lib.helpers")
+answer = util.answer
+same = util == again
+prompt = assets.read("prompts/colorize.txt")
+missing_ok, missing_err = pcall(require, "lib.missing")
+_, escape_err = assets.read("../outside.txt")
+`,
+		"lib/util.lua":         `return { answer = 42 }`,
+		"lib/helpers/init.lua": `return "helpers"`,
+		"prompts/colorize.txt": "Colorize this image",
+	})
+
+	pkg, err := OpenPackage(path)
+	if err != nil {
+		t.Fatalf("OpenPackage failed: %v", err)
+	}
+
+	manifest, _ := ParseManifest(pkg.Main)
+	sandbox := NewSandbox(manifest, 1)
+	defer sandbox.Close()
+	NewPackageAPI(pkg).Register(sandbox.GetState())
+
+	if err := sandbox.LoadSource(pkg.Main); err != nil {
+		t.Fatalf("LoadSource failed: %v", err)
+	}
+
+	L := sandbox.GetState()
+	if got := L.GetGlobal("answer"); got != lua.LNumber(42) {
+		t.Errorf("Expected answer 42, got %v", got)
+	}
+	if got := L.GetGlobal("same"); got != lua.LTrue {
+		t.Errorf("Expected require to cache modules")
+	}
+	if got := L.GetGlobal("helpers"); got.String() != "helpers" {
+		t.Errorf("Expected init.lua module, got %v", got)
+	}
+	if got := L.GetGlobal("prompt"); got.String() != "Colorize this image" {
+		t.Errorf("Expected asset content, got %v", got)
+	}
+	if got := L.GetGlobal("missing_ok"); got != lua.LFalse {
+		t.Errorf("Expected require of missing module to fail")
+	}
+	if got := L.GetGlobal("missing_err"); !strings.Contains(got.String(), "not found") {
+		t.Errorf("Expected not found error, got %v", got)
+	}
+	if got := L.GetGlobal("escape_err"); !strings.Contains(got.String(), "invalid path") {
+		t.Errorf("Expected invalid path error, got %v", got)
+	}
+}
+
+func TestPackageAPI_RequireLoop(t *testing.T) {
+	pkg := &Package{files: map[string][]byte{
+		"a.lua": []byte(`return require("b")`),
+		"b.lua": []byte(`return require("a")`),
+	}}
+
+	sandbox := NewSandbox(&Manifest{Name: "Loop"}, 1)
+	defer sandbox.Close()
+	NewPackageAPI(pkg).Register(sandbox.GetState())
+
+	err := sandbox.LoadSource(`require("a")`)
+	if err == nil || !strings.Contains(err.Error(), "loop") {
+		t.Errorf("Expected loop error, got %v", err)
+	}
+}
+
+func TestImportPlugin_RefusesInstalledPlugin(t *testing.T) {
+	m := newTestManager(t)
+	installed := importTestPlugin(t, m, "vault.lua", `
+Plugin = { name = "Vault" }
+storage.set("api_key", "secret")
+`)
+	waitForStorage(t, m, installed.ID, "api_key")
+
+	// Neither another file with the same name nor another plugin in the same
+	// file takes over the installed plugin and its storage
+	pkg := writeTestPackage(t, map[string]string{"plugin.lua": `Plugin = { name = "Vault" }`})
+	if _, err := m.ImportPlugin(pkg, nil); err == nil || !strings.Contains(err.Error(), "already installed") {
+		t.Errorf("Expected a package with the same name to be refused, got %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "vault.lua")
+	if err := os.WriteFile(path, []byte(`Plugin = { name = "Thief" }`), 0644); err != nil {
+		t.Fatalf("failed to write plugin: %v", err)
+	}
+	if _, err := m.ImportPlugin(path, nil); err == nil || !strings.Contains(err.Error(), "already installed") {
+		t.Errorf("Expected a plugin in the same file to be refused, got %v", err)
+	}
+	if source, _ := os.ReadFile(filepath.Join(m.pluginsDir, "vault.lua")); !strings.Contains(string(source), `"Vault"`) {
+		t.Errorf("Expected the installed file to be kept, got %s", source)
+	}
+
+	// Converting to a package goes through an upgrade and keeps the plugin's ID
+	upgraded, err := m.UpgradePlugin(installed.ID, pkg, false)
+	if err != nil {
+		t.Fatalf("UpgradePlugin failed: %v", err)
+	}
+	if upgraded.ID != installed.ID || upgraded.Filename != "test-plugin.zip" {
+		t.Errorf("Unexpected upgraded plugin: %+v", upgraded)
+	}
+}
+
+func TestImportPlugin_CleansUpFailedImport(t *testing.T) {
+	m := newTestManager(t)
+	path := filepath.Join(t.TempDir(), "flaky.lua")
+	write := func(source string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(source), 0644); err != nil {
+			t.Fatalf("failed to write plugin: %v", err)
+		}
+	}
+	leftovers := func() string {
+		t.Helper()
+		var plugins, permissions int
+		m.db.QueryRow("SELECT COUNT(*) FROM plugins").Scan(&plugins)
+		m.db.QueryRow("SELECT COUNT(*) FROM plugin_permissions").Scan(&permissions)
+		_, err := os.Stat(filepath.Join(m.pluginsDir, "flaky.lua"))
+		return fmt.Sprintf("%d plugins, %d permissions, file kept: %v", plugins, permissions, err == nil)
+	}
+	want := "0 plugins, 0 permissions, file kept: false"
+
+	// A plugin that fails to load is removed again
+	write(`Plugin = { name = "Flaky", scopes = {"clips.read"} }
+error("broken")`)
+	if _, err := m.ImportPlugin(path, nil); err == nil {
+		t.Fatal("Expected a plugin that fails to load to be refused")
+	}
+	if got := leftovers(); got != want {
+		t.Errorf("Expected a failed load to leave nothing behind, got %s", got)
+	}
+
+	// So is a plugin whose grants can't be recorded
+	write(`Plugin = { name = "Flaky", scopes = {"clips.read"} }`)
+	m.db.Exec(`CREATE TRIGGER refuse_grants BEFORE INSERT ON plugin_permissions BEGIN SELECT RAISE(ABORT, 'disk full'); END`)
+	if _, err := m.ImportPlugin(path, nil); err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Fatalf("Expected the failed grant to be reported, got %v", err)
+	}
+	if got := leftovers(); got != want {
+		t.Errorf("Expected a failed grant to leave nothing behind, got %s", got)
+	}
+
+	m.db.Exec("DROP TRIGGER refuse_grants")
+	if _, err := m.ImportPlugin(path, nil); err != nil {
+		t.Fatalf("Expected the plugin to import after failed attempts, got %v", err)
+	}
+}
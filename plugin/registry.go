@@ -0,0 +1,319 @@
+package plugin
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// RegistryIndexFile is the index looked up inside directory registries
+	RegistryIndexFile = "index.json"
+
+	// settingRegistry stores the configured registry location
+	settingRegistry = "plugin_registry"
+
+	registryFetchTimeout = 60 * time.Second
+)
+
+// RegistryEntry describes one published version of a plugin
+type RegistryEntry struct {
+	Name        string `json:"name"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+	File        string `json:"file"`   // path or URL, relative to the index
+	SHA256      string `json:"sha256"` // hex checksum of the file
+}
+
+// RegistryIndex is the JSON document listing the plugins of a registry
+type RegistryIndex struct {
+	Plugins []RegistryEntry `json:"plugins"`
+}
+
+// PluginUpdate describes an available upgrade for an installed plugin
+type PluginUpdate struct {
+	PluginID       int64  `json:"plugin_id"`
+	Name           string `json:"name"`
+	CurrentVersion string `json:"current_version"`
+	LatestVersion  string `json:"latest_version"`
+	Description    string `json:"description,omitempty"`
+}
+
+// Registry lists published plugins and fetches their files
+type Registry interface {
+	// List returns all published plugin versions
+	List() ([]RegistryEntry, error)
+	// Fetch downloads an entry into dir, verifies its checksum and returns the local path
+	Fetch(entry RegistryEntry, dir string) (string, error)
+}
+
+// OpenRegistry opens a registry from a location: a directory (with or without
+// an index.json), a path to an index JSON file, or an http(s) URL serving one
+func OpenRegistry(location string) (Registry, error) {
+	location = strings.TrimSpace(location)
+	if location == "" {
+		return nil, fmt.Errorf("no plugin registry configured")
+	}
+
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		base, err := url.Parse(location)
+		if err != nil {
+			return nil, fmt.Errorf("invalid registry URL: %w", err)
+		}
+		return &httpRegistry{index: base, client: &http.Client{Timeout: registryFetchTimeout}}, nil
+	}
+
+	info, err := os.Stat(location)
+	if err != nil {
+		return nil, fmt.Errorf("registry not found: %w", err)
+	}
+	if info.IsDir() {
+		return &dirRegistry{dir: location}, nil
+	}
+	return &fileRegistry{indexPath: location}, nil
+}
+
+// LatestEntries reduces a registry listing to the highest version per plugin name
+func LatestEntries(entries []RegistryEntry) map[string]RegistryEntry {
+	latest := make(map[string]RegistryEntry)
+	for _, entry := range entries {
+		if current, ok := latest[entry.Name]; !ok || CompareVersions(entry.Version, current.Version) > 0 {
+			latest[entry.Name] = entry
+		}
+	}
+	return latest
+}
+
+// LoadRegistryLocation reads the configured registry location from settings
+func LoadRegistryLocation(db *sql.DB) string {
+	var location string
+	_ = db.QueryRow("SELECT value FROM settings WHERE key = ?", settingRegistry).Scan(&location)
+	return location
+}
+
+// SaveRegistryLocation stores the registry location in settings
+func SaveRegistryLocation(db *sql.DB, location string) error {
+	_, err := db.Exec(`
+		INSERT INTO settings (key, value) VALUES (?, ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value
+	`, settingRegistry, location)
+	return err
+}
+
+// parseRegistryIndex decodes and validates an index document
+func parseRegistryIndex(r io.Reader) ([]RegistryEntry, error) {
+	var index RegistryIndex
+	if err := json.NewDecoder(io.LimitReader(r, MaxPackageSize)).Decode(&index); err != nil {
+		return nil, fmt.Errorf("invalid registry index: %w", err)
+	}
+	for _, entry := range index.Plugins {
+		if entry.Name == "" || entry.File == "" || entry.SHA256 == "" {
+			return nil, fmt.Errorf("invalid registry entry for %q: name, file and sha256 are required", entry.Name)
+		}
+		if !IsPluginFile(entry.File) {
+			return nil, fmt.Errorf("invalid registry entry for %q: unsupported file %s", entry.Name, entry.File)
+		}
+	}
+	return index.Plugins, nil
+}
+
+// saveVerified writes fetched content into dir after checking its checksum
+func saveVerified(entry RegistryEntry, data []byte, dir string) (string, error) {
+	sum := sha256.Sum256(data)
+	if !strings.EqualFold(hex.EncodeToString(sum[:]), entry.SHA256) {
+		return "", fmt.Errorf("checksum mismatch for %s %s", entry.Name, entry.Version)
+	}
+
+	dest := filepath.Join(dir, path.Base(filepath.ToSlash(entry.File)))
+	if err := os.WriteFile(dest, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to save plugin: %w", err)
+	}
+	return dest, nil
+}
+
+// readLimited reads at most MaxPackageSize bytes
+func readLimited(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxPackageSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxPackageSize {
+		return nil, fmt.Errorf("plugin too large (max %d bytes)", MaxPackageSize)
+	}
+	return data, nil
+}
+
+// dirRegistry is a directory of plugin files. If it contains an index.json
+// that is used; otherwise the plugin files themselves are scanned.
+type dirRegistry struct {
+	dir string
+}
+
+func (r *dirRegistry) List() ([]RegistryEntry, error) {
+	indexPath := filepath.Join(r.dir, RegistryIndexFile)
+	if _, err := os.Stat(indexPath); err == nil {
+		return (&fileRegistry{indexPath: indexPath}).List()
+	}
+
+	files, err := os.ReadDir(r.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read registry: %w", err)
+	}
+
+	var entries []RegistryEntry
+	for _, f := range files {
+		if f.IsDir() || !IsPluginFile(f.Name()) {
+			continue
+		}
+		filePath := filepath.Join(r.dir, f.Name())
+		pkg, err := OpenPackage(filePath)
+		if err != nil {
+			continue
+		}
+		manifest, err := ParseManifest(pkg.Main)
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			continue
+		}
+		sum := sha256.Sum256(data)
+		entries = append(entries, RegistryEntry{
+			Name:        manifest.Name,
+			Version:     manifest.Version,
+			Description: manifest.Description,
+			File:        f.Name(),
+			SHA256:      hex.EncodeToString(sum[:]),
+		})
+	}
+	return entries, nil
+}
+
+func (r *dirRegistry) Fetch(entry RegistryEntry, dir string) (string, error) {
+	return fetchLocal(filepath.Join(r.dir, filepath.FromSlash(entry.File)), entry, dir)
+}
+
+// fileRegistry is an index JSON file on disk; entry files are relative to it
+type fileRegistry struct {
+	indexPath string
+}
+
+func (r *fileRegistry) List() ([]RegistryEntry, error) {
+	f, err := os.Open(r.indexPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open registry index: %w", err)
+	}
+	defer f.Close()
+	return parseRegistryIndex(f)
+}
+
+func (r *fileRegistry) Fetch(entry RegistryEntry, dir string) (string, error) {
+	source := filepath.FromSlash(entry.File)
+	if !filepath.IsAbs(source) {
+		source = filepath.Join(filepath.Dir(r.indexPath), source)
+	}
+	return fetchLocal(source, entry, dir)
+}
+
+// fetchLocal copies a plugin file (and its detached signature, if any) into dir
+func fetchLocal(source string, entry RegistryEntry, dir string) (string, error) {
+	f, err := os.Open(source)
+	if err != nil {
+		return "", fmt.Errorf("failed to open plugin: %w", err)
+	}
+	data, err := readLimited(f)
+	f.Close()
+	if err != nil {
+		return "", err
+	}
+
+	dest, err := saveVerified(entry, data, dir)
+	if err != nil {
+		return "", err
+	}
+	if sig, err := os.ReadFile(source + SignatureExt); err == nil {
+		if err := os.WriteFile(dest+SignatureExt, sig, 0644); err != nil {
+			return "", fmt.Errorf("failed to save plugin signature: %w", err)
+		}
+	}
+	return dest, nil
+}
+
+// httpRegistry is an index JSON served over http(s), e.g. by a local server
+type httpRegistry struct {
+	index  *url.URL
+	client *http.Client
+}
+
+func (r *httpRegistry) List() ([]RegistryEntry, error) {
+	resp, err := r.client.Get(r.index.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch registry index: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch registry index: %s", resp.Status)
+	}
+	return parseRegistryIndex(resp.Body)
+}
+
+func (r *httpRegistry) Fetch(entry RegistryEntry, dir string) (string, error) {
+	ref, err := url.Parse(entry.File)
+	if err != nil {
+		return "", fmt.Errorf("invalid plugin URL: %w", err)
+	}
+	fileURL := r.index.ResolveReference(ref)
+
+	data, err := r.get(fileURL.String())
+	if err != nil {
+		return "", err
+	}
+	if data == nil {
+		return "", fmt.Errorf("plugin file not found: %s", fileURL)
+	}
+
+	dest, err := saveVerified(entry, data, dir)
+	if err != nil {
+		return "", err
+	}
+
+	// Detached signatures are optional, so a missing one is not an error
+	sig, err := r.get(fileURL.String() + SignatureExt)
+	if err != nil {
+		return "", err
+	}
+	if sig != nil {
+		if err := os.WriteFile(dest+SignatureExt, sig, 0644); err != nil {
+			return "", fmt.Errorf("failed to save plugin signature: %w", err)
+		}
+	}
+	return dest, nil
+}
+
+// get fetches a URL, returning nil data for 404 responses
+func (r *httpRegistry) get(target string) ([]byte, error) {
+	resp, err := r.client.Get(target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", target, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: %s", target, resp.Status)
+	}
+	return readLimited(resp.Body)
+}
@@ -0,0 +1,151 @@
+package plugin
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestDirRegistry_ScanAndFetch(t *testing.T) {
+	dir := t.TempDir()
+	v1 := `Plugin = { name = "Updater", version = "1.0.0" }`
+	v2 := `Plugin = { name = "Updater", version = "1.2.0" }`
+	os.WriteFile(filepath.Join(dir, "updater-1.0.0.lua"), []byte(v1), 0644)
+	os.WriteFile(filepath.Join(dir, "updater-1.2.0.lua"), []byte(v2), 0644)
+	os.WriteFile(filepath.Join(dir, "updater-1.2.0.lua.sig"), []byte("sig"), 0644)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("ignored"), 0644)
+
+	reg, err := OpenRegistry(dir)
+	if err != nil {
+		t.Fatalf("OpenRegistry failed: %v", err)
+	}
+
+	entries, err := reg.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(entries))
+	}
+
+	latest := LatestEntries(entries)["Updater"]
+	if latest.Version != "1.2.0" {
+		t.Errorf("Expected latest 1.2.0, got %s", latest.Version)
+	}
+
+	path, err := reg.Fetch(latest, t.TempDir())
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != v2 {
+		t.Errorf("Unexpected fetched content: %s", data)
+	}
+	if _, err := os.Stat(path + SignatureExt); err != nil {
+		t.Errorf("Expected detached signature to be fetched: %v", err)
+	}
+}
+
+func TestFileRegistry_ChecksumMismatch(t *testing.T) {
+	dir := t.TempDir()
+	source := `Plugin = { name = "Updater", version = "2.0.0" }`
+	os.WriteFile(filepath.Join(dir, "updater.lua"), []byte(source), 0644)
+	index := `{"plugins": [{"name": "Updater", "version": "2.0.0", "file": "updater.lua", "sha256": "` + sha256Hex("something else") + `"}]}`
+	indexPath := filepath.Join(dir, "plugins.json")
+	os.WriteFile(indexPath, []byte(index), 0644)
+
+	reg, err := OpenRegistry(indexPath)
+	if err != nil {
+		t.Fatalf("OpenRegistry failed: %v", err)
+	}
+	entries, err := reg.List()
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("List failed: %v, %v", entries, err)
+	}
+
+	_, err = reg.Fetch(entries[0], t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("Expected checksum mismatch, got %v", err)
+	}
+}
+
+func TestHTTPRegistry(t *testing.T) {
+	source := `Plugin = { name = "Remote", version = "0.3.0" }`
+	mux := http.NewServeMux()
+	mux.HandleFunc("/registry/index.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"plugins": [{"name": "Remote", "version": "0.3.0", "file": "files/remote.lua", "sha256": "` + sha256Hex(source) + `"}]}`))
+	})
+	mux.HandleFunc("/registry/files/remote.lua", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(source))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	reg, err := OpenRegistry(server.URL + "/registry/index.json")
+	if err != nil {
+		t.Fatalf("OpenRegistry failed: %v", err)
+	}
+	entries, err := reg.List()
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("List failed: %v, %v", entries, err)
+	}
+
+	path, err := reg.Fetch(entries[0], t.TempDir())
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if filepath.Base(path) != "remote.lua" {
+		t.Errorf("Expected remote.lua, got %s", filepath.Base(path))
+	}
+	if _, err := os.Stat(path + SignatureExt); !os.IsNotExist(err) {
+		t.Errorf("Expected no signature for unsigned plugin")
+	}
+}
+
+func TestParseRegistryIndex_Invalid(t *testing.T) {
+	for _, index := range []string{
+		`not json`,
+		`{"plugins": [{"name": "No File", "version": "1.0.0", "sha256": "abc"}]}`,
+		`{"plugins": [{"name": "Bad", "version": "1.0.0", "file": "bad.exe", "sha256": "abc"}]}`,
+	} {
+		if _, err := parseRegistryIndex(strings.NewReader(index)); err == nil {
+			t.Errorf("Expected error for index %s", index)
+		}
+	}
+}
+
+func TestUpgradeFromRegistry_VersionMismatch(t *testing.T) {
+	m := newTestManager(t)
+	installed := importTestPlugin(t, m, "updater.lua", `Plugin = { name = "Updater", version = "1.0.0" }`)
+
+	// The index promises 2.0.0 but serves an older file with a valid checksum
+	dir := t.TempDir()
+	source := `Plugin = { name = "Updater", version = "1.5.0" }`
+	os.WriteFile(filepath.Join(dir, "updater-2.0.0.lua"), []byte(source), 0644)
+	index := `{"plugins": [{"name": "Updater", "version": "2.0.0", "file": "updater-2.0.0.lua", "sha256": "` + sha256Hex(source) + `"}]}`
+	indexPath := filepath.Join(dir, "plugins.json")
+	os.WriteFile(indexPath, []byte(index), 0644)
+
+	reg, err := OpenRegistry(indexPath)
+	if err != nil {
+		t.Fatalf("OpenRegistry failed: %v", err)
+	}
+	_, err = m.UpgradeFromRegistry(reg, installed.ID, false)
+	if err == nil || !strings.Contains(err.Error(), "download is v1.5.0") {
+		t.Errorf("Expected the version mismatch to be refused, got %v", err)
+	}
+	var version string
+	m.db.QueryRow("SELECT version FROM plugins WHERE id = ?", installed.ID).Scan(&version)
+	if version != "1.0.0" {
+		t.Errorf("Expected the installed version to be kept, got %s", version)
+	}
+}
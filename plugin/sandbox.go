@@ -2,6 +2,7 @@ package plugin
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -15,6 +16,9 @@ const (
 	MaxMemoryMB      = 50
 )
 
+// errNoHandler is returned when a plugin does not define the called handler
+var errNoHandler = errors.New("handler not defined")
+
 // Sandbox wraps a Lua state with resource limits
 type Sandbox struct {
 	L        *lua.LState
@@ -22,6 +26,12 @@ type Sandbox struct {
 	pluginID int64
 	mu       sync.Mutex
 	cancel   context.CancelFunc
+
+	// eventDepth is the length of the event chain being handled, see CallEventHandler
+	eventDepth int
+
+	// action names what the plugin is running: a UI action ID or handler name
+	action string
 }
 
 // NewSandbox creates a new sandboxed Lua environment
@@ -96,6 +106,9 @@ func (s *Sandbox) CallHandler(name string, args ...lua.LValue) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	s.action = name
+	defer func() { s.action = "" }()
+
 	fn := s.L.GetGlobal(name)
 	if fn == lua.LNil {
 		return nil // Handler not defined, skip silently
@@ -136,9 +149,19 @@ func (s *Sandbox) CallHandler(name string, args ...lua.LValue) error {
 
 // CallHandlerWithData calls a handler function with Go data that will be converted to Lua inside the mutex
 func (s *Sandbox) CallHandlerWithData(name string, data interface{}) error {
+	return s.CallEventHandler(name, data, 0)
+}
+
+// CallEventHandler is CallHandlerWithData for an event that is depth steps into
+// a chain of plugins reacting to each other's changes (0 for app events)
+func (s *Sandbox) CallEventHandler(name string, data interface{}, depth int) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	s.eventDepth = depth
+	s.action = name
+	defer func() { s.eventDepth, s.action = 0, "" }()
+
 	fn := s.L.GetGlobal(name)
 	if fn == lua.LNil {
 		return nil // Handler not defined, skip silently
@@ -200,6 +223,19 @@ func (s *Sandbox) GetState() *lua.LState {
 	return s.L
 }
 
+// EventDepth returns the depth of the event being handled. It is only
+// meaningful while the plugin is running, i.e. from within its API calls.
+func (s *Sandbox) EventDepth() int {
+	return s.eventDepth
+}
+
+// Action returns the UI action ID or handler name being run, for recording
+// where a plugin's clips came from. Like EventDepth, it is only meaningful
+// from within the plugin's API calls.
+func (s *Sandbox) Action() string {
+	return s.action
+}
+
 // GetManifest returns the plugin manifest
 func (s *Sandbox) GetManifest() *Manifest {
 	return s.manifest
@@ -216,6 +252,9 @@ func (s *Sandbox) CallUIAction(actionID string, clipIDs []int64, options map[str
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	s.action = actionID
+	defer func() { s.action = "" }()
+
 	fn := s.L.GetGlobal("on_ui_action")
 	if fn == lua.LNil {
 		return nil, fmt.Errorf("plugin does not implement on_ui_action")
@@ -294,3 +333,56 @@ func (s *Sandbox) CallUIAction(actionID string, clipIDs []int64, options map[str
 
 	return result, nil
 }
+
+// CallBusHandler calls a message bus handler with Go arguments (converted inside
+// the mutex) and returns its first result converted back to Go. Waiting for the
+// sandbox counts against the timeout, so plugins calling each other can't deadlock.
+func (s *Sandbox) CallBusHandler(name string, timeout time.Duration, args ...interface{}) (interface{}, error) {
+	deadline := time.Now().Add(timeout)
+	for !s.mu.TryLock() {
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("handler %s timed out waiting for plugin after %v", name, timeout)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	defer s.mu.Unlock()
+
+	s.action = name
+	defer func() { s.action = "" }()
+
+	fn := s.L.GetGlobal(name)
+	if fn == lua.LNil {
+		return nil, errNoHandler
+	}
+
+	if _, ok := fn.(*lua.LFunction); !ok {
+		return nil, fmt.Errorf("%s is not a function", name)
+	}
+
+	// Create context with the remaining time
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	s.cancel = cancel
+	defer func() {
+		cancel()
+		s.cancel = nil
+	}()
+
+	s.L.SetContext(ctx)
+
+	s.L.Push(fn)
+	for _, arg := range args {
+		s.L.Push(goToLua(s.L, arg))
+	}
+
+	err := s.L.PCall(len(args), 1, nil)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("handler %s timed out after %v", name, timeout)
+		}
+		return nil, fmt.Errorf("handler %s failed: %w", name, err)
+	}
+
+	ret := s.L.Get(-1)
+	s.L.Pop(1)
+	return luaToGo(ret), nil
+}
@@ -1,57 +1,210 @@
 package plugin
 
 import (
+	"database/sql"
 	"fmt"
 	"log"
+	"sort"
 	"sync"
 	"time"
 )
 
+// Task results recorded after each run
+const (
+	TaskResultSuccess = "success"
+	TaskResultError   = "error"
+)
+
+// TaskStatus reports the state of a scheduled task
+type TaskStatus struct {
+	Name       string `json:"name"`
+	Interval   int    `json:"interval,omitempty"`
+	Cron       string `json:"cron,omitempty"`
+	Timezone   string `json:"timezone,omitempty"`
+	NextRun    string `json:"next_run,omitempty"` // RFC3339, empty if not scheduled
+	LastRun    string `json:"last_run,omitempty"` // RFC3339, empty if never run
+	LastResult string `json:"last_result,omitempty"`
+	LastError  string `json:"last_error,omitempty"`
+}
+
 // ScheduledTask represents a running scheduled task
 type ScheduledTask struct {
-	name     string
-	interval time.Duration
-	sandbox  *Sandbox
-	stopCh   chan struct{}
-	running  bool
-	stopped  bool // Prevents double-close of stopCh
-	mu       sync.Mutex
+	pluginID   int64
+	name       string
+	schedule   Schedule
+	cron       *CronSchedule
+	loc        *time.Location
+	sandbox    *Sandbox
+	db         *sql.DB
+	now        func() time.Time
+	stopCh     chan struct{}
+	running    bool
+	stopped    bool // Prevents double-close of stopCh
+	nextRun    time.Time
+	lastRun    time.Time
+	lastResult string
+	lastError  string
+	mu         sync.Mutex
 }
 
 // Scheduler manages scheduled tasks for plugins
 type Scheduler struct {
-	tasks map[string]*ScheduledTask // key: pluginID:taskName
-	mu    sync.RWMutex
+	db     *sql.DB                   // persists last runs; may be nil
+	tasks  map[string]*ScheduledTask // key: pluginID:taskName
+	manual bool                      // tasks only run when Advance moves the clock
+	clock  time.Time                 // current time of a manual scheduler
+	mu     sync.RWMutex
 }
 
-// NewScheduler creates a new scheduler
-func NewScheduler() *Scheduler {
+// NewScheduler creates a new scheduler. Last-run times are persisted in db
+// (if not nil) so tasks keep their phase and can catch up across restarts.
+func NewScheduler(db *sql.DB) *Scheduler {
 	return &Scheduler{
+		db:    db,
 		tasks: make(map[string]*ScheduledTask),
 	}
 }
 
-// AddTask adds a scheduled task
-func (s *Scheduler) AddTask(pluginID int64, taskName string, interval int, sandbox *Sandbox) {
+// NewManualScheduler creates a scheduler with a fake clock starting at start.
+// Tasks don't run on their own; Advance moves the clock and runs the tasks
+// that come due, so tests of schedules don't have to wait.
+func NewManualScheduler(db *sql.DB, start time.Time) *Scheduler {
+	return &Scheduler{
+		db:     db,
+		tasks:  make(map[string]*ScheduledTask),
+		manual: true,
+		clock:  start,
+	}
+}
+
+// Now returns the scheduler's time: the fake clock of a manual scheduler,
+// otherwise the current time
+func (s *Scheduler) Now() time.Time {
+	if !s.manual {
+		return time.Now()
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.clock
+}
+
+// Advance moves the clock of a manual scheduler forward by d, running each
+// task as it comes due in order, and returns the number of runs. It does
+// nothing on a scheduler using real time.
+func (s *Scheduler) Advance(d time.Duration) int {
+	if !s.manual {
+		return 0
+	}
+
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	target := s.clock.Add(d)
+	s.mu.Unlock()
 
-	key := taskKey(pluginID, taskName)
+	runs := 0
+	for {
+		task, at := s.nextDue(target)
+		if task == nil {
+			break
+		}
 
-	// Stop existing task if any
-	if existing, ok := s.tasks[key]; ok {
-		existing.Stop()
+		s.mu.Lock()
+		if at.After(s.clock) {
+			s.clock = at
+		}
+		s.mu.Unlock()
+
+		task.execute()
+		task.mu.Lock()
+		task.nextRun = task.next(at)
+		task.mu.Unlock()
+		runs++
+	}
+
+	s.mu.Lock()
+	s.clock = target
+	s.mu.Unlock()
+	return runs
+}
+
+// nextDue returns the task due first at or before target (ties broken by
+// key, so runs are in a stable order)
+func (s *Scheduler) nextDue(target time.Time) (*ScheduledTask, time.Time) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]string, 0, len(s.tasks))
+	for key := range s.tasks {
+		keys = append(keys, key)
 	}
+	sort.Strings(keys)
+
+	var due *ScheduledTask
+	var dueAt time.Time
+	for _, key := range keys {
+		task := s.tasks[key]
+		task.mu.Lock()
+		next := task.nextRun
+		task.mu.Unlock()
+		if next.IsZero() || next.After(target) {
+			continue
+		}
+		if due == nil || next.Before(dueAt) {
+			due, dueAt = task, next
+		}
+	}
+	return due, dueAt
+}
 
+// AddTask adds a scheduled task
+func (s *Scheduler) AddTask(pluginID int64, sched Schedule, sandbox *Sandbox) error {
 	task := &ScheduledTask{
-		name:     taskName,
-		interval: time.Duration(interval) * time.Second,
+		pluginID: pluginID,
+		name:     sched.Name,
+		schedule: sched,
+		loc:      time.Local,
 		sandbox:  sandbox,
+		db:       s.db,
+		now:      s.Now,
 		stopCh:   make(chan struct{}),
+		running:  true,
+	}
+
+	if sched.Cron != "" {
+		cron, err := ParseCron(sched.Cron)
+		if err != nil {
+			return err
+		}
+		task.cron = cron
+		if sched.Timezone != "" {
+			loc, err := time.LoadLocation(sched.Timezone)
+			if err != nil {
+				return fmt.Errorf("unknown timezone %s: %w", sched.Timezone, err)
+			}
+			task.loc = loc
+		}
+	} else if sched.Interval <= 0 {
+		return fmt.Errorf("schedule %s needs an interval or cron expression", sched.Name)
+	}
+
+	task.loadLastRun()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := taskKey(pluginID, sched.Name)
+
+	// Stop existing task if any
+	if existing, ok := s.tasks[key]; ok {
+		existing.Stop()
 	}
 
 	s.tasks[key] = task
+	if s.manual {
+		task.nextRun = task.firstRun(s.clock)
+		return nil
+	}
 	go task.run()
+	return nil
 }
 
 // RemovePluginTasks removes all tasks for a plugin
@@ -79,6 +232,22 @@ func (s *Scheduler) StopAll() {
 	s.tasks = make(map[string]*ScheduledTask)
 }
 
+// PluginTaskStatus returns the status of all running tasks of a plugin
+func (s *Scheduler) PluginTaskStatus(pluginID int64) []TaskStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	statuses := []TaskStatus{}
+	prefix := taskKeyPrefix(pluginID)
+	for key, task := range s.tasks {
+		if len(key) >= len(prefix) && key[:len(prefix)] == prefix {
+			statuses = append(statuses, task.Status())
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
 func taskKey(pluginID int64, taskName string) string {
 	return fmt.Sprintf("%d:%s", pluginID, taskName)
 }
@@ -87,21 +256,65 @@ func taskKeyPrefix(pluginID int64) string {
 	return fmt.Sprintf("%d:", pluginID)
 }
 
+// next computes the next run after t
+func (t *ScheduledTask) next(after time.Time) time.Time {
+	if t.cron != nil {
+		return t.cron.Next(after.In(t.loc))
+	}
+	return after.Add(time.Duration(t.schedule.Interval) * time.Second)
+}
+
+// firstRun decides when to run first: immediately if a run was missed while
+// the app was closed (and catch-up is on), otherwise at the next regular time.
+// Interval tasks keep their phase from the last run instead of restarting it.
+func (t *ScheduledTask) firstRun(now time.Time) time.Time {
+	if t.lastRun.IsZero() {
+		return t.next(now)
+	}
+
+	due := t.next(t.lastRun)
+	if due.IsZero() || due.After(now) {
+		return due
+	}
+	if t.schedule.CatchUp {
+		return now
+	}
+
+	if t.cron != nil {
+		return t.next(now)
+	}
+	interval := time.Duration(t.schedule.Interval) * time.Second
+	missed := now.Sub(t.lastRun) / interval
+	return t.lastRun.Add((missed + 1) * interval)
+}
+
 func (t *ScheduledTask) run() {
 	t.mu.Lock()
-	t.running = true
+	t.nextRun = t.firstRun(t.now())
 	t.mu.Unlock()
 
-	ticker := time.NewTicker(t.interval)
-	defer ticker.Stop()
-
 	for {
+		t.mu.Lock()
+		nextRun := t.nextRun
+		t.mu.Unlock()
+
+		if nextRun.IsZero() {
+			log.Printf("Scheduled task %s has no upcoming runs", t.name)
+			return
+		}
+
+		timer := time.NewTimer(time.Until(nextRun))
 		select {
 		case <-t.stopCh:
+			timer.Stop()
 			return
-		case <-ticker.C:
+		case <-timer.C:
 			t.execute()
 		}
+
+		t.mu.Lock()
+		t.nextRun = t.next(t.now())
+		t.mu.Unlock()
 	}
 }
 
@@ -110,6 +323,7 @@ func (t *ScheduledTask) execute() {
 	defer func() {
 		if r := recover(); r != nil {
 			log.Printf("Scheduled task %s panicked: %v", t.name, r)
+			t.recordRun(t.now(), fmt.Errorf("panic: %v", r))
 		}
 	}()
 
@@ -122,10 +336,87 @@ func (t *ScheduledTask) execute() {
 	sandbox := t.sandbox
 	t.mu.Unlock()
 
+	started := t.now()
+
 	// Call the handler function named after the task
-	if err := sandbox.CallHandler(t.name); err != nil {
+	err := sandbox.CallHandler(t.name)
+	if err != nil {
 		log.Printf("Scheduled task %s failed: %v", t.name, err)
 	}
+	t.recordRun(started, err)
+}
+
+// recordRun stores the outcome of a run in memory and in the database
+func (t *ScheduledTask) recordRun(at time.Time, err error) {
+	t.mu.Lock()
+	t.lastRun = at
+	t.lastResult = TaskResultSuccess
+	t.lastError = ""
+	if err != nil {
+		t.lastResult = TaskResultError
+		t.lastError = err.Error()
+	}
+	result, lastError := t.lastResult, t.lastError
+	t.mu.Unlock()
+
+	if t.db == nil {
+		return
+	}
+	_, dbErr := t.db.Exec(`
+		INSERT INTO plugin_schedule_runs (plugin_id, task_name, last_run, last_result, last_error)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(plugin_id, task_name) DO UPDATE SET
+			last_run = excluded.last_run,
+			last_result = excluded.last_result,
+			last_error = excluded.last_error
+	`, t.pluginID, t.name, at.UTC().Format(time.RFC3339), result, lastError)
+	if dbErr != nil {
+		log.Printf("Failed to record run of scheduled task %s: %v", t.name, dbErr)
+	}
+}
+
+// loadLastRun restores the last run of the task from the database
+func (t *ScheduledTask) loadLastRun() {
+	if t.db == nil {
+		return
+	}
+
+	var lastRun string
+	err := t.db.QueryRow(`
+		SELECT last_run, last_result, last_error FROM plugin_schedule_runs
+		WHERE plugin_id = ? AND task_name = ?
+	`, t.pluginID, t.name).Scan(&lastRun, &t.lastResult, &t.lastError)
+	if err != nil {
+		return
+	}
+	if parsed, err := time.Parse(time.RFC3339, lastRun); err == nil {
+		t.lastRun = parsed
+	}
+}
+
+// Status returns the current state of the task
+func (t *ScheduledTask) Status() TaskStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	status := TaskStatus{
+		Name:       t.name,
+		Interval:   t.schedule.Interval,
+		Cron:       t.schedule.Cron,
+		Timezone:   t.schedule.Timezone,
+		LastResult: t.lastResult,
+		LastError:  t.lastError,
+	}
+	if t.cron != nil {
+		status.Interval = 0
+	}
+	if !t.nextRun.IsZero() {
+		status.NextRun = t.nextRun.Format(time.RFC3339)
+	}
+	if !t.lastRun.IsZero() {
+		status.LastRun = t.lastRun.In(t.loc).Format(time.RFC3339)
+	}
+	return status
 }
 
 // Stop stops the scheduled task
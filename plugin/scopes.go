@@ -0,0 +1,224 @@
+package plugin
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"go-clipboard/store"
+)
+
+// Clip and tag scopes. Plugins can only use the clips and tags APIs within
+// the scopes they declare in their manifest and the user grants, which is
+// recorded in plugin_permissions with the scope as the permission type.
+const (
+	ScopeClipsRead   = "clips.read"   // list clips and read their data
+	ScopeClipsWrite  = "clips.write"  // create, change, archive, pin and reorder clips
+	ScopeClipsDelete = "clips.delete" // delete clips and restore them from the trash
+	ScopeTagsWrite   = "tags.write"   // create, change and delete tags, and tag clips
+)
+
+// ValidScopes returns the scopes a manifest can declare
+func ValidScopes() []string {
+	return []string{ScopeClipsRead, ScopeClipsWrite, ScopeClipsDelete, ScopeTagsWrite}
+}
+
+// IsValidScope checks if a scope name is known
+func IsValidScope(scope string) bool {
+	return containsString(ValidScopes(), scope)
+}
+
+// ScopeLimits restricts a plugin's clip scopes to some clips. Empty fields
+// don't restrict.
+type ScopeLimits struct {
+	ContentTypes []string `json:"content_types,omitempty"` // exact, or a prefix such as "image/*"
+	Tags         []string `json:"tags,omitempty"`          // clips with at least one of these tags
+}
+
+// allowsContentType checks a content type against the ContentTypes limit
+func (l ScopeLimits) allowsContentType(contentType string) bool {
+	if len(l.ContentTypes) == 0 {
+		return true
+	}
+	for _, allowed := range l.ContentTypes {
+		if prefix, ok := strings.CutSuffix(allowed, "*"); ok {
+			if strings.HasPrefix(contentType, prefix) {
+				return true
+			}
+		} else if contentType == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// hasGrant checks for a granted permission. Grants restored from a backup
+// must be confirmed again.
+func hasGrant(db *sql.DB, pluginID int64, permissionType string) bool {
+	var granted int
+	err := db.QueryRow(`SELECT 1 FROM plugin_permissions
+		WHERE plugin_id = ? AND permission_type = ? AND COALESCE(pending_reconfirm, 0) = 0`,
+		pluginID, permissionType).Scan(&granted)
+	return err == nil
+}
+
+// scopeChecker enforces a plugin's scopes inside the clips and tags APIs.
+// Grants are read on every check so revoking takes effect immediately.
+type scopeChecker struct {
+	db       *sql.DB
+	pluginID int64
+	manifest *Manifest
+}
+
+func newScopeChecker(db *sql.DB, pluginID int64, manifest *Manifest) *scopeChecker {
+	return &scopeChecker{db: db, pluginID: pluginID, manifest: manifest}
+}
+
+func (s *scopeChecker) limits() ScopeLimits {
+	if s.manifest == nil {
+		return ScopeLimits{}
+	}
+	return s.manifest.ScopeLimits
+}
+
+// require fails unless the plugin declared the scope and the user granted it
+func (s *scopeChecker) require(scope string) error {
+	if s.manifest == nil || !containsString(s.manifest.Scopes, scope) {
+		return fmt.Errorf("plugin did not declare the %s scope", scope)
+	}
+	if !hasGrant(s.db, s.pluginID, scope) {
+		return fmt.Errorf("%s scope was not granted", scope)
+	}
+	return nil
+}
+
+// clipCondition returns an SQL condition on the clips table selecting the
+// clips within the plugin's limits. It returns "" when there are no limits.
+func (s *scopeChecker) clipCondition() (string, []interface{}) {
+	limits := s.limits()
+	var conditions []string
+	var args []interface{}
+
+	if len(limits.ContentTypes) > 0 {
+		var types []string
+		for _, allowed := range limits.ContentTypes {
+			if prefix, ok := strings.CutSuffix(allowed, "*"); ok {
+				types = append(types, "substr(content_type, 1, ?) = ?")
+				args = append(args, len(prefix), prefix)
+			} else {
+				types = append(types, "content_type = ?")
+				args = append(args, allowed)
+			}
+		}
+		conditions = append(conditions, "("+strings.Join(types, " OR ")+")")
+	}
+
+	if query := store.AnyTag(limits.Tags); query != nil {
+		condition, tagArgs := query.Condition("id")
+		conditions = append(conditions, condition)
+		args = append(args, tagArgs...)
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// inLimits reports whether a clip is within the plugin's limits
+func (s *scopeChecker) inLimits(id int64) (bool, error) {
+	condition, args := s.clipCondition()
+	if condition == "" {
+		return true, nil
+	}
+	var found int
+	err := s.db.QueryRow("SELECT 1 FROM clips WHERE id = ? AND "+condition,
+		append([]interface{}{id}, args...)...).Scan(&found)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check clip scope: %w", err)
+	}
+	return true, nil
+}
+
+// canReadSensitive reports whether the plugin declared the sensitive_clips
+// permission and the user granted it
+func (s *scopeChecker) canReadSensitive() bool {
+	if s.manifest == nil || !s.manifest.Permissions.SensitiveClips {
+		return false
+	}
+	return hasGrant(s.db, s.pluginID, PermissionSensitiveClips)
+}
+
+// visible reports whether a clip is within the plugin's limits and, when
+// sensitive, the plugin may read sensitive clips
+func (s *scopeChecker) visible(id int64) (bool, error) {
+	var sensitive bool
+	err := s.db.QueryRow("SELECT is_sensitive FROM clips WHERE id = ?", id).Scan(&sensitive)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check clip: %w", err)
+	}
+	if sensitive && !s.canReadSensitive() {
+		return false, nil
+	}
+	return s.inLimits(id)
+}
+
+// checkClips fails unless the scope is granted and every clip is within the
+// plugin's limits
+func (s *scopeChecker) checkClips(scope string, ids ...int64) error {
+	if err := s.require(scope); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		ok, err := s.inLimits(id)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("clip %d is outside the plugin's scope", id)
+		}
+	}
+	return nil
+}
+
+// checkContentType fails unless a content type is within the plugin's limits
+func (s *scopeChecker) checkContentType(contentType string) error {
+	if !s.limits().allowsContentType(contentType) {
+		return fmt.Errorf("content type %s is outside the plugin's scope", contentType)
+	}
+	return nil
+}
+
+// checkTag fails unless tags.write is granted and, when the plugin is limited
+// to some tags, the tag is one of them or one of their children
+func (s *scopeChecker) checkTag(name string) error {
+	if err := s.require(ScopeTagsWrite); err != nil {
+		return err
+	}
+	tags := s.limits().Tags
+	if len(tags) == 0 {
+		return nil
+	}
+	for _, tag := range tags {
+		if store.TagMatches(name, tag) {
+			return nil
+		}
+	}
+	return fmt.Errorf("tag %s is outside the plugin's scope", name)
+}
+
+// checkTagID is checkTag for an existing tag
+func (s *scopeChecker) checkTagID(id int64) error {
+	var name string
+	err := s.db.QueryRow("SELECT name FROM tags WHERE id = ?", id).Scan(&name)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("tag not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to query tag: %w", err)
+	}
+	return s.checkTag(name)
+}
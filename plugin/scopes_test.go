@@ -0,0 +1,354 @@
+package plugin
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"go-clipboard/store"
+)
+
+func TestParseManifest_Scopes(t *testing.T) {
+	manifest, err := ParseManifest(`Plugin = {
+    name = "Scoped",
+    scopes = {"clips.read", "tags.write"},
+    scope_limits = { content_types = {"image/*", "text/plain"}, tags = {"inbox"} },
+}`)
+	if err != nil {
+		t.Fatalf("ParseManifest failed: %v", err)
+	}
+	if len(manifest.Scopes) != 2 || manifest.Scopes[0] != ScopeClipsRead || manifest.Scopes[1] != ScopeTagsWrite {
+		t.Errorf("Unexpected scopes: %v", manifest.Scopes)
+	}
+	limits := manifest.ScopeLimits
+	if len(limits.ContentTypes) != 2 || limits.ContentTypes[0] != "image/*" || len(limits.Tags) != 1 || limits.Tags[0] != "inbox" {
+		t.Errorf("Unexpected limits: %+v", limits)
+	}
+
+	if _, err := ParseManifest(`Plugin = { name = "Bad", scopes = {"clips.everything"} }`); err == nil {
+		t.Error("Expected an unknown scope to be refused")
+	}
+}
+
+func TestScopeLimits_AllowsContentType(t *testing.T) {
+	limits := ScopeLimits{ContentTypes: []string{"image/*", "text/plain"}}
+	for contentType, want := range map[string]bool{
+		"image/png":     true,
+		"text/plain":    true,
+		"text/html":     false,
+		"imagery/other": false,
+	} {
+		if got := limits.allowsContentType(contentType); got != want {
+			t.Errorf("allowsContentType(%s) = %v, want %v", contentType, got, want)
+		}
+	}
+	if !(ScopeLimits{}).allowsContentType("application/pdf") {
+		t.Error("Expected no limits to allow every content type")
+	}
+}
+
+func TestClipsAPI_RequiresScopes(t *testing.T) {
+	m := newTestManager(t)
+
+	clip, _ := m.store.CreateClip(store.User, store.NewClip{ContentType: "text/plain", Data: []byte("a")})
+
+	source := fmt.Sprintf(`
+Plugin = {
+    name = "%%s",
+    scopes = { %%s },
+    ui = { card_actions = { { id = "run", label = "Run" } } },
+}
+function on_ui_action(action, clip_ids)
+    local list, list_err = clips.list()
+    local created, create_err = clips.create({ data = "b", content_type = "text/plain" })
+    local deleted, delete_err = clips.delete(%d)
+    storage.set("result", tostring(list and #list or list_err) .. "|" .. tostring(created and "created" or create_err) .. "|" .. tostring(deleted or delete_err))
+    return { success = true }
+end
+`, clip.ID)
+	run := func(p *Plugin) string {
+		t.Helper()
+		m.db.Exec("DELETE FROM plugin_storage WHERE plugin_id = ?", p.ID)
+		if _, err := m.ExecuteUIAction(p.ID, "run", []int64{clip.ID}, nil); err != nil {
+			t.Fatalf("ExecuteUIAction failed: %v", err)
+		}
+		return waitForStorage(t, m, p.ID, "result")
+	}
+
+	none := importTestPlugin(t, m, "none.lua", fmt.Sprintf(source, "None", ""))
+	want := "plugin did not declare the clips.read scope|plugin did not declare the clips.write scope|plugin did not declare the clips.delete scope"
+	if got := run(none); got != want {
+		t.Errorf("Expected every call to be refused, got %q", got)
+	}
+
+	reader := importTestPlugin(t, m, "reader.lua", fmt.Sprintf(source, "Reader", `"clips.read"`))
+	want = "1|plugin did not declare the clips.write scope|plugin did not declare the clips.delete scope"
+	if got := run(reader); got != want {
+		t.Errorf("Expected only reading to be allowed, got %q", got)
+	}
+
+	// Revoking takes effect without reloading the plugin
+	if err := m.SetScopeGranted(reader.ID, ScopeClipsRead, false); err != nil {
+		t.Fatalf("SetScopeGranted failed: %v", err)
+	}
+	want = "clips.read scope was not granted|plugin did not declare the clips.write scope|plugin did not declare the clips.delete scope"
+	if got := run(reader); got != want {
+		t.Errorf("Expected reading to be refused once revoked, got %q", got)
+	}
+	if err := m.SetScopeGranted(reader.ID, ScopeClipsWrite, true); err == nil {
+		t.Error("Expected an undeclared scope to be refused")
+	}
+	if err := m.SetScopeGranted(reader.ID, ScopeClipsRead, true); err != nil {
+		t.Fatalf("SetScopeGranted failed: %v", err)
+	}
+
+	all := importTestPlugin(t, m, "all.lua", fmt.Sprintf(source, "All", `"clips.read", "clips.write", "clips.delete"`))
+	if got := run(all); got != "1|created|true" {
+		t.Errorf("Expected every call to succeed, got %q", got)
+	}
+
+	entries, _ := m.store.QueryAudit(store.AuditFilter{PluginID: all.ID, Action: store.AuditPermissionGranted})
+	if len(entries) != 3 {
+		t.Errorf("Expected the scopes granted on import to be audited, got %+v", entries)
+	}
+}
+
+func TestClipsAPI_ScopeLimits(t *testing.T) {
+	m := newTestManager(t)
+
+	image, _ := m.store.CreateClip(store.User, store.NewClip{ContentType: "image/png", Data: []byte("png")})
+	text, _ := m.store.CreateClip(store.User, store.NewClip{ContentType: "text/plain", Data: []byte("text")})
+
+	p := importTestPlugin(t, m, "images.lua", fmt.Sprintf(`
+Plugin = {
+    name = "Images",
+    scopes = {"clips.read", "clips.write"},
+    scope_limits = { content_types = {"image/*"} },
+}
+local list = clips.list()
+storage.set("list", #list .. ":" .. list[1].id)
+storage.set("get", tostring(clips.get(%d) ~= nil) .. ":" .. tostring(clips.get(%d) == nil))
+local _, pin_err = clips.pin(%d)
+storage.set("pin", tostring(pin_err))
+local _, create_err = clips.create({ data = "x", content_type = "text/plain" })
+storage.set("create", tostring(create_err))
+local _, update_err = clips.update(%d, { content_type = "text/plain" })
+storage.set("update", tostring(update_err))
+`, image.ID, text.ID, text.ID, image.ID))
+
+	if got := waitForStorage(t, m, p.ID, "list"); got != fmt.Sprintf("1:%d", image.ID) {
+		t.Errorf("Expected only the image to be listed, got %s", got)
+	}
+	if got := waitForStorage(t, m, p.ID, "get"); got != "true:true" {
+		t.Errorf("Expected the text clip to look missing, got %s", got)
+	}
+	if got := waitForStorage(t, m, p.ID, "pin"); got != fmt.Sprintf("clip %d is outside the plugin's scope", text.ID) {
+		t.Errorf("Expected pinning the text clip to be refused, got %s", got)
+	}
+	if got := waitForStorage(t, m, p.ID, "create"); got != "content type text/plain is outside the plugin's scope" {
+		t.Errorf("Expected creating a text clip to be refused, got %s", got)
+	}
+	if got := waitForStorage(t, m, p.ID, "update"); got != "content type text/plain is outside the plugin's scope" {
+		t.Errorf("Expected changing the image to text to be refused, got %s", got)
+	}
+}
+
+func TestTagsAPI_RequiresScope(t *testing.T) {
+	m := newTestManager(t)
+
+	inbox, _ := m.store.CreateClip(store.User, store.NewClip{Data: []byte("a")})
+	other, _ := m.store.CreateClip(store.User, store.NewClip{Data: []byte("b")})
+	inboxTag, _ := m.store.CreateTag(store.User, "inbox")
+	m.store.CreateTag(store.User, "work")
+	m.store.AddTagToClip(store.User, inbox.ID, inboxTag.ID)
+
+	reader := importTestPlugin(t, m, "reader.lua", `
+Plugin = { name = "Reader" }
+local _, err = tags.create("new")
+storage.set("result", #tags.list() .. ":" .. tostring(err))
+`)
+	if got := waitForStorage(t, m, reader.ID, "result"); got != "2:plugin did not declare the tags.write scope" {
+		t.Errorf("Expected reading to be allowed and writing refused, got %s", got)
+	}
+
+	p := importTestPlugin(t, m, "tagger.lua", fmt.Sprintf(`
+Plugin = {
+    name = "Tagger",
+    scopes = {"tags.write"},
+    scope_limits = { tags = {"inbox", "done"} },
+}
+local done = tags.create("done")
+local _, create_err = tags.create("work2")
+storage.set("create", tostring(done ~= nil) .. ":" .. tostring(create_err))
+local ok = tags.add_to_clip(done.id, %d)
+local _, clip_err = tags.add_to_clip(done.id, %d)
+storage.set("add", tostring(ok) .. ":" .. tostring(clip_err))
+`, inbox.ID, other.ID))
+
+	if got := waitForStorage(t, m, p.ID, "create"); got != "true:tag work2 is outside the plugin's scope" {
+		t.Errorf("Expected only listed tags to be created, got %s", got)
+	}
+	if got := waitForStorage(t, m, p.ID, "add"); got != fmt.Sprintf("true:clip %d is outside the plugin's scope", other.ID) {
+		t.Errorf("Expected only clips in scope to be tagged, got %s", got)
+	}
+}
+
+// makeLegacyPlugin turns an imported plugin into one installed before scopes
+// existed, without grants
+func makeLegacyPlugin(t *testing.T, m *Manager, pluginID int64) {
+	t.Helper()
+
+	m.UnloadPlugin(pluginID)
+	if _, err := m.db.Exec("DELETE FROM plugin_permissions WHERE plugin_id = ?", pluginID); err != nil {
+		t.Fatalf("failed to delete grants: %v", err)
+	}
+	if _, err := m.db.Exec("UPDATE plugins SET scopes_migrated = NULL WHERE id = ?", pluginID); err != nil {
+		t.Fatalf("failed to reset plugin: %v", err)
+	}
+}
+
+func TestScopes_LegacyPluginsAndUpgrades(t *testing.T) {
+	m := newTestManager(t)
+	p := importTestPlugin(t, m, "legacy.lua", `Plugin = { name = "Legacy", version = "1.0.0", scopes = {"clips.read"} }`)
+
+	// A plugin installed before scopes is granted its declared scopes once
+	makeLegacyPlugin(t, m, p.ID)
+	if err := m.EnablePlugin(p.ID); err != nil {
+		t.Fatalf("EnablePlugin failed: %v", err)
+	}
+	if !hasGrant(m.db, p.ID, ScopeClipsRead) {
+		t.Error("Expected the legacy plugin to be granted clips.read")
+	}
+	if err := m.SetScopeGranted(p.ID, ScopeClipsRead, false); err != nil {
+		t.Fatalf("SetScopeGranted failed: %v", err)
+	}
+	m.UnloadPlugin(p.ID)
+	if err := m.EnablePlugin(p.ID); err != nil {
+		t.Fatalf("EnablePlugin failed: %v", err)
+	}
+	if hasGrant(m.db, p.ID, ScopeClipsRead) {
+		t.Error("Expected a revoked scope to stay revoked after the migration")
+	}
+	m.SetScopeGranted(p.ID, ScopeClipsRead, true)
+
+	// Scopes added by an upgrade wait for the user
+	upgraded, err := m.UpgradePlugin(p.ID, writeTestFile(t, t.TempDir(), "legacy.lua",
+		`Plugin = { name = "Legacy", version = "1.1.0", scopes = {"clips.read", "clips.delete"} }`), false)
+	if err != nil {
+		t.Fatalf("UpgradePlugin failed: %v", err)
+	}
+	if pending := m.PendingScopes(p.ID, upgraded.Manifest); len(pending) != 1 || pending[0] != ScopeClipsDelete {
+		t.Errorf("Expected clips.delete to be pending, got %v", pending)
+	}
+	if err := m.SetScopeGranted(p.ID, ScopeClipsDelete, true); err != nil {
+		t.Fatalf("SetScopeGranted failed: %v", err)
+	}
+	if pending := m.PendingScopes(p.ID, upgraded.Manifest); len(pending) != 0 {
+		t.Errorf("Expected no pending scopes, got %v", pending)
+	}
+
+	// A legacy plugin upgraded before it was loaded keeps the scopes of the
+	// installed version only
+	makeLegacyPlugin(t, m, p.ID)
+	upgraded, err = m.UpgradePlugin(p.ID, writeTestFile(t, t.TempDir(), "legacy.lua",
+		`Plugin = { name = "Legacy", version = "1.2.0", scopes = {"clips.read", "clips.delete", "tags.write"} }`), false)
+	if err != nil {
+		t.Fatalf("UpgradePlugin failed: %v", err)
+	}
+	if pending := m.PendingScopes(p.ID, upgraded.Manifest); len(pending) != 1 || pending[0] != ScopeTagsWrite {
+		t.Errorf("Expected only tags.write to be pending, got %v", pending)
+	}
+}
+
+func TestScopeLimits_NestedTags(t *testing.T) {
+	m := newTestManager(t)
+
+	child, _ := m.store.CreateClip(store.User, store.NewClip{ContentType: "text/plain", Data: []byte("child")})
+	similar, _ := m.store.CreateClip(store.User, store.NewClip{ContentType: "text/plain", Data: []byte("similar")})
+	workAPI, _ := m.store.CreateTag(store.User, "Work/api")
+	workshop, _ := m.store.CreateTag(store.User, "workshop")
+	m.store.AddTagToClip(store.User, child.ID, workAPI.ID)
+	m.store.AddTagToClip(store.User, similar.ID, workshop.ID)
+
+	// A tag limit covers the tag's children, like tag queries do
+	p := importTestPlugin(t, m, "work.lua", fmt.Sprintf(`
+Plugin = {
+    name = "Work",
+    scopes = {"clips.read", "tags.write"},
+    scope_limits = { tags = {"work"} },
+}
+local list = clips.list()
+storage.set("list", #list .. ":" .. list[1].id)
+storage.set("get", tostring(clips.get(%d) ~= nil) .. ":" .. tostring(clips.get(%d) == nil))
+local nested = tags.create("work/new")
+local _, similar_err = tags.create("workshop2")
+storage.set("create", tostring(nested ~= nil) .. ":" .. tostring(similar_err))
+`, child.ID, similar.ID))
+
+	if got := waitForStorage(t, m, p.ID, "list"); got != fmt.Sprintf("1:%d", child.ID) {
+		t.Errorf("Expected only the clip tagged Work/api to be listed, got %s", got)
+	}
+	if got := waitForStorage(t, m, p.ID, "get"); got != "true:true" {
+		t.Errorf("Expected the workshop clip to look missing, got %s", got)
+	}
+	if got := waitForStorage(t, m, p.ID, "create"); got != "true:tag workshop2 is outside the plugin's scope" {
+		t.Errorf("Expected only work and its children to be created, got %s", got)
+	}
+}
+
+func TestCollectionsAPI_RequiresScopes(t *testing.T) {
+	m := newTestManager(t)
+
+	image, _ := m.store.CreateClip(store.User, store.NewClip{ContentType: "image/png", Data: []byte("png")})
+	text, _ := m.store.CreateClip(store.User, store.NewClip{ContentType: "text/plain", Data: []byte("text")})
+	board, _ := m.store.CreateCollection(store.User, "Board", "")
+	m.store.AddToCollection(store.User, board.ID, []int64{text.ID, image.ID})
+
+	// Without clip scopes, neither the clips of collections nor changing
+	// them are allowed
+	source := fmt.Sprintf(`
+local results = {}
+local _, list_err = collections.list()
+local _, get_err = collections.get(%d)
+local _, for_clip_err = collections.get_for_clip(%d)
+local _, add_err = collections.add_clips(%d, { %d })
+local _, remove_err = collections.remove_clips(%d, { %d })
+local _, reorder_err = collections.reorder(%d, { %d })
+storage.set("result", table.concat({ list_err, get_err, for_clip_err, add_err, remove_err, reorder_err }, "|"))
+`, board.ID, image.ID, board.ID, image.ID, board.ID, image.ID, board.ID, image.ID)
+	none := importTestPlugin(t, m, "none.lua", `Plugin = { name = "None" }`+source)
+	read := "plugin did not declare the clips.read scope"
+	write := "plugin did not declare the clips.write scope"
+	want := strings.Join([]string{read, read, read, write, write, write}, "|")
+	if got := waitForStorage(t, m, none.ID, "result"); got != want {
+		t.Errorf("Expected every call to be refused, got %q", got)
+	}
+
+	// Clips outside the limits are left out and can't be changed
+	p := importTestPlugin(t, m, "images.lua", fmt.Sprintf(`
+Plugin = {
+    name = "Images",
+    scopes = {"clips.read", "clips.write"},
+    scope_limits = { content_types = {"image/*"} },
+}
+local board = collections.get(%d)
+local in_text = #collections.get_for_clip(%d)
+local in_image = #collections.get_for_clip(%d)
+storage.set("get", #board.clip_ids .. ":" .. board.clip_ids[1] .. ":" .. board.count .. ":" .. in_text .. ":" .. in_image)
+local _, add_err = collections.add_clips(%d, { %d })
+local _, remove_err = collections.remove_clips(%d, { %d })
+local _, reorder_err = collections.reorder(%d, { %d, %d })
+storage.set("write", table.concat({ add_err, remove_err, reorder_err }, "|"))
+`, board.ID, text.ID, image.ID, board.ID, text.ID, board.ID, text.ID, board.ID, image.ID, text.ID))
+
+	if got := waitForStorage(t, m, p.ID, "get"); got != fmt.Sprintf("1:%d:1:0:1", image.ID) {
+		t.Errorf("Expected only the image to be visible, got %s", got)
+	}
+	outside := fmt.Sprintf("clip %d is outside the plugin's scope", text.ID)
+	if got := waitForStorage(t, m, p.ID, "write"); got != strings.Join([]string{outside, outside, outside}, "|") {
+		t.Errorf("Expected changes to the text clip to be refused, got %s", got)
+	}
+	if ids, _ := m.store.CollectionClips(board.ID); len(ids) != 2 || ids[0] != text.ID {
+		t.Errorf("Expected the collection to be unchanged, got %v", ids)
+	}
+}
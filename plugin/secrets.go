@@ -0,0 +1,429 @@
+package plugin
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+
+	"go-clipboard/store"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// SecretsKeyFile is the name of the key file that encrypts plugin secrets
+// while clips aren't encrypted. It is kept next to the plugins directory
+// rather than in it, so backups, which copy the plugins directory, never
+// contain it. Once clips are encrypted, secrets are encrypted with a key
+// derived from the data key instead and the key file is removed.
+const SecretsKeyFile = "plugin-secrets.key"
+
+// secretsKeyLabel tells the key of plugin secrets apart from other keys
+// derived from the same secret
+const secretsKeyLabel = "mahpastes plugin secrets"
+
+// ErrSecretUnreadable is returned when a secret can't be decrypted, for
+// example because its key file was replaced
+var ErrSecretUnreadable = errors.New("secret can't be decrypted")
+
+// SecretStore keeps the values of password settings encrypted in the
+// plugin_secrets table
+type SecretStore struct {
+	db      *sql.DB
+	store   *store.Service
+	keyFile string
+
+	mu       sync.Mutex
+	fileAEAD cipher.AEAD // cipher of the key file, once read
+}
+
+// NewSecretStore creates a secret store. Values are encrypted with a key
+// derived from the data key of st while its clips are encrypted, and with the
+// key in keyFile otherwise. The key file is created the first time a secret
+// is stored.
+func NewSecretStore(db *sql.DB, st *store.Service, keyFile string) *SecretStore {
+	return &SecretStore{db: db, store: st, keyFile: keyFile}
+}
+
+// cipher returns the AEAD secrets are currently encrypted with, creating the
+// key file if create is set and it is needed but doesn't exist yet. Fails
+// with store.ErrLocked while encrypted clips are locked.
+func (s *SecretStore) cipher(create bool) (cipher.AEAD, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.currentCipher(create)
+}
+
+// currentCipher is cipher for callers that hold s.mu
+func (s *SecretStore) currentCipher(create bool) (cipher.AEAD, error) {
+	key, err := s.store.DeriveKey(secretsKeyLabel)
+	if errors.Is(err, store.ErrEncryptionDisabled) {
+		return s.fileCipher(create)
+	}
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newSecretsAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Secrets saved before clips were encrypted move to the derived key
+	if err := s.moveFromKeyFile(aead); err != nil {
+		return nil, err
+	}
+	return aead, nil
+}
+
+// fileCipher returns the AEAD for the key file. Callers hold s.mu.
+func (s *SecretStore) fileCipher(create bool) (cipher.AEAD, error) {
+	if s.fileAEAD != nil {
+		return s.fileAEAD, nil
+	}
+
+	secret, err := os.ReadFile(s.keyFile)
+	if os.IsNotExist(err) && create {
+		if err := store.GenerateKeyFile(s.keyFile); err != nil {
+			return nil, err
+		}
+		secret, err = os.ReadFile(s.keyFile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets key file: %w", err)
+	}
+	if len(secret) < store.KeyFileSize {
+		return nil, fmt.Errorf("secrets key file is too short")
+	}
+
+	key, err := hkdf.Key(sha256.New, secret, nil, secretsKeyLabel, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	aead, err := newSecretsAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	s.fileAEAD = aead
+	return aead, nil
+}
+
+func newSecretsAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return aead, nil
+}
+
+// moveFromKeyFile encrypts the secrets that the key file can decrypt with
+// aead instead, then removes the key file. Callers hold s.mu.
+func (s *SecretStore) moveFromKeyFile(aead cipher.AEAD) error {
+	if _, err := os.Stat(s.keyFile); os.IsNotExist(err) {
+		return nil
+	}
+	file, err := s.fileCipher(false)
+	if err != nil {
+		return err
+	}
+
+	values, err := s.readAll(file)
+	if err != nil {
+		return err
+	}
+	if err := s.writeAll(aead, values); err != nil {
+		return err
+	}
+	if err := os.Remove(s.keyFile); err != nil {
+		return fmt.Errorf("failed to remove secrets key file: %w", err)
+	}
+	s.fileAEAD = nil
+	return nil
+}
+
+// secretValue is a decrypted row of plugin_secrets
+type secretValue struct {
+	pluginID int64
+	key      string
+	value    []byte
+}
+
+// readAll decrypts every secret aead can decrypt. The others are left out.
+func (s *SecretStore) readAll(aead cipher.AEAD) ([]secretValue, error) {
+	rows, err := s.db.Query("SELECT plugin_id, key, value FROM plugin_secrets")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query secrets: %w", err)
+	}
+	defer rows.Close()
+
+	var values []secretValue
+	for rows.Next() {
+		var v secretValue
+		var sealed []byte
+		if err := rows.Scan(&v.pluginID, &v.key, &sealed); err != nil {
+			return nil, fmt.Errorf("failed to scan secret: %w", err)
+		}
+		if v.value, err = openSecret(aead, sealed, v.pluginID, v.key); err != nil {
+			log.Printf("Plugin %d: secret %s can't be decrypted, leaving it as is", v.pluginID, v.key)
+			continue
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
+
+// writeAll encrypts secrets with aead in one transaction
+func (s *SecretStore) writeAll(aead cipher.AEAD, values []secretValue) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, v := range values {
+		sealed, err := sealSecret(aead, v.value, v.pluginID, v.key)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE plugin_secrets SET value = ? WHERE plugin_id = ? AND key = ?",
+			sealed, v.pluginID, v.key); err != nil {
+			return fmt.Errorf("failed to update secret: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// Reencrypt runs change, which turns the encryption of clips on or off, and
+// encrypts the secrets with the key for the new state. Turning encryption off
+// needs this, since secrets can't be read once the data key is gone.
+func (s *SecretStore) Reencrypt(change func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var values []secretValue
+	var count int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM plugin_secrets").Scan(&count); err != nil {
+		return fmt.Errorf("failed to count secrets: %w", err)
+	}
+	if count > 0 {
+		aead, err := s.currentCipher(false)
+		if err != nil {
+			return err
+		}
+		if values, err = s.readAll(aead); err != nil {
+			return err
+		}
+	}
+
+	if err := change(); err != nil {
+		return err
+	}
+	if count == 0 {
+		return nil
+	}
+
+	aead, err := s.currentCipher(true)
+	if err == nil {
+		err = s.writeAll(aead, values)
+	}
+	if err != nil {
+		return fmt.Errorf("encryption changed, but plugin secrets weren't re-encrypted: %w", err)
+	}
+	return nil
+}
+
+// additionalData binds a sealed value to its plugin and key, so values can't
+// be swapped between rows
+func additionalData(pluginID int64, key string) []byte {
+	return []byte(strconv.FormatInt(pluginID, 10) + "\x00" + key)
+}
+
+// sealSecret encrypts a secret as nonce || ciphertext
+func sealSecret(aead cipher.AEAD, value []byte, pluginID int64, key string) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(value)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, value, additionalData(pluginID, key)), nil
+}
+
+// openSecret decrypts a secret sealed by sealSecret
+func openSecret(aead cipher.AEAD, sealed []byte, pluginID int64, key string) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrSecretUnreadable
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, additionalData(pluginID, key))
+	if err != nil {
+		return nil, ErrSecretUnreadable
+	}
+	return plain, nil
+}
+
+// Get returns a plugin's secret. ok is false when the secret isn't set.
+func (s *SecretStore) Get(pluginID int64, key string) (value string, ok bool, err error) {
+	var sealed []byte
+	err = s.db.QueryRow(
+		"SELECT value FROM plugin_secrets WHERE plugin_id = ? AND key = ?",
+		pluginID, key,
+	).Scan(&sealed)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to query secret: %w", err)
+	}
+
+	aead, err := s.cipher(false)
+	if err != nil {
+		return "", false, fmt.Errorf("%w: %v", ErrSecretUnreadable, err)
+	}
+	// Getting the cipher may have moved the secret away from the key file
+	err = s.db.QueryRow(
+		"SELECT value FROM plugin_secrets WHERE plugin_id = ? AND key = ?",
+		pluginID, key,
+	).Scan(&sealed)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to query secret: %w", err)
+	}
+	plain, err := openSecret(aead, sealed, pluginID, key)
+	if err != nil {
+		return "", false, err
+	}
+	return string(plain), true, nil
+}
+
+// Set stores a plugin's secret. An empty value deletes it.
+func (s *SecretStore) Set(pluginID int64, key, value string) error {
+	if value == "" {
+		_, err := s.db.Exec("DELETE FROM plugin_secrets WHERE plugin_id = ? AND key = ?", pluginID, key)
+		if err != nil {
+			return fmt.Errorf("failed to delete secret: %w", err)
+		}
+		return nil
+	}
+
+	aead, err := s.cipher(true)
+	if err != nil {
+		return err
+	}
+	sealed, err := sealSecret(aead, []byte(value), pluginID, key)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`
+		INSERT INTO plugin_secrets (plugin_id, key, value, updated_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(plugin_id, key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at
+	`, pluginID, key, sealed)
+	if err != nil {
+		return fmt.Errorf("failed to store secret: %w", err)
+	}
+	return nil
+}
+
+// Keys returns the keys of a plugin's stored secrets
+func (s *SecretStore) Keys(pluginID int64) ([]string, error) {
+	rows, err := s.db.Query("SELECT key FROM plugin_secrets WHERE plugin_id = ? ORDER BY key", pluginID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query secrets: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("failed to scan secret: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// MigrateStorage moves the given keys of a plugin out of plugin_storage,
+// where older versions kept password settings in plaintext
+func (s *SecretStore) MigrateStorage(pluginID int64, keys []string) error {
+	for _, key := range keys {
+		var value []byte
+		err := s.db.QueryRow(
+			"SELECT value FROM plugin_storage WHERE plugin_id = ? AND key = ?",
+			pluginID, key,
+		).Scan(&value)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to query storage: %w", err)
+		}
+
+		if err := s.Set(pluginID, key, string(value)); err != nil {
+			return err
+		}
+		if _, err := s.db.Exec(
+			"DELETE FROM plugin_storage WHERE plugin_id = ? AND key = ?",
+			pluginID, key,
+		); err != nil {
+			return fmt.Errorf("failed to delete plaintext value: %w", err)
+		}
+	}
+	return nil
+}
+
+// SecretsAPI gives a plugin read access to its own secrets
+type SecretsAPI struct {
+	secrets  *SecretStore
+	pluginID int64
+}
+
+// NewSecretsAPI creates a new secrets API instance
+func NewSecretsAPI(secrets *SecretStore, pluginID int64) *SecretsAPI {
+	return &SecretsAPI{
+		secrets:  secrets,
+		pluginID: pluginID,
+	}
+}
+
+// Register adds the secrets module to the Lua state
+func (s *SecretsAPI) Register(L *lua.LState) {
+	secretsMod := L.NewTable()
+
+	secretsMod.RawSetString("get", L.NewFunction(s.get))
+
+	L.SetGlobal("secrets", secretsMod)
+}
+
+func (s *SecretsAPI) get(L *lua.LState) int {
+	key := L.CheckString(1)
+
+	value, ok, err := s.secrets.Get(s.pluginID, key)
+	if err != nil {
+		log.Printf("secrets.get: failed to read %s: %v", key, err)
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	if !ok {
+		L.Push(lua.LNil)
+		return 1
+	}
+
+	L.Push(lua.LString(value))
+	return 1
+}
@@ -0,0 +1,147 @@
+package plugin
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go-clipboard/store"
+)
+
+func TestSecretStore(t *testing.T) {
+	m := newTestManager(t)
+	secrets := m.Secrets()
+	first := importTestPlugin(t, m, "first.lua", `Plugin = { name = "First" }`)
+	second := importTestPlugin(t, m, "second.lua", `Plugin = { name = "Second" }`)
+
+	if err := secrets.Set(first.ID, "api_key", "sk-secret"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(m.pluginsDir), SecretsKeyFile)); err != nil {
+		t.Errorf("Expected the key file next to the plugins directory: %v", err)
+	}
+
+	var sealed []byte
+	m.db.QueryRow("SELECT value FROM plugin_secrets WHERE plugin_id = ? AND key = 'api_key'", first.ID).Scan(&sealed)
+	if len(sealed) == 0 || bytes.Contains(sealed, []byte("sk-secret")) {
+		t.Errorf("Expected the value to be encrypted, got %q", sealed)
+	}
+
+	if value, ok, err := secrets.Get(first.ID, "api_key"); err != nil || !ok || value != "sk-secret" {
+		t.Errorf("Expected sk-secret, got %q, %v, %v", value, ok, err)
+	}
+	if _, ok, err := secrets.Get(second.ID, "api_key"); err != nil || ok {
+		t.Errorf("Expected no secret for another plugin, got %v, %v", ok, err)
+	}
+
+	// A value copied to another plugin can't be read
+	m.db.Exec("INSERT INTO plugin_secrets (plugin_id, key, value) VALUES (?, 'api_key', ?)", second.ID, sealed)
+	if _, _, err := secrets.Get(second.ID, "api_key"); !errors.Is(err, ErrSecretUnreadable) {
+		t.Errorf("Expected ErrSecretUnreadable, got %v", err)
+	}
+
+	if err := secrets.Set(first.ID, "api_key", ""); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if keys, _ := secrets.Keys(first.ID); len(keys) != 0 {
+		t.Errorf("Expected an empty value to delete the secret, got %v", keys)
+	}
+}
+
+func TestSecretStore_FollowsEncryption(t *testing.T) {
+	m := newTestManager(t)
+	secrets := m.Secrets()
+	p := importTestPlugin(t, m, "client.lua", `Plugin = { name = "Client" }`)
+	keyFile := filepath.Join(filepath.Dir(m.pluginsDir), SecretsKeyFile)
+	master := store.MasterKey{KeyFile: filepath.Join(t.TempDir(), "mahpastes.key")}
+	store.GenerateKeyFile(master.KeyFile)
+	read := func() string {
+		t.Helper()
+		value, _, err := secrets.Get(p.ID, "api_key")
+		if err != nil {
+			return err.Error()
+		}
+		return value
+	}
+
+	// Secrets saved before clips were encrypted move to the data key, and
+	// the key file goes away
+	secrets.Set(p.ID, "api_key", "sk-secret")
+	if err := m.store.EnableEncryption(master); err != nil {
+		t.Fatalf("EnableEncryption failed: %v", err)
+	}
+	if got := read(); got != "sk-secret" {
+		t.Errorf("Expected the secret to be readable once encrypted, got %q", got)
+	}
+	if _, err := os.Stat(keyFile); !os.IsNotExist(err) {
+		t.Errorf("Expected the key file to be removed, got %v", err)
+	}
+
+	// While locked, secrets can't be read or saved
+	m.store.Lock()
+	if _, _, err := secrets.Get(p.ID, "api_key"); !errors.Is(err, ErrSecretUnreadable) {
+		t.Errorf("Expected ErrSecretUnreadable while locked, got %v", err)
+	}
+	if err := secrets.Set(p.ID, "token", "abc"); !errors.Is(err, store.ErrLocked) {
+		t.Errorf("Expected ErrLocked while locked, got %v", err)
+	}
+	m.store.Unlock(master)
+
+	// Turning encryption off moves them back to a new key file
+	if err := secrets.Reencrypt(func() error { return m.store.DisableEncryption(master) }); err != nil {
+		t.Fatalf("Reencrypt failed: %v", err)
+	}
+	if _, err := os.Stat(keyFile); err != nil {
+		t.Errorf("Expected a new key file: %v", err)
+	}
+	if got := read(); got != "sk-secret" {
+		t.Errorf("Expected the secret to be readable once decrypted, got %q", got)
+	}
+
+	// A failed change leaves them as they were
+	if err := secrets.Reencrypt(func() error { return store.ErrWrongKey }); !errors.Is(err, store.ErrWrongKey) {
+		t.Errorf("Expected the change's error, got %v", err)
+	}
+	if got := read(); got != "sk-secret" {
+		t.Errorf("Expected the secret to survive a failed change, got %q", got)
+	}
+}
+
+func TestSecretsAPI(t *testing.T) {
+	m := newTestManager(t)
+
+	p := importTestPlugin(t, m, "secrets.lua", `
+Plugin = {
+    name = "Secrets",
+    settings = { {key = "api_key", type = "password", label = "API Key"} },
+    ui = { lightbox_buttons = { { id = "read", label = "Read" } } },
+}
+function on_ui_action(action, clip_ids)
+    local ok, err = storage.set("api_key", "overwritten")
+    storage.set("result", tostring(secrets.get("api_key")) .. ":" .. tostring(storage.get("api_key")) .. ":" .. tostring(err))
+    return { success = true }
+end
+`)
+
+	// Values saved in plaintext by older versions are moved when the plugin loads
+	m.db.Exec("INSERT INTO plugin_storage (plugin_id, key, value) VALUES (?, 'api_key', 'sk-old')", p.ID)
+	if err := m.DisablePlugin(p.ID); err != nil {
+		t.Fatalf("DisablePlugin failed: %v", err)
+	}
+	if err := m.EnablePlugin(p.ID); err != nil {
+		t.Fatalf("EnablePlugin failed: %v", err)
+	}
+
+	if _, err := m.ExecuteUIAction(p.ID, "read", nil, nil); err != nil {
+		t.Fatalf("ExecuteUIAction failed: %v", err)
+	}
+	want := "sk-old:nil:api_key is a password setting, read it with secrets.get"
+	if got := waitForStorage(t, m, p.ID, "result"); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if keys, _ := m.PasswordKeys(p.ID); len(keys) != 1 || keys[0] != "api_key" {
+		t.Errorf("Expected password keys [api_key], got %v", keys)
+	}
+}
@@ -0,0 +1,238 @@
+package plugin
+
+import (
+	"archive/zip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// SignatureFile is the detached signature inside a zipped package
+	SignatureFile = "plugin.sig"
+	// SignatureExt is appended to single-file plugins for their detached signature
+	SignatureExt = ".sig"
+
+	// Settings keys for the signing trust policy
+	settingTrustedKeys   = "plugin_trusted_keys"
+	settingRequireSigned = "plugin_require_signed"
+)
+
+// Signature states reported for a plugin
+const (
+	SignatureUnsigned  = "unsigned"  // no signature present
+	SignatureSigned    = "signed"    // valid signature by a trusted key
+	SignatureUntrusted = "untrusted" // signature present but no trusted key verifies it
+	SignatureInvalid   = "invalid"   // malformed signature file
+)
+
+// TrustedKey is an ed25519 public key allowed to sign plugins
+type TrustedKey struct {
+	Name      string `json:"name"`
+	PublicKey string `json:"public_key"` // base64-encoded raw ed25519 public key
+}
+
+// TrustPolicy holds the signing configuration stored in settings
+type TrustPolicy struct {
+	Keys          []TrustedKey
+	RequireSigned bool
+}
+
+// SignatureStatus describes the outcome of verifying a plugin package
+type SignatureStatus struct {
+	State    string `json:"state"`
+	SignedBy string `json:"signed_by,omitempty"`
+	Key      string `json:"-"` // public key that verified the signature
+}
+
+// String returns a human readable status like "unsigned" or "signed by Alice"
+func (s SignatureStatus) String() string {
+	if s.State == SignatureSigned {
+		return "signed by " + s.SignedBy
+	}
+	return s.State
+}
+
+// PackageDigest computes a stable digest over all package files except the signature
+func PackageDigest(pkg *Package) []byte {
+	h := sha256.New()
+	for _, name := range pkg.Files() {
+		sum := sha256.Sum256(pkg.files[name])
+		fmt.Fprintf(h, "%s\n%s\n", name, hex.EncodeToString(sum[:]))
+	}
+	return h.Sum(nil)
+}
+
+// SignPackage signs a package digest and returns the base64 signature
+func SignPackage(pkg *Package, key ed25519.PrivateKey) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(key, PackageDigest(pkg)))
+}
+
+// VerifyPackage checks the package signature against the trusted keys
+func VerifyPackage(pkg *Package, keys []TrustedKey) SignatureStatus {
+	if len(pkg.signature) == 0 {
+		return SignatureStatus{State: SignatureUnsigned}
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(pkg.signature)))
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return SignatureStatus{State: SignatureInvalid}
+	}
+
+	digest := PackageDigest(pkg)
+	for _, key := range keys {
+		pub, err := decodePublicKey(key.PublicKey)
+		if err != nil {
+			continue
+		}
+		if ed25519.Verify(pub, digest, sig) {
+			return SignatureStatus{State: SignatureSigned, SignedBy: key.Name, Key: key.PublicKey}
+		}
+	}
+
+	return SignatureStatus{State: SignatureUntrusted}
+}
+
+// CheckTrust verifies a package and enforces the trust policy.
+// Untrusted or invalid signatures are always refused since they indicate
+// tampering or an unknown signer; unsigned plugins only when required.
+func CheckTrust(pkg *Package, policy TrustPolicy) (SignatureStatus, error) {
+	status := VerifyPackage(pkg, policy.Keys)
+
+	switch status.State {
+	case SignatureInvalid:
+		return status, fmt.Errorf("plugin signature is malformed")
+	case SignatureUntrusted:
+		return status, fmt.Errorf("plugin signature does not match any trusted key")
+	case SignatureUnsigned:
+		if policy.RequireSigned {
+			return status, fmt.Errorf("unsigned plugins are not allowed")
+		}
+	}
+
+	return status, nil
+}
+
+// decodePublicKey parses a base64-encoded ed25519 public key
+func decodePublicKey(encoded string) (ed25519.PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key encoding: %w", err)
+	}
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key size: %d bytes", len(raw))
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// ValidateTrustedKey checks a key before it is added to the trusted list
+func ValidateTrustedKey(key TrustedKey) error {
+	if strings.TrimSpace(key.Name) == "" {
+		return fmt.Errorf("key name cannot be empty")
+	}
+	_, err := decodePublicKey(key.PublicKey)
+	return err
+}
+
+// LoadTrustPolicy reads the trusted keys and signing requirement from settings
+func LoadTrustPolicy(db *sql.DB) TrustPolicy {
+	var policy TrustPolicy
+
+	var keysJSON string
+	err := db.QueryRow("SELECT value FROM settings WHERE key = ?", settingTrustedKeys).Scan(&keysJSON)
+	if err == nil && keysJSON != "" {
+		_ = json.Unmarshal([]byte(keysJSON), &policy.Keys)
+	}
+
+	var required string
+	if err := db.QueryRow("SELECT value FROM settings WHERE key = ?", settingRequireSigned).Scan(&required); err == nil {
+		policy.RequireSigned = required == "true"
+	}
+
+	return policy
+}
+
+// SaveTrustedKeys stores the trusted key list in settings
+func SaveTrustedKeys(db *sql.DB, keys []TrustedKey) error {
+	if keys == nil {
+		keys = []TrustedKey{}
+	}
+	data, err := json.Marshal(keys)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`
+		INSERT INTO settings (key, value) VALUES (?, ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value
+	`, settingTrustedKeys, string(data))
+	return err
+}
+
+// SaveRequireSigned stores whether unsigned plugins are refused
+func SaveRequireSigned(db *sql.DB, required bool) error {
+	value := "false"
+	if required {
+		value = "true"
+	}
+	_, err := db.Exec(`
+		INSERT INTO settings (key, value) VALUES (?, ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value
+	`, settingRequireSigned, value)
+	return err
+}
+
+// SignPackageFile signs a plugin on disk. Zipped packages get plugin.sig
+// added to the archive; single .lua files get a detached <file>.sig.
+func SignPackageFile(path string, key ed25519.PrivateKey) error {
+	pkg, err := OpenPackage(path)
+	if err != nil {
+		return err
+	}
+	sig := SignPackage(pkg, key)
+
+	if !strings.EqualFold(filepath.Ext(path), PackageExt) {
+		return os.WriteFile(path+SignatureExt, []byte(sig+"\n"), 0644)
+	}
+
+	tmpPath := path + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath)
+
+	w := zip.NewWriter(f)
+	for _, name := range pkg.Files() {
+		entry, err := w.Create(name)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		if _, err := entry.Write(pkg.files[name]); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	entry, err := w.Create(SignatureFile)
+	if err == nil {
+		_, err = entry.Write([]byte(sig + "\n"))
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write signed package: %w", err)
+	}
+
+	return os.Rename(tmpPath, path)
+}
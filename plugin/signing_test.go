@@ -0,0 +1,173 @@
+package plugin
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestKey generates a key pair and its trusted key entry
+func newTestKey(t *testing.T, name string) (ed25519.PrivateKey, TrustedKey) {
+	t.Helper()
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	return priv, TrustedKey{Name: name, PublicKey: base64.StdEncoding.EncodeToString(pub)}
+}
+
+func TestSignPackageFile_Zip(t *testing.T) {
+	priv, trusted := newTestKey(t, "Alice")
+	path := writeTestPackage(t, map[string]string{
+		"plugin.lua":   `Plugin = { name = "Signed" }`,
+		"lib/util.lua": `return {}`,
+	})
+
+	if err := SignPackageFile(path, priv); err != nil {
+		t.Fatalf("SignPackageFile failed: %v", err)
+	}
+
+	pkg, err := OpenPackage(path)
+	if err != nil {
+		t.Fatalf("OpenPackage failed: %v", err)
+	}
+	if len(pkg.Files()) != 2 {
+		t.Errorf("Expected signature to be excluded from files, got %v", pkg.Files())
+	}
+
+	status := VerifyPackage(pkg, []TrustedKey{trusted})
+	if status.State != SignatureSigned || status.SignedBy != "Alice" {
+		t.Errorf("Expected signed by Alice, got %+v", status)
+	}
+	if status.String() != "signed by Alice" {
+		t.Errorf("Expected 'signed by Alice', got '%s'", status.String())
+	}
+}
+
+func TestSignPackageFile_LuaTampered(t *testing.T) {
+	priv, trusted := newTestKey(t, "Alice")
+	path := filepath.Join(t.TempDir(), "single.lua")
+	if err := os.WriteFile(path, []byte(`Plugin = { name = "Single" }`), 0644); err != nil {
+		t.Fatalf("failed to write plugin: %v", err)
+	}
+
+	if err := SignPackageFile(path, priv); err != nil {
+		t.Fatalf("SignPackageFile failed: %v", err)
+	}
+
+	pkg, _ := OpenPackage(path)
+	if status := VerifyPackage(pkg, []TrustedKey{trusted}); status.State != SignatureSigned {
+		t.Fatalf("Expected signed, got %+v", status)
+	}
+
+	// Modify the plugin after signing
+	if err := os.WriteFile(path, []byte(`Plugin = { name = "Single" } evil()`), 0644); err != nil {
+		t.Fatalf("failed to write plugin: %v", err)
+	}
+	pkg, _ = OpenPackage(path)
+	if _, err := CheckTrust(pkg, TrustPolicy{Keys: []TrustedKey{trusted}}); err == nil {
+		t.Error("Expected tampered plugin to be refused")
+	}
+}
+
+func TestCheckTrust_Policy(t *testing.T) {
+	priv, _ := newTestKey(t, "Mallory")
+	_, trusted := newTestKey(t, "Alice")
+
+	unsigned := &Package{files: map[string][]byte{PackageEntry: []byte(`Plugin = {}`)}}
+	if status, err := CheckTrust(unsigned, TrustPolicy{}); err != nil || status.State != SignatureUnsigned {
+		t.Errorf("Expected unsigned plugin to be allowed, got %+v, %v", status, err)
+	}
+	if _, err := CheckTrust(unsigned, TrustPolicy{RequireSigned: true}); err == nil {
+		t.Error("Expected unsigned plugin to be refused when signatures are required")
+	}
+
+	untrusted := &Package{files: unsigned.files}
+	untrusted.signature = []byte(SignPackage(untrusted, priv))
+	status, err := CheckTrust(untrusted, TrustPolicy{Keys: []TrustedKey{trusted}})
+	if err == nil || status.State != SignatureUntrusted {
+		t.Errorf("Expected untrusted signature to be refused, got %+v, %v", status, err)
+	}
+
+	malformed := &Package{files: unsigned.files, signature: []byte("not a signature")}
+	if status, err := CheckTrust(malformed, TrustPolicy{}); err == nil || status.State != SignatureInvalid {
+		t.Errorf("Expected malformed signature to be refused, got %+v, %v", status, err)
+	}
+}
+
+func TestValidateTrustedKey(t *testing.T) {
+	_, trusted := newTestKey(t, "Alice")
+	if err := ValidateTrustedKey(trusted); err != nil {
+		t.Errorf("Expected valid key, got %v", err)
+	}
+	if err := ValidateTrustedKey(TrustedKey{Name: "", PublicKey: trusted.PublicKey}); err == nil {
+		t.Error("Expected error for empty name")
+	}
+	if err := ValidateTrustedKey(TrustedKey{Name: "Short", PublicKey: "AAAA"}); err == nil {
+		t.Error("Expected error for wrong key size")
+	}
+}
+
+// writeSignedPlugin writes a single-file plugin, signed if priv is set
+func writeSignedPlugin(t *testing.T, source string, priv ed25519.PrivateKey) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "guard.lua")
+	if err := os.WriteFile(path, []byte(source), 0644); err != nil {
+		t.Fatalf("failed to write plugin: %v", err)
+	}
+	if priv != nil {
+		if err := SignPackageFile(path, priv); err != nil {
+			t.Fatalf("SignPackageFile failed: %v", err)
+		}
+	}
+	return path
+}
+
+func TestUpgradePlugin_SignerChange(t *testing.T) {
+	m := newTestManager(t)
+	alicePriv, alice := newTestKey(t, "Alice")
+	bobPriv, bob := newTestKey(t, "Bob")
+	if err := SaveTrustedKeys(m.db, []TrustedKey{alice, bob}); err != nil {
+		t.Fatalf("SaveTrustedKeys failed: %v", err)
+	}
+
+	installed, err := m.ImportPlugin(writeSignedPlugin(t, `Plugin = { name = "Guard", version = "1.0.0" }`, alicePriv), nil)
+	if err != nil {
+		t.Fatalf("ImportPlugin failed: %v", err)
+	}
+
+	// Another trusted key or a dropped signature needs confirmation
+	byBob := writeSignedPlugin(t, `Plugin = { name = "Guard", version = "1.1.0" }`, bobPriv)
+	_, err = m.UpgradePlugin(installed.ID, byBob, false)
+	if !errors.Is(err, ErrSignerChanged) || !strings.Contains(err.Error(), "signed by Alice") {
+		t.Errorf("Expected an upgrade signed by Bob to be refused, got %v", err)
+	}
+	unsigned := writeSignedPlugin(t, `Plugin = { name = "Guard", version = "1.1.0" }`, nil)
+	if _, err := m.UpgradePlugin(installed.ID, unsigned, false); !errors.Is(err, ErrSignerChanged) {
+		t.Errorf("Expected an unsigned upgrade to be refused, got %v", err)
+	}
+
+	if _, err := m.UpgradePlugin(installed.ID, writeSignedPlugin(t, `Plugin = { name = "Guard", version = "1.1.0" }`, alicePriv), false); err != nil {
+		t.Fatalf("Expected an upgrade by the same signer to work, got %v", err)
+	}
+
+	// Once confirmed, the new signer is the one later upgrades are compared with
+	if _, err := m.UpgradePlugin(installed.ID, byBob, true); err != nil {
+		t.Fatalf("Expected a confirmed signer change to work, got %v", err)
+	}
+	var signer string
+	m.db.QueryRow("SELECT signer FROM plugins WHERE id = ?", installed.ID).Scan(&signer)
+	if signer != bob.PublicKey {
+		t.Errorf("Expected Bob's key to be recorded, got %q", signer)
+	}
+	if _, err := m.UpgradePlugin(installed.ID, writeSignedPlugin(t, `Plugin = { name = "Guard", version = "1.2.0" }`, alicePriv), false); !errors.Is(err, ErrSignerChanged) {
+		t.Errorf("Expected going back to Alice to need confirmation, got %v", err)
+	}
+}
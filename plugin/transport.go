@@ -0,0 +1,269 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// HTTPFixtures is a file of recorded HTTP interactions, replayed by
+// ReplayTransport so plugins can be tested offline:
+//
+//	{
+//	  "interactions": [
+//	    {
+//	      "request": {"method": "POST", "url": "https://fal.run/fal-ai/flux", "body_contains": "prompt"},
+//	      "response": {"status": 200, "headers": {"Content-Type": ["application/json"]}, "body": "{\"ok\": true}"}
+//	    }
+//	  ]
+//	}
+type HTTPFixtures struct {
+	Interactions []HTTPInteraction `json:"interactions"`
+}
+
+// HTTPInteraction is a recorded request and the response replayed for it
+type HTTPInteraction struct {
+	Request  FixtureRequest  `json:"request"`
+	Response FixtureResponse `json:"response"`
+	Repeat   bool            `json:"repeat,omitempty"` // replayed for every matching request instead of once
+}
+
+// FixtureRequest describes the requests an interaction answers. Empty fields
+// match any request.
+type FixtureRequest struct {
+	Method       string            `json:"method"`
+	URL          string            `json:"url"`                     // full URL including the query
+	Headers      map[string]string `json:"headers,omitempty"`       // headers the request must have
+	BodyContains string            `json:"body_contains,omitempty"` // text the request body must contain
+}
+
+// FixtureResponse is the response replayed for a request. A 3xx status with
+// a Location header makes the client follow the redirect, which goes through
+// the plugin's redirect checks like a real one.
+type FixtureResponse struct {
+	Status     int                 `json:"status"`
+	Headers    map[string][]string `json:"headers,omitempty"`
+	Body       string              `json:"body,omitempty"`
+	BodyBase64 string              `json:"body_base64,omitempty"` // binary body, used instead of Body
+}
+
+// LoadHTTPFixtures reads a fixture file
+func LoadHTTPFixtures(path string) (*HTTPFixtures, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixtures: %w", err)
+	}
+	var fixtures HTTPFixtures
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		return nil, fmt.Errorf("invalid fixtures %s: %w", path, err)
+	}
+	return &fixtures, nil
+}
+
+// Save writes the fixtures to a file
+func (f *HTTPFixtures) Save(path string) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// matches reports whether a request matches the fixture request
+func (r FixtureRequest) matches(req *http.Request, body []byte) bool {
+	if r.Method != "" && !strings.EqualFold(r.Method, req.Method) {
+		return false
+	}
+	if r.URL != "" && r.URL != req.URL.String() {
+		return false
+	}
+	for key, value := range r.Headers {
+		if req.Header.Get(key) != value {
+			return false
+		}
+	}
+	return r.BodyContains == "" || bytes.Contains(body, []byte(r.BodyContains))
+}
+
+// response builds the replayed response for a request
+func (r FixtureResponse) response(req *http.Request) (*http.Response, error) {
+	body := []byte(r.Body)
+	if r.BodyBase64 != "" {
+		decoded, err := base64.StdEncoding.DecodeString(r.BodyBase64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid body_base64 in fixture for %s %s: %w", req.Method, req.URL, err)
+		}
+		body = decoded
+	}
+
+	status := r.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	header := make(http.Header)
+	for key, values := range r.Headers {
+		for _, value := range values {
+			header.Add(key, value)
+		}
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// ReplayTransport answers requests from recorded interactions instead of the
+// network. Each request gets the first unused interaction that matches it,
+// so replays are deterministic; requests without one fail.
+type ReplayTransport struct {
+	mu           sync.Mutex
+	interactions []HTTPInteraction
+	used         []bool
+	requests     []ReplayedRequest
+}
+
+// ReplayedRequest is a request a ReplayTransport received
+type ReplayedRequest struct {
+	Method string
+	URL    string
+	Header http.Header
+	Body   []byte
+}
+
+// NewReplayTransport creates a transport replaying the fixtures
+func NewReplayTransport(fixtures *HTTPFixtures) *ReplayTransport {
+	return &ReplayTransport{
+		interactions: fixtures.Interactions,
+		used:         make([]bool, len(fixtures.Interactions)),
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.requests = append(t.requests, ReplayedRequest{Method: req.Method, URL: req.URL.String(), Header: req.Header.Clone(), Body: body})
+	for i, interaction := range t.interactions {
+		if t.used[i] && !interaction.Repeat {
+			continue
+		}
+		if interaction.Request.matches(req, body) {
+			t.used[i] = true
+			return interaction.Response.response(req)
+		}
+	}
+	return nil, fmt.Errorf("no fixture for %s %s", req.Method, req.URL)
+}
+
+// Requests returns the requests the transport received, in order
+func (t *ReplayTransport) Requests() []ReplayedRequest {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]ReplayedRequest(nil), t.requests...)
+}
+
+// Unused returns the interactions no request matched
+func (t *ReplayTransport) Unused() []HTTPInteraction {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var unused []HTTPInteraction
+	for i, interaction := range t.interactions {
+		if !t.used[i] {
+			unused = append(unused, interaction)
+		}
+	}
+	return unused
+}
+
+// RecordTransport sends requests with Next (http.DefaultTransport if nil)
+// and records each one with its response, to be saved as fixtures. Requests
+// are recorded by method and URL; add body_contains or headers by hand where
+// requests to the same URL must be told apart.
+type RecordTransport struct {
+	Next http.RoundTripper
+
+	mu       sync.Mutex
+	fixtures HTTPFixtures
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *RecordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	recorded := FixtureResponse{Status: resp.StatusCode, Headers: resp.Header.Clone()}
+	if isTextContent(resp.Header.Get("Content-Type")) {
+		recorded.Body = string(respBody)
+	} else {
+		recorded.BodyBase64 = base64.StdEncoding.EncodeToString(respBody)
+	}
+
+	t.mu.Lock()
+	t.fixtures.Interactions = append(t.fixtures.Interactions, HTTPInteraction{
+		Request:  FixtureRequest{Method: req.Method, URL: req.URL.String()},
+		Response: recorded,
+	})
+	t.mu.Unlock()
+	return resp, nil
+}
+
+// Fixtures returns the interactions recorded so far
+func (t *RecordTransport) Fixtures() *HTTPFixtures {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return &HTTPFixtures{Interactions: append([]HTTPInteraction(nil), t.fixtures.Interactions...)}
+}
+
+// readRequestBody reads a request's body and puts it back for sending
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// isTextContent reports whether a content type is text that can be stored
+// as is in a fixture
+func isTextContent(contentType string) bool {
+	return strings.HasPrefix(contentType, "text/") || strings.Contains(contentType, "json") ||
+		strings.Contains(contentType, "xml") || contentType == "application/x-www-form-urlencoded"
+}
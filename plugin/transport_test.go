@@ -0,0 +1,177 @@
+package plugin
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// importFixturePlugin imports a plugin declaring api.example.com and
+// cdn.example.com, approves only api.example.com, and runs source as its
+// on_ui_action handler
+func importFixturePlugin(t *testing.T, m *Manager, source string) *Plugin {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "fixture.lua")
+	manifest := `
+Plugin = {
+    name = "Fixture",
+    network = {
+        ["api.example.com"] = {"GET", "POST"},
+        ["cdn.example.com"] = {"GET"},
+    },
+    ui = { card_actions = { { id = "run", label = "Run" } } },
+}
+function on_ui_action(action, clip_ids, options)
+` + source + `
+    return { success = true }
+end
+`
+	if err := os.WriteFile(path, []byte(manifest), 0644); err != nil {
+		t.Fatalf("failed to write plugin: %v", err)
+	}
+	p, err := m.ImportPlugin(path, []string{"api.example.com"})
+	if err != nil {
+		t.Fatalf("ImportPlugin failed: %v", err)
+	}
+	if _, err := m.ExecuteUIAction(p.ID, "run", nil, nil); err != nil {
+		t.Fatalf("ExecuteUIAction failed: %v", err)
+	}
+	return p
+}
+
+func TestReplayTransport_ReplaysInteractions(t *testing.T) {
+	m := newTestManager(t)
+	replay := NewReplayTransport(&HTTPFixtures{Interactions: []HTTPInteraction{
+		{
+			Request:  FixtureRequest{Method: "POST", URL: "https://api.example.com/jobs", BodyContains: `"prompt"`},
+			Response: FixtureResponse{Status: 201, Body: `{"id": "job-1"}`},
+		},
+		{
+			Request:  FixtureRequest{Method: "GET", URL: "https://api.example.com/jobs/job-1"},
+			Response: FixtureResponse{Body: "pending"},
+		},
+		{
+			Request:  FixtureRequest{Method: "GET", URL: "https://api.example.com/jobs/job-1"},
+			Response: FixtureResponse{Body: "done"},
+		},
+		{
+			Request:  FixtureRequest{Method: "GET", URL: "https://api.example.com/unused"},
+			Response: FixtureResponse{Body: "never"},
+		},
+	}})
+	m.SetTransport(replay)
+
+	p := importFixturePlugin(t, m, `
+    local created = http.post("https://api.example.com/jobs", { body = '{"prompt": "a cat"}' })
+    local first = http.get("https://api.example.com/jobs/job-1")
+    local second = http.get("https://api.example.com/jobs/job-1")
+    local _, err = http.get("https://api.example.com/missing")
+    storage.set("result", created.status .. "|" .. first.body .. "|" .. second.body .. "|" .. tostring(err))
+`)
+
+	got := waitForStorage(t, m, p.ID, "result")
+	if !strings.HasPrefix(got, "201|pending|done|") || !strings.Contains(got, "no fixture for GET https://api.example.com/missing") {
+		t.Errorf("Unexpected result: %s", got)
+	}
+	if requests := replay.Requests(); len(requests) != 4 || string(requests[0].Body) != `{"prompt": "a cat"}` {
+		t.Errorf("Unexpected requests: %+v", requests)
+	}
+	if unused := replay.Unused(); len(unused) != 1 || unused[0].Request.URL != "https://api.example.com/unused" {
+		t.Errorf("Unexpected unused interactions: %+v", unused)
+	}
+}
+
+func TestReplayTransport_RedirectsGoThroughAllowlist(t *testing.T) {
+	m := newTestManager(t)
+	redirect := func(from, to string) HTTPInteraction {
+		return HTTPInteraction{
+			Request:  FixtureRequest{Method: "GET", URL: from},
+			Response: FixtureResponse{Status: http.StatusFound, Headers: map[string][]string{"Location": {to}}},
+		}
+	}
+	m.SetTransport(NewReplayTransport(&HTTPFixtures{Interactions: []HTTPInteraction{
+		redirect("https://api.example.com/moved", "https://api.example.com/final"),
+		{Request: FixtureRequest{URL: "https://api.example.com/final"}, Response: FixtureResponse{Body: "arrived"}},
+		redirect("https://api.example.com/cdn", "https://cdn.example.com/file"),
+		redirect("https://api.example.com/evil", "https://evil.example.org/"),
+		redirect("https://api.example.com/plain", "http://api.example.com/final"),
+	}}))
+
+	p := importFixturePlugin(t, m, `
+    local results = {}
+    for _, path in ipairs({ "moved", "cdn", "evil", "plain" }) do
+        local resp, err = http.get("https://api.example.com/" .. path)
+        table.insert(results, resp and resp.body or err)
+    end
+    storage.set("result", table.concat(results, "\n"))
+`)
+
+	results := strings.Split(waitForStorage(t, m, p.ID, "result"), "\n")
+	if len(results) != 4 {
+		t.Fatalf("Unexpected results: %q", results)
+	}
+	if results[0] != "arrived" {
+		t.Errorf("Expected a redirect within an approved domain to be followed, got %q", results[0])
+	}
+	for i, want := range []string{
+		"redirect to unauthorized domain: cdn.example.com", // declared but not approved
+		"redirect to unauthorized domain: evil.example.org",
+		"redirect to non-HTTPS URL not allowed",
+	} {
+		if !strings.Contains(results[i+1], want) {
+			t.Errorf("Expected %q, got %q", want, results[i+1])
+		}
+	}
+}
+
+func TestRecordTransport_RecordsReplayableFixtures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/image" {
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte{0x89, 'P', 'N', 'G', 0x00})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"path": %q}`, r.URL.Path)
+	}))
+	defer server.Close()
+
+	recorder := &RecordTransport{}
+	client := &http.Client{Transport: recorder}
+	for _, path := range []string{"/info", "/image"} {
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", path, err)
+		}
+		resp.Body.Close()
+	}
+
+	path := filepath.Join(t.TempDir(), "fixtures.json")
+	if err := recorder.Fixtures().Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	fixtures, err := LoadHTTPFixtures(path)
+	if err != nil {
+		t.Fatalf("LoadHTTPFixtures failed: %v", err)
+	}
+	if len(fixtures.Interactions) != 2 || fixtures.Interactions[0].Response.Body != `{"path": "/info"}` ||
+		fixtures.Interactions[1].Response.BodyBase64 == "" {
+		t.Fatalf("Unexpected fixtures: %+v", fixtures)
+	}
+
+	server.Close()
+	client.Transport = NewReplayTransport(fixtures)
+	resp, err := client.Get(server.URL + "/image")
+	if err != nil {
+		t.Fatalf("Replayed GET failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "image/png" || resp.ContentLength != 5 {
+		t.Errorf("Unexpected replayed response: %s, %d bytes", resp.Header.Get("Content-Type"), resp.ContentLength)
+	}
+}
@@ -2,10 +2,14 @@ package main
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"strconv"
+	"strings"
 
 	"go-clipboard/plugin"
+	"go-clipboard/store"
 
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
@@ -30,8 +34,38 @@ type PluginInfo struct {
 	Author      string                `json:"author"`
 	Enabled     bool                  `json:"enabled"`
 	Status      string                `json:"status"`
+	Signature   string                `json:"signature"` // "unsigned", "signed by X", "untrusted" or "invalid"
 	Events      []string              `json:"events"`
 	Settings    []plugin.SettingField `json:"settings"`
+
+	// SensitiveClips is set if the plugin asks to read clips marked sensitive
+	SensitiveClips bool `json:"sensitive_clips"`
+
+	// Scopes are the clip and tag scopes the plugin declares, limited to the
+	// clips in ScopeLimits. PendingScopes are the declared scopes the user
+	// hasn't granted, such as ones added by an upgrade.
+	Scopes        []string           `json:"scopes"`
+	ScopeLimits   plugin.ScopeLimits `json:"scope_limits"`
+	PendingScopes []string           `json:"pending_scopes"`
+
+	// Network maps the domains the plugin declares to their allowed methods.
+	// PendingDomains are the declared domains the user hasn't approved, such
+	// as ones added by an upgrade.
+	Network        map[string][]string `json:"network"`
+	PendingDomains []string            `json:"pending_domains"`
+}
+
+// PluginImportPreview describes a plugin file before it is imported, so the
+// user can review the scopes and network domains it asks for
+type PluginImportPreview struct {
+	Path        string              `json:"path"`
+	Name        string              `json:"name"`
+	Version     string              `json:"version"`
+	Description string              `json:"description"`
+	Author      string              `json:"author"`
+	Scopes      []string            `json:"scopes"`
+	ScopeLimits plugin.ScopeLimits  `json:"scope_limits"`
+	Network     map[string][]string `json:"network"`
 }
 
 // PluginUIAction represents a UI action with plugin context
@@ -61,7 +95,7 @@ func (s *PluginService) GetPlugins() ([]PluginInfo, error) {
 	}
 
 	rows, err := s.app.db.Query(`
-		SELECT id, name, version, enabled, status
+		SELECT id, filename, name, version, enabled, status
 		FROM plugins ORDER BY name
 	`)
 	if err != nil {
@@ -72,8 +106,9 @@ func (s *PluginService) GetPlugins() ([]PluginInfo, error) {
 	var plugins []PluginInfo
 	for rows.Next() {
 		var p PluginInfo
+		var filename string
 		var enabled int
-		if err := rows.Scan(&p.ID, &p.Name, &p.Version, &enabled, &p.Status); err != nil {
+		if err := rows.Scan(&p.ID, &filename, &p.Name, &p.Version, &enabled, &p.Status); err != nil {
 			log.Printf("GetPlugins: failed to scan row: %v", err)
 			continue
 		}
@@ -81,15 +116,27 @@ func (s *PluginService) GetPlugins() ([]PluginInfo, error) {
 
 		// Get additional info from loaded plugin if available
 		if s.app.pluginManager != nil {
+			loadedPlugin := false
 			for _, loaded := range s.app.pluginManager.GetPlugins() {
 				if loaded.ID == p.ID && loaded.Manifest != nil {
 					p.Description = loaded.Manifest.Description
 					p.Author = loaded.Manifest.Author
 					p.Events = loaded.Manifest.Events
 					p.Settings = loaded.Manifest.Settings
+					p.SensitiveClips = loaded.Manifest.Permissions.SensitiveClips
+					p.Scopes = loaded.Manifest.Scopes
+					p.ScopeLimits = loaded.Manifest.ScopeLimits
+					p.PendingScopes = s.app.pluginManager.PendingScopes(p.ID, loaded.Manifest)
+					p.Network = loaded.Manifest.Network
+					p.PendingDomains = s.app.pluginManager.PendingDomains(p.ID, loaded.Manifest)
+					p.Signature = loaded.Signature.String()
+					loadedPlugin = true
 					break
 				}
 			}
+			if !loadedPlugin {
+				p.Signature = s.app.pluginManager.VerifyPluginFile(filename).String()
+			}
 		}
 
 		plugins = append(plugins, p)
@@ -101,8 +148,9 @@ func (s *PluginService) GetPlugins() ([]PluginInfo, error) {
 	return plugins, nil
 }
 
-// ImportPlugin imports a plugin from a file path
-func (s *PluginService) ImportPlugin() (*PluginInfo, error) {
+// ShowImportPluginDialog lets the user pick a plugin file and returns what it
+// asks for. Nothing is imported until ConfirmImportPlugin is called.
+func (s *PluginService) ShowImportPluginDialog() (*PluginImportPreview, error) {
 	if s.app.pluginManager == nil {
 		return nil, fmt.Errorf("plugin manager not initialized")
 	}
@@ -111,7 +159,7 @@ func (s *PluginService) ImportPlugin() (*PluginInfo, error) {
 	path, err := runtime.OpenFileDialog(s.app.ctx, runtime.OpenDialogOptions{
 		Title: "Select Plugin File",
 		Filters: []runtime.FileFilter{
-			{DisplayName: "Lua Scripts", Pattern: "*.lua"},
+			{DisplayName: "Plugins (*.lua, *.zip)", Pattern: "*.lua;*.zip"},
 		},
 	})
 	if err != nil {
@@ -121,12 +169,53 @@ func (s *PluginService) ImportPlugin() (*PluginInfo, error) {
 		return nil, nil // User cancelled
 	}
 
-	p, err := s.app.pluginManager.ImportPlugin(path)
+	manifest, err := readPluginManifest(path)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := manifest.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+	return &PluginImportPreview{
+		Path:        path,
+		Name:        manifest.Name,
+		Version:     manifest.Version,
+		Description: manifest.Description,
+		Author:      manifest.Author,
+		Scopes:      scopes,
+		ScopeLimits: manifest.ScopeLimits,
+		Network:     manifest.Network,
+	}, nil
+}
+
+// readPluginManifest reads the manifest of a plugin file that isn't imported yet
+func readPluginManifest(path string) (*plugin.Manifest, error) {
+	pkg, err := plugin.OpenPackage(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid plugin: %w", err)
+	}
+	manifest, err := plugin.ParseManifest(pkg.Main)
+	if err != nil {
+		return nil, fmt.Errorf("invalid plugin: %w", err)
+	}
+	return manifest, nil
+}
+
+// ConfirmImportPlugin imports a plugin after the user agreed to the scopes it
+// declares. domains are the declared network domains the user approved.
+func (s *PluginService) ConfirmImportPlugin(path string, domains []string) (*PluginInfo, error) {
+	if s.app.pluginManager == nil {
+		return nil, fmt.Errorf("plugin manager not initialized")
+	}
+
+	p, err := s.app.pluginManager.ImportPlugin(path, domains)
 	if err != nil {
 		return nil, err
 	}
 
-	return pluginToInfo(p), nil
+	return s.pluginToInfo(p), nil
 }
 
 // EnablePlugin enables a plugin
@@ -160,7 +249,7 @@ func (s *PluginService) GetPluginPermissions(id int64) ([]map[string]string, err
 	}
 
 	rows, err := s.app.db.Query(`
-		SELECT permission_type, path, granted_at
+		SELECT permission_type, path, granted_at, COALESCE(pending_reconfirm, 0)
 		FROM plugin_permissions WHERE plugin_id = ?
 	`, id)
 	if err != nil {
@@ -172,14 +261,16 @@ func (s *PluginService) GetPluginPermissions(id int64) ([]map[string]string, err
 	for rows.Next() {
 		var permType, path string
 		var grantedAt string
-		if err := rows.Scan(&permType, &path, &grantedAt); err != nil {
+		var pending bool
+		if err := rows.Scan(&permType, &path, &grantedAt, &pending); err != nil {
 			log.Printf("GetPluginPermissions: failed to scan row: %v", err)
 			continue
 		}
 		perms = append(perms, map[string]string{
-			"type":       permType,
-			"path":       path,
-			"granted_at": grantedAt,
+			"type":              permType,
+			"path":              path,
+			"granted_at":        grantedAt,
+			"pending_reconfirm": strconv.FormatBool(pending), // restored from a backup
 		})
 	}
 
@@ -195,37 +286,273 @@ func (s *PluginService) RevokePluginPermission(pluginID int64, permType, path st
 		return fmt.Errorf("database not initialized")
 	}
 
-	_, err := s.app.db.Exec(`
+	result, err := s.app.db.Exec(`
 		DELETE FROM plugin_permissions
 		WHERE plugin_id = ? AND permission_type = ? AND path = ?
 	`, pluginID, permType, path)
-	return err
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		if err := s.app.store.Audit(store.User, store.AuditPermissionRevoked, store.TargetPlugin, pluginID, map[string]interface{}{
+			"permission": permType,
+			"path":       path,
+		}); err != nil {
+			log.Printf("Failed to audit permission revoke: %v", err)
+		}
+	}
+	return nil
+}
+
+// SetPluginSensitiveClipsAccess grants or revokes a plugin's access to clips
+// marked sensitive. The plugin must declare the sensitive_clips permission.
+func (s *PluginService) SetPluginSensitiveClipsAccess(pluginID int64, granted bool) error {
+	if s.app.pluginManager == nil {
+		return fmt.Errorf("plugin manager not initialized")
+	}
+	return s.app.pluginManager.SetSensitiveClipsAccess(pluginID, granted)
+}
+
+// SetPluginScopeGranted grants or revokes one of the clip and tag scopes a
+// plugin declares
+func (s *PluginService) SetPluginScopeGranted(pluginID int64, scope string, granted bool) error {
+	if s.app.pluginManager == nil {
+		return fmt.Errorf("plugin manager not initialized")
+	}
+	return s.app.pluginManager.SetScopeGranted(pluginID, scope, granted)
+}
+
+// GetPluginSchedules returns a plugin's scheduled tasks with next run and last result
+func (s *PluginService) GetPluginSchedules(id int64) ([]plugin.TaskStatus, error) {
+	if s.app.pluginManager == nil {
+		return []plugin.TaskStatus{}, nil
+	}
+	return s.app.pluginManager.GetTaskStatus(id)
+}
+
+// GetTrustedKeys returns the public keys trusted to sign plugins
+func (s *PluginService) GetTrustedKeys() ([]plugin.TrustedKey, error) {
+	if s.app.db == nil {
+		return []plugin.TrustedKey{}, nil
+	}
+	keys := plugin.LoadTrustPolicy(s.app.db).Keys
+	if keys == nil {
+		keys = []plugin.TrustedKey{}
+	}
+	return keys, nil
+}
+
+// AddTrustedKey adds (or replaces by name) a trusted ed25519 public key
+func (s *PluginService) AddTrustedKey(name, publicKey string) error {
+	if s.app.db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	key := plugin.TrustedKey{Name: strings.TrimSpace(name), PublicKey: strings.TrimSpace(publicKey)}
+	if err := plugin.ValidateTrustedKey(key); err != nil {
+		return err
+	}
+
+	var keys []plugin.TrustedKey
+	for _, existing := range plugin.LoadTrustPolicy(s.app.db).Keys {
+		if existing.Name != key.Name {
+			keys = append(keys, existing)
+		}
+	}
+	keys = append(keys, key)
+
+	return plugin.SaveTrustedKeys(s.app.db, keys)
+}
+
+// RemoveTrustedKey removes a trusted key by name. Plugins signed only by
+// that key will fail verification on their next load.
+func (s *PluginService) RemoveTrustedKey(name string) error {
+	if s.app.db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	var keys []plugin.TrustedKey
+	for _, existing := range plugin.LoadTrustPolicy(s.app.db).Keys {
+		if existing.Name != name {
+			keys = append(keys, existing)
+		}
+	}
+
+	return plugin.SaveTrustedKeys(s.app.db, keys)
+}
+
+// GetRequireSignedPlugins returns whether unsigned plugins are refused
+func (s *PluginService) GetRequireSignedPlugins() bool {
+	if s.app.db == nil {
+		return false
+	}
+	return plugin.LoadTrustPolicy(s.app.db).RequireSigned
+}
+
+// SetRequireSignedPlugins sets whether unsigned plugins are refused on import and load
+func (s *PluginService) SetRequireSignedPlugins(required bool) error {
+	if s.app.db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	return plugin.SaveRequireSigned(s.app.db, required)
+}
+
+// SetPluginDomainApproved approves or revokes one of the network domains a
+// plugin declares
+func (s *PluginService) SetPluginDomainApproved(pluginID int64, domain string, approved bool) error {
+	if s.app.pluginManager == nil {
+		return fmt.Errorf("plugin manager not initialized")
+	}
+	return s.app.pluginManager.SetDomainApproved(pluginID, domain, approved)
+}
+
+// GetPluginNetworkLog returns a plugin's most recent requests, newest first,
+// including the ones that were refused
+func (s *PluginService) GetPluginNetworkLog(pluginID int64, limit int) ([]plugin.NetworkLogEntry, error) {
+	if s.app.db == nil {
+		return []plugin.NetworkLogEntry{}, nil
+	}
+	return plugin.QueryNetworkLog(s.app.db, pluginID, limit)
+}
+
+// GetBlockSensitiveUploads returns whether plugin requests carrying the data
+// of clips marked sensitive are refused
+func (s *PluginService) GetBlockSensitiveUploads() bool {
+	if s.app.db == nil {
+		return false
+	}
+	return plugin.LoadBlockSensitiveUploads(s.app.db)
+}
+
+// SetBlockSensitiveUploads sets whether plugin requests carrying the data of
+// clips marked sensitive are refused
+func (s *PluginService) SetBlockSensitiveUploads(block bool) error {
+	if s.app.db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	return plugin.SaveBlockSensitiveUploads(s.app.db, block)
+}
+
+// GetPluginRegistry returns the configured registry location (directory, index file or URL)
+func (s *PluginService) GetPluginRegistry() string {
+	if s.app.db == nil {
+		return ""
+	}
+	return plugin.LoadRegistryLocation(s.app.db)
+}
+
+// SetPluginRegistry sets the registry location. An empty location disables updates.
+func (s *PluginService) SetPluginRegistry(location string) error {
+	if s.app.db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	location = strings.TrimSpace(location)
+	if location != "" {
+		if _, err := plugin.OpenRegistry(location); err != nil {
+			return err
+		}
+	}
+	return plugin.SaveRegistryLocation(s.app.db, location)
+}
+
+// CheckPluginUpdates returns installed plugins that have a newer version in the registry
+func (s *PluginService) CheckPluginUpdates() ([]plugin.PluginUpdate, error) {
+	if s.app.pluginManager == nil {
+		return nil, fmt.Errorf("plugin manager not initialized")
+	}
+
+	reg, err := plugin.OpenRegistry(plugin.LoadRegistryLocation(s.app.db))
+	if err != nil {
+		return nil, err
+	}
+	return s.app.pluginManager.CheckForUpdates(reg)
+}
+
+// UpgradePlugin upgrades a plugin to the latest registry version, keeping its
+// storage, permissions and enabled state. An upgrade signed by another key
+// than the installed version fails unless allowSignerChange is set after
+// the user confirmed the change.
+func (s *PluginService) UpgradePlugin(id int64, allowSignerChange bool) (*PluginInfo, error) {
+	if s.app.pluginManager == nil {
+		return nil, fmt.Errorf("plugin manager not initialized")
+	}
+
+	reg, err := plugin.OpenRegistry(plugin.LoadRegistryLocation(s.app.db))
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := s.app.pluginManager.UpgradeFromRegistry(reg, id, allowSignerChange)
+	if err != nil {
+		return nil, err
+	}
+	return s.pluginToInfo(p), nil
 }
 
 // Helper function to convert plugin.Plugin to PluginInfo
-func pluginToInfo(p *plugin.Plugin) *PluginInfo {
+func (s *PluginService) pluginToInfo(p *plugin.Plugin) *PluginInfo {
 	info := &PluginInfo{
-		ID:      p.ID,
-		Name:    p.Name,
-		Version: p.Version,
-		Enabled: p.Enabled,
-		Status:  p.Status,
+		ID:        p.ID,
+		Name:      p.Name,
+		Version:   p.Version,
+		Enabled:   p.Enabled,
+		Status:    p.Status,
+		Signature: p.Signature.String(),
 	}
 	if p.Manifest != nil {
 		info.Description = p.Manifest.Description
 		info.Author = p.Manifest.Author
 		info.Events = p.Manifest.Events
 		info.Settings = p.Manifest.Settings
+		info.SensitiveClips = p.Manifest.Permissions.SensitiveClips
+		info.Scopes = p.Manifest.Scopes
+		info.ScopeLimits = p.Manifest.ScopeLimits
+		info.PendingScopes = s.app.pluginManager.PendingScopes(p.ID, p.Manifest)
+		info.Network = p.Manifest.Network
+		info.PendingDomains = s.app.pluginManager.PendingDomains(p.ID, p.Manifest)
 	}
 	return info
 }
 
-// GetPluginStorage retrieves a value from a plugin's storage
+// secretPlaceholder stands in for the value of a saved password setting, which
+// is never sent to the frontend
+const secretPlaceholder = "********"
+
+// passwordKeys returns the keys of a plugin's password settings
+func (s *PluginService) passwordKeys(pluginID int64) map[string]bool {
+	keys := make(map[string]bool)
+	if s.app.pluginManager == nil {
+		return keys
+	}
+	list, err := s.app.pluginManager.PasswordKeys(pluginID)
+	if err != nil {
+		log.Printf("Failed to read password settings of plugin %d: %v", pluginID, err)
+	}
+	for _, key := range list {
+		keys[key] = true
+	}
+	return keys
+}
+
+// GetPluginStorage retrieves a value from a plugin's storage. Saved password
+// settings are returned as a placeholder.
 func (s *PluginService) GetPluginStorage(pluginID int64, key string) (string, error) {
 	if s.app.db == nil {
 		return "", fmt.Errorf("database not initialized")
 	}
 
+	isPassword := s.passwordKeys(pluginID)[key]
+	if isPassword {
+		_, ok, err := s.app.pluginManager.Secrets().Get(pluginID, key)
+		if err != nil && !errors.Is(err, plugin.ErrSecretUnreadable) {
+			return "", err
+		}
+		if ok || err != nil {
+			return secretPlaceholder, nil
+		}
+	}
+
 	var value string
 	err := s.app.db.QueryRow(`
 		SELECT value FROM plugin_storage WHERE plugin_id = ? AND key = ?
@@ -236,29 +563,50 @@ func (s *PluginService) GetPluginStorage(pluginID int64, key string) (string, er
 	if err != nil {
 		return "", err
 	}
+	if isPassword {
+		// Not yet moved to the secrets store
+		return secretPlaceholder, nil
+	}
 	return value, nil
 }
 
-// ImportPluginFromPath imports a plugin from a file path (for testing/CLI use)
+// ImportPluginFromPath imports a plugin from a file path (for testing/CLI use),
+// approving every network domain it declares
 func (s *PluginService) ImportPluginFromPath(path string) (*PluginInfo, error) {
 	if s.app.pluginManager == nil {
 		return nil, fmt.Errorf("plugin manager not initialized")
 	}
 
-	p, err := s.app.pluginManager.ImportPlugin(path)
+	manifest, err := readPluginManifest(path)
+	if err != nil {
+		return nil, err
+	}
+	p, err := s.app.pluginManager.ImportPlugin(path, plugin.DeclaredDomains(manifest))
 	if err != nil {
 		return nil, err
 	}
 
-	return pluginToInfo(p), nil
+	return s.pluginToInfo(p), nil
 }
 
-// SetPluginStorage sets a value in a plugin's storage (for testing)
+// SetPluginStorage sets a value in a plugin's storage. Password settings are
+// encrypted in the secrets store; the placeholder leaves them unchanged.
 func (s *PluginService) SetPluginStorage(pluginID int64, key, value string) error {
 	if s.app.db == nil {
 		return fmt.Errorf("database not initialized")
 	}
 
+	if s.passwordKeys(pluginID)[key] {
+		if value == secretPlaceholder {
+			return nil
+		}
+		if err := s.app.pluginManager.Secrets().Set(pluginID, key, value); err != nil {
+			return err
+		}
+		_, err := s.app.db.Exec("DELETE FROM plugin_storage WHERE plugin_id = ? AND key = ?", pluginID, key)
+		return err
+	}
+
 	_, err := s.app.db.Exec(`
 		INSERT INTO plugin_storage (plugin_id, key, value)
 		VALUES (?, ?, ?)
@@ -267,7 +615,8 @@ func (s *PluginService) SetPluginStorage(pluginID int64, key, value string) erro
 	return err
 }
 
-// GetAllPluginStorage retrieves all storage key-value pairs for a plugin
+// GetAllPluginStorage retrieves all storage key-value pairs for a plugin.
+// Saved password settings are included as a placeholder.
 func (s *PluginService) GetAllPluginStorage(pluginID int64) (map[string]string, error) {
 	if s.app.db == nil {
 		return map[string]string{}, nil
@@ -281,6 +630,7 @@ func (s *PluginService) GetAllPluginStorage(pluginID int64) (map[string]string,
 	}
 	defer rows.Close()
 
+	passwordKeys := s.passwordKeys(pluginID)
 	result := make(map[string]string)
 	for rows.Next() {
 		var key string
@@ -288,9 +638,24 @@ func (s *PluginService) GetAllPluginStorage(pluginID int64) (map[string]string,
 		if err := rows.Scan(&key, &value); err != nil {
 			continue
 		}
+		if passwordKeys[key] {
+			// Not yet moved to the secrets store
+			result[key] = secretPlaceholder
+			continue
+		}
 		result[key] = string(value)
 	}
 
+	if s.app.pluginManager != nil {
+		keys, err := s.app.pluginManager.Secrets().Keys(pluginID)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			result[key] = secretPlaceholder
+		}
+	}
+
 	return result, nil
 }
 
@@ -13,6 +13,9 @@ Plugin = {
         ["*.fal.media"] = {"GET"},
     },
 
+    -- Reads the image and saves the result as a new clip
+    scopes = {"clips.read", "clips.write"},
+
     settings = {
         {key = "api_key", type = "password", label = "FAL.AI API Key", required = true},
     },
@@ -183,7 +186,7 @@ end
 
 -- Handle UI action from lightbox or card menu
 function on_ui_action(action_id, clip_ids, options)
-    local api_key = storage.get("api_key")
+    local api_key = secrets.get("api_key")
     if not api_key or api_key == "" then
         toast.show("FAL.AI API key not configured. Please set it in plugin settings.", "error")
         return {success = false, error = "API key not configured"}
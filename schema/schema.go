@@ -0,0 +1,340 @@
+// Package schema creates the tables of the clips database and migrates the
+// databases of older versions. The app, the plugin test runner and tests all
+// build their databases with it.
+package schema
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Migrate creates the tables and adds the columns of newer versions. It is
+// safe to run on every start.
+func Migrate(db *sql.DB) error {
+	createTableSQL := `
+    CREATE TABLE IF NOT EXISTS clips (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        content_type TEXT NOT NULL,
+        data BLOB NOT NULL,
+        filename TEXT,
+        created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+    );`
+
+	if _, err := db.Exec(createTableSQL); err != nil {
+		return fmt.Errorf("failed to create table: %w", err)
+	}
+
+	// Migrate: Add is_archived column if it doesn't exist
+	_, _ = db.Exec("ALTER TABLE clips ADD COLUMN is_archived INTEGER DEFAULT 0")
+	// Migrate: Add expires_at column if it doesn't exist
+	_, _ = db.Exec("ALTER TABLE clips ADD COLUMN expires_at DATETIME")
+	// Migrate: Add parent_id column linking derived clips to their source
+	_, _ = db.Exec("ALTER TABLE clips ADD COLUMN parent_id INTEGER")
+	// Migrate: Add provenance and content info columns
+	for _, column := range []string{
+		"source_kind TEXT",
+		"source_path TEXT",
+		"source_url TEXT",
+		"source_plugin_id INTEGER",
+		"source_action TEXT",
+		"source_mtime DATETIME",
+		"width INTEGER",
+		"height INTEGER",
+		"line_count INTEGER",
+		"language TEXT",
+	} {
+		_, _ = db.Exec("ALTER TABLE clips ADD COLUMN " + column)
+	}
+	if _, err := db.Exec("CREATE INDEX IF NOT EXISTS idx_clips_source_kind ON clips(source_kind)"); err != nil {
+		log.Printf("Warning: Failed to create clips source index: %v", err)
+	}
+	// Migrate: Add pinning and manual ordering columns
+	_, _ = db.Exec("ALTER TABLE clips ADD COLUMN is_pinned INTEGER DEFAULT 0")
+	_, _ = db.Exec("ALTER TABLE clips ADD COLUMN sort_position INTEGER")
+	// Migrate: Add deleted_at column for the trash (NULL = not trashed)
+	_, _ = db.Exec("ALTER TABLE clips ADD COLUMN deleted_at DATETIME")
+	if _, err := db.Exec("CREATE INDEX IF NOT EXISTS idx_clips_deleted_at ON clips(deleted_at)"); err != nil {
+		log.Printf("Warning: Failed to create clips deleted_at index: %v", err)
+	}
+	// Migrate: Add is_encrypted column (1 = data is encrypted with the data key)
+	_, _ = db.Exec("ALTER TABLE clips ADD COLUMN is_encrypted INTEGER NOT NULL DEFAULT 0")
+	// Migrate: Add secrets column (comma-separated secret rules found in the data)
+	_, _ = db.Exec("ALTER TABLE clips ADD COLUMN secrets TEXT")
+	// Migrate: Add is_sensitive column (1 = masked in the gallery and hidden from plugins)
+	_, _ = db.Exec("ALTER TABLE clips ADD COLUMN is_sensitive INTEGER NOT NULL DEFAULT 0")
+
+	// Create encryption table holding the wrapped data key while clip data is
+	// encrypted. It has at most one row.
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS encryption (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		key_source TEXT NOT NULL,
+		key_file TEXT,
+		salt BLOB NOT NULL,
+		iterations INTEGER,
+		wrapped_key BLOB NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		log.Printf("Warning: Failed to create encryption table: %v", err)
+	}
+
+	// Create settings table
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS settings (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL
+	)`); err != nil {
+		log.Printf("Warning: Failed to create settings table: %v", err)
+	}
+
+	// Create watched_folders table
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS watched_folders (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		path TEXT NOT NULL UNIQUE,
+		filter_mode TEXT NOT NULL DEFAULT 'all',
+		filter_presets TEXT,
+		filter_regex TEXT,
+		process_existing INTEGER DEFAULT 0,
+		auto_archive INTEGER DEFAULT 0,
+		is_paused INTEGER DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		log.Printf("Warning: Failed to create watched_folders table: %v", err)
+	}
+
+	// Initialize global watch pause setting if not exists
+	if _, err := db.Exec(`INSERT OR IGNORE INTO settings (key, value) VALUES ('global_watch_paused', 'false')`); err != nil {
+		log.Printf("Warning: Failed to initialize global_watch_paused setting: %v", err)
+	}
+
+	// Create tags table
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS tags (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		color TEXT NOT NULL
+	)`); err != nil {
+		log.Printf("Warning: Failed to create tags table: %v", err)
+	}
+
+	// Create clip_tags join table
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS clip_tags (
+		clip_id INTEGER NOT NULL,
+		tag_id INTEGER NOT NULL,
+		PRIMARY KEY (clip_id, tag_id),
+		FOREIGN KEY (clip_id) REFERENCES clips(id) ON DELETE CASCADE,
+		FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+	)`); err != nil {
+		log.Printf("Warning: Failed to create clip_tags table: %v", err)
+	}
+
+	// Create collections table for curated, ordered groups of clips
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS collections (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		description TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		log.Printf("Warning: Failed to create collections table: %v", err)
+	}
+
+	// Create collection_clips join table with each clip's position in the collection
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS collection_clips (
+		collection_id INTEGER NOT NULL,
+		clip_id INTEGER NOT NULL,
+		position INTEGER NOT NULL DEFAULT 0,
+		added_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (collection_id, clip_id),
+		FOREIGN KEY (collection_id) REFERENCES collections(id) ON DELETE CASCADE,
+		FOREIGN KEY (clip_id) REFERENCES clips(id) ON DELETE CASCADE
+	)`); err != nil {
+		log.Printf("Warning: Failed to create collection_clips table: %v", err)
+	}
+	if _, err := db.Exec("CREATE INDEX IF NOT EXISTS idx_collection_clips_clip ON collection_clips(clip_id)"); err != nil {
+		log.Printf("Warning: Failed to create collection_clips index: %v", err)
+	}
+
+	// Create saved_searches table holding named filters as JSON
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS saved_searches (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		filter TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		log.Printf("Warning: Failed to create saved_searches table: %v", err)
+	}
+
+	// Create saved_search_matches table recording which clips match each saved
+	// search, so plugins are only notified when a clip starts matching
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS saved_search_matches (
+		search_id INTEGER NOT NULL,
+		clip_id INTEGER NOT NULL,
+		PRIMARY KEY (search_id, clip_id),
+		FOREIGN KEY (search_id) REFERENCES saved_searches(id) ON DELETE CASCADE,
+		FOREIGN KEY (clip_id) REFERENCES clips(id) ON DELETE CASCADE
+	)`); err != nil {
+		log.Printf("Warning: Failed to create saved_search_matches table: %v", err)
+	}
+
+	// Create clip_metadata table for key/value data attached by plugins
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS clip_metadata (
+		clip_id INTEGER NOT NULL,
+		key TEXT NOT NULL,
+		value TEXT NOT NULL,
+		PRIMARY KEY (clip_id, key),
+		FOREIGN KEY (clip_id) REFERENCES clips(id) ON DELETE CASCADE
+	)`); err != nil {
+		log.Printf("Warning: Failed to create clip_metadata table: %v", err)
+	}
+
+	// Create clip_revisions table holding previous versions of edited clips
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS clip_revisions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		clip_id INTEGER NOT NULL,
+		content_type TEXT NOT NULL,
+		data BLOB NOT NULL,
+		filename TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		is_delta INTEGER NOT NULL DEFAULT 0,
+		size INTEGER NOT NULL DEFAULT 0,
+		FOREIGN KEY (clip_id) REFERENCES clips(id) ON DELETE CASCADE
+	)`); err != nil {
+		log.Printf("Warning: Failed to create clip_revisions table: %v", err)
+	}
+	// Migrate: Add delta storage columns to clip_revisions if they don't exist
+	_, _ = db.Exec("ALTER TABLE clip_revisions ADD COLUMN is_delta INTEGER NOT NULL DEFAULT 0")
+	_, _ = db.Exec("ALTER TABLE clip_revisions ADD COLUMN size INTEGER NOT NULL DEFAULT 0")
+	_, _ = db.Exec("ALTER TABLE clip_revisions ADD COLUMN is_encrypted INTEGER NOT NULL DEFAULT 0")
+	if _, err := db.Exec("CREATE INDEX IF NOT EXISTS idx_clip_revisions_clip ON clip_revisions(clip_id)"); err != nil {
+		log.Printf("Warning: Failed to create clip_revisions index: %v", err)
+	}
+
+	// Migrate: Add auto_tag_id column to watched_folders if it doesn't exist
+	_, _ = db.Exec("ALTER TABLE watched_folders ADD COLUMN auto_tag_id INTEGER")
+
+	// Create plugins table
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS plugins (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		filename TEXT UNIQUE NOT NULL,
+		name TEXT NOT NULL,
+		version TEXT,
+		enabled INTEGER DEFAULT 1,
+		status TEXT DEFAULT 'enabled',
+		error_count INTEGER DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		log.Printf("Warning: Failed to create plugins table: %v", err)
+	}
+
+	// Migrate: Add the public key that signed each plugin (empty if unsigned),
+	// compared on upgrades. Existing plugins get it at their next load.
+	_, _ = db.Exec("ALTER TABLE plugins ADD COLUMN signer TEXT")
+
+	// Migrate: Plugins installed before scopes existed could use the clips and
+	// tags APIs without grants. They are granted the scopes they declare once,
+	// at their next load, and get 1 here afterwards.
+	_, _ = db.Exec("ALTER TABLE plugins ADD COLUMN scopes_migrated INTEGER")
+
+	// Migrate: Plugins installed before network domains needed approval could
+	// reach every domain they declare. Those domains are approved once, at
+	// their next load, and they get 1 here afterwards.
+	_, _ = db.Exec("ALTER TABLE plugins ADD COLUMN domains_migrated INTEGER")
+
+	// Create plugin_permissions table
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS plugin_permissions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		plugin_id INTEGER NOT NULL,
+		permission_type TEXT NOT NULL,
+		path TEXT NOT NULL,
+		granted_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (plugin_id) REFERENCES plugins(id) ON DELETE CASCADE
+	)`); err != nil {
+		log.Printf("Warning: Failed to create plugin_permissions table: %v", err)
+	}
+
+	// Migrate: Add pending_reconfirm column to plugin_permissions if it doesn't exist
+	_, _ = db.Exec("ALTER TABLE plugin_permissions ADD COLUMN pending_reconfirm INTEGER DEFAULT 0")
+
+	// Create plugin_storage table
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS plugin_storage (
+		plugin_id INTEGER NOT NULL,
+		key TEXT NOT NULL,
+		value BLOB,
+		PRIMARY KEY (plugin_id, key),
+		FOREIGN KEY (plugin_id) REFERENCES plugins(id) ON DELETE CASCADE
+	)`); err != nil {
+		log.Printf("Warning: Failed to create plugin_storage table: %v", err)
+	}
+
+	// Create plugin_secrets table (values of password settings, encrypted with
+	// the key file next to the plugins directory)
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS plugin_secrets (
+		plugin_id INTEGER NOT NULL,
+		key TEXT NOT NULL,
+		value BLOB NOT NULL,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (plugin_id, key),
+		FOREIGN KEY (plugin_id) REFERENCES plugins(id) ON DELETE CASCADE
+	)`); err != nil {
+		log.Printf("Warning: Failed to create plugin_secrets table: %v", err)
+	}
+
+	// Create plugin_network_log table (each plugin's recent requests, trimmed
+	// to plugin.NetworkLogLimit entries per plugin)
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS plugin_network_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		plugin_id INTEGER NOT NULL,
+		created_at DATETIME NOT NULL,
+		method TEXT NOT NULL,
+		host TEXT NOT NULL,
+		status INTEGER NOT NULL DEFAULT 0,
+		bytes_out INTEGER NOT NULL DEFAULT 0,
+		bytes_in INTEGER NOT NULL DEFAULT 0,
+		error TEXT NOT NULL DEFAULT '',
+		FOREIGN KEY (plugin_id) REFERENCES plugins(id) ON DELETE CASCADE
+	)`); err != nil {
+		log.Printf("Warning: Failed to create plugin_network_log table: %v", err)
+	}
+	if _, err := db.Exec("CREATE INDEX IF NOT EXISTS idx_plugin_network_log_plugin ON plugin_network_log(plugin_id, id)"); err != nil {
+		log.Printf("Warning: Failed to create plugin_network_log index: %v", err)
+	}
+
+	// Create plugin_schedule_runs table (last run of each scheduled task, for catch-up)
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS plugin_schedule_runs (
+		plugin_id INTEGER NOT NULL,
+		task_name TEXT NOT NULL,
+		last_run TEXT NOT NULL,
+		last_result TEXT NOT NULL,
+		last_error TEXT NOT NULL DEFAULT '',
+		PRIMARY KEY (plugin_id, task_name),
+		FOREIGN KEY (plugin_id) REFERENCES plugins(id) ON DELETE CASCADE
+	)`); err != nil {
+		log.Printf("Warning: Failed to create plugin_schedule_runs table: %v", err)
+	}
+
+	// Create audit_log table. Triggers keep it append-only; it isn't part of
+	// backups and survives restores.
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		created_at DATETIME NOT NULL,
+		action TEXT NOT NULL,
+		actor TEXT NOT NULL,
+		plugin_id INTEGER,
+		job TEXT,
+		target_type TEXT,
+		target_id INTEGER,
+		details TEXT
+	)`); err != nil {
+		log.Printf("Warning: Failed to create audit_log table: %v", err)
+	}
+	if _, err := db.Exec("CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log(created_at)"); err != nil {
+		log.Printf("Warning: Failed to create audit_log index: %v", err)
+	}
+	for _, op := range []string{"UPDATE", "DELETE"} {
+		if _, err := db.Exec(fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS audit_log_no_%s BEFORE %s ON audit_log
+			BEGIN SELECT RAISE(ABORT, 'audit_log is append-only'); END`, strings.ToLower(op), op)); err != nil {
+			log.Printf("Warning: Failed to create audit_log trigger: %v", err)
+		}
+	}
+
+	return nil
+}
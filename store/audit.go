@@ -0,0 +1,178 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Audited actions. Store changes use the name of their event.
+const (
+	AuditClipTrashed        = "clip:trashed"
+	AuditClipDeleted        = "clip:deleted"
+	AuditClipRestored       = "clip:restored"
+	AuditTagDeleted         = "tag:deleted"
+	AuditCollectionDeleted  = "collection:deleted"
+	AuditPermissionGranted  = "permission:granted"
+	AuditPermissionDenied   = "permission:denied"
+	AuditPermissionRevoked  = "permission:revoked"
+	AuditPluginAction       = "plugin:action"
+	AuditPluginAutoDisabled = "plugin:auto_disabled"
+	AuditBackupCreated      = "backup:created"
+	AuditBackupRestored     = "backup:restored"
+)
+
+// Audit target types
+const (
+	TargetClip       = "clip"
+	TargetTag        = "tag"
+	TargetCollection = "collection"
+	TargetPlugin     = "plugin"
+	TargetBackup     = "backup"
+)
+
+// AuditEntry is a row of the audit log
+type AuditEntry struct {
+	ID         int64                  `json:"id"`
+	CreatedAt  time.Time              `json:"created_at"`
+	Action     string                 `json:"action"`
+	Actor      string                 `json:"actor"`               // actor kind
+	PluginID   int64                  `json:"plugin_id,omitempty"` // plugin that made the change
+	Job        string                 `json:"job,omitempty"`       // system job that made the change
+	TargetType string                 `json:"target_type,omitempty"`
+	TargetID   int64                  `json:"target_id,omitempty"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+}
+
+// AuditFilter selects audit log entries. Empty fields don't filter.
+type AuditFilter struct {
+	Action     string `json:"action"`
+	Actor      string `json:"actor"`
+	PluginID   int64  `json:"plugin_id"` // entries made by or about the plugin
+	TargetType string `json:"target_type"`
+	TargetID   int64  `json:"target_id"`
+	Since      int64  `json:"since"` // Unix seconds
+	Until      int64  `json:"until"` // Unix seconds
+	Limit      int    `json:"limit"` // 0 returns every entry
+	Offset     int    `json:"offset"`
+}
+
+// Audit appends an entry to the audit log. The log can't be changed or
+// cleared once written.
+func (s *Service) Audit(actor Actor, action, targetType string, targetID int64, details map[string]interface{}) error {
+	var encoded interface{}
+	if len(details) > 0 {
+		data, err := json.Marshal(details)
+		if err != nil {
+			return fmt.Errorf("failed to encode details: %w", err)
+		}
+		encoded = string(data)
+	}
+	var pluginID interface{}
+	if actor.Kind == ActorPlugin {
+		pluginID = actor.PluginID
+	}
+	var target interface{}
+	if targetID != 0 {
+		target = targetID
+	}
+
+	_, err := s.db.Exec(`INSERT INTO audit_log (created_at, action, actor, plugin_id, job, target_type, target_id, details)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		time.Now().UTC().Format("2006-01-02 15:04:05"), action, actor.Kind, pluginID, actor.Job, targetType, target, encoded)
+	if err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
+
+// auditEvent records the store events that destroy or bring back data
+func (s *Service) auditEvent(e Event) {
+	var targetType string
+	switch e.Name {
+	case AuditClipTrashed, AuditClipDeleted, AuditClipRestored:
+		targetType = TargetClip
+	case AuditTagDeleted:
+		targetType = TargetTag
+	case AuditCollectionDeleted:
+		targetType = TargetCollection
+	default:
+		return
+	}
+
+	var id int64
+	switch data := e.Data.(type) {
+	case int64:
+		id = data
+	case map[string]interface{}:
+		id, _ = data["id"].(int64)
+	}
+	if err := s.Audit(e.Actor, e.Name, targetType, id, nil); err != nil {
+		log.Printf("Failed to audit %s of %s %d: %v", e.Name, targetType, id, err)
+	}
+}
+
+// QueryAudit returns the audit log entries matching a filter, newest first
+func (s *Service) QueryAudit(f AuditFilter) ([]AuditEntry, error) {
+	var conditions []string
+	var args []interface{}
+	add := func(condition string, values ...interface{}) {
+		conditions = append(conditions, condition)
+		args = append(args, values...)
+	}
+	if f.Action != "" {
+		add("action = ?", f.Action)
+	}
+	if f.Actor != "" {
+		add("actor = ?", f.Actor)
+	}
+	if f.PluginID != 0 {
+		add("(plugin_id = ? OR (target_type = ? AND target_id = ?))", f.PluginID, TargetPlugin, f.PluginID)
+	}
+	if f.TargetType != "" {
+		add("target_type = ?", f.TargetType)
+	}
+	if f.TargetID != 0 {
+		add("target_id = ?", f.TargetID)
+	}
+	if f.Since != 0 {
+		add("created_at >= ?", time.Unix(f.Since, 0).UTC().Format("2006-01-02 15:04:05"))
+	}
+	if f.Until != 0 {
+		add("created_at < ?", time.Unix(f.Until, 0).UTC().Format("2006-01-02 15:04:05"))
+	}
+
+	query := "SELECT id, created_at, action, actor, COALESCE(plugin_id, 0), COALESCE(job, ''), COALESCE(target_type, ''), COALESCE(target_id, 0), details FROM audit_log"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id DESC"
+	if f.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, f.Limit, f.Offset)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var e AuditEntry
+		var details *string
+		if err := rows.Scan(&e.ID, &e.CreatedAt, &e.Action, &e.Actor, &e.PluginID, &e.Job, &e.TargetType, &e.TargetID, &details); err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		if details != nil {
+			if err := json.Unmarshal([]byte(*details), &e.Details); err != nil {
+				return nil, fmt.Errorf("failed to decode audit details: %w", err)
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
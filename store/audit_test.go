@@ -0,0 +1,69 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAudit_RecordsDestructiveEvents(t *testing.T) {
+	s, _ := newTestService(t)
+
+	a, _ := s.CreateClip(User, NewClip{Data: []byte("a")})
+	b, _ := s.CreateClip(User, NewClip{Data: []byte("b")})
+	s.TrashClips(User, []int64{a.ID, b.ID})
+	s.RestoreClips(Plugin(7), []int64{a.ID})
+	s.PurgeTrash(SystemJob(JobTrashPurge), time.Now().Add(time.Hour))
+
+	entries, err := s.QueryAudit(AuditFilter{})
+	if err != nil {
+		t.Fatalf("QueryAudit failed: %v", err)
+	}
+	want := []struct {
+		action string
+		actor  Actor
+		id     int64
+	}{
+		{AuditClipDeleted, SystemJob(JobTrashPurge), b.ID},
+		{AuditClipRestored, Plugin(7), a.ID},
+		{AuditClipTrashed, User, b.ID},
+		{AuditClipTrashed, User, a.ID},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("Expected %d entries, got %+v", len(want), entries)
+	}
+	for i, w := range want {
+		e := entries[i]
+		if e.Action != w.action || e.Actor != w.actor.Kind || e.PluginID != w.actor.PluginID ||
+			e.Job != w.actor.Job || e.TargetType != TargetClip || e.TargetID != w.id {
+			t.Errorf("Entry %d: expected %s of clip %d by %+v, got %+v", i, w.action, w.id, w.actor, e)
+		}
+	}
+}
+
+func TestQueryAudit_Filters(t *testing.T) {
+	s, _ := newTestService(t)
+
+	s.Audit(User, AuditPermissionGranted, TargetPlugin, 3, map[string]interface{}{"permission": "fs_read"})
+	s.Audit(Plugin(3), AuditClipDeleted, TargetClip, 10, nil)
+	s.Audit(User, AuditBackupCreated, TargetBackup, 0, map[string]interface{}{"path": "/tmp/b.zip"})
+
+	byPlugin, _ := s.QueryAudit(AuditFilter{PluginID: 3})
+	if len(byPlugin) != 2 {
+		t.Errorf("Expected entries made by or about plugin 3, got %+v", byPlugin)
+	}
+
+	backups, _ := s.QueryAudit(AuditFilter{Action: AuditBackupCreated})
+	if len(backups) != 1 || backups[0].Details["path"] != "/tmp/b.zip" {
+		t.Errorf("Expected the backup entry with its details, got %+v", backups)
+	}
+
+	page, _ := s.QueryAudit(AuditFilter{Limit: 1, Offset: 1})
+	if len(page) != 1 || page[0].Action != AuditClipDeleted {
+		t.Errorf("Expected the second newest entry, got %+v", page)
+	}
+
+	future, _ := s.QueryAudit(AuditFilter{Since: time.Now().Add(time.Hour).Unix()})
+	if len(future) != 0 {
+		t.Errorf("Expected no entries after now, got %+v", future)
+	}
+}
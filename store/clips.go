@@ -0,0 +1,472 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// maxContentTypeLength is the maximum length for a MIME content type string
+	maxContentTypeLength = 256
+
+	// Limits for metadata attached to clips
+	MaxMetadataKeyLength   = 64
+	MaxMetadataValueLength = 4096
+)
+
+// validMIMEType matches standard MIME type format (e.g. "application/json", "image/png")
+var validMIMEType = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9!#$&\-^_.+]*\/[a-zA-Z0-9][a-zA-Z0-9!#$&\-^_.+]*$`)
+
+// NewClip holds the fields of a clip to create
+type NewClip struct {
+	ContentType string // empty or "text/plain" is sniffed for HTML and JSON
+	Data        []byte
+	Filename    string
+	ExpiresAt   *time.Time
+	ParentID    int64 // clip this one was derived from, 0 for none
+	Source      Source
+}
+
+// Clip is a created clip
+type Clip struct {
+	ID          int64
+	ContentType string
+	Filename    string
+}
+
+// ValidateContentType returns ct if it is a well-formed MIME type,
+// otherwise application/octet-stream
+func ValidateContentType(ct string) string {
+	if len(ct) > maxContentTypeLength {
+		return "application/octet-stream"
+	}
+	if !validMIMEType.MatchString(ct) {
+		return "application/octet-stream"
+	}
+	return ct
+}
+
+// DetectContentType refines plain text into HTML or JSON and validates
+// any other content type
+func DetectContentType(contentType string, data []byte) string {
+	if contentType != "text/plain" && contentType != "" {
+		return ValidateContentType(contentType)
+	}
+
+	trimmedText := strings.TrimSpace(string(data))
+	if strings.HasPrefix(trimmedText, "<!DOCTYPE html") {
+		return "text/html"
+	}
+	if isJSON(trimmedText) {
+		return "application/json"
+	}
+	return "text/plain"
+}
+
+// isJSON checks if a string is valid JSON
+func isJSON(s string) bool {
+	var js json.RawMessage
+	return json.Unmarshal([]byte(s), &js) == nil
+}
+
+// CreateClip inserts a clip and emits clip:created
+func (s *Service) CreateClip(actor Actor, c NewClip) (*Clip, error) {
+	contentType := DetectContentType(c.ContentType, c.Data)
+
+	var parentID sql.NullInt64
+	if c.ParentID != 0 {
+		if !s.clipExists(c.ParentID) {
+			return nil, fmt.Errorf("parent clip %d not found", c.ParentID)
+		}
+		parentID = sql.NullInt64{Int64: c.ParentID, Valid: true}
+	}
+
+	secrets, err := s.checkSecrets(contentType, c.Data)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := s.SecretPolicy().secretExpiration(secrets, c.ExpiresAt)
+
+	src := sourceFor(actor, c.Source)
+	info := analyzeContent(contentType, c.Filename, c.Data)
+	data, encrypted, err := s.seal(c.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.db.Exec(`INSERT INTO clips (content_type, data, is_encrypted, filename, expires_at, parent_id,
+		source_kind, source_path, source_url, source_plugin_id, source_action, source_mtime,
+		width, height, line_count, language, secrets)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		contentType, data, boolToInt(encrypted), c.Filename, expiresAt, parentID,
+		src.Kind, nullString(src.Path), nullString(src.URL), nullInt64(src.PluginID), nullString(src.Action), src.ModTime,
+		nullInt64(int64(info.Width)), nullInt64(int64(info.Height)), nullInt64(int64(info.LineCount)), nullString(info.Language),
+		joinSecrets(secrets))
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert into db: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inserted ID: %w", err)
+	}
+
+	s.emit(actor, "clip:created", map[string]interface{}{
+		"id":           id,
+		"content_type": contentType,
+		"filename":     c.Filename,
+	})
+
+	return &Clip{ID: id, ContentType: contentType, Filename: c.Filename}, nil
+}
+
+// DeleteClip deletes a clip and any tags left without clips
+func (s *Service) DeleteClip(actor Actor, id int64) error {
+	return s.DeleteClips(actor, []int64{id})
+}
+
+// DeleteClips deletes clips, emitting clip:deleted for each one that existed,
+// and removes tags left without clips
+func (s *Service) DeleteClips(actor Actor, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	marks, args := placeholders(ids)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Get all tag IDs associated with these clips before deleting
+	tagIDs, err := queryIDs(tx, "SELECT DISTINCT tag_id FROM clip_tags WHERE clip_id IN ("+marks+")", args...)
+	if err != nil {
+		return fmt.Errorf("failed to query clip tags: %w", err)
+	}
+	existing, err := queryIDs(tx, "SELECT id FROM clips WHERE id IN ("+marks+")", args...)
+	if err != nil {
+		return fmt.Errorf("failed to query clips: %w", err)
+	}
+
+	// Explicitly delete dependent rows (don't rely on CASCADE)
+	for _, table := range []string{"clip_tags", "clip_metadata", "clip_revisions", "collection_clips", "saved_search_matches"} {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE clip_id IN ("+marks+")", args...); err != nil {
+			return fmt.Errorf("failed to delete from %s: %w", table, err)
+		}
+	}
+	// Clips derived from deleted ones lose their link
+	if _, err := tx.Exec("UPDATE clips SET parent_id = NULL WHERE parent_id IN ("+marks+")", args...); err != nil {
+		return fmt.Errorf("failed to unlink derived clips: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM clips WHERE id IN ("+marks+")", args...); err != nil {
+		return fmt.Errorf("failed to delete clips: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	for _, id := range existing {
+		s.emit(actor, "clip:deleted", id)
+	}
+
+	// Clean up orphaned tags
+	for _, tagID := range tagIDs {
+		s.deleteTagIfOrphaned(actor, tagID)
+	}
+	return nil
+}
+
+// SetArchived archives or unarchives a clip, emitting clip:archived or
+// clip:unarchived if its state changed
+func (s *Service) SetArchived(actor Actor, id int64, archived bool) error {
+	result, err := s.db.Exec("UPDATE clips SET is_archived = ? WHERE id = ? AND is_archived != ? AND deleted_at IS NULL",
+		boolToInt(archived), id, boolToInt(archived))
+	if err != nil {
+		return fmt.Errorf("failed to update archive state: %w", err)
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		if !s.clipExists(id) {
+			return ErrClipNotFound
+		}
+		return nil
+	}
+
+	s.emitArchived(actor, id, archived)
+	return nil
+}
+
+// ToggleArchive flips the archived state of clips
+func (s *Service) ToggleArchive(actor Actor, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	marks, args := placeholders(ids)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE clips SET is_archived = NOT is_archived WHERE deleted_at IS NULL AND id IN ("+marks+")", args...); err != nil {
+		return fmt.Errorf("failed to toggle archive: %w", err)
+	}
+
+	// Read back the new states for the events
+	rows, err := tx.Query("SELECT id, is_archived FROM clips WHERE deleted_at IS NULL AND id IN ("+marks+")", args...)
+	if err != nil {
+		return fmt.Errorf("failed to query archive state: %w", err)
+	}
+	states := make(map[int64]bool)
+	for rows.Next() {
+		var id int64
+		var isArchived int
+		if err := rows.Scan(&id, &isArchived); err == nil {
+			states[id] = isArchived == 1
+		}
+	}
+	rows.Close()
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	for _, id := range ids {
+		if archived, ok := states[id]; ok {
+			s.emitArchived(actor, id, archived)
+		}
+	}
+	return nil
+}
+
+func (s *Service) emitArchived(actor Actor, id int64, archived bool) {
+	event := "clip:unarchived"
+	if archived {
+		event = "clip:archived"
+	}
+	s.emit(actor, event, map[string]interface{}{
+		"id": id,
+	})
+}
+
+// CancelExpiration removes the expiration of a clip
+func (s *Service) CancelExpiration(actor Actor, id int64) error {
+	_, err := s.db.Exec("UPDATE clips SET expires_at = NULL WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to cancel expiration: %w", err)
+	}
+	return nil
+}
+
+func (s *Service) clipExists(id int64) bool {
+	var exists int
+	return s.db.QueryRow("SELECT 1 FROM clips WHERE id = ? AND deleted_at IS NULL", id).Scan(&exists) == nil
+}
+
+// querier is implemented by *sql.DB and *sql.Tx
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// queryIDs runs a query returning a single integer column
+func queryIDs(q querier, query string, args ...interface{}) ([]int64, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids, rows.Err()
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// ClipUpdate holds the changes to a clip. Nil fields are left unchanged.
+type ClipUpdate struct {
+	Data            []byte
+	ContentType     *string
+	Filename        *string
+	ExpiresAt       *time.Time
+	ClearExpiration bool
+	Metadata        map[string]string // an empty value removes the key
+}
+
+// UpdateClip applies changes to a clip and emits clip:updated with the list of
+// changed fields. The previous data, content type and filename are kept in
+// clip_revisions so edits can be undone.
+func (s *Service) UpdateClip(actor Actor, id int64, u ClipUpdate) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	updated, err := s.updateClip(tx, id, u)
+	if err != nil || updated == nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	s.emit(actor, "clip:updated", updated)
+	return nil
+}
+
+// updateClip applies u within tx and returns the clip:updated payload, or nil
+// if nothing changed
+func (s *Service) updateClip(tx *sql.Tx, id int64, u ClipUpdate) (map[string]interface{}, error) {
+	var contentType string
+	var data []byte
+	var encrypted bool
+	var filename, oldSecrets sql.NullString
+	var oldExpiresAt sql.NullTime
+	err := tx.QueryRow("SELECT content_type, data, is_encrypted, filename, secrets, expires_at FROM clips WHERE id = ? AND deleted_at IS NULL", id).
+		Scan(&contentType, &data, &encrypted, &filename, &oldSecrets, &oldExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrClipNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query clip: %w", err)
+	}
+	if data, err = s.DecryptData(data, encrypted); err != nil {
+		return nil, err
+	}
+
+	newData, newContentType, newFilename := data, contentType, filename.String
+	var changes []string
+	if u.Data != nil {
+		newData = u.Data
+		changes = append(changes, "data")
+	}
+	if u.ContentType != nil {
+		newContentType = DetectContentType(*u.ContentType, newData)
+		if newContentType != contentType {
+			changes = append(changes, "content_type")
+		}
+	}
+	if u.Filename != nil && *u.Filename != filename.String {
+		newFilename = *u.Filename
+		changes = append(changes, "filename")
+	}
+
+	expiresAt, setExpiration := u.ExpiresAt, u.ExpiresAt != nil || u.ClearExpiration
+	if len(changes) > 0 {
+		secrets, err := s.checkSecrets(newContentType, newData)
+		if err != nil {
+			return nil, err
+		}
+		// A clip that starts holding secrets expires like a new clip with
+		// them would, keeping an earlier expiration
+		if len(secrets) > 0 && len(ParseSecrets(oldSecrets)) == 0 {
+			if !setExpiration && oldExpiresAt.Valid {
+				expiresAt = &oldExpiresAt.Time
+			}
+			if policyExpiresAt := s.SecretPolicy().secretExpiration(secrets, expiresAt); policyExpiresAt != expiresAt {
+				expiresAt, setExpiration = policyExpiresAt, true
+			}
+		}
+		old := revisionContent{ContentType: contentType, Data: data, Filename: filename}
+		if err := s.recordRevision(tx, id, old, newContentType, newData); err != nil {
+			return nil, err
+		}
+		info := analyzeContent(newContentType, newFilename, newData)
+		stored, encrypted, err := s.seal(newData)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(`UPDATE clips SET content_type = ?, data = ?, is_encrypted = ?, filename = ?,
+			width = ?, height = ?, line_count = ?, language = ?, secrets = ? WHERE id = ?`,
+			newContentType, stored, boolToInt(encrypted), newFilename,
+			nullInt64(int64(info.Width)), nullInt64(int64(info.Height)), nullInt64(int64(info.LineCount)), nullString(info.Language),
+			joinSecrets(secrets), id); err != nil {
+			return nil, fmt.Errorf("failed to update clip: %w", err)
+		}
+	}
+
+	if setExpiration {
+		if _, err := tx.Exec("UPDATE clips SET expires_at = ? WHERE id = ?", expiresAt, id); err != nil {
+			return nil, fmt.Errorf("failed to update expiration: %w", err)
+		}
+		changes = append(changes, "expires_at")
+	}
+
+	if len(u.Metadata) > 0 {
+		if err := setMetadata(tx, id, u.Metadata); err != nil {
+			return nil, err
+		}
+		changes = append(changes, "metadata")
+	}
+
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	return map[string]interface{}{
+		"id":           id,
+		"content_type": newContentType,
+		"filename":     newFilename,
+		"changes":      changes,
+	}, nil
+}
+
+// setMetadata stores metadata values, removing keys set to ""
+func setMetadata(tx *sql.Tx, clipID int64, metadata map[string]string) error {
+	for key, value := range metadata {
+		if key == "" || len(key) > MaxMetadataKeyLength {
+			return fmt.Errorf("invalid metadata key %q (1-%d characters)", key, MaxMetadataKeyLength)
+		}
+		if len(value) > MaxMetadataValueLength {
+			return fmt.Errorf("metadata %s too long (max %d characters)", key, MaxMetadataValueLength)
+		}
+
+		var err error
+		if value == "" {
+			_, err = tx.Exec("DELETE FROM clip_metadata WHERE clip_id = ? AND key = ?", clipID, key)
+		} else {
+			_, err = tx.Exec(`INSERT INTO clip_metadata (clip_id, key, value) VALUES (?, ?, ?)
+				ON CONFLICT(clip_id, key) DO UPDATE SET value = excluded.value`, clipID, key, value)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to set metadata %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// GetMetadata returns the metadata attached to a clip
+func (s *Service) GetMetadata(clipID int64) (map[string]string, error) {
+	rows, err := s.db.Query("SELECT key, value FROM clip_metadata WHERE clip_id = ?", clipID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query metadata: %w", err)
+	}
+	defer rows.Close()
+
+	metadata := make(map[string]string)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err == nil {
+			metadata[key] = value
+		}
+	}
+	return metadata, rows.Err()
+}
@@ -0,0 +1,335 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Collection limits
+const (
+	MaxCollectionNameLength        = 100
+	MaxCollectionDescriptionLength = 1000
+)
+
+var ErrCollectionNotFound = errors.New("collection not found")
+
+// Collection is a named, ordered group of clips. Unlike tags, collections are
+// curated and are never deleted when their last clip is removed.
+type Collection struct {
+	ID          int64
+	Name        string
+	Description string
+	Count       int // number of clips in the collection
+	CreatedAt   time.Time
+}
+
+// validateCollection trims a collection's name and description and checks their length
+func validateCollection(name, description string) (string, string, error) {
+	name = strings.TrimSpace(name)
+	description = strings.TrimSpace(description)
+	if name == "" {
+		return "", "", fmt.Errorf("collection name cannot be empty")
+	}
+	if len(name) > MaxCollectionNameLength {
+		return "", "", fmt.Errorf("collection name too long (max %d characters)", MaxCollectionNameLength)
+	}
+	if len(description) > MaxCollectionDescriptionLength {
+		return "", "", fmt.Errorf("collection description too long (max %d characters)", MaxCollectionDescriptionLength)
+	}
+	return name, description, nil
+}
+
+// CreateCollection creates an empty collection and emits collection:created
+func (s *Service) CreateCollection(actor Actor, name, description string) (*Collection, error) {
+	name, description, err := validateCollection(name, description)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.db.Exec("INSERT INTO collections (name, description) VALUES (?, ?)", name, description)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return nil, fmt.Errorf("collection already exists: %s", name)
+		}
+		return nil, fmt.Errorf("failed to create collection: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get collection ID: %w", err)
+	}
+
+	s.emit(actor, "collection:created", map[string]interface{}{
+		"id":          id,
+		"name":        name,
+		"description": description,
+	})
+	return s.GetCollection(id)
+}
+
+// UpdateCollection renames a collection, sets its description and emits
+// collection:updated
+func (s *Service) UpdateCollection(actor Actor, id int64, name, description string) error {
+	name, description, err := validateCollection(name, description)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.Exec("UPDATE collections SET name = ?, description = ? WHERE id = ?", name, description, id)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return fmt.Errorf("collection name already exists: %s", name)
+		}
+		return fmt.Errorf("failed to update collection: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrCollectionNotFound
+	}
+
+	s.emit(actor, "collection:updated", map[string]interface{}{
+		"id":          id,
+		"name":        name,
+		"description": description,
+	})
+	return nil
+}
+
+// DeleteCollection deletes a collection and emits collection:deleted. Its
+// clips are not deleted.
+func (s *Service) DeleteCollection(actor Actor, id int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM collection_clips WHERE collection_id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete from collection_clips: %w", err)
+	}
+	result, err := tx.Exec("DELETE FROM collections WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete collection: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if n, _ := result.RowsAffected(); n > 0 {
+		s.emit(actor, "collection:deleted", id)
+	}
+	return nil
+}
+
+// AddToCollection appends clips to the end of a collection, emitting
+// collection:clip_added for each clip that wasn't in it yet. Clips that don't
+// exist are skipped.
+func (s *Service) AddToCollection(actor Actor, collectionID int64, clipIDs []int64) error {
+	if !s.collectionExists(collectionID) {
+		return ErrCollectionNotFound
+	}
+	if len(clipIDs) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var next int
+	if err := tx.QueryRow("SELECT COALESCE(MAX(position) + 1, 0) FROM collection_clips WHERE collection_id = ?",
+		collectionID).Scan(&next); err != nil {
+		return fmt.Errorf("failed to query collection positions: %w", err)
+	}
+
+	stmt, err := tx.Prepare(`INSERT OR IGNORE INTO collection_clips (collection_id, clip_id, position)
+		SELECT ?, id, ? FROM clips WHERE id = ? AND deleted_at IS NULL`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	var added []int64
+	for _, clipID := range clipIDs {
+		result, err := stmt.Exec(collectionID, next, clipID)
+		if err != nil {
+			return fmt.Errorf("failed to add clip %d to collection: %w", clipID, err)
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			added = append(added, clipID)
+			next++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	for _, clipID := range added {
+		s.emit(actor, "collection:clip_added", map[string]interface{}{
+			"collection_id": collectionID,
+			"clip_id":       clipID,
+		})
+	}
+	return nil
+}
+
+// RemoveFromCollection removes clips from a collection, emitting
+// collection:clip_removed for each clip that was in it. The collection is
+// kept even when it becomes empty.
+func (s *Service) RemoveFromCollection(actor Actor, collectionID int64, clipIDs []int64) error {
+	if !s.collectionExists(collectionID) {
+		return ErrCollectionNotFound
+	}
+	if len(clipIDs) == 0 {
+		return nil
+	}
+	marks, args := placeholders(clipIDs)
+	args = append([]interface{}{collectionID}, args...)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	removed, err := queryIDs(tx, "SELECT clip_id FROM collection_clips WHERE collection_id = ? AND clip_id IN ("+marks+")", args...)
+	if err != nil {
+		return fmt.Errorf("failed to query collection clips: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM collection_clips WHERE collection_id = ? AND clip_id IN ("+marks+")", args...); err != nil {
+		return fmt.Errorf("failed to remove clips from collection: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	for _, clipID := range removed {
+		s.emit(actor, "collection:clip_removed", map[string]interface{}{
+			"collection_id": collectionID,
+			"clip_id":       clipID,
+		})
+	}
+	return nil
+}
+
+// ReorderCollection moves clips of a collection to the front in the order of
+// clipIDs; the other clips follow in their current order. Clips that aren't in
+// the collection are skipped. No event is emitted.
+func (s *Service) ReorderCollection(actor Actor, collectionID int64, clipIDs []int64) error {
+	if !s.collectionExists(collectionID) {
+		return ErrCollectionNotFound
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	current, err := queryIDs(tx, "SELECT clip_id FROM collection_clips WHERE collection_id = ? ORDER BY position", collectionID)
+	if err != nil {
+		return fmt.Errorf("failed to query collection clips: %w", err)
+	}
+	member := make(map[int64]bool, len(current))
+	for _, id := range current {
+		member[id] = true
+	}
+
+	order := make([]int64, 0, len(current))
+	for _, id := range clipIDs {
+		if member[id] {
+			order = append(order, id)
+			member[id] = false // placed, skip later duplicates
+		}
+	}
+	for _, id := range current {
+		if member[id] {
+			order = append(order, id)
+		}
+	}
+
+	stmt, err := tx.Prepare("UPDATE collection_clips SET position = ? WHERE collection_id = ? AND clip_id = ?")
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for i, id := range order {
+		if _, err := stmt.Exec(i, collectionID, id); err != nil {
+			return fmt.Errorf("failed to set position of clip %d: %w", id, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+const collectionColumns = `SELECT c.id, c.name, c.description, c.created_at,
+	(SELECT COUNT(*) FROM collection_clips cc INNER JOIN clips ON clips.id = cc.clip_id
+		WHERE cc.collection_id = c.id AND clips.deleted_at IS NULL)
+	FROM collections c`
+
+// ListCollections returns all collections by name
+func (s *Service) ListCollections() ([]Collection, error) {
+	return s.queryCollections(collectionColumns + " ORDER BY c.name")
+}
+
+// ClipCollections returns the collections a clip belongs to, by name
+func (s *Service) ClipCollections(clipID int64) ([]Collection, error) {
+	return s.queryCollections(collectionColumns+`
+		WHERE c.id IN (SELECT collection_id FROM collection_clips WHERE clip_id = ?)
+		ORDER BY c.name`, clipID)
+}
+
+// GetCollection returns a collection by ID
+func (s *Service) GetCollection(id int64) (*Collection, error) {
+	collections, err := s.queryCollections(collectionColumns+" WHERE c.id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(collections) == 0 {
+		return nil, ErrCollectionNotFound
+	}
+	return &collections[0], nil
+}
+
+// CollectionClips returns the IDs of the clips in a collection, in order
+func (s *Service) CollectionClips(collectionID int64) ([]int64, error) {
+	if !s.collectionExists(collectionID) {
+		return nil, ErrCollectionNotFound
+	}
+	return queryIDs(s.db, `SELECT cc.clip_id FROM collection_clips cc INNER JOIN clips c ON c.id = cc.clip_id
+		WHERE cc.collection_id = ? AND c.deleted_at IS NULL ORDER BY cc.position`, collectionID)
+}
+
+func (s *Service) queryCollections(query string, args ...interface{}) ([]Collection, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query collections: %w", err)
+	}
+	defer rows.Close()
+
+	var collections []Collection
+	for rows.Next() {
+		var c Collection
+		var description sql.NullString
+		if err := rows.Scan(&c.ID, &c.Name, &description, &c.CreatedAt, &c.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan collection: %w", err)
+		}
+		c.Description = description.String
+		collections = append(collections, c)
+	}
+	return collections, rows.Err()
+}
+
+func (s *Service) collectionExists(id int64) bool {
+	var exists int
+	return s.db.QueryRow("SELECT 1 FROM collections WHERE id = ?", id).Scan(&exists) == nil
+}
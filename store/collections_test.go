@@ -0,0 +1,98 @@
+package store
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCollections_Membership(t *testing.T) {
+	s, rec := newTestService(t)
+
+	a, _ := s.CreateClip(User, NewClip{Data: []byte("a")})
+	b, _ := s.CreateClip(User, NewClip{Data: []byte("b")})
+	c, _ := s.CreateClip(User, NewClip{Data: []byte("c")})
+
+	coll, err := s.CreateCollection(User, "  Moodboard ", "Ideas")
+	if err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+	if coll.Name != "Moodboard" || coll.Description != "Ideas" {
+		t.Errorf("Unexpected collection: %+v", coll)
+	}
+	if _, err := s.CreateCollection(User, "Moodboard", ""); err == nil {
+		t.Error("Expected error for duplicate collection name")
+	}
+	rec.names()
+
+	// Adding twice or adding a missing clip changes nothing
+	s.AddToCollection(User, coll.ID, []int64{b.ID, a.ID})
+	s.AddToCollection(User, coll.ID, []int64{a.ID, c.ID, 999})
+	ids, _ := s.CollectionClips(coll.ID)
+	if !equalIDs(ids, []int64{b.ID, a.ID, c.ID}) {
+		t.Errorf("Expected clips in insertion order, got %v", ids)
+	}
+
+	// Listed clips move to the front, the others keep their order
+	s.ReorderCollection(User, coll.ID, []int64{c.ID, 999})
+	ids, _ = s.CollectionClips(coll.ID)
+	if !equalIDs(ids, []int64{c.ID, b.ID, a.ID}) {
+		t.Errorf("Unexpected order after reorder: %v", ids)
+	}
+
+	// Unlike tags, emptied collections are kept
+	s.RemoveFromCollection(User, coll.ID, []int64{a.ID, b.ID})
+	s.DeleteClip(User, c.ID)
+	got, err := s.GetCollection(coll.ID)
+	if err != nil {
+		t.Fatalf("Expected empty collection to be kept: %v", err)
+	}
+	if got.Count != 0 {
+		t.Errorf("Expected empty collection, got %d clips", got.Count)
+	}
+
+	want := []string{
+		"collection:clip_added", "collection:clip_added", "collection:clip_added",
+		"collection:clip_removed", "collection:clip_removed", "clip:deleted",
+	}
+	if got := rec.names(); !equalNames(got, want) {
+		t.Errorf("Expected events %v, got %v", want, got)
+	}
+
+	if err := s.AddToCollection(User, 999, []int64{a.ID}); !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("Expected ErrCollectionNotFound, got %v", err)
+	}
+}
+
+func TestCollections_UpdateAndDelete(t *testing.T) {
+	s, rec := newTestService(t)
+
+	clip, _ := s.CreateClip(User, NewClip{Data: []byte("a")})
+	coll, _ := s.CreateCollection(User, "Drafts", "")
+	s.AddToCollection(User, coll.ID, []int64{clip.ID})
+
+	if err := s.UpdateCollection(User, coll.ID, "Final", "Ready to ship"); err != nil {
+		t.Fatalf("UpdateCollection failed: %v", err)
+	}
+	if err := s.UpdateCollection(User, 999, "x", ""); !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("Expected ErrCollectionNotFound, got %v", err)
+	}
+	collections, _ := s.ClipCollections(clip.ID)
+	if len(collections) != 1 || collections[0].Name != "Final" || collections[0].Count != 1 {
+		t.Errorf("Unexpected clip collections: %+v", collections)
+	}
+	rec.names()
+
+	// Deleting a collection keeps its clips
+	if err := s.DeleteCollection(User, coll.ID); err != nil {
+		t.Fatalf("DeleteCollection failed: %v", err)
+	}
+	if !s.clipExists(clip.ID) {
+		t.Error("Expected clip to be kept")
+	}
+	if collections, _ := s.ClipCollections(clip.ID); len(collections) != 0 {
+		t.Errorf("Expected no collections, got %+v", collections)
+	}
+	if got := rec.names(); !equalNames(got, []string{"collection:deleted"}) {
+		t.Errorf("Expected collection:deleted, got %v", got)
+	}
+}
@@ -0,0 +1,123 @@
+package store
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+)
+
+// Line diff operations
+const (
+	DiffEqual  = "equal"
+	DiffInsert = "insert"
+	DiffDelete = "delete"
+)
+
+// maxDiffCells caps the size of the LCS table. Larger inputs are shown as a
+// full replacement instead of a line-by-line diff.
+const maxDiffCells = 4_000_000
+
+var errInvalidDelta = errors.New("invalid revision delta")
+
+// DiffLine is one line of a diff between two texts
+type DiffLine struct {
+	Op   string
+	Text string
+}
+
+// makeDelta encodes target relative to base as the lengths of their common
+// prefix and suffix plus the bytes in between. Text edits usually touch one
+// region, so this is far smaller than the full text.
+func makeDelta(base, target []byte) []byte {
+	prefix := 0
+	for prefix < len(base) && prefix < len(target) && base[prefix] == target[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(base)-prefix && suffix < len(target)-prefix &&
+		base[len(base)-1-suffix] == target[len(target)-1-suffix] {
+		suffix++
+	}
+
+	middle := target[prefix : len(target)-suffix]
+	delta := make([]byte, 0, 2*binary.MaxVarintLen64+len(middle))
+	delta = binary.AppendUvarint(delta, uint64(prefix))
+	delta = binary.AppendUvarint(delta, uint64(suffix))
+	return append(delta, middle...)
+}
+
+// applyDelta rebuilds the target that delta was made from
+func applyDelta(base, delta []byte) ([]byte, error) {
+	prefix, n := binary.Uvarint(delta)
+	if n <= 0 {
+		return nil, errInvalidDelta
+	}
+	delta = delta[n:]
+	suffix, n := binary.Uvarint(delta)
+	if n <= 0 || prefix+suffix > uint64(len(base)) {
+		return nil, errInvalidDelta
+	}
+	middle := delta[n:]
+
+	out := make([]byte, 0, int(prefix)+len(middle)+int(suffix))
+	out = append(out, base[:prefix]...)
+	out = append(out, middle...)
+	return append(out, base[uint64(len(base))-suffix:]...), nil
+}
+
+// diffLines returns the line diff turning a into b
+func diffLines(a, b string) []DiffLine {
+	x, y := strings.Split(a, "\n"), strings.Split(b, "\n")
+
+	// Trim common lines at both ends so the table only covers the edit
+	var head, tail []DiffLine
+	for len(x) > 0 && len(y) > 0 && x[0] == y[0] {
+		head = append(head, DiffLine{DiffEqual, x[0]})
+		x, y = x[1:], y[1:]
+	}
+	for len(x) > 0 && len(y) > 0 && x[len(x)-1] == y[len(y)-1] {
+		tail = append([]DiffLine{{DiffEqual, x[len(x)-1]}}, tail...)
+		x, y = x[:len(x)-1], y[:len(y)-1]
+	}
+
+	result := head
+	if (len(x)+1)*(len(y)+1) > maxDiffCells {
+		for _, line := range x {
+			result = append(result, DiffLine{DiffDelete, line})
+		}
+		for _, line := range y {
+			result = append(result, DiffLine{DiffInsert, line})
+		}
+		return append(result, tail...)
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of x[i:] and y[j:]
+	cols := len(y) + 1
+	lcs := make([]int32, (len(x)+1)*cols)
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i*cols+j] = lcs[(i+1)*cols+j+1] + 1
+			} else {
+				lcs[i*cols+j] = max(lcs[(i+1)*cols+j], lcs[i*cols+j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			result = append(result, DiffLine{DiffEqual, x[i]})
+			i++
+			j++
+		case i < len(x) && (j == len(y) || lcs[(i+1)*cols+j] >= lcs[i*cols+j+1]):
+			result = append(result, DiffLine{DiffDelete, x[i]})
+			i++
+		default:
+			result = append(result, DiffLine{DiffInsert, y[j]})
+			j++
+		}
+	}
+	return append(result, tail...)
+}
@@ -0,0 +1,604 @@
+package store
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Clip data is encrypted with envelope encryption: a random data key encrypts
+// the data of clips and revisions with AES-256-GCM, and the data key is stored
+// in the encryption table wrapped by a master key. The master key is derived
+// from a passphrase or read from a key file and is never stored, so changing
+// it only rewraps the data key.
+
+// Master key sources
+const (
+	KeySourcePassphrase = "passphrase"
+	KeySourceKeyFile    = "key_file"
+)
+
+const (
+	// KeyFileSize is the number of random bytes in a generated key file
+	KeyFileSize = 32
+
+	// MinPassphraseLength is the minimum length of a passphrase
+	MinPassphraseLength = 8
+
+	keySize  = 32 // AES-256
+	saltSize = 16
+
+	// sealOverhead is the number of bytes encryption adds to clip data: the
+	// GCM nonce and tag
+	sealOverhead = 12 + 16
+)
+
+// passphraseIterations is the PBKDF2-SHA256 work factor for new passphrases.
+// Each key records its own, so this can be raised without breaking old ones.
+var passphraseIterations = 600_000
+
+var (
+	ErrLocked             = errors.New("clip database is locked")
+	ErrWrongKey           = errors.New("wrong passphrase or key file")
+	ErrEncryptionEnabled  = errors.New("encryption is already enabled")
+	ErrEncryptionDisabled = errors.New("encryption is not enabled")
+)
+
+// wrappedKeyLabel is authenticated along with the wrapped data key
+var wrappedKeyLabel = []byte("mahpastes data key")
+
+// MasterKey is a passphrase or the path of a key file, exactly one of which
+// must be set
+type MasterKey struct {
+	Passphrase string `json:"passphrase"`
+	KeyFile    string `json:"key_file"`
+}
+
+// source validates the key and returns where it comes from
+func (m MasterKey) source() (string, error) {
+	switch {
+	case m.Passphrase != "" && m.KeyFile != "":
+		return "", fmt.Errorf("use either a passphrase or a key file, not both")
+	case m.KeyFile != "":
+		return KeySourceKeyFile, nil
+	case len(m.Passphrase) >= MinPassphraseLength:
+		return KeySourcePassphrase, nil
+	case m.Passphrase != "":
+		return "", fmt.Errorf("passphrase must be at least %d characters", MinPassphraseLength)
+	default:
+		return "", fmt.Errorf("a passphrase or key file is required")
+	}
+}
+
+// EncryptionStatus describes whether clip data is encrypted and can be read
+type EncryptionStatus struct {
+	Enabled   bool   `json:"enabled"`
+	Locked    bool   `json:"locked"`     // the master key hasn't been given yet
+	KeySource string `json:"key_source"` // KeySourcePassphrase or KeySourceKeyFile
+	KeyFile   string `json:"key_file"`   // key file used to unlock on startup
+}
+
+// keyring holds the unwrapped data key while the database is unlocked
+type keyring struct {
+	mu      sync.RWMutex
+	enabled bool
+	aead    cipher.AEAD // nil while locked
+	dataKey []byte      // nil while locked, see DeriveKey
+}
+
+// encryptionConfig is the row of the encryption table
+type encryptionConfig struct {
+	KeySource  string
+	KeyFile    string
+	Salt       []byte
+	Iterations int
+	WrappedKey []byte
+}
+
+// loadEncryptionConfig returns the encryption settings, or nil if encryption
+// is disabled
+func loadEncryptionConfig(q querier) (*encryptionConfig, error) {
+	var c encryptionConfig
+	var keyFile sql.NullString
+	var iterations sql.NullInt64
+	err := q.QueryRow("SELECT key_source, key_file, salt, iterations, wrapped_key FROM encryption WHERE id = 1").
+		Scan(&c.KeySource, &keyFile, &c.Salt, &iterations, &c.WrappedKey)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encryption settings: %w", err)
+	}
+	c.KeyFile = keyFile.String
+	c.Iterations = int(iterations.Int64)
+	return &c, nil
+}
+
+// newEncryptionConfig wraps dataKey with a master key, using a fresh salt
+func newEncryptionConfig(master MasterKey, dataKey []byte) (*encryptionConfig, error) {
+	source, err := master.source()
+	if err != nil {
+		return nil, err
+	}
+	c := &encryptionConfig{KeySource: source, KeyFile: master.KeyFile, Salt: make([]byte, saltSize)}
+	if source == KeySourcePassphrase {
+		c.Iterations = passphraseIterations
+	}
+	if _, err := rand.Read(c.Salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	kek, err := c.masterKey(master)
+	if err != nil {
+		return nil, err
+	}
+	c.WrappedKey, err = sealWith(kek, dataKey, wrappedKeyLabel)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// masterKey derives the key that wraps the data key
+func (c *encryptionConfig) masterKey(master MasterKey) (cipher.AEAD, error) {
+	source, err := master.source()
+	if err != nil {
+		return nil, err
+	}
+	if source != c.KeySource {
+		if c.KeySource == KeySourceKeyFile {
+			return nil, fmt.Errorf("%w: this database is unlocked with a key file", ErrWrongKey)
+		}
+		return nil, fmt.Errorf("%w: this database is unlocked with a passphrase", ErrWrongKey)
+	}
+
+	var key []byte
+	if source == KeySourcePassphrase {
+		key, err = pbkdf2.Key(sha256.New, master.Passphrase, c.Salt, c.Iterations, keySize)
+	} else {
+		var secret []byte
+		if secret, err = os.ReadFile(master.KeyFile); err != nil {
+			return nil, fmt.Errorf("failed to read key file: %w", err)
+		}
+		if len(secret) < KeyFileSize {
+			return nil, fmt.Errorf("key file must hold at least %d bytes", KeyFileSize)
+		}
+		key, err = hkdf.Key(sha256.New, secret, c.Salt, "mahpastes key file", keySize)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive master key: %w", err)
+	}
+	return newAEAD(key)
+}
+
+// unwrap returns the data key
+func (c *encryptionConfig) unwrap(master MasterKey) ([]byte, error) {
+	kek, err := c.masterKey(master)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := openWith(kek, c.WrappedKey, wrappedKeyLabel)
+	if err != nil {
+		return nil, ErrWrongKey
+	}
+	return dataKey, nil
+}
+
+func (c *encryptionConfig) save(tx *sql.Tx) error {
+	if _, err := tx.Exec(`INSERT INTO encryption (id, key_source, key_file, salt, iterations, wrapped_key)
+		VALUES (1, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET key_source = excluded.key_source, key_file = excluded.key_file,
+			salt = excluded.salt, iterations = excluded.iterations, wrapped_key = excluded.wrapped_key`,
+		c.KeySource, nullString(c.KeyFile), c.Salt, nullInt64(int64(c.Iterations)), c.WrappedKey); err != nil {
+		return fmt.Errorf("failed to save encryption settings: %w", err)
+	}
+	return nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealWith encrypts data as nonce || ciphertext
+func sealWith(aead cipher.AEAD, data, additional []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(data)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, data, additional), nil
+}
+
+// openWith decrypts data sealed by sealWith
+func openWith(aead cipher.AEAD, sealed, additional []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("encrypted data is truncated")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additional)
+}
+
+// GenerateKeyFile writes a new random key file. Existing files are never
+// overwritten.
+func GenerateKeyFile(path string) error {
+	secret := make([]byte, KeyFileSize)
+	if _, err := rand.Read(secret); err != nil {
+		return fmt.Errorf("failed to generate key: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create key file: %w", err)
+	}
+	if _, err := f.Write(secret); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write key file: %w", err)
+	}
+	return f.Close()
+}
+
+// LoadEncryption reads the encryption settings, for example at startup or
+// after a backup was restored. The database starts locked, unless its key
+// comes from a key file that can be read.
+func (s *Service) LoadEncryption() error {
+	config, err := loadEncryptionConfig(s.db)
+	if err != nil {
+		return err
+	}
+
+	s.keys.mu.Lock()
+	defer s.keys.mu.Unlock()
+	s.keys.enabled = config != nil
+	s.keys.aead = nil
+	s.keys.dataKey = nil
+	if config == nil || config.KeySource != KeySourceKeyFile {
+		return nil
+	}
+	if dataKey, err := config.unwrap(MasterKey{KeyFile: config.KeyFile}); err == nil {
+		if s.keys.aead, err = newAEAD(dataKey); err != nil {
+			return err
+		}
+		s.keys.dataKey = dataKey
+	}
+	return nil
+}
+
+// EncryptionStatus reports whether clip data is encrypted and unlocked
+func (s *Service) EncryptionStatus() (*EncryptionStatus, error) {
+	config, err := loadEncryptionConfig(s.db)
+	if err != nil {
+		return nil, err
+	}
+	status := &EncryptionStatus{}
+	if config == nil {
+		return status, nil
+	}
+
+	s.keys.mu.RLock()
+	defer s.keys.mu.RUnlock()
+	status.Enabled = true
+	status.Locked = s.keys.aead == nil
+	status.KeySource = config.KeySource
+	status.KeyFile = config.KeyFile
+	return status, nil
+}
+
+// EnableEncryption encrypts the data of every clip and revision with a new
+// data key wrapped by master, and leaves the database unlocked. The database
+// file is then rebuilt so no plaintext is left in free pages.
+func (s *Service) EnableEncryption(master MasterKey) error {
+	s.keys.mu.Lock()
+	defer s.keys.mu.Unlock()
+
+	if config, err := loadEncryptionConfig(s.db); err != nil {
+		return err
+	} else if config != nil {
+		return ErrEncryptionEnabled
+	}
+
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return fmt.Errorf("failed to generate data key: %w", err)
+	}
+	config, err := newEncryptionConfig(master, dataKey)
+	if err != nil {
+		return err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := config.save(tx); err != nil {
+		return err
+	}
+	for _, table := range []string{"clips", "clip_revisions"} {
+		if err := recryptTable(tx, table, false, func(data []byte) ([]byte, error) {
+			return sealWith(aead, data, nil)
+		}); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	s.keys.enabled = true
+	s.keys.aead = aead
+	s.keys.dataKey = dataKey
+	if err := s.scrub(); err != nil {
+		return fmt.Errorf("encryption enabled, but %w", err)
+	}
+	return nil
+}
+
+// DisableEncryption decrypts the data of every clip and revision and removes
+// the data key
+func (s *Service) DisableEncryption(master MasterKey) error {
+	s.keys.mu.Lock()
+	defer s.keys.mu.Unlock()
+
+	config, err := loadEncryptionConfig(s.db)
+	if err != nil {
+		return err
+	}
+	if config == nil {
+		return ErrEncryptionDisabled
+	}
+	dataKey, err := config.unwrap(master)
+	if err != nil {
+		return err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"clips", "clip_revisions"} {
+		if err := recryptTable(tx, table, true, func(data []byte) ([]byte, error) {
+			return openWith(aead, data, nil)
+		}); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM encryption"); err != nil {
+		return fmt.Errorf("failed to remove encryption settings: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	s.keys.enabled = false
+	s.keys.aead = nil
+	s.keys.dataKey = nil
+	return nil
+}
+
+// recryptBatchSize limits the rows loaded at once when encrypting or
+// decrypting a table
+const recryptBatchSize = 100
+
+// recryptTable rewrites the data of every row of table whose is_encrypted flag
+// equals encrypted, flipping the flag
+func recryptTable(tx *sql.Tx, table string, encrypted bool, transform func([]byte) ([]byte, error)) error {
+	var lastID int64
+	for {
+		rows, err := tx.Query("SELECT id, data FROM "+table+" WHERE is_encrypted = ? AND id > ? ORDER BY id LIMIT ?",
+			boolToInt(encrypted), lastID, recryptBatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to query %s: %w", table, err)
+		}
+		type row struct {
+			id   int64
+			data []byte
+		}
+		var batch []row
+		for rows.Next() {
+			var r row
+			if err := rows.Scan(&r.id, &r.data); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan %s: %w", table, err)
+			}
+			batch = append(batch, r)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+
+		for _, r := range batch {
+			data, err := transform(r.data)
+			if err != nil {
+				return fmt.Errorf("failed to convert %s %d: %w", table, r.id, err)
+			}
+			if _, err := tx.Exec("UPDATE "+table+" SET data = ?, is_encrypted = ? WHERE id = ?",
+				data, boolToInt(!encrypted), r.id); err != nil {
+				return fmt.Errorf("failed to update %s %d: %w", table, r.id, err)
+			}
+			lastID = r.id
+		}
+	}
+}
+
+// scrub rebuilds the database file and truncates the write-ahead log, so data
+// that was replaced by its encrypted form can't be recovered from free pages
+func (s *Service) scrub() error {
+	if _, err := s.db.Exec("VACUUM"); err != nil {
+		return fmt.Errorf("failed to vacuum database: %w", err)
+	}
+	// Fails harmlessly when the database doesn't use a write-ahead log
+	_, _ = s.db.Exec("PRAGMA wal_checkpoint(TRUNCATE)")
+	return nil
+}
+
+// Unlock unwraps the data key with master so encrypted clips can be read and
+// new clips encrypted
+func (s *Service) Unlock(master MasterKey) error {
+	config, err := loadEncryptionConfig(s.db)
+	if err != nil {
+		return err
+	}
+	if config == nil {
+		return ErrEncryptionDisabled
+	}
+	dataKey, err := config.unwrap(master)
+	if err != nil {
+		return err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return err
+	}
+
+	s.keys.mu.Lock()
+	defer s.keys.mu.Unlock()
+	s.keys.enabled = true
+	s.keys.aead = aead
+	s.keys.dataKey = dataKey
+	return nil
+}
+
+// Lock forgets the data key until the database is unlocked again
+func (s *Service) Lock() {
+	s.keys.mu.Lock()
+	defer s.keys.mu.Unlock()
+	s.keys.aead = nil
+	s.keys.dataKey = nil
+}
+
+// Locked reports whether encryption is enabled and the data key isn't loaded,
+// so encrypted clips can't be read
+func (s *Service) Locked() bool {
+	s.keys.mu.RLock()
+	defer s.keys.mu.RUnlock()
+	return s.keys.enabled && s.keys.aead == nil
+}
+
+// Rekey wraps the data key with a new master key. Clip data isn't re-encrypted,
+// so this is fast regardless of the size of the database.
+func (s *Service) Rekey(current, next MasterKey) error {
+	s.keys.mu.Lock()
+	defer s.keys.mu.Unlock()
+
+	config, err := loadEncryptionConfig(s.db)
+	if err != nil {
+		return err
+	}
+	if config == nil {
+		return ErrEncryptionDisabled
+	}
+	dataKey, err := config.unwrap(current)
+	if err != nil {
+		return err
+	}
+	rekeyed, err := newEncryptionConfig(next, dataKey)
+	if err != nil {
+		return err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	if err := rekeyed.save(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	s.keys.aead = aead
+	s.keys.dataKey = dataKey
+	return nil
+}
+
+// DeriveKey derives a key from the data key for other data kept encrypted,
+// such as plugin secrets, so it is protected by the same master key. label
+// tells the uses apart. Fails with ErrEncryptionDisabled if clips aren't
+// encrypted and ErrLocked while they are locked.
+func (s *Service) DeriveKey(label string) ([]byte, error) {
+	s.keys.mu.RLock()
+	defer s.keys.mu.RUnlock()
+	if !s.keys.enabled {
+		return nil, ErrEncryptionDisabled
+	}
+	if s.keys.dataKey == nil {
+		return nil, ErrLocked
+	}
+	key, err := hkdf.Key(sha256.New, s.keys.dataKey, nil, label, keySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	return key, nil
+}
+
+// seal encrypts clip data if encryption is enabled, reporting whether it did
+func (s *Service) seal(data []byte) ([]byte, bool, error) {
+	s.keys.mu.RLock()
+	defer s.keys.mu.RUnlock()
+	if !s.keys.enabled {
+		return data, false, nil
+	}
+	if s.keys.aead == nil {
+		return nil, false, ErrLocked
+	}
+	sealed, err := sealWith(s.keys.aead, data, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	return sealed, true, nil
+}
+
+// DecryptData returns the plaintext of clip data read from the database, where
+// encrypted is the row's is_encrypted flag
+func (s *Service) DecryptData(data []byte, encrypted bool) ([]byte, error) {
+	if !encrypted {
+		return data, nil
+	}
+	s.keys.mu.RLock()
+	defer s.keys.mu.RUnlock()
+	if s.keys.aead == nil {
+		return nil, ErrLocked
+	}
+	plain, err := openWith(s.keys.aead, data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt clip data: %w", err)
+	}
+	return plain, nil
+}
+
+// dataSize returns an SQL expression for the size of the plaintext data of a
+// clip or revision, where col qualifies a column name
+func dataSize(col func(string) string) string {
+	return fmt.Sprintf("(LENGTH(%s) - %d * %s)", col("data"), sealOverhead, col("is_encrypted"))
+}
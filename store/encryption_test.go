@@ -0,0 +1,244 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func init() {
+	// Keep key derivation fast in tests
+	passphraseIterations = 1000
+}
+
+func clipData(t *testing.T, s *Service, id int64) []byte {
+	t.Helper()
+	_, data, _, err := s.RevisionData(id, 0)
+	if err != nil {
+		t.Fatalf("RevisionData failed: %v", err)
+	}
+	return data
+}
+
+func storedData(t *testing.T, s *Service, table string, id int64) ([]byte, bool) {
+	t.Helper()
+	var data []byte
+	var encrypted bool
+	if err := s.db.QueryRow("SELECT data, is_encrypted FROM "+table+" WHERE id = ?", id).Scan(&data, &encrypted); err != nil {
+		t.Fatalf("Failed to read %s %d: %v", table, id, err)
+	}
+	return data, encrypted
+}
+
+func TestEnableEncryption(t *testing.T) {
+	s, _ := newTestService(t)
+	passphrase := MasterKey{Passphrase: "correct horse"}
+
+	clip, _ := s.CreateClip(User, NewClip{ContentType: "text/plain", Data: []byte("secret v1")})
+	s.UpdateClip(User, clip.ID, ClipUpdate{Data: []byte("secret v2")})
+
+	if err := s.EnableEncryption(MasterKey{Passphrase: "short"}); err == nil {
+		t.Error("Expected a short passphrase to be rejected")
+	}
+	if err := s.EnableEncryption(passphrase); err != nil {
+		t.Fatalf("EnableEncryption failed: %v", err)
+	}
+	if err := s.EnableEncryption(passphrase); !errors.Is(err, ErrEncryptionEnabled) {
+		t.Errorf("Expected ErrEncryptionEnabled, got %v", err)
+	}
+
+	// Existing clips and revisions are encrypted in place
+	data, encrypted := storedData(t, s, "clips", clip.ID)
+	if !encrypted || bytes.Contains(data, []byte("secret")) {
+		t.Errorf("Expected the clip to be encrypted, got %q", data)
+	}
+	if len(data) != len("secret v2")+sealOverhead {
+		t.Errorf("Expected %d bytes, got %d", len("secret v2")+sealOverhead, len(data))
+	}
+	var plainRevisions int
+	s.db.QueryRow("SELECT COUNT(*) FROM clip_revisions WHERE is_encrypted = 0").Scan(&plainRevisions)
+	if plainRevisions != 0 {
+		t.Errorf("Expected all revisions to be encrypted, %d are not", plainRevisions)
+	}
+
+	// Reads and writes stay transparent
+	if got := clipData(t, s, clip.ID); string(got) != "secret v2" {
+		t.Errorf("Expected 'secret v2', got %q", got)
+	}
+	revisions, _ := s.ListRevisions(clip.ID)
+	if _, got, _, err := s.RevisionData(clip.ID, revisions[0].ID); err != nil || string(got) != "secret v1" {
+		t.Errorf("Expected revision 'secret v1', got %q (%v)", got, err)
+	}
+	other, err := s.CreateClip(User, NewClip{ContentType: "text/plain", Data: []byte("new secret")})
+	if err != nil {
+		t.Fatalf("CreateClip failed: %v", err)
+	}
+	if _, encrypted := storedData(t, s, "clips", other.ID); !encrypted {
+		t.Error("Expected new clips to be encrypted")
+	}
+	if got := clipData(t, s, other.ID); string(got) != "new secret" {
+		t.Errorf("Expected 'new secret', got %q", got)
+	}
+
+	status, _ := s.EncryptionStatus()
+	if !status.Enabled || status.Locked || status.KeySource != KeySourcePassphrase {
+		t.Errorf("Unexpected status %+v", status)
+	}
+}
+
+func TestEncryption_LockAndUnlock(t *testing.T) {
+	s, _ := newTestService(t)
+	clip, _ := s.CreateClip(User, NewClip{ContentType: "text/plain", Data: []byte("secret")})
+	s.EnableEncryption(MasterKey{Passphrase: "correct horse"})
+
+	// LoadEncryption runs at startup and leaves passphrase databases locked
+	if err := s.LoadEncryption(); err != nil {
+		t.Fatalf("LoadEncryption failed: %v", err)
+	}
+	if status, _ := s.EncryptionStatus(); !status.Locked {
+		t.Error("Expected the database to be locked")
+	}
+	if _, _, _, err := s.RevisionData(clip.ID, 0); !errors.Is(err, ErrLocked) {
+		t.Errorf("Expected ErrLocked reading a clip, got %v", err)
+	}
+	if _, err := s.CreateClip(User, NewClip{ContentType: "text/plain", Data: []byte("x")}); !errors.Is(err, ErrLocked) {
+		t.Errorf("Expected ErrLocked creating a clip, got %v", err)
+	}
+
+	if err := s.Unlock(MasterKey{Passphrase: "wrong horse"}); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("Expected ErrWrongKey, got %v", err)
+	}
+	if err := s.Unlock(MasterKey{Passphrase: "correct horse"}); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if got := clipData(t, s, clip.ID); string(got) != "secret" {
+		t.Errorf("Expected 'secret', got %q", got)
+	}
+
+	s.Lock()
+	if _, _, _, err := s.RevisionData(clip.ID, 0); !errors.Is(err, ErrLocked) {
+		t.Errorf("Expected ErrLocked after Lock, got %v", err)
+	}
+}
+
+func TestEncryption_Rekey(t *testing.T) {
+	s, _ := newTestService(t)
+	clip, _ := s.CreateClip(User, NewClip{ContentType: "text/plain", Data: []byte("secret")})
+	old := MasterKey{Passphrase: "correct horse"}
+	s.EnableEncryption(old)
+	before, _ := storedData(t, s, "clips", clip.ID)
+
+	if err := s.Rekey(MasterKey{Passphrase: "wrong horse"}, MasterKey{Passphrase: "battery staple"}); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("Expected ErrWrongKey, got %v", err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "mahpastes.key")
+	if err := GenerateKeyFile(keyFile); err != nil {
+		t.Fatalf("GenerateKeyFile failed: %v", err)
+	}
+	if err := GenerateKeyFile(keyFile); err == nil {
+		t.Error("Expected an existing key file not to be overwritten")
+	}
+	if err := s.Rekey(old, MasterKey{KeyFile: keyFile}); err != nil {
+		t.Fatalf("Rekey failed: %v", err)
+	}
+
+	// Only the data key is rewrapped
+	if after, _ := storedData(t, s, "clips", clip.ID); !bytes.Equal(before, after) {
+		t.Error("Expected clip data not to be re-encrypted")
+	}
+
+	// Key file databases unlock on startup
+	if err := s.LoadEncryption(); err != nil {
+		t.Fatalf("LoadEncryption failed: %v", err)
+	}
+	if status, _ := s.EncryptionStatus(); status.Locked || status.KeySource != KeySourceKeyFile || status.KeyFile != keyFile {
+		t.Errorf("Expected to be unlocked with the key file, got %+v", status)
+	}
+	if got := clipData(t, s, clip.ID); string(got) != "secret" {
+		t.Errorf("Expected 'secret', got %q", got)
+	}
+
+	// ...unless the key file is gone
+	os.Remove(keyFile)
+	s.LoadEncryption()
+	if status, _ := s.EncryptionStatus(); !status.Locked {
+		t.Error("Expected the database to stay locked without its key file")
+	}
+	if err := s.Unlock(old); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("Expected the old passphrase to be rejected, got %v", err)
+	}
+}
+
+func TestEncryption_DeriveKey(t *testing.T) {
+	s, _ := newTestService(t)
+	if _, err := s.DeriveKey("plugin secrets"); !errors.Is(err, ErrEncryptionDisabled) {
+		t.Errorf("Expected ErrEncryptionDisabled, got %v", err)
+	}
+
+	keyFile := filepath.Join(t.TempDir(), "mahpastes.key")
+	GenerateKeyFile(keyFile)
+	s.EnableEncryption(MasterKey{KeyFile: keyFile})
+	key, err := s.DeriveKey("plugin secrets")
+	if err != nil || len(key) != keySize {
+		t.Fatalf("Expected a %d byte key, got %d bytes, %v", keySize, len(key), err)
+	}
+	if other, _ := s.DeriveKey("other"); bytes.Equal(key, other) {
+		t.Error("Expected labels to derive different keys")
+	}
+
+	s.Lock()
+	if _, err := s.DeriveKey("plugin secrets"); !errors.Is(err, ErrLocked) {
+		t.Errorf("Expected ErrLocked, got %v", err)
+	}
+
+	// The same key comes back after unlocking on startup
+	s.LoadEncryption()
+	if again, _ := s.DeriveKey("plugin secrets"); !bytes.Equal(key, again) {
+		t.Error("Expected the same key after unlocking")
+	}
+}
+
+func TestDisableEncryption(t *testing.T) {
+	s, _ := newTestService(t)
+	clip, _ := s.CreateClip(User, NewClip{ContentType: "text/plain", Data: []byte("secret v1")})
+	s.UpdateClip(User, clip.ID, ClipUpdate{Data: []byte("secret v2")})
+	key := MasterKey{Passphrase: "correct horse"}
+	s.EnableEncryption(key)
+
+	if err := s.DisableEncryption(MasterKey{Passphrase: "wrong horse"}); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("Expected ErrWrongKey, got %v", err)
+	}
+	if err := s.DisableEncryption(key); err != nil {
+		t.Fatalf("DisableEncryption failed: %v", err)
+	}
+	if err := s.DisableEncryption(key); !errors.Is(err, ErrEncryptionDisabled) {
+		t.Errorf("Expected ErrEncryptionDisabled, got %v", err)
+	}
+
+	if data, encrypted := storedData(t, s, "clips", clip.ID); encrypted || string(data) != "secret v2" {
+		t.Errorf("Expected plaintext 'secret v2', got %q", data)
+	}
+	revisions, _ := s.ListRevisions(clip.ID)
+	if _, got, _, err := s.RevisionData(clip.ID, revisions[0].ID); err != nil || string(got) != "secret v1" {
+		t.Errorf("Expected revision 'secret v1', got %q (%v)", got, err)
+	}
+	if status, _ := s.EncryptionStatus(); status.Enabled {
+		t.Error("Expected encryption to be disabled")
+	}
+}
+
+func TestEncryption_SizeFilters(t *testing.T) {
+	s, _ := newTestService(t)
+	small, _ := s.CreateClip(User, NewClip{ContentType: "text/plain", Data: []byte("0123456789")})
+	s.EnableEncryption(MasterKey{Passphrase: "correct horse"})
+	large, _ := s.CreateClip(User, NewClip{ContentType: "text/plain", Data: bytes.Repeat([]byte("x"), 100)})
+
+	// Sizes ignore the encryption overhead
+	condition, args, _ := Filter{MaxSize: 10}.Condition("c")
+	ids, _ := queryIDs(s.db, "SELECT c.id FROM clips c WHERE "+condition, args...)
+	if !equalIDs(ids, []int64{small.ID}) {
+		t.Errorf("Expected only clip %d (not %d), got %v", small.ID, large.ID, ids)
+	}
+}
@@ -0,0 +1,102 @@
+package store
+
+import "fmt"
+
+// SetPinned pins or unpins a clip, emitting clip:pinned or clip:unpinned if
+// its state changed. Pinned clips are listed first, never expire and are
+// skipped by bulk deletes unless forced.
+func (s *Service) SetPinned(actor Actor, id int64, pinned bool) error {
+	result, err := s.db.Exec("UPDATE clips SET is_pinned = ? WHERE id = ? AND is_pinned != ? AND deleted_at IS NULL",
+		boolToInt(pinned), id, boolToInt(pinned))
+	if err != nil {
+		return fmt.Errorf("failed to update pin state: %w", err)
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		if !s.clipExists(id) {
+			return ErrClipNotFound
+		}
+		return nil
+	}
+
+	event := "clip:unpinned"
+	if pinned {
+		event = "clip:pinned"
+	}
+	s.emit(actor, event, map[string]interface{}{
+		"id": id,
+	})
+	return nil
+}
+
+// ReorderClips gives clips a manual sort position in the order of ids and
+// emits clip:reordered with the clips it moved. Clips with a position are
+// listed before the others of their group (pinned or not); clips that don't
+// exist or are in the trash are skipped.
+func (s *Service) ReorderClips(actor Actor, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare("UPDATE clips SET sort_position = ? WHERE id = ? AND deleted_at IS NULL")
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	var reordered []int64
+	for i, id := range ids {
+		result, err := stmt.Exec(i, id)
+		if err != nil {
+			return fmt.Errorf("failed to set position of clip %d: %w", id, err)
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			reordered = append(reordered, id)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if len(reordered) > 0 {
+		s.emit(actor, "clip:reordered", map[string]interface{}{
+			"ids": reordered,
+		})
+	}
+	return nil
+}
+
+// SplitPinned separates pinned clips from the others, e.g. to leave them
+// out of a bulk delete. Clips that don't exist or are in the trash are counted
+// as unpinned.
+func (s *Service) SplitPinned(ids []int64) (unpinned, pinned []int64, err error) {
+	if len(ids) == 0 {
+		return nil, nil, nil
+	}
+	marks, args := placeholders(ids)
+
+	pinnedIDs, err := queryIDs(s.db, "SELECT id FROM clips WHERE is_pinned = 1 AND deleted_at IS NULL AND id IN ("+marks+")", args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query pinned clips: %w", err)
+	}
+	isPinned := make(map[int64]bool, len(pinnedIDs))
+	for _, id := range pinnedIDs {
+		isPinned[id] = true
+	}
+
+	for _, id := range ids {
+		if isPinned[id] {
+			pinned = append(pinned, id)
+		} else {
+			unpinned = append(unpinned, id)
+		}
+	}
+	return unpinned, pinned, nil
+}
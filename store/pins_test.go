@@ -0,0 +1,86 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestSetPinned(t *testing.T) {
+	s, rec := newTestService(t)
+
+	clip, _ := s.CreateClip(User, NewClip{Data: []byte("a")})
+	rec.names()
+
+	if err := s.SetPinned(User, clip.ID, true); err != nil {
+		t.Fatalf("SetPinned failed: %v", err)
+	}
+	// Pinning again changes nothing and emits nothing
+	s.SetPinned(User, clip.ID, true)
+	s.SetPinned(User, clip.ID, false)
+
+	want := []string{"clip:pinned", "clip:unpinned"}
+	if got := rec.names(); !equalNames(got, want) {
+		t.Errorf("Expected events %v, got %v", want, got)
+	}
+	if err := s.SetPinned(User, 999, true); !errors.Is(err, ErrClipNotFound) {
+		t.Errorf("Expected ErrClipNotFound, got %v", err)
+	}
+}
+
+func TestSplitPinned(t *testing.T) {
+	s, _ := newTestService(t)
+
+	a, _ := s.CreateClip(User, NewClip{Data: []byte("a")})
+	b, _ := s.CreateClip(User, NewClip{Data: []byte("b")})
+	s.SetPinned(User, b.ID, true)
+	trashed, _ := s.CreateClip(User, NewClip{Data: []byte("trashed")})
+	s.SetPinned(User, trashed.ID, true)
+	s.TrashClips(User, []int64{trashed.ID})
+
+	unpinned, pinned, err := s.SplitPinned([]int64{a.ID, b.ID, 999, trashed.ID})
+	if err != nil {
+		t.Fatalf("SplitPinned failed: %v", err)
+	}
+	if len(unpinned) != 3 || unpinned[0] != a.ID || unpinned[1] != 999 || unpinned[2] != trashed.ID {
+		t.Errorf("Unexpected unpinned clips: %v", unpinned)
+	}
+	if len(pinned) != 1 || pinned[0] != b.ID {
+		t.Errorf("Unexpected pinned clips: %v", pinned)
+	}
+}
+
+func TestReorderClips(t *testing.T) {
+	s, rec := newTestService(t)
+
+	a, _ := s.CreateClip(User, NewClip{Data: []byte("a")})
+	b, _ := s.CreateClip(User, NewClip{Data: []byte("b")})
+	c, _ := s.CreateClip(User, NewClip{Data: []byte("c")})
+	trashed, _ := s.CreateClip(User, NewClip{Data: []byte("trashed")})
+	s.TrashClips(User, []int64{trashed.ID})
+	rec.names()
+
+	if err := s.ReorderClips(User, []int64{c.ID, trashed.ID, a.ID, 999}); err != nil {
+		t.Fatalf("ReorderClips failed: %v", err)
+	}
+	if len(rec.events) != 1 || rec.events[0].Name != "clip:reordered" {
+		t.Fatalf("Expected clip:reordered, got %v", rec.names())
+	}
+	if ids := rec.events[0].Data.(map[string]interface{})["ids"].([]int64); !equalIDs(ids, []int64{c.ID, a.ID}) {
+		t.Errorf("Expected the moved clips in the event, got %v", ids)
+	}
+	var position sql.NullInt64
+	s.db.QueryRow("SELECT sort_position FROM clips WHERE id = ?", trashed.ID).Scan(&position)
+	if position.Valid {
+		t.Errorf("Expected the trashed clip to keep no position, got %d", position.Int64)
+	}
+
+	// Same ordering as the gallery: positioned clips first, then newest first
+	got, _ := queryIDs(s.db, "SELECT id FROM clips WHERE deleted_at IS NULL ORDER BY is_pinned DESC, sort_position IS NULL, sort_position, created_at DESC, id DESC")
+	want := []int64{c.ID, a.ID, b.ID}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Expected order %v, got %v", want, got)
+		}
+	}
+}
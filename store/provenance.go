@@ -0,0 +1,211 @@
+package store
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"image"
+	"path/filepath"
+	"strings"
+	"time"
+
+	// Register decoders for reading image dimensions
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// Source kinds
+const (
+	SourcePaste  = "paste"
+	SourceFile   = "file" // uploaded or dropped file
+	SourceWatch  = "watch"
+	SourcePlugin = "plugin"
+	SourceURL    = "url" // downloaded by a plugin with clips.create_from_url
+	SourceEditor = "editor"
+)
+
+// Source records where a clip came from
+type Source struct {
+	Kind     string // one of the Source* kinds, derived from the actor if empty
+	Path     string // original file path, if known
+	URL      string // URL the content was downloaded from
+	PluginID int64
+	Action   string     // plugin UI action or handler that created the clip
+	ModTime  *time.Time // modification time of the original file
+}
+
+// ContentInfo holds properties derived from a clip's content
+type ContentInfo struct {
+	Width     int // image dimensions, 0 for other content
+	Height    int
+	LineCount int    // for text content
+	Language  string // e.g. "go", "json", "markdown"; empty if unknown
+}
+
+// ClipInfo is the provenance and content info of a clip
+type ClipInfo struct {
+	Source Source
+	ContentInfo
+}
+
+// sourceFor fills in the source kind and plugin from the actor creating a clip
+func sourceFor(actor Actor, src Source) Source {
+	if actor.Kind == ActorPlugin && src.PluginID == 0 {
+		src.PluginID = actor.PluginID
+	}
+	if src.Kind == "" {
+		switch actor.Kind {
+		case ActorWatcher:
+			src.Kind = SourceWatch
+		case ActorPlugin:
+			src.Kind = SourcePlugin
+		default:
+			src.Kind = SourceFile
+		}
+	}
+	return src
+}
+
+// analyzeContent derives image dimensions or text line count and language
+func analyzeContent(contentType, filename string, data []byte) ContentInfo {
+	var info ContentInfo
+	if strings.HasPrefix(contentType, "image/") {
+		if cfg, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+			info.Width, info.Height = cfg.Width, cfg.Height
+		}
+		return info
+	}
+	if !IsText(contentType) {
+		return info
+	}
+
+	if len(data) > 0 {
+		info.LineCount = bytes.Count(data, []byte("\n")) + 1
+		if data[len(data)-1] == '\n' {
+			info.LineCount--
+		}
+	}
+	info.Language = detectLanguage(contentType, filename, data)
+	return info
+}
+
+// languageByExtension maps file extensions to language names
+var languageByExtension = map[string]string{
+	".go":   "go",
+	".py":   "python",
+	".js":   "javascript",
+	".mjs":  "javascript",
+	".ts":   "typescript",
+	".rs":   "rust",
+	".rb":   "ruby",
+	".java": "java",
+	".c":    "c",
+	".h":    "c",
+	".cpp":  "cpp",
+	".cs":   "csharp",
+	".lua":  "lua",
+	".sh":   "shell",
+	".sql":  "sql",
+	".md":   "markdown",
+	".html": "html",
+	".css":  "css",
+	".json": "json",
+	".yaml": "yaml",
+	".yml":  "yaml",
+	".toml": "toml",
+	".xml":  "xml",
+}
+
+// detectLanguage guesses the language of text from its content type,
+// filename extension or first lines
+func detectLanguage(contentType, filename string, data []byte) string {
+	switch contentType {
+	case "application/json":
+		return "json"
+	case "text/html":
+		return "html"
+	case "text/css":
+		return "css"
+	case "text/markdown":
+		return "markdown"
+	}
+	if lang, ok := languageByExtension[strings.ToLower(filepath.Ext(filename))]; ok {
+		return lang
+	}
+
+	head := string(data)
+	if len(head) > 1024 {
+		head = head[:1024]
+	}
+	trimmed := strings.TrimSpace(head)
+	switch {
+	case strings.HasPrefix(trimmed, "#!"):
+		firstLine, _, _ := strings.Cut(trimmed, "\n")
+		if strings.Contains(firstLine, "python") {
+			return "python"
+		}
+		if strings.Contains(firstLine, "node") {
+			return "javascript"
+		}
+		return "shell"
+	case strings.HasPrefix(trimmed, "package ") && strings.Contains(head, "func "):
+		return "go"
+	case strings.Contains(head, "\ndef ") || strings.HasPrefix(trimmed, "def ") || (strings.HasPrefix(trimmed, "import ") && strings.Contains(head, ":\n")):
+		return "python"
+	case strings.Contains(head, "function ") || (strings.Contains(head, "const ") && strings.Contains(head, "=>")):
+		return "javascript"
+	case strings.HasPrefix(strings.ToUpper(trimmed), "SELECT ") || strings.HasPrefix(strings.ToUpper(trimmed), "CREATE TABLE"):
+		return "sql"
+	case strings.HasPrefix(trimmed, "# ") || strings.Contains(head, "\n## "):
+		return "markdown"
+	}
+	return ""
+}
+
+// nullString converts an empty string to NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// nullInt64 converts 0 to NULL
+func nullInt64(n int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: n, Valid: n != 0}
+}
+
+// GetClipInfo returns where a clip came from and what its content looks like
+func (s *Service) GetClipInfo(clipID int64) (*ClipInfo, error) {
+	var kind, path, url, action, language sql.NullString
+	var pluginID, width, height, lineCount sql.NullInt64
+	var modTime sql.NullTime
+
+	err := s.db.QueryRow(`SELECT source_kind, source_path, source_url, source_plugin_id, source_action,
+		source_mtime, width, height, line_count, language FROM clips WHERE id = ?`, clipID).
+		Scan(&kind, &path, &url, &pluginID, &action, &modTime, &width, &height, &lineCount, &language)
+	if err == sql.ErrNoRows {
+		return nil, ErrClipNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query clip info: %w", err)
+	}
+
+	info := &ClipInfo{
+		Source: Source{
+			Kind:     kind.String,
+			Path:     path.String,
+			URL:      url.String,
+			PluginID: pluginID.Int64,
+			Action:   action.String,
+		},
+		ContentInfo: ContentInfo{
+			Width:     int(width.Int64),
+			Height:    int(height.Int64),
+			LineCount: int(lineCount.Int64),
+			Language:  language.String,
+		},
+	}
+	if modTime.Valid {
+		info.Source.ModTime = &modTime.Time
+	}
+	return info, nil
+}
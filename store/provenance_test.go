@@ -0,0 +1,90 @@
+package store
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+	"time"
+)
+
+func TestCreateClip_RecordsSource(t *testing.T) {
+	s, _ := newTestService(t)
+
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	watched, _ := s.CreateClip(Watcher, NewClip{
+		Data:   []byte("a"),
+		Source: Source{Path: "/home/me/Downloads/a.txt", ModTime: &modTime},
+	})
+	info, err := s.GetClipInfo(watched.ID)
+	if err != nil {
+		t.Fatalf("GetClipInfo failed: %v", err)
+	}
+	if info.Source.Kind != SourceWatch || info.Source.Path != "/home/me/Downloads/a.txt" {
+		t.Errorf("Unexpected source: %+v", info.Source)
+	}
+	if info.Source.ModTime == nil || !info.Source.ModTime.Equal(modTime) {
+		t.Errorf("Expected mtime %v, got %v", modTime, info.Source.ModTime)
+	}
+
+	fromPlugin, _ := s.CreateClip(Plugin(4), NewClip{Data: []byte("b"), Source: Source{Action: "upscale"}})
+	info, _ = s.GetClipInfo(fromPlugin.ID)
+	if info.Source.Kind != SourcePlugin || info.Source.PluginID != 4 || info.Source.Action != "upscale" {
+		t.Errorf("Unexpected plugin source: %+v", info.Source)
+	}
+
+	pasted, _ := s.CreateClip(User, NewClip{Data: []byte("c"), Source: Source{Kind: SourcePaste}})
+	info, _ = s.GetClipInfo(pasted.ID)
+	if info.Source.Kind != SourcePaste || info.Source.ModTime != nil {
+		t.Errorf("Unexpected paste source: %+v", info.Source)
+	}
+
+	if _, err := s.GetClipInfo(999); err != ErrClipNotFound {
+		t.Errorf("Expected ErrClipNotFound, got %v", err)
+	}
+}
+
+func TestContentInfo(t *testing.T) {
+	s, _ := newTestService(t)
+
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 30, 20)))
+	img, _ := s.CreateClip(User, NewClip{ContentType: "image/png", Data: buf.Bytes()})
+	info, _ := s.GetClipInfo(img.ID)
+	if info.Width != 30 || info.Height != 20 {
+		t.Errorf("Expected 30x20, got %dx%d", info.Width, info.Height)
+	}
+
+	code, _ := s.CreateClip(User, NewClip{ContentType: "text/plain", Data: []byte("package main\n\nfunc main() {}\n")})
+	info, _ = s.GetClipInfo(code.ID)
+	if info.LineCount != 3 || info.Language != "go" {
+		t.Errorf("Expected 3 lines of go, got %d lines of %q", info.LineCount, info.Language)
+	}
+
+	// Content info follows updates
+	name := "notes.md"
+	s.UpdateClip(User, code.ID, ClipUpdate{Data: []byte("one line"), Filename: &name})
+	info, _ = s.GetClipInfo(code.ID)
+	if info.LineCount != 1 || info.Language != "markdown" {
+		t.Errorf("Expected 1 line of markdown, got %d lines of %q", info.LineCount, info.Language)
+	}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		contentType, filename, data, want string
+	}{
+		{"application/json", "", "{}", "json"},
+		{"text/plain", "script.PY", "x = 1", "python"},
+		{"text/plain", "", "#!/bin/bash\necho hi", "shell"},
+		{"text/plain", "", "def main():\n    pass", "python"},
+		{"text/plain", "", "select * from clips", "sql"},
+		{"text/plain", "", "just some words", ""},
+	}
+
+	for _, tt := range tests {
+		if got := detectLanguage(tt.contentType, tt.filename, []byte(tt.data)); got != tt.want {
+			t.Errorf("detectLanguage(%q, %q, %q) = %q, want %q", tt.contentType, tt.filename, tt.data, got, tt.want)
+		}
+	}
+}
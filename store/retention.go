@@ -0,0 +1,328 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// VacuumThreshold is the number of bytes a retention run must free before the
+// database file is compacted
+const VacuumThreshold = 64 << 20
+
+// deleteBatchSize limits the clips deleted per statement
+const deleteBatchSize = 500
+
+// Reasons a clip is deleted by a retention run
+const (
+	ReasonMaxAge         = "max_age"
+	ReasonUntaggedMaxAge = "untagged_max_age"
+	ReasonTagMaxAge      = "tag_max_age"
+	ReasonMaxClips       = "max_clips"
+	ReasonMaxBytes       = "max_bytes"
+)
+
+// RetentionPolicy decides which clips the cleanup job deletes. Zero values
+// disable a rule. Pinned clips are never deleted. Policies are stored as JSON
+// in the settings, so fields must keep their names.
+type RetentionPolicy struct {
+	MaxAgeDays         int   `json:"max_age_days"`          // delete clips older than this
+	UntaggedMaxAgeDays int   `json:"untagged_max_age_days"` // delete untagged clips older than this
+	MaxClips           int   `json:"max_clips"`             // delete the oldest clips beyond this count
+	MaxBytes           int64 `json:"max_bytes"`             // delete the oldest clips beyond this total size
+	KeepArchived       bool  `json:"keep_archived"`         // never delete archived clips
+
+	TagRules []TagRetentionRule `json:"tag_rules"`
+}
+
+// TagRetentionRule overrides the age rules for clips with a tag or one of its
+// children. When several rules apply to a clip, Keep wins, then the longest
+// age.
+type TagRetentionRule struct {
+	Tag        string `json:"tag"`
+	MaxAgeDays int    `json:"max_age_days"`
+	Keep       bool   `json:"keep"` // never delete, not even for quotas
+}
+
+// Enabled reports whether the policy has any rule that deletes clips
+func (p RetentionPolicy) Enabled() bool {
+	if p.MaxAgeDays > 0 || p.UntaggedMaxAgeDays > 0 || p.MaxClips > 0 || p.MaxBytes > 0 {
+		return true
+	}
+	for _, r := range p.TagRules {
+		if r.MaxAgeDays > 0 && !r.Keep {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the policy and normalizes its tag names
+func (p *RetentionPolicy) Validate() error {
+	if p.MaxAgeDays < 0 || p.UntaggedMaxAgeDays < 0 || p.MaxClips < 0 || p.MaxBytes < 0 {
+		return fmt.Errorf("retention limits cannot be negative")
+	}
+	for i := range p.TagRules {
+		r := &p.TagRules[i]
+		r.Tag = normalizeTagPath(r.Tag)
+		if r.Tag == "" {
+			return fmt.Errorf("retention rule %d: tag name cannot be empty", i+1)
+		}
+		if r.MaxAgeDays < 0 {
+			return fmt.Errorf("retention rule for %s: max age cannot be negative", r.Tag)
+		}
+	}
+	return nil
+}
+
+// ruleFor returns the tag rule that applies to a clip with the given tags, or
+// nil if none does
+func (p RetentionPolicy) ruleFor(tags []string) *TagRetentionRule {
+	var best *TagRetentionRule
+	for i := range p.TagRules {
+		r := &p.TagRules[i]
+		if (!r.Keep && r.MaxAgeDays == 0) || !hasTagOrChild(tags, r.Tag) {
+			continue
+		}
+		if best == nil || (r.Keep && !best.Keep) || (!best.Keep && r.MaxAgeDays > best.MaxAgeDays) {
+			best = r
+		}
+	}
+	return best
+}
+
+// hasTagOrChild reports whether tags contains tag or one of its children,
+// ignoring case
+func hasTagOrChild(tags []string, tag string) bool {
+	for _, t := range tags {
+		if TagMatches(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// RetentionCandidate is a clip a retention run deletes
+type RetentionCandidate struct {
+	ID          int64
+	ContentType string
+	Filename    string
+	Size        int64
+	CreatedAt   time.Time
+	Reason      string
+}
+
+// RetentionReport describes the clips deleted, or to be deleted, by a
+// retention run
+type RetentionReport struct {
+	Clips      []RetentionCandidate // oldest first
+	FreedBytes int64
+	ByReason   map[string]int
+	Vacuumed   bool
+}
+
+// retentionClip is a clip considered by a retention run
+type retentionClip struct {
+	RetentionCandidate
+	archived bool
+	pinned   bool
+	tags     []string
+}
+
+// PlanRetention returns the clips a policy would delete now, without deleting
+// anything
+func (s *Service) PlanRetention(policy RetentionPolicy) (*RetentionReport, error) {
+	report := &RetentionReport{ByReason: map[string]int{}}
+	if !policy.Enabled() {
+		return report, nil
+	}
+
+	clips, err := s.retentionClips()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	olderThan := func(c *retentionClip, days int) bool {
+		return days > 0 && c.CreatedAt.Before(now.AddDate(0, 0, -days))
+	}
+
+	totalClips := len(clips)
+	var totalBytes int64
+	for _, c := range clips {
+		totalBytes += c.Size
+	}
+
+	deleted := make(map[int64]bool)
+	remove := func(c *retentionClip, reason string) {
+		deleted[c.ID] = true
+		c.Reason = reason
+		report.Clips = append(report.Clips, c.RetentionCandidate)
+		report.FreedBytes += c.Size
+		report.ByReason[reason]++
+		totalClips--
+		totalBytes -= c.Size
+	}
+
+	// Age rules, then quotas on what's left, oldest first
+	var deletable []*retentionClip
+	for i := range clips {
+		c := &clips[i]
+		if c.pinned || (c.archived && policy.KeepArchived) {
+			continue
+		}
+		rule := policy.ruleFor(c.tags)
+		if rule != nil && rule.Keep {
+			continue
+		}
+
+		switch {
+		case rule != nil && rule.MaxAgeDays > 0:
+			if olderThan(c, rule.MaxAgeDays) {
+				remove(c, ReasonTagMaxAge)
+			}
+		case len(c.tags) == 0 && olderThan(c, policy.UntaggedMaxAgeDays):
+			remove(c, ReasonUntaggedMaxAge)
+		case olderThan(c, policy.MaxAgeDays):
+			remove(c, ReasonMaxAge)
+		}
+		if !deleted[c.ID] {
+			deletable = append(deletable, c)
+		}
+	}
+
+	for _, c := range deletable {
+		switch {
+		case policy.MaxClips > 0 && totalClips > policy.MaxClips:
+			remove(c, ReasonMaxClips)
+		case policy.MaxBytes > 0 && totalBytes > policy.MaxBytes:
+			remove(c, ReasonMaxBytes)
+		}
+	}
+
+	sort.SliceStable(report.Clips, func(i, j int) bool {
+		return report.Clips[i].CreatedAt.Before(report.Clips[j].CreatedAt)
+	})
+	return report, nil
+}
+
+// retentionClips loads every clip outside the trash with its size and tag
+// names, oldest first. Trashed clips are purged by the trash's own expiry and
+// don't count towards the quotas.
+func (s *Service) retentionClips() ([]retentionClip, error) {
+	size := dataSize(func(name string) string { return name })
+	rows, err := s.db.Query(`SELECT id, content_type, filename, ` + size + `, created_at, is_archived, is_pinned
+		FROM clips WHERE deleted_at IS NULL ORDER BY created_at, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query clips: %w", err)
+	}
+	defer rows.Close()
+
+	var clips []retentionClip
+	index := make(map[int64]int)
+	for rows.Next() {
+		var c retentionClip
+		var filename sql.NullString
+		var archived, pinned int
+		if err := rows.Scan(&c.ID, &c.ContentType, &filename, &c.Size, &c.CreatedAt, &archived, &pinned); err != nil {
+			return nil, fmt.Errorf("failed to scan clip: %w", err)
+		}
+		c.Filename = filename.String
+		c.archived = archived == 1
+		c.pinned = pinned == 1
+		index[c.ID] = len(clips)
+		clips = append(clips, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	tagRows, err := s.db.Query("SELECT ct.clip_id, t.name FROM clip_tags ct INNER JOIN tags t ON t.id = ct.tag_id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query clip tags: %w", err)
+	}
+	defer tagRows.Close()
+
+	for tagRows.Next() {
+		var clipID int64
+		var name string
+		if err := tagRows.Scan(&clipID, &name); err != nil {
+			return nil, fmt.Errorf("failed to scan clip tag: %w", err)
+		}
+		if i, ok := index[clipID]; ok {
+			clips[i].tags = append(clips[i].tags, name)
+		}
+	}
+	return clips, tagRows.Err()
+}
+
+// ApplyRetention deletes the clips selected by PlanRetention, emitting
+// clip:deleted for each and a retention:applied summary if any were deleted.
+// The database is compacted once at least VacuumThreshold bytes are freed.
+func (s *Service) ApplyRetention(actor Actor, policy RetentionPolicy) (*RetentionReport, error) {
+	report, err := s.PlanRetention(policy)
+	if err != nil {
+		return nil, err
+	}
+	if len(report.Clips) == 0 {
+		return report, nil
+	}
+
+	ids := make([]int64, len(report.Clips))
+	for i, c := range report.Clips {
+		ids[i] = c.ID
+	}
+	if err := s.deleteInBatches(actor, ids); err != nil {
+		return nil, err
+	}
+
+	if report.FreedBytes >= VacuumThreshold {
+		if err := s.vacuum(); err != nil {
+			return nil, err
+		}
+		report.Vacuumed = true
+	}
+
+	byReason := make(map[string]interface{}, len(report.ByReason))
+	for reason, n := range report.ByReason {
+		byReason[reason] = n
+	}
+	s.emit(actor, "retention:applied", map[string]interface{}{
+		"deleted":     len(report.Clips),
+		"freed_bytes": report.FreedBytes,
+		"by_reason":   byReason,
+	})
+	return report, nil
+}
+
+// deleteInBatches deletes many clips with DeleteClips, deleteBatchSize at a time
+func (s *Service) deleteInBatches(actor Actor, ids []int64) error {
+	for start := 0; start < len(ids); start += deleteBatchSize {
+		end := start + deleteBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		if err := s.DeleteClips(actor, ids[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// vacuum returns free pages to the file system, incrementally if the
+// database was created with auto_vacuum = INCREMENTAL
+func (s *Service) vacuum() error {
+	var mode int
+	if err := s.db.QueryRow("PRAGMA auto_vacuum").Scan(&mode); err != nil {
+		return fmt.Errorf("failed to read auto_vacuum mode: %w", err)
+	}
+	statement := "VACUUM"
+	if mode == 2 {
+		statement = "PRAGMA incremental_vacuum"
+	}
+	if _, err := s.db.Exec(statement); err != nil {
+		return fmt.Errorf("failed to vacuum database: %w", err)
+	}
+	return nil
+}
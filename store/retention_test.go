@@ -0,0 +1,166 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+)
+
+// ageClip creates a clip that is the given number of days old
+func ageClip(t *testing.T, s *Service, days int, size int) int64 {
+	t.Helper()
+	clip, err := s.CreateClip(User, NewClip{ContentType: "text/plain", Data: make([]byte, size)})
+	if err != nil {
+		t.Fatalf("CreateClip failed: %v", err)
+	}
+	if _, err := s.db.Exec("UPDATE clips SET created_at = datetime('now', ?) WHERE id = ?",
+		fmt.Sprintf("-%d days", days), clip.ID); err != nil {
+		t.Fatalf("Failed to age clip: %v", err)
+	}
+	return clip.ID
+}
+
+func reportIDs(r *RetentionReport) []int64 {
+	ids := make([]int64, len(r.Clips))
+	for i, c := range r.Clips {
+		ids[i] = c.ID
+	}
+	return ids
+}
+
+func TestPlanRetention_AgeRules(t *testing.T) {
+	s, _ := newTestService(t)
+
+	oldUntagged := ageClip(t, s, 40, 10)
+	ageClip(t, s, 5, 10) // untagged but recent
+	oldTagged := ageClip(t, s, 40, 10)
+	oldKept := ageClip(t, s, 400, 10)
+	oldTemp := ageClip(t, s, 3, 10)
+	oldArchived := ageClip(t, s, 400, 10)
+	oldPinned := ageClip(t, s, 400, 10)
+
+	work, _ := s.CreateTag(User, "work")
+	keep, _ := s.CreateTag(User, "Keep/Forever")
+	temp, _ := s.CreateTag(User, "temp")
+	s.AddTagToClip(User, oldTagged, work.ID)
+	s.AddTagToClip(User, oldKept, keep.ID)
+	s.AddTagToClip(User, oldTemp, temp.ID)
+	s.SetArchived(User, oldArchived, true)
+	s.SetPinned(User, oldPinned, true)
+
+	policy := RetentionPolicy{
+		MaxAgeDays:         365,
+		UntaggedMaxAgeDays: 30,
+		KeepArchived:       true,
+		TagRules: []TagRetentionRule{
+			{Tag: "keep", Keep: true},
+			{Tag: "temp", MaxAgeDays: 1},
+		},
+	}
+	if err := policy.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	report, err := s.PlanRetention(policy)
+	if err != nil {
+		t.Fatalf("PlanRetention failed: %v", err)
+	}
+	if got := reportIDs(report); !equalIDs(got, []int64{oldUntagged, oldTemp}) {
+		t.Errorf("Expected clips %v, got %v", []int64{oldUntagged, oldTemp}, got)
+	}
+	if report.ByReason[ReasonUntaggedMaxAge] != 1 || report.ByReason[ReasonTagMaxAge] != 1 {
+		t.Errorf("Unexpected reasons: %v", report.ByReason)
+	}
+	if report.FreedBytes != 20 {
+		t.Errorf("Expected 20 freed bytes, got %d", report.FreedBytes)
+	}
+
+	// Without KeepArchived the archived clip is past the global age
+	policy.KeepArchived = false
+	report, _ = s.PlanRetention(policy)
+	if got := reportIDs(report); !equalIDs(got, []int64{oldArchived, oldUntagged, oldTemp}) {
+		t.Errorf("Expected the archived clip to be deleted too, got %v", got)
+	}
+}
+
+func TestPlanRetention_Quotas(t *testing.T) {
+	s, _ := newTestService(t)
+
+	first := ageClip(t, s, 5, 100)
+	pinned := ageClip(t, s, 4, 100)
+	second := ageClip(t, s, 3, 100)
+	third := ageClip(t, s, 2, 100)
+	ageClip(t, s, 1, 100)
+	s.SetPinned(User, pinned, true)
+
+	// A trashed clip counts towards neither quota and isn't deleted by them
+	trashed := ageClip(t, s, 6, 100)
+	if err := s.TrashClips(User, []int64{trashed}); err != nil {
+		t.Fatalf("TrashClips failed: %v", err)
+	}
+
+	report, err := s.PlanRetention(RetentionPolicy{MaxClips: 3})
+	if err != nil {
+		t.Fatalf("PlanRetention failed: %v", err)
+	}
+	if got := reportIDs(report); !equalIDs(got, []int64{first, second}) {
+		t.Errorf("Expected the oldest unpinned clips, got %v", got)
+	}
+
+	report, _ = s.PlanRetention(RetentionPolicy{MaxBytes: 250})
+	if got := reportIDs(report); !equalIDs(got, []int64{first, second, third}) {
+		t.Errorf("Expected clips over the size quota, got %v", got)
+	}
+	if report.ByReason[ReasonMaxBytes] != 3 {
+		t.Errorf("Unexpected reasons: %v", report.ByReason)
+	}
+
+	if report, _ := s.PlanRetention(RetentionPolicy{}); len(report.Clips) != 0 {
+		t.Errorf("Expected an empty policy to delete nothing, got %v", reportIDs(report))
+	}
+}
+
+func TestApplyRetention(t *testing.T) {
+	s, rec := newTestService(t)
+
+	old := ageClip(t, s, 10, 10)
+	kept := ageClip(t, s, 1, 10)
+	rec.names()
+
+	report, err := s.ApplyRetention(System, RetentionPolicy{MaxAgeDays: 7})
+	if err != nil {
+		t.Fatalf("ApplyRetention failed: %v", err)
+	}
+	if got := reportIDs(report); !equalIDs(got, []int64{old}) {
+		t.Errorf("Expected clip %d deleted, got %v", old, got)
+	}
+	if got := rec.names(); !equalNames(got, []string{"clip:deleted", "retention:applied"}) {
+		t.Errorf("Unexpected events: %v", got)
+	}
+	if !s.clipExists(kept) || s.clipExists(old) {
+		t.Error("Expected only the old clip to be deleted")
+	}
+
+	// Nothing left to delete, no summary
+	s.ApplyRetention(System, RetentionPolicy{MaxAgeDays: 7})
+	if got := rec.names(); len(got) != 0 {
+		t.Errorf("Expected no events, got %v", got)
+	}
+}
+
+func TestRetentionPolicy_Validate(t *testing.T) {
+	invalid := []RetentionPolicy{
+		{MaxAgeDays: -1},
+		{MaxBytes: -1},
+		{TagRules: []TagRetentionRule{{Tag: " / "}}},
+		{TagRules: []TagRetentionRule{{Tag: "a", MaxAgeDays: -2}}},
+	}
+	for _, p := range invalid {
+		if err := p.Validate(); err == nil {
+			t.Errorf("Expected error for %+v", p)
+		}
+	}
+
+	p := RetentionPolicy{TagRules: []TagRetentionRule{{Tag: " project / alpha ", Keep: true}}}
+	if err := p.Validate(); err != nil || p.TagRules[0].Tag != "project/alpha" {
+		t.Errorf("Expected normalized tag, got %q (%v)", p.TagRules[0].Tag, err)
+	}
+}
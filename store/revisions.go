@@ -0,0 +1,266 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultRevisionBudget is the number of bytes of revisions kept per clip.
+// The oldest revisions are dropped once a clip's history exceeds it.
+const DefaultRevisionBudget = 20 * 1024 * 1024
+
+var (
+	ErrRevisionNotFound = errors.New("revision not found")
+	ErrNotText          = errors.New("only text revisions can be compared")
+)
+
+// Revision describes a previous version of a clip
+type Revision struct {
+	ID          int64
+	ClipID      int64
+	ContentType string
+	Filename    string
+	Size        int64 // size of the full content, not of the stored delta
+	CreatedAt   time.Time
+}
+
+// revisionContent is the content of a clip version
+type revisionContent struct {
+	ContentType string
+	Data        []byte
+	Filename    sql.NullString
+}
+
+// IsText reports whether clips of a content type hold text
+func IsText(contentType string) bool {
+	return strings.HasPrefix(contentType, "text/") || contentType == "application/json"
+}
+
+// recordRevision stores old as the newest revision of a clip that is about to
+// be replaced by newData. Text replaced by text is stored as a delta against the
+// new content, so each delta applies to the next newer version; anything else is
+// stored in full. The history is then pruned to the revision budget.
+func (s *Service) recordRevision(tx *sql.Tx, clipID int64, old revisionContent, newContentType string, newData []byte) error {
+	stored, isDelta := old.Data, false
+	if IsText(old.ContentType) && IsText(newContentType) {
+		if delta := makeDelta(newData, old.Data); len(delta) < len(old.Data) {
+			stored, isDelta = delta, true
+		}
+	}
+
+	stored, encrypted, err := s.seal(stored)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`INSERT INTO clip_revisions (clip_id, content_type, data, is_encrypted, filename, is_delta, size)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		clipID, old.ContentType, stored, boolToInt(encrypted), old.Filename, boolToInt(isDelta), len(old.Data)); err != nil {
+		return fmt.Errorf("failed to record revision: %w", err)
+	}
+	return s.pruneRevisions(tx, clipID)
+}
+
+// pruneRevisions deletes the oldest revisions of a clip beyond the budget. The
+// newest revision is always kept so the last edit can be undone. Dropping old
+// revisions never breaks newer ones, as deltas only refer to newer versions.
+func (s *Service) pruneRevisions(tx *sql.Tx, clipID int64) error {
+	budget := s.revisionBudget
+	if budget == 0 {
+		budget = DefaultRevisionBudget
+	}
+
+	rows, err := tx.Query("SELECT id, LENGTH(data) FROM clip_revisions WHERE clip_id = ? ORDER BY id DESC", clipID)
+	if err != nil {
+		return fmt.Errorf("failed to query revisions: %w", err)
+	}
+	var total int64
+	var cutoff int64
+	for rows.Next() {
+		var id, size int64
+		if err := rows.Scan(&id, &size); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan revision: %w", err)
+		}
+		if total > 0 && total+size > budget {
+			cutoff = id
+			break
+		}
+		total += size
+	}
+	rows.Close()
+
+	if cutoff == 0 {
+		return nil
+	}
+	if _, err := tx.Exec("DELETE FROM clip_revisions WHERE clip_id = ? AND id <= ?", clipID, cutoff); err != nil {
+		return fmt.Errorf("failed to prune revisions: %w", err)
+	}
+	return nil
+}
+
+// ListRevisions returns the revisions of a clip, newest first
+func (s *Service) ListRevisions(clipID int64) ([]Revision, error) {
+	if !s.clipExists(clipID) {
+		return nil, ErrClipNotFound
+	}
+
+	rows, err := s.db.Query(`SELECT id, content_type, filename, size, created_at
+		FROM clip_revisions WHERE clip_id = ? ORDER BY id DESC`, clipID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query revisions: %w", err)
+	}
+	defer rows.Close()
+
+	var revisions []Revision
+	for rows.Next() {
+		r := Revision{ClipID: clipID}
+		var filename sql.NullString
+		if err := rows.Scan(&r.ID, &r.ContentType, &filename, &r.Size, &r.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan revision: %w", err)
+		}
+		r.Filename = filename.String
+		revisions = append(revisions, r)
+	}
+	return revisions, rows.Err()
+}
+
+// loadRevision rebuilds a revision by applying deltas backwards from the
+// current clip. A revisionID of 0 returns the current clip.
+func (s *Service) loadRevision(q querier, clipID, revisionID int64) (*revisionContent, error) {
+	current := &revisionContent{}
+	var encrypted bool
+	err := q.QueryRow("SELECT content_type, data, is_encrypted, filename FROM clips WHERE id = ?", clipID).
+		Scan(&current.ContentType, &current.Data, &encrypted, &current.Filename)
+	if err == sql.ErrNoRows {
+		return nil, ErrClipNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query clip: %w", err)
+	}
+	if current.Data, err = s.DecryptData(current.Data, encrypted); err != nil {
+		return nil, err
+	}
+	if revisionID == 0 {
+		return current, nil
+	}
+
+	rows, err := q.Query(`SELECT id, content_type, data, is_encrypted, filename, is_delta FROM clip_revisions
+		WHERE clip_id = ? AND id >= ? ORDER BY id DESC`, clipID, revisionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query revisions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var isDelta bool
+		var data []byte
+		next := &revisionContent{}
+		if err := rows.Scan(&id, &next.ContentType, &data, &encrypted, &next.Filename, &isDelta); err != nil {
+			return nil, fmt.Errorf("failed to scan revision: %w", err)
+		}
+		if data, err = s.DecryptData(data, encrypted); err != nil {
+			return nil, fmt.Errorf("revision %d: %w", id, err)
+		}
+		next.Data = data
+		if isDelta {
+			if next.Data, err = applyDelta(current.Data, data); err != nil {
+				return nil, fmt.Errorf("revision %d: %w", id, err)
+			}
+		}
+		current = next
+		if id == revisionID {
+			return current, nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return nil, ErrRevisionNotFound
+}
+
+// RevisionData returns the content type, data and filename of a revision.
+// A revisionID of 0 returns the current clip.
+func (s *Service) RevisionData(clipID, revisionID int64) (string, []byte, string, error) {
+	rev, err := s.loadRevision(s.db, clipID, revisionID)
+	if err != nil {
+		return "", nil, "", err
+	}
+	return rev.ContentType, rev.Data, rev.Filename.String, nil
+}
+
+// DiffRevisions returns the line diff between two text revisions of a clip.
+// A revision ID of 0 stands for the current clip.
+func (s *Service) DiffRevisions(clipID, fromID, toID int64) ([]DiffLine, error) {
+	from, err := s.loadRevision(s.db, clipID, fromID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.loadRevision(s.db, clipID, toID)
+	if err != nil {
+		return nil, err
+	}
+	if !IsText(from.ContentType) || !IsText(to.ContentType) {
+		return nil, ErrNotText
+	}
+	return diffLines(string(from.Data), string(to.Data)), nil
+}
+
+// RestoreRevision makes a revision the clip's current content and emits
+// clip:updated. The replaced content becomes a new revision, so a restore can
+// itself be undone.
+func (s *Service) RestoreRevision(actor Actor, clipID, revisionID int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rev, err := s.loadRevision(tx, clipID, revisionID)
+	if err != nil {
+		return err
+	}
+	updated, err := s.updateClip(tx, clipID, ClipUpdate{
+		Data:        rev.Data,
+		ContentType: &rev.ContentType,
+		Filename:    &rev.Filename.String,
+	})
+	if err != nil || updated == nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	s.emit(actor, "clip:updated", updated)
+	return nil
+}
+
+// LinkDerived records that a clip was derived from another one, e.g. the
+// result of a plugin action. Clips that already have a parent keep it. No event
+// is emitted as the clip's content doesn't change.
+func (s *Service) LinkDerived(clipID, parentID int64) error {
+	if clipID == parentID {
+		return nil
+	}
+	if !s.clipExists(parentID) {
+		return ErrClipNotFound
+	}
+	result, err := s.db.Exec("UPDATE clips SET parent_id = ? WHERE id = ? AND parent_id IS NULL", parentID, clipID)
+	if err != nil {
+		return fmt.Errorf("failed to link clip: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 && !s.clipExists(clipID) {
+		return ErrClipNotFound
+	}
+	return nil
+}
+
+// DerivedClips returns the IDs of clips derived from a clip
+func (s *Service) DerivedClips(clipID int64) ([]int64, error) {
+	return queryIDs(s.db, "SELECT id FROM clips WHERE parent_id = ? AND deleted_at IS NULL ORDER BY id", clipID)
+}
@@ -0,0 +1,193 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestDelta_RoundTrip(t *testing.T) {
+	tests := []struct{ base, target string }{
+		{"hello world", "hello there world"},
+		{"abc", ""},
+		{"", "abc"},
+		{"same", "same"},
+		{"aaaa", "aa"},
+		{"line one\nline two\n", "line one\nline 2\n"},
+	}
+
+	for _, tt := range tests {
+		delta := makeDelta([]byte(tt.base), []byte(tt.target))
+		got, err := applyDelta([]byte(tt.base), delta)
+		if err != nil {
+			t.Fatalf("applyDelta(%q) failed: %v", tt.base, err)
+		}
+		if string(got) != tt.target {
+			t.Errorf("applyDelta(%q, makeDelta) = %q, want %q", tt.base, got, tt.target)
+		}
+	}
+
+	if _, err := applyDelta([]byte("ab"), makeDelta([]byte("abcdef"), []byte("abXdef"))); err == nil {
+		t.Error("Expected error applying a delta to the wrong base")
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	got := diffLines("a\nb\nc\nd", "a\nc\nx\nd")
+	want := []DiffLine{
+		{DiffEqual, "a"},
+		{DiffDelete, "b"},
+		{DiffEqual, "c"},
+		{DiffInsert, "x"},
+		{DiffEqual, "d"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Line %d: expected %v, got %v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestRevisions_TextHistory(t *testing.T) {
+	s, _ := newTestService(t)
+
+	clip, _ := s.CreateClip(User, NewClip{ContentType: "text/plain", Data: []byte("one\ntwo\nthree"), Filename: "v1.txt"})
+	versions := []string{"one\n2\nthree", "one\n2\nthree\nfour", "zero\none\n2\nthree\nfour"}
+	for _, v := range versions {
+		if err := s.UpdateClip(User, clip.ID, ClipUpdate{Data: []byte(v)}); err != nil {
+			t.Fatalf("UpdateClip failed: %v", err)
+		}
+	}
+
+	revisions, err := s.ListRevisions(clip.ID)
+	if err != nil {
+		t.Fatalf("ListRevisions failed: %v", err)
+	}
+	if len(revisions) != 3 {
+		t.Fatalf("Expected 3 revisions, got %d", len(revisions))
+	}
+
+	// Text revisions are stored as deltas but rebuilt in full
+	var deltas int
+	s.db.QueryRow("SELECT COUNT(*) FROM clip_revisions WHERE is_delta = 1").Scan(&deltas)
+	if deltas != 3 {
+		t.Errorf("Expected 3 delta revisions, got %d", deltas)
+	}
+	oldest := revisions[2]
+	_, data, filename, err := s.RevisionData(clip.ID, oldest.ID)
+	if err != nil {
+		t.Fatalf("RevisionData failed: %v", err)
+	}
+	if string(data) != "one\ntwo\nthree" || filename != "v1.txt" {
+		t.Errorf("Unexpected oldest revision: %q %q", data, filename)
+	}
+	if oldest.Size != int64(len("one\ntwo\nthree")) {
+		t.Errorf("Expected size of the full content, got %d", oldest.Size)
+	}
+
+	diff, err := s.DiffRevisions(clip.ID, oldest.ID, 0)
+	if err != nil {
+		t.Fatalf("DiffRevisions failed: %v", err)
+	}
+	var ops []string
+	for _, l := range diff {
+		ops = append(ops, l.Op+":"+l.Text)
+	}
+	want := []string{"insert:zero", "equal:one", "delete:two", "insert:2", "equal:three", "insert:four"}
+	if !equalNames(ops, want) {
+		t.Errorf("Expected diff %v, got %v", want, ops)
+	}
+
+	// Restoring keeps the replaced content as a new revision
+	if err := s.RestoreRevision(User, clip.ID, oldest.ID); err != nil {
+		t.Fatalf("RestoreRevision failed: %v", err)
+	}
+	_, data, _, _ = s.RevisionData(clip.ID, 0)
+	if string(data) != "one\ntwo\nthree" {
+		t.Errorf("Clip not restored: %q", data)
+	}
+	revisions, _ = s.ListRevisions(clip.ID)
+	if len(revisions) != 4 {
+		t.Fatalf("Expected 4 revisions after restore, got %d", len(revisions))
+	}
+	_, data, _, _ = s.RevisionData(clip.ID, revisions[0].ID)
+	if string(data) != versions[2] {
+		t.Errorf("Restore should keep the replaced content, got %q", data)
+	}
+	// Older deltas still resolve through the restored content
+	_, data, _, _ = s.RevisionData(clip.ID, revisions[2].ID)
+	if string(data) != versions[0] {
+		t.Errorf("Expected %q, got %q", versions[0], data)
+	}
+
+	if err := s.RestoreRevision(User, clip.ID, 999); !errors.Is(err, ErrRevisionNotFound) {
+		t.Errorf("Expected ErrRevisionNotFound, got %v", err)
+	}
+}
+
+func TestRevisions_BinaryBudget(t *testing.T) {
+	s, _ := newTestService(t)
+	s.revisionBudget = 25
+
+	clip, _ := s.CreateClip(User, NewClip{ContentType: "image/png", Data: bytes.Repeat([]byte{0}, 10)})
+	for i := 1; i <= 3; i++ {
+		data := bytes.Repeat([]byte{byte(i)}, 10)
+		if err := s.UpdateClip(User, clip.ID, ClipUpdate{Data: data}); err != nil {
+			t.Fatalf("UpdateClip failed: %v", err)
+		}
+	}
+
+	// Three 10 byte revisions don't fit a 25 byte budget, so the oldest goes
+	revisions, _ := s.ListRevisions(clip.ID)
+	if len(revisions) != 2 {
+		t.Fatalf("Expected 2 revisions within budget, got %d", len(revisions))
+	}
+	_, data, _, _ := s.RevisionData(clip.ID, revisions[1].ID)
+	if !bytes.Equal(data, bytes.Repeat([]byte{1}, 10)) {
+		t.Errorf("Expected the second version to be kept, got %v", data)
+	}
+
+	if _, err := s.DiffRevisions(clip.ID, revisions[1].ID, 0); !errors.Is(err, ErrNotText) {
+		t.Errorf("Expected ErrNotText, got %v", err)
+	}
+
+	// The newest revision is kept even if it exceeds the budget on its own
+	s.revisionBudget = 5
+	s.UpdateClip(User, clip.ID, ClipUpdate{Data: []byte("0123456789")})
+	if revisions, _ := s.ListRevisions(clip.ID); len(revisions) != 1 {
+		t.Errorf("Expected only the newest revision, got %d", len(revisions))
+	}
+}
+
+func TestLinkDerived(t *testing.T) {
+	s, _ := newTestService(t)
+
+	source, _ := s.CreateClip(User, NewClip{Data: []byte("source")})
+	result, _ := s.CreateClip(User, NewClip{Data: []byte("result")})
+	edit, err := s.CreateClip(User, NewClip{Data: []byte("edit"), ParentID: source.ID})
+	if err != nil {
+		t.Fatalf("CreateClip with parent failed: %v", err)
+	}
+	if _, err := s.CreateClip(User, NewClip{Data: []byte("x"), ParentID: 999}); err == nil {
+		t.Error("Expected error for missing parent")
+	}
+
+	if err := s.LinkDerived(result.ID, source.ID); err != nil {
+		t.Fatalf("LinkDerived failed: %v", err)
+	}
+	derived, _ := s.DerivedClips(source.ID)
+	if len(derived) != 2 || derived[0] != result.ID || derived[1] != edit.ID {
+		t.Errorf("Unexpected derived clips: %v", derived)
+	}
+
+	// Deleting the source unlinks the derived clips
+	s.DeleteClip(User, source.ID)
+	var linked int
+	s.db.QueryRow("SELECT COUNT(*) FROM clips WHERE parent_id IS NOT NULL").Scan(&linked)
+	if linked != 0 {
+		t.Errorf("Expected derived clips to be unlinked, %d still linked", linked)
+	}
+}
@@ -0,0 +1,380 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MaxSavedSearchNameLength is the maximum length of a saved search name
+const MaxSavedSearchNameLength = 100
+
+var ErrSavedSearchNotFound = errors.New("saved search not found")
+
+// Filter selects clips. Empty fields don't filter. Filters are stored as JSON
+// in saved searches, so fields must keep their names.
+type Filter struct {
+	Archived bool   `json:"archived"`
+	Trashed  bool   `json:"trashed"` // list the trash instead, archived or not
+	Text     string `json:"text"`    // case-insensitive, in the filename or text content
+
+	TagIDs   []int64 `json:"tag_ids"`   // clips must have all of these tags or their children
+	TagQuery string  `json:"tag_query"` // see TagQuery
+
+	ContentType   string `json:"content_type"`   // exact, or a prefix such as "image/*"
+	CreatedAfter  int64  `json:"created_after"`  // Unix seconds
+	CreatedBefore int64  `json:"created_before"` // Unix seconds
+	WithinDays    int    `json:"within_days"`    // created in the last n days
+	MinSize       int64  `json:"min_size"`       // bytes
+	MaxSize       int64  `json:"max_size"`       // bytes
+
+	SourceKind string `json:"source_kind"`
+	SourcePath string `json:"source_path"` // prefix of the original path or URL
+	PluginID   int64  `json:"plugin_id"`
+	Language   string `json:"language"`
+
+	CollectionID int64 `json:"collection_id"`
+}
+
+// Condition returns an SQL condition selecting the visible clips that match
+// the filter, where alias is the alias of the clips table (e.g. "c"). Trashed
+// clips are only selected by a Trashed filter.
+func (f Filter) Condition(alias string) (string, []interface{}, error) {
+	col := func(name string) string { return alias + "." + name }
+
+	var conditions []string
+	var args []interface{}
+	if f.Trashed {
+		conditions = []string{col("deleted_at") + " IS NOT NULL"}
+	} else {
+		conditions = []string{
+			col("deleted_at") + " IS NULL",
+			col("is_archived") + " = ?",
+			"(" + col("is_pinned") + " = 1 OR " + col("expires_at") + " IS NULL OR " + col("expires_at") + " > CURRENT_TIMESTAMP)",
+		}
+		args = []interface{}{boolToInt(f.Archived)}
+	}
+	add := func(condition string, values ...interface{}) {
+		conditions = append(conditions, condition)
+		args = append(args, values...)
+	}
+
+	if f.Text != "" {
+		pattern := "%" + escapeLike(f.Text) + "%"
+		// The content of encrypted clips can't be searched by the database, and
+		// sensitive clips only match by filename so a search can't probe them
+		add(fmt.Sprintf(`(%s LIKE ? ESCAPE '\' OR (%s = 0 AND %s = 0 AND (%s LIKE 'text/%%' OR %s = 'application/json') AND CAST(%s AS TEXT) LIKE ? ESCAPE '\'))`,
+			col("filename"), col("is_encrypted"), col("is_sensitive"), col("content_type"), col("content_type"), col("data")), pattern, pattern)
+	}
+
+	tagQuery, err := ParseTagQuery(f.TagQuery)
+	if err != nil {
+		return "", nil, err
+	}
+	if tagQuery = AllTags(f.TagIDs).And(tagQuery); tagQuery != nil {
+		condition, tagArgs := tagQuery.Condition(col("id"))
+		add(condition, tagArgs...)
+	}
+
+	if prefix, ok := strings.CutSuffix(f.ContentType, "*"); ok {
+		add("SUBSTR("+col("content_type")+", 1, LENGTH(?)) = ?", prefix, prefix)
+	} else if f.ContentType != "" {
+		add(col("content_type")+" = ?", f.ContentType)
+	}
+	if f.CreatedAfter != 0 {
+		add(col("created_at")+" >= datetime(?, 'unixepoch')", f.CreatedAfter)
+	}
+	if f.CreatedBefore != 0 {
+		add(col("created_at")+" < datetime(?, 'unixepoch')", f.CreatedBefore)
+	}
+	if f.WithinDays > 0 {
+		add(col("created_at")+" >= datetime('now', ?)", fmt.Sprintf("-%d days", f.WithinDays))
+	}
+	if f.MinSize > 0 {
+		add(dataSize(col)+" >= ?", f.MinSize)
+	}
+	if f.MaxSize > 0 {
+		add(dataSize(col)+" <= ?", f.MaxSize)
+	}
+
+	if f.SourceKind != "" {
+		add(col("source_kind")+" = ?", f.SourceKind)
+	}
+	if f.SourcePath != "" {
+		add("(SUBSTR("+col("source_path")+", 1, LENGTH(?)) = ? OR SUBSTR("+col("source_url")+", 1, LENGTH(?)) = ?)",
+			f.SourcePath, f.SourcePath, f.SourcePath, f.SourcePath)
+	}
+	if f.PluginID != 0 {
+		add(col("source_plugin_id")+" = ?", f.PluginID)
+	}
+	if f.Language != "" {
+		add(col("language")+" = ?", f.Language)
+	}
+	if f.CollectionID != 0 {
+		add(col("id")+" IN (SELECT clip_id FROM collection_clips WHERE collection_id = ?)", f.CollectionID)
+	}
+
+	return strings.Join(conditions, " AND "), args, nil
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// SavedSearch is a named filter. Plugins are notified with search:matched
+// when a clip starts matching it.
+type SavedSearch struct {
+	ID        int64
+	Name      string
+	Filter    Filter
+	CreatedAt time.Time
+}
+
+// validateSavedSearch trims a saved search name and checks that the filter is valid
+func validateSavedSearch(name string, filter Filter) (string, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", "", fmt.Errorf("saved search name cannot be empty")
+	}
+	if len(name) > MaxSavedSearchNameLength {
+		return "", "", fmt.Errorf("saved search name too long (max %d characters)", MaxSavedSearchNameLength)
+	}
+	if _, _, err := filter.Condition("c"); err != nil {
+		return "", "", err
+	}
+	encoded, err := json.Marshal(filter)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encode filter: %w", err)
+	}
+	return name, string(encoded), nil
+}
+
+// CreateSavedSearch saves a filter under a name. Clips that already match it
+// are recorded without notifying plugins.
+func (s *Service) CreateSavedSearch(name string, filter Filter) (*SavedSearch, error) {
+	name, encoded, err := validateSavedSearch(name, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("INSERT INTO saved_searches (name, filter) VALUES (?, ?)", name, encoded)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return nil, fmt.Errorf("saved search already exists: %s", name)
+		}
+		return nil, fmt.Errorf("failed to create saved search: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get saved search ID: %w", err)
+	}
+	if err := recordMatches(tx, id, filter); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return s.GetSavedSearch(id)
+}
+
+// UpdateSavedSearch renames a saved search and replaces its filter. The
+// clips matching the new filter are recorded without notifying plugins.
+func (s *Service) UpdateSavedSearch(id int64, name string, filter Filter) error {
+	name, encoded, err := validateSavedSearch(name, filter)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("UPDATE saved_searches SET name = ?, filter = ? WHERE id = ?", name, encoded, id)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return fmt.Errorf("saved search name already exists: %s", name)
+		}
+		return fmt.Errorf("failed to update saved search: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrSavedSearchNotFound
+	}
+	if _, err := tx.Exec("DELETE FROM saved_search_matches WHERE search_id = ?", id); err != nil {
+		return fmt.Errorf("failed to clear matches: %w", err)
+	}
+	if err := recordMatches(tx, id, filter); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// DeleteSavedSearch deletes a saved search. Its clips are not affected.
+func (s *Service) DeleteSavedSearch(id int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM saved_search_matches WHERE search_id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete from saved_search_matches: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM saved_searches WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete saved search: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// recordMatches records the clips currently matching a saved search
+func recordMatches(tx *sql.Tx, searchID int64, filter Filter) error {
+	condition, args, err := filter.Condition("c")
+	if err != nil {
+		return err
+	}
+	args = append([]interface{}{searchID}, args...)
+	if _, err := tx.Exec("INSERT OR IGNORE INTO saved_search_matches (search_id, clip_id) SELECT ?, c.id FROM clips c WHERE "+condition, args...); err != nil {
+		return fmt.Errorf("failed to record matches: %w", err)
+	}
+	return nil
+}
+
+// ListSavedSearches returns all saved searches by name
+func (s *Service) ListSavedSearches() ([]SavedSearch, error) {
+	rows, err := s.db.Query("SELECT id, name, filter, created_at FROM saved_searches ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query saved searches: %w", err)
+	}
+	defer rows.Close()
+
+	var searches []SavedSearch
+	for rows.Next() {
+		search, err := scanSavedSearch(rows)
+		if err != nil {
+			return nil, err
+		}
+		searches = append(searches, *search)
+	}
+	return searches, rows.Err()
+}
+
+// GetSavedSearch returns a saved search by ID
+func (s *Service) GetSavedSearch(id int64) (*SavedSearch, error) {
+	search, err := scanSavedSearch(s.db.QueryRow("SELECT id, name, filter, created_at FROM saved_searches WHERE id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSavedSearchNotFound
+	}
+	return search, err
+}
+
+func scanSavedSearch(row interface{ Scan(...interface{}) error }) (*SavedSearch, error) {
+	var search SavedSearch
+	var encoded string
+	if err := row.Scan(&search.ID, &search.Name, &encoded, &search.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan saved search: %w", err)
+	}
+	if err := json.Unmarshal([]byte(encoded), &search.Filter); err != nil {
+		return nil, fmt.Errorf("saved search %d has an invalid filter: %w", search.ID, err)
+	}
+	return &search, nil
+}
+
+// CountClips returns the number of clips matching a filter
+func (s *Service) CountClips(filter Filter) (int, error) {
+	condition, args, err := filter.Condition("c")
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM clips c WHERE "+condition, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count clips: %w", err)
+	}
+	return count, nil
+}
+
+// changedClipIDs returns the clips whose properties an event may have changed
+func changedClipIDs(e Event) []int64 {
+	data, ok := e.Data.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	switch e.Name {
+	case "clip:created", "clip:updated", "clip:archived", "clip:unarchived", "clip:pinned", "clip:unpinned",
+		"clip:trashed", "clip:restored":
+		if id, ok := data["id"].(int64); ok {
+			return []int64{id}
+		}
+	case "tag:added_to_clip", "tag:removed_from_clip", "collection:clip_added", "collection:clip_removed":
+		if id, ok := data["clip_id"].(int64); ok {
+			return []int64{id}
+		}
+	}
+	return nil
+}
+
+// matchSavedSearches checks the clips changed by an event against the saved
+// searches and emits search:matched, with the event's actor, for each search a
+// clip starts matching. Clips that stop matching are forgotten so they are
+// reported again if they match later.
+func (s *Service) matchSavedSearches(e Event) {
+	clipIDs := changedClipIDs(e)
+	if len(clipIDs) == 0 {
+		return
+	}
+	searches, err := s.ListSavedSearches()
+	if err != nil || len(searches) == 0 {
+		return
+	}
+
+	for _, clipID := range clipIDs {
+		for _, search := range searches {
+			condition, args, err := search.Filter.Condition("c")
+			if err != nil {
+				continue
+			}
+			var matches int
+			args = append([]interface{}{clipID}, args...)
+			if err := s.db.QueryRow("SELECT COUNT(*) FROM clips c WHERE c.id = ? AND "+condition, args...).Scan(&matches); err != nil {
+				continue
+			}
+
+			if matches == 0 {
+				s.db.Exec("DELETE FROM saved_search_matches WHERE search_id = ? AND clip_id = ?", search.ID, clipID)
+				continue
+			}
+			result, err := s.db.Exec("INSERT OR IGNORE INTO saved_search_matches (search_id, clip_id) VALUES (?, ?)", search.ID, clipID)
+			if err != nil {
+				continue
+			}
+			if n, _ := result.RowsAffected(); n > 0 {
+				s.emit(e.Actor, "search:matched", map[string]interface{}{
+					"search_id": search.ID,
+					"name":      search.Name,
+					"clip_id":   clipID,
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFilter_Condition(t *testing.T) {
+	s, _ := newTestService(t)
+
+	notes, _ := s.CreateClip(User, NewClip{ContentType: "text/plain", Data: []byte("Meeting NOTES 100%"), Filename: "a.txt"})
+	config, _ := s.CreateClip(User, NewClip{ContentType: "application/json", Data: []byte(`{"api": true}`), Filename: "config.json"})
+	image, _ := s.CreateClip(User, NewClip{ContentType: "image/png", Data: make([]byte, 2048), Filename: "notes.png"})
+	old, _ := s.CreateClip(User, NewClip{ContentType: "text/plain", Data: []byte("old")})
+	s.db.Exec("UPDATE clips SET created_at = datetime('now', '-10 days') WHERE id = ?", old.ID)
+	s.SetArchived(User, config.ID, true)
+
+	week := time.Now().AddDate(0, 0, -7).Unix()
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []int64
+	}{
+		{"all active", Filter{}, []int64{notes.ID, image.ID, old.ID}},
+		{"archived", Filter{Archived: true}, []int64{config.ID}},
+		{"text in content or filename", Filter{Text: "notes"}, []int64{notes.ID, image.ID}},
+		{"text wildcards are literal", Filter{Text: "0%"}, []int64{notes.ID}},
+		{"content type prefix", Filter{ContentType: "text/*"}, []int64{notes.ID, old.ID}},
+		{"exact content type", Filter{ContentType: "image/png"}, []int64{image.ID}},
+		{"size", Filter{MinSize: 1024}, []int64{image.ID}},
+		{"max size", Filter{MaxSize: 10}, []int64{old.ID}},
+		{"within days", Filter{WithinDays: 7}, []int64{notes.ID, image.ID}},
+		{"created before", Filter{CreatedBefore: week}, []int64{old.ID}},
+		{"created after", Filter{CreatedAfter: week, ContentType: "text/plain"}, []int64{notes.ID}},
+	}
+	for _, tt := range tests {
+		condition, args, err := tt.filter.Condition("c")
+		if err != nil {
+			t.Fatalf("%s: Condition failed: %v", tt.name, err)
+		}
+		got, err := queryIDs(s.db, "SELECT c.id FROM clips c WHERE "+condition+" ORDER BY c.id", args...)
+		if err != nil {
+			t.Fatalf("%s: query failed: %v", tt.name, err)
+		}
+		if !equalIDs(got, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+
+	// Sensitive clips only match by filename
+	private, _ := s.CreateClip(User, NewClip{ContentType: "text/plain", Data: []byte("recovery notes"), Filename: "vault.txt"})
+	s.SetSensitive(User, []int64{private.ID}, true, 0)
+	for text, want := range map[string][]int64{"notes": {notes.ID, image.ID}, "vault": {private.ID}} {
+		condition, args, _ := Filter{Text: text}.Condition("c")
+		if got, _ := queryIDs(s.db, "SELECT c.id FROM clips c WHERE "+condition+" ORDER BY c.id", args...); !equalIDs(got, want) {
+			t.Errorf("text %q: expected %v, got %v", text, want, got)
+		}
+	}
+
+	if _, _, err := (Filter{TagQuery: "(a"}).Condition("c"); err == nil {
+		t.Error("Expected error for invalid tag query")
+	}
+}
+
+func TestSavedSearch_Matched(t *testing.T) {
+	s, rec := newTestService(t)
+
+	existing, _ := s.CreateClip(User, NewClip{ContentType: "application/json", Data: []byte("{}")})
+	api, _ := s.CreateTag(User, "api")
+	s.AddTagToClip(User, existing.ID, api.ID)
+
+	search, err := s.CreateSavedSearch("API JSON", Filter{ContentType: "application/json", TagQuery: "api", WithinDays: 7})
+	if err != nil {
+		t.Fatalf("CreateSavedSearch failed: %v", err)
+	}
+	if search.Filter.TagQuery != "api" {
+		t.Errorf("Filter not stored: %+v", search.Filter)
+	}
+	if _, err := s.CreateSavedSearch("Broken", Filter{TagQuery: "NOT"}); err == nil {
+		t.Error("Expected error for invalid filter")
+	}
+	rec.names()
+
+	// Clips matching when the search is saved don't notify
+	s.UpdateClip(User, existing.ID, ClipUpdate{Data: []byte(`{"a": 1}`)})
+	if got := rec.names(); !equalNames(got, []string{"clip:updated"}) {
+		t.Errorf("Expected no match for a clip that already matched, got %v", got)
+	}
+
+	// A new clip matches once it gets the tag, and only once
+	clip, _ := s.CreateClip(Plugin(7), NewClip{ContentType: "application/json", Data: []byte("[]")})
+	s.AddTagToClip(Plugin(7), clip.ID, api.ID)
+	s.UpdateClip(User, clip.ID, ClipUpdate{Data: []byte("[1]")})
+	events := append([]Event(nil), rec.events...)
+	want := []string{"clip:created", "tag:added_to_clip", "search:matched", "clip:updated"}
+	if got := rec.names(); !equalNames(got, want) {
+		t.Fatalf("Expected events %v, got %v", want, got)
+	}
+	matched := events[2]
+	data := matched.Data.(map[string]interface{})
+	if data["search_id"] != search.ID || data["clip_id"] != clip.ID || matched.Actor != Plugin(7) {
+		t.Errorf("Unexpected search:matched event: %+v", matched)
+	}
+
+	// Clips that stop matching are reported again when they match again
+	s.RemoveTagFromClip(User, clip.ID, api.ID)
+	rec.names()
+	s.AddTagToClip(User, clip.ID, api.ID)
+	if got := rec.names(); !equalNames(got, []string{"tag:added_to_clip", "search:matched"}) {
+		t.Errorf("Expected the clip to match again, got %v", got)
+	}
+
+	if count, _ := s.CountClips(search.Filter); count != 2 {
+		t.Errorf("Expected 2 matching clips, got %d", count)
+	}
+	if err := s.DeleteSavedSearch(search.ID); err != nil {
+		t.Fatalf("DeleteSavedSearch failed: %v", err)
+	}
+	if _, err := s.GetSavedSearch(search.ID); err != ErrSavedSearchNotFound {
+		t.Errorf("Expected ErrSavedSearchNotFound, got %v", err)
+	}
+}
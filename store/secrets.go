@@ -0,0 +1,380 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Secret rules
+const (
+	SecretAWSAccessKey = "aws_access_key"
+	SecretAWSSecretKey = "aws_secret_key"
+	SecretGitHubToken  = "github_token"
+	SecretSlackToken   = "slack_token"
+	SecretSlackWebhook = "slack_webhook"
+	SecretPrivateKey   = "private_key"
+	SecretJWT          = "jwt"
+	SecretCreditCard   = "credit_card"
+	SecretPassword     = "password"
+)
+
+// What happens to a clip that contains a secret
+const (
+	SecretActionOff    = "off"    // don't scan
+	SecretActionFlag   = "flag"   // save the clip and record what was found
+	SecretActionReject = "reject" // refuse to save the clip
+)
+
+// maxSecretScanBytes limits how much of a clip is scanned
+const maxSecretScanBytes = 1 << 20
+
+var ErrSecretDetected = errors.New("clip contains a secret")
+
+// SecretPolicy decides what happens to text clips that contain secrets such
+// as API keys or private keys. Policies are stored as JSON in the settings, so
+// fields must keep their names.
+type SecretPolicy struct {
+	Action           string   `json:"action"`             // SecretActionOff, SecretActionFlag or SecretActionReject
+	MaskPreviews     bool     `json:"mask_previews"`      // hide the secrets of flagged clips in previews
+	ExpireMinutes    int      `json:"expire_minutes"`     // expire flagged clips this long after capture, 0 to keep them
+	IncludeInBackups bool     `json:"include_in_backups"` // back up flagged clips too
+	DisabledRules    []string `json:"disabled_rules"`     // rules that aren't checked
+}
+
+// DefaultSecretPolicy flags secrets and masks them in previews
+func DefaultSecretPolicy() SecretPolicy {
+	return SecretPolicy{Action: SecretActionFlag, MaskPreviews: true}
+}
+
+// Validate checks the policy
+func (p *SecretPolicy) Validate() error {
+	switch p.Action {
+	case SecretActionOff, SecretActionFlag, SecretActionReject:
+	case "":
+		p.Action = SecretActionFlag
+	default:
+		return fmt.Errorf("unknown secret action %q", p.Action)
+	}
+	if p.ExpireMinutes < 0 {
+		return fmt.Errorf("secret expiration cannot be negative")
+	}
+	for _, name := range p.DisabledRules {
+		if ruleByName(name) == nil {
+			return fmt.Errorf("unknown secret rule %q", name)
+		}
+	}
+	return nil
+}
+
+func (p SecretPolicy) ruleEnabled(name string) bool {
+	for _, disabled := range p.DisabledRules {
+		if disabled == name {
+			return false
+		}
+	}
+	return true
+}
+
+// secretRule finds one kind of secret. Matches of group (the whole match if
+// 0) must have at least minEntropy bits per character and pass check, if set.
+type secretRule struct {
+	name       string
+	re         *regexp.Regexp
+	group      int
+	minEntropy float64
+	check      func(string) bool
+}
+
+var secretRules = []secretRule{
+	{name: SecretAWSAccessKey, re: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{
+		name:       SecretAWSSecretKey,
+		re:         regexp.MustCompile(`(?i)aws.{0,20}?(?:secret|key).{0,20}?[=:\s"']([A-Za-z0-9/+]{40})\b`),
+		group:      1,
+		minEntropy: 4,
+	},
+	{name: SecretGitHubToken, re: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,255}|github_pat_[A-Za-z0-9_]{22,255})\b`)},
+	{name: SecretSlackToken, re: regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`)},
+	{name: SecretSlackWebhook, re: regexp.MustCompile(`https://hooks\.slack\.com/services/T[A-Z0-9]+/B[A-Z0-9]+/[A-Za-z0-9]+`)},
+	{
+		// A truncated key is still a secret, so the END line is optional
+		name: SecretPrivateKey,
+		re:   regexp.MustCompile(`-----BEGIN (?:[A-Z0-9]+ )*PRIVATE KEY(?: BLOCK)?-----(?s:.*?)(?:-----END (?:[A-Z0-9]+ )*PRIVATE KEY(?: BLOCK)?-----|\z)`),
+	},
+	{name: SecretJWT, re: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{5,}\.eyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]{10,}`)},
+	{name: SecretCreditCard, re: regexp.MustCompile(`\b[3-6]\d{3}(?:[ -]?\d){9,15}\b`), check: validCardNumber},
+	{
+		name:       SecretPassword,
+		re:         regexp.MustCompile(`(?i)(?:password|passwd|pwd|secret|api[_-]?key|access[_-]?token|auth[_-]?token)["']?\s*[:=]\s*["']?([^\s"',;()]{8,})`),
+		group:      1,
+		minEntropy: 2.5,
+		check:      looksLikePassword,
+	},
+}
+
+func ruleByName(name string) *secretRule {
+	for i := range secretRules {
+		if secretRules[i].name == name {
+			return &secretRules[i]
+		}
+	}
+	return nil
+}
+
+// SecretRules returns the names of all secret rules
+func SecretRules() []string {
+	names := make([]string, len(secretRules))
+	for i, r := range secretRules {
+		names[i] = r.name
+	}
+	return names
+}
+
+// SecretMatch is a secret found in a text, as a byte range
+type SecretMatch struct {
+	Rule  string
+	Start int
+	End   int
+}
+
+// ScanSecrets returns the secrets found in text by the rules the policy
+// enables, in order of position
+func (p SecretPolicy) ScanSecrets(text string) []SecretMatch {
+	if len(text) > maxSecretScanBytes {
+		text = text[:maxSecretScanBytes]
+	}
+	var matches []SecretMatch
+	for _, r := range secretRules {
+		if !p.ruleEnabled(r.name) {
+			continue
+		}
+		for _, loc := range r.re.FindAllStringSubmatchIndex(text, -1) {
+			start, end := loc[2*r.group], loc[2*r.group+1]
+			value := text[start:end]
+			if r.minEntropy > 0 && entropy(value) < r.minEntropy {
+				continue
+			}
+			if r.check != nil && !r.check(value) {
+				continue
+			}
+			matches = append(matches, SecretMatch{Rule: r.name, Start: start, End: end})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Start < matches[j].Start })
+	return matches
+}
+
+// secretKinds returns the sorted, distinct rules that matched
+func secretKinds(matches []SecretMatch) []string {
+	seen := map[string]bool{}
+	var kinds []string
+	for _, m := range matches {
+		if !seen[m.Rule] {
+			seen[m.Rule] = true
+			kinds = append(kinds, m.Rule)
+		}
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// secretMask replaces all but the first characters of a secret
+const secretMask = "••••••••"
+
+// MaskSecrets returns text with every secret found by the policy's rules
+// replaced by its first four characters and a mask
+func (p SecretPolicy) MaskSecrets(text string) string {
+	matches := p.ScanSecrets(text)
+	if len(matches) == 0 {
+		return text
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		if m.Start < last {
+			// Overlaps a secret that was already masked
+			if m.End > last {
+				last = m.End
+			}
+			continue
+		}
+		keep := m.Start + min(4, m.End-m.Start)
+		b.WriteString(text[last:keep])
+		b.WriteString(secretMask)
+		last = m.End
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// entropy returns the Shannon entropy of s in bits per character
+func entropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	counts := map[rune]int{}
+	n := 0
+	for _, r := range s {
+		counts[r]++
+		n++
+	}
+	var bits float64
+	for _, c := range counts {
+		p := float64(c) / float64(n)
+		bits -= p * math.Log2(p)
+	}
+	return bits
+}
+
+// looksLikePassword tells assigned values apart from placeholders such as
+// $PASSWORD or <token> and from identifiers such as getPassword
+func looksLikePassword(s string) bool {
+	if strings.ContainsAny(s[:1], "$<{%") {
+		return false
+	}
+	return strings.IndexFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}) >= 0
+}
+
+// validCardNumber reports whether s, ignoring spaces and dashes, is a card
+// number with a valid Luhn check digit
+func validCardNumber(s string) bool {
+	var digits []int
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			digits = append(digits, int(r-'0'))
+		}
+	}
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+	sum := 0
+	for i := range digits {
+		d := digits[len(digits)-1-i]
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// SetSecretPolicy sets the policy applied to clips from now on
+func (s *Service) SetSecretPolicy(p SecretPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.secretPolicy = p
+}
+
+// SecretPolicy returns the policy applied to new clips
+func (s *Service) SecretPolicy() SecretPolicy {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.secretPolicy
+}
+
+// checkSecrets scans the data of a text clip and returns the rules that
+// matched, or ErrSecretDetected if the policy refuses such clips
+func (s *Service) checkSecrets(contentType string, data []byte) ([]string, error) {
+	policy := s.SecretPolicy()
+	if policy.Action == SecretActionOff || !IsText(contentType) {
+		return nil, nil
+	}
+	kinds := secretKinds(policy.ScanSecrets(string(data)))
+	if len(kinds) > 0 && policy.Action == SecretActionReject {
+		return nil, fmt.Errorf("%w (%s)", ErrSecretDetected, strings.Join(kinds, ", "))
+	}
+	return kinds, nil
+}
+
+// secretExpiration returns when a clip captured now with secrets should
+// expire, keeping an earlier expiration
+func (p SecretPolicy) secretExpiration(kinds []string, expiresAt *time.Time) *time.Time {
+	if len(kinds) == 0 || p.ExpireMinutes == 0 {
+		return expiresAt
+	}
+	expires := time.Now().Add(time.Duration(p.ExpireMinutes) * time.Minute)
+	if expiresAt != nil && expiresAt.Before(expires) {
+		return expiresAt
+	}
+	return &expires
+}
+
+// ParseSecrets splits the secrets column of a clip
+func ParseSecrets(column sql.NullString) []string {
+	if !column.Valid || column.String == "" {
+		return nil
+	}
+	return strings.Split(column.String, ",")
+}
+
+func joinSecrets(kinds []string) sql.NullString {
+	return nullString(strings.Join(kinds, ","))
+}
+
+// RescanSecrets scans every text clip with the current policy and updates
+// which secrets they are flagged for. Clips are never rejected or expired by a
+// rescan. Returns the number of flagged clips.
+func (s *Service) RescanSecrets() (int, error) {
+	policy := s.SecretPolicy()
+	flagged := 0
+	var lastID int64
+	for {
+		rows, err := s.db.Query(`SELECT id, content_type, data, is_encrypted FROM clips
+			WHERE id > ? ORDER BY id LIMIT ?`, lastID, recryptBatchSize)
+		if err != nil {
+			return flagged, fmt.Errorf("failed to query clips: %w", err)
+		}
+		type row struct {
+			id    int64
+			kinds []string
+		}
+		var batch []row
+		for rows.Next() {
+			var r row
+			var contentType string
+			var data []byte
+			var encrypted bool
+			if err := rows.Scan(&r.id, &contentType, &data, &encrypted); err != nil {
+				rows.Close()
+				return flagged, fmt.Errorf("failed to scan clip: %w", err)
+			}
+			lastID = r.id
+			if policy.Action != SecretActionOff && IsText(contentType) {
+				if data, err = s.DecryptData(data, encrypted); err != nil {
+					rows.Close()
+					return flagged, err
+				}
+				r.kinds = secretKinds(policy.ScanSecrets(string(data)))
+			}
+			batch = append(batch, r)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return flagged, err
+		}
+		if len(batch) == 0 {
+			return flagged, nil
+		}
+
+		for _, r := range batch {
+			if len(r.kinds) > 0 {
+				flagged++
+			}
+			if _, err := s.db.Exec("UPDATE clips SET secrets = ? WHERE id = ?", joinSecrets(r.kinds), r.id); err != nil {
+				return flagged, fmt.Errorf("failed to update clip %d: %w", r.id, err)
+			}
+		}
+	}
+}
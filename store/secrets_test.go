@@ -0,0 +1,224 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test secrets are assembled at runtime so the source doesn't trip scanners
+var (
+	testAWSKey      = "AKIA" + "IOSFODNN7EXAMPLE"
+	testGitHubToken = "ghp_" + strings.Repeat("a1B2", 9)
+	testJWT         = "eyJhbGciOiJIUzI1NiJ9" + ".eyJzdWIiOiIxMjM0NTY3ODkwIn0" + ".dozjgNryP4J3jVmNHl0w5N_XgL0n3I9PlFUP0THsR8U"
+	testPrivateKey  = "-----BEGIN RSA " + "PRIVATE KEY-----\nMIIEow\n-----END RSA " + "PRIVATE KEY-----"
+)
+
+func TestScanSecrets(t *testing.T) {
+	policy := DefaultSecretPolicy()
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"aws_access_key_id = " + testAWSKey, []string{SecretAWSAccessKey}},
+		{"aws_secret_access_key=wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY", []string{SecretAWSSecretKey}},
+		{"token: " + testGitHubToken, []string{SecretGitHubToken}},
+		{"xoxb-" + "1234567890-abcdefghij", []string{SecretSlackToken}},
+		{"https://hooks.slack.com/services/" + "T0000/B0000/XXXXXXXX", []string{SecretSlackWebhook}},
+		{testPrivateKey, []string{SecretPrivateKey}},
+		{"-----BEGIN " + "PRIVATE KEY-----\nMIIEvQ (cut off)", []string{SecretPrivateKey}},
+		{"Authorization: Bearer " + testJWT, []string{SecretJWT}},
+		{"card 4111 1111 1111 1111 exp 12/30", []string{SecretCreditCard}},
+		{`DB_PASSWORD="hunter2hunter2"`, []string{SecretPassword}},
+
+		// Look-alikes
+		{"order 4111 1111 1111 1112", nil},
+		{"password = getPassword()", nil},
+		{"password=$DB_PASSWORD", nil},
+		{"password: aaaaaaaa1", nil},
+		{"just some text about keys", nil},
+	}
+	for _, tt := range tests {
+		got := secretKinds(policy.ScanSecrets(tt.text))
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("ScanSecrets(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+
+	policy.DisabledRules = []string{SecretCreditCard}
+	if got := policy.ScanSecrets("4111 1111 1111 1111"); len(got) != 0 {
+		t.Errorf("Expected disabled rule to be skipped, got %v", got)
+	}
+}
+
+func TestMaskSecrets(t *testing.T) {
+	policy := DefaultSecretPolicy()
+	got := policy.MaskSecrets("key " + testAWSKey + " and " + testGitHubToken + ".")
+	want := "key AKIA" + secretMask + " and ghp_" + secretMask + "."
+	if got != want {
+		t.Errorf("MaskSecrets = %q, want %q", got, want)
+	}
+	if got := policy.MaskSecrets("nothing here"); got != "nothing here" {
+		t.Errorf("Expected text without secrets unchanged, got %q", got)
+	}
+}
+
+func clipSecrets(t *testing.T, s *Service, id int64) ([]string, *time.Time) {
+	t.Helper()
+	var secrets sql.NullString
+	var expiresAt sql.NullTime
+	if err := s.db.QueryRow("SELECT secrets, expires_at FROM clips WHERE id = ?", id).Scan(&secrets, &expiresAt); err != nil {
+		t.Fatalf("Failed to read clip: %v", err)
+	}
+	if expiresAt.Valid {
+		return ParseSecrets(secrets), &expiresAt.Time
+	}
+	return ParseSecrets(secrets), nil
+}
+
+func TestCreateClip_FlagsSecrets(t *testing.T) {
+	s, _ := newTestService(t)
+	s.SetSecretPolicy(SecretPolicy{Action: SecretActionFlag, ExpireMinutes: 10})
+
+	clip, err := s.CreateClip(User, NewClip{ContentType: "text/plain", Data: []byte(testJWT + "\n" + testAWSKey)})
+	if err != nil {
+		t.Fatalf("CreateClip failed: %v", err)
+	}
+	secrets, expiresAt := clipSecrets(t, s, clip.ID)
+	if strings.Join(secrets, ",") != "aws_access_key,jwt" {
+		t.Errorf("Unexpected secrets %v", secrets)
+	}
+	if expiresAt == nil || time.Until(*expiresAt) > 10*time.Minute || time.Until(*expiresAt) < 9*time.Minute {
+		t.Errorf("Expected the clip to expire in 10 minutes, got %v", expiresAt)
+	}
+
+	// An earlier expiration is kept
+	soon := time.Now().Add(time.Minute)
+	early, _ := s.CreateClip(User, NewClip{ContentType: "text/plain", Data: []byte(testAWSKey), ExpiresAt: &soon})
+	if _, expiresAt := clipSecrets(t, s, early.ID); expiresAt == nil || expiresAt.After(soon.Add(time.Second)) {
+		t.Errorf("Expected the earlier expiration to be kept, got %v", expiresAt)
+	}
+
+	// Clean and binary clips aren't flagged
+	clean, _ := s.CreateClip(User, NewClip{ContentType: "text/plain", Data: []byte("hello")})
+	binary, _ := s.CreateClip(User, NewClip{ContentType: "image/png", Data: []byte(testAWSKey)})
+	for _, id := range []int64{clean.ID, binary.ID} {
+		if secrets, expiresAt := clipSecrets(t, s, id); secrets != nil || expiresAt != nil {
+			t.Errorf("Expected clip %d not to be flagged, got %v %v", id, secrets, expiresAt)
+		}
+	}
+
+	// Updates rescan the data
+	s.UpdateClip(User, clean.ID, ClipUpdate{Data: []byte(testPrivateKey)})
+	if secrets, _ := clipSecrets(t, s, clean.ID); strings.Join(secrets, ",") != SecretPrivateKey {
+		t.Errorf("Expected the updated clip to be flagged, got %v", secrets)
+	}
+	s.UpdateClip(User, clean.ID, ClipUpdate{Data: []byte("hello again")})
+	if secrets, _ := clipSecrets(t, s, clean.ID); secrets != nil {
+		t.Errorf("Expected the flag to be cleared, got %v", secrets)
+	}
+}
+
+func TestUpdateClip_ExpiresNewSecrets(t *testing.T) {
+	s, _ := newTestService(t)
+	s.SetSecretPolicy(SecretPolicy{Action: SecretActionFlag, ExpireMinutes: 10})
+
+	// A clean clip that is updated to hold a secret expires like a new one
+	clip, _ := s.CreateClip(User, NewClip{ContentType: "text/plain", Data: []byte("hello")})
+	if err := s.UpdateClip(User, clip.ID, ClipUpdate{Data: []byte(testAWSKey)}); err != nil {
+		t.Fatalf("UpdateClip failed: %v", err)
+	}
+	_, expiresAt := clipSecrets(t, s, clip.ID)
+	if expiresAt == nil || time.Until(*expiresAt) > 10*time.Minute || time.Until(*expiresAt) < 9*time.Minute {
+		t.Errorf("Expected the clip to expire in 10 minutes, got %v", expiresAt)
+	}
+
+	// Changing a clip that already held secrets keeps the expiration the
+	// user set since
+	if err := s.UpdateClip(User, clip.ID, ClipUpdate{ClearExpiration: true}); err != nil {
+		t.Fatalf("UpdateClip failed: %v", err)
+	}
+	s.UpdateClip(User, clip.ID, ClipUpdate{Data: []byte(testAWSKey + "\n" + testJWT)})
+	if _, expiresAt := clipSecrets(t, s, clip.ID); expiresAt != nil {
+		t.Errorf("Expected the cleared expiration to be kept, got %v", expiresAt)
+	}
+
+	// An earlier expiration, set before or with the update, is kept
+	soon := time.Now().Add(time.Minute)
+	early, _ := s.CreateClip(User, NewClip{ContentType: "text/plain", Data: []byte("hello"), ExpiresAt: &soon})
+	s.UpdateClip(User, early.ID, ClipUpdate{Data: []byte(testPrivateKey)})
+	if _, expiresAt := clipSecrets(t, s, early.ID); expiresAt == nil || expiresAt.After(soon.Add(time.Second)) {
+		t.Errorf("Expected the earlier expiration to be kept, got %v", expiresAt)
+	}
+	later := time.Now().Add(time.Hour)
+	withExpiry, _ := s.CreateClip(User, NewClip{ContentType: "text/plain", Data: []byte("hello")})
+	s.UpdateClip(User, withExpiry.ID, ClipUpdate{Data: []byte(testAWSKey), ExpiresAt: &later})
+	if _, expiresAt := clipSecrets(t, s, withExpiry.ID); expiresAt == nil || time.Until(*expiresAt) > 10*time.Minute {
+		t.Errorf("Expected the secret policy to shorten the new expiration, got %v", expiresAt)
+	}
+}
+
+func TestCreateClip_RejectsSecrets(t *testing.T) {
+	s, rec := newTestService(t)
+	s.SetSecretPolicy(SecretPolicy{Action: SecretActionReject})
+
+	_, err := s.CreateClip(User, NewClip{ContentType: "text/plain", Data: []byte("key=" + testAWSKey)})
+	if !errors.Is(err, ErrSecretDetected) || !strings.Contains(err.Error(), SecretAWSAccessKey) {
+		t.Errorf("Expected ErrSecretDetected naming the rule, got %v", err)
+	}
+	var count int
+	s.db.QueryRow("SELECT COUNT(*) FROM clips").Scan(&count)
+	if count != 0 || len(rec.names()) != 0 {
+		t.Errorf("Expected nothing to be saved, got %d clips", count)
+	}
+
+	clip, _ := s.CreateClip(User, NewClip{ContentType: "text/plain", Data: []byte("hello")})
+	if err := s.UpdateClip(User, clip.ID, ClipUpdate{Data: []byte(testAWSKey)}); !errors.Is(err, ErrSecretDetected) {
+		t.Errorf("Expected the update to be refused, got %v", err)
+	}
+
+	s.SetSecretPolicy(SecretPolicy{Action: SecretActionOff})
+	if _, err := s.CreateClip(User, NewClip{ContentType: "text/plain", Data: []byte(testAWSKey)}); err != nil {
+		t.Errorf("Expected clips to be saved unscanned, got %v", err)
+	}
+}
+
+func TestRescanSecrets(t *testing.T) {
+	s, _ := newTestService(t)
+	s.SetSecretPolicy(SecretPolicy{Action: SecretActionOff})
+	secret, _ := s.CreateClip(User, NewClip{ContentType: "text/plain", Data: []byte(testGitHubToken)})
+	clean, _ := s.CreateClip(User, NewClip{ContentType: "text/plain", Data: []byte("hello")})
+
+	s.SetSecretPolicy(DefaultSecretPolicy())
+	flagged, err := s.RescanSecrets()
+	if err != nil {
+		t.Fatalf("RescanSecrets failed: %v", err)
+	}
+	if flagged != 1 {
+		t.Errorf("Expected 1 flagged clip, got %d", flagged)
+	}
+	if secrets, expiresAt := clipSecrets(t, s, secret.ID); strings.Join(secrets, ",") != SecretGitHubToken || expiresAt != nil {
+		t.Errorf("Expected only a flag, got %v %v", secrets, expiresAt)
+	}
+	if secrets, _ := clipSecrets(t, s, clean.ID); secrets != nil {
+		t.Errorf("Expected clean clip not to be flagged, got %v", secrets)
+	}
+}
+
+func TestSecretPolicy_Validate(t *testing.T) {
+	p := SecretPolicy{}
+	if err := p.Validate(); err != nil || p.Action != SecretActionFlag {
+		t.Errorf("Expected empty action to default to flag, got %q (%v)", p.Action, err)
+	}
+	for _, bad := range []SecretPolicy{
+		{Action: "delete"},
+		{Action: SecretActionFlag, ExpireMinutes: -1},
+		{Action: SecretActionFlag, DisabledRules: []string{"nope"}},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Expected %+v to be invalid", bad)
+		}
+	}
+}
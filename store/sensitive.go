@@ -0,0 +1,78 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNotRevealed is returned when the data of a sensitive clip is read
+// without revealing it first
+var ErrNotRevealed = errors.New("sensitive clip must be revealed first")
+
+// SetSensitive marks clips as sensitive or clears the mark, emitting
+// clip:updated for each clip that changed. Sensitive clips are masked in the
+// gallery and hidden from plugins without the sensitive_clips permission.
+// When marking, expireAfter > 0 makes unpinned clips expire that long from
+// now unless they already expire sooner.
+func (s *Service) SetSensitive(actor Actor, ids []int64, sensitive bool, expireAfter time.Duration) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	marks, args := placeholders(ids)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	changed, err := queryIDs(tx, "SELECT id FROM clips WHERE deleted_at IS NULL AND is_sensitive != ? AND id IN ("+marks+")",
+		append([]interface{}{boolToInt(sensitive)}, args...)...)
+	if err != nil {
+		return fmt.Errorf("failed to query clips: %w", err)
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	marks, args = placeholders(changed)
+
+	if _, err := tx.Exec("UPDATE clips SET is_sensitive = ? WHERE id IN ("+marks+")",
+		append([]interface{}{boolToInt(sensitive)}, args...)...); err != nil {
+		return fmt.Errorf("failed to update sensitive state: %w", err)
+	}
+	if sensitive && expireAfter > 0 {
+		expires := time.Now().Add(expireAfter)
+		if _, err := tx.Exec("UPDATE clips SET expires_at = ? WHERE is_pinned = 0 AND (expires_at IS NULL OR expires_at > ?) AND id IN ("+marks+")",
+			append([]interface{}{expires, expires}, args...)...); err != nil {
+			return fmt.Errorf("failed to update expiration: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	// The payload leaves out the filename and content type of the clip
+	for _, id := range changed {
+		s.emit(actor, "clip:updated", map[string]interface{}{
+			"id":      id,
+			"changes": []string{"is_sensitive"},
+		})
+	}
+	return nil
+}
+
+// IsSensitive reports whether a clip is marked sensitive
+func (s *Service) IsSensitive(id int64) (bool, error) {
+	var sensitive bool
+	err := s.db.QueryRow("SELECT is_sensitive FROM clips WHERE id = ?", id).Scan(&sensitive)
+	if err == sql.ErrNoRows {
+		return false, ErrClipNotFound
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to query clip: %w", err)
+	}
+	return sensitive, nil
+}
@@ -0,0 +1,60 @@
+package store
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSetSensitive(t *testing.T) {
+	s, rec := newTestService(t)
+
+	a, _ := s.CreateClip(User, NewClip{Data: []byte("a")})
+	b, _ := s.CreateClip(User, NewClip{Data: []byte("b")})
+	rec.names()
+
+	if err := s.SetSensitive(User, []int64{a.ID, b.ID}, true, 0); err != nil {
+		t.Fatalf("SetSensitive failed: %v", err)
+	}
+	// Marking again changes nothing and emits nothing
+	s.SetSensitive(User, []int64{a.ID}, true, 0)
+	s.SetSensitive(User, []int64{b.ID}, false, 0)
+
+	want := []string{"clip:updated", "clip:updated", "clip:updated"}
+	if got := rec.names(); !equalNames(got, want) {
+		t.Errorf("Expected events %v, got %v", want, got)
+	}
+	if sensitive, _ := s.IsSensitive(a.ID); !sensitive {
+		t.Error("Expected clip a to be sensitive")
+	}
+	if sensitive, _ := s.IsSensitive(b.ID); sensitive {
+		t.Error("Expected clip b not to be sensitive")
+	}
+	if _, err := s.IsSensitive(999); !errors.Is(err, ErrClipNotFound) {
+		t.Errorf("Expected ErrClipNotFound, got %v", err)
+	}
+}
+
+func TestSetSensitive_Expiration(t *testing.T) {
+	s, _ := newTestService(t)
+
+	plain, _ := s.CreateClip(User, NewClip{Data: []byte("a")})
+	pinned, _ := s.CreateClip(User, NewClip{Data: []byte("b")})
+	s.SetPinned(User, pinned.ID, true)
+	soon := time.Now().Add(time.Minute)
+	early, _ := s.CreateClip(User, NewClip{Data: []byte("c"), ExpiresAt: &soon})
+
+	if err := s.SetSensitive(User, []int64{plain.ID, pinned.ID, early.ID}, true, 10*time.Minute); err != nil {
+		t.Fatalf("SetSensitive failed: %v", err)
+	}
+
+	if _, expiresAt := clipSecrets(t, s, plain.ID); expiresAt == nil || time.Until(*expiresAt) > 10*time.Minute || time.Until(*expiresAt) < 9*time.Minute {
+		t.Errorf("Expected the clip to expire in 10 minutes, got %v", expiresAt)
+	}
+	if _, expiresAt := clipSecrets(t, s, pinned.ID); expiresAt != nil {
+		t.Errorf("Expected the pinned clip not to expire, got %v", expiresAt)
+	}
+	if _, expiresAt := clipSecrets(t, s, early.ID); expiresAt == nil || expiresAt.After(soon.Add(time.Second)) {
+		t.Errorf("Expected the earlier expiration to be kept, got %v", expiresAt)
+	}
+}
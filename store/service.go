@@ -0,0 +1,112 @@
+// Package store owns every change to clips, tags and collections. The app, the folder
+// watcher and plugins all mutate through a Service so validation, cleanup
+// and change events behave the same no matter who made the change.
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"sync"
+)
+
+// Actor kinds
+const (
+	ActorUser    = "user"
+	ActorWatcher = "watcher"
+	ActorPlugin  = "plugin"
+	ActorSystem  = "system"
+)
+
+// Jobs that change clips on their own
+const (
+	JobExpiry     = "expiry"
+	JobTrashPurge = "trash_purge"
+	JobRetention  = "retention"
+)
+
+var (
+	ErrClipNotFound = errors.New("clip not found")
+	ErrTagNotFound  = errors.New("tag not found")
+)
+
+// Actor identifies who made a change
+type Actor struct {
+	Kind     string
+	PluginID int64  // set when Kind is ActorPlugin
+	Job      string // set for system actors that run as a job, e.g. JobExpiry
+}
+
+var (
+	User    = Actor{Kind: ActorUser}
+	Watcher = Actor{Kind: ActorWatcher}
+	System  = Actor{Kind: ActorSystem}
+)
+
+// SystemJob returns the actor for changes made by a background job
+func SystemJob(name string) Actor {
+	return Actor{Kind: ActorSystem, Job: name}
+}
+
+// Plugin returns the actor for changes made by a plugin
+func Plugin(pluginID int64) Actor {
+	return Actor{Kind: ActorPlugin, PluginID: pluginID}
+}
+
+// Event describes a change. Name and Data match the plugin event of the same name
+// (e.g. "clip:created").
+type Event struct {
+	Name  string
+	Data  interface{}
+	Actor Actor
+}
+
+// Listener is called synchronously after each change is committed
+type Listener func(Event)
+
+// Service performs clip and tag mutations and notifies listeners
+type Service struct {
+	db             *sql.DB
+	listeners      []Listener
+	mu             sync.RWMutex
+	revisionBudget int64 // bytes of revisions kept per clip, DefaultRevisionBudget if 0
+	keys           keyring
+	secretPolicy   SecretPolicy
+}
+
+// New creates a service on top of the clips database
+func New(db *sql.DB) *Service {
+	return &Service{db: db, secretPolicy: DefaultSecretPolicy()}
+}
+
+// Subscribe registers a listener for all change events
+func (s *Service) Subscribe(fn Listener) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
+
+func (s *Service) emit(actor Actor, name string, data interface{}) {
+	s.mu.RLock()
+	listeners := make([]Listener, len(s.listeners))
+	copy(listeners, s.listeners)
+	s.mu.RUnlock()
+
+	event := Event{Name: name, Data: data, Actor: actor}
+	for _, fn := range listeners {
+		fn(event)
+	}
+	s.matchSavedSearches(event)
+	s.auditEvent(event)
+}
+
+// placeholders returns "?,?,?" and the matching arguments for an IN clause
+func placeholders(ids []int64) (string, []interface{}) {
+	marks := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		marks[i] = "?"
+		args[i] = id
+	}
+	return strings.Join(marks, ","), args
+}
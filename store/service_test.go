@@ -0,0 +1,320 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"go-clipboard/schema"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// recorder collects the events emitted by a service
+type recorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *recorder) record(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+// names returns the names of the recorded events and clears them
+func (r *recorder) names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := make([]string, len(r.events))
+	for i, e := range r.events {
+		names[i] = e.Name
+	}
+	r.events = nil
+	return names
+}
+
+// newTestService creates a Service backed by a temporary database with the app's schema
+func newTestService(t *testing.T) (*Service, *recorder) {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := schema.Migrate(db); err != nil {
+		t.Fatalf("schema.Migrate failed: %v", err)
+	}
+
+	s := New(db)
+	rec := &recorder{}
+	s.Subscribe(rec.record)
+	return s, rec
+}
+
+func equalNames(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func equalIDs(got, want []int64) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDetectContentType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		data        string
+		want        string
+	}{
+		{"", "hello", "text/plain"},
+		{"text/plain", `{"a": 1}`, "application/json"},
+		{"", "  <!DOCTYPE html><html></html>", "text/html"},
+		{"image/png", "{}", "image/png"},
+		{"not a mime type", "x", "application/octet-stream"},
+	}
+
+	for _, tt := range tests {
+		if got := DetectContentType(tt.contentType, []byte(tt.data)); got != tt.want {
+			t.Errorf("DetectContentType(%q, %q) = %q, want %q", tt.contentType, tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestCreateClip_EmitsWithActor(t *testing.T) {
+	s, rec := newTestService(t)
+
+	clip, err := s.CreateClip(Plugin(7), NewClip{ContentType: "text/plain", Data: []byte(`[1, 2]`), Filename: "list"})
+	if err != nil {
+		t.Fatalf("CreateClip failed: %v", err)
+	}
+	if clip.ContentType != "application/json" {
+		t.Errorf("Expected sniffed JSON, got %s", clip.ContentType)
+	}
+
+	if len(rec.events) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(rec.events))
+	}
+	e := rec.events[0]
+	if e.Name != "clip:created" || e.Actor.Kind != ActorPlugin || e.Actor.PluginID != 7 {
+		t.Errorf("Unexpected event: %+v", e)
+	}
+	if data := e.Data.(map[string]interface{}); data["id"] != clip.ID {
+		t.Errorf("Event has wrong clip ID: %v", data["id"])
+	}
+}
+
+func TestDeleteClips_RemovesOrphanedTags(t *testing.T) {
+	s, rec := newTestService(t)
+
+	a, _ := s.CreateClip(User, NewClip{Data: []byte("a")})
+	b, _ := s.CreateClip(User, NewClip{Data: []byte("b")})
+	only, _ := s.CreateTag(User, "only-a")
+	shared, _ := s.CreateTag(User, "shared")
+	if err := s.AddTagToClips(User, []int64{a.ID}, only.ID); err != nil {
+		t.Fatalf("AddTagToClips failed: %v", err)
+	}
+	if err := s.AddTagToClips(User, []int64{a.ID, b.ID}, shared.ID); err != nil {
+		t.Fatalf("AddTagToClips failed: %v", err)
+	}
+	rec.names()
+
+	if err := s.DeleteClips(User, []int64{a.ID, 999}); err != nil {
+		t.Fatalf("DeleteClips failed: %v", err)
+	}
+
+	want := []string{"clip:deleted", "tag:deleted"}
+	if got := rec.names(); !equalNames(got, want) {
+		t.Errorf("Expected events %v, got %v", want, got)
+	}
+	if s.tagExists(only.ID) {
+		t.Error("Orphaned tag should be deleted")
+	}
+	if !s.tagExists(shared.ID) {
+		t.Error("Tag still in use should be kept")
+	}
+}
+
+func TestAddTagToClips_OnlyEmitsForNewTags(t *testing.T) {
+	s, rec := newTestService(t)
+
+	a, _ := s.CreateClip(User, NewClip{Data: []byte("a")})
+	b, _ := s.CreateClip(User, NewClip{Data: []byte("b")})
+	tag, _ := s.CreateTag(User, "work")
+	if err := s.AddTagToClip(User, a.ID, tag.ID); err != nil {
+		t.Fatalf("AddTagToClip failed: %v", err)
+	}
+	rec.names()
+
+	if err := s.AddTagToClips(User, []int64{a.ID, b.ID, 999}, tag.ID); err != nil {
+		t.Fatalf("AddTagToClips failed: %v", err)
+	}
+	if got := rec.names(); !equalNames(got, []string{"tag:added_to_clip"}) {
+		t.Errorf("Expected one tag:added_to_clip, got %v", got)
+	}
+
+	if err := s.AddTagToClip(User, 999, tag.ID); !errors.Is(err, ErrClipNotFound) {
+		t.Errorf("Expected ErrClipNotFound, got %v", err)
+	}
+	if err := s.AddTagToClip(User, a.ID, 999); !errors.Is(err, ErrTagNotFound) {
+		t.Errorf("Expected ErrTagNotFound, got %v", err)
+	}
+}
+
+func TestRemoveTagFromClip_DeletesOrphanedTag(t *testing.T) {
+	s, rec := newTestService(t)
+
+	clip, _ := s.CreateClip(User, NewClip{Data: []byte("a")})
+	tag, _ := s.CreateTag(User, "temp")
+	s.AddTagToClip(User, clip.ID, tag.ID)
+	rec.names()
+
+	if err := s.RemoveTagFromClip(User, clip.ID, tag.ID); err != nil {
+		t.Fatalf("RemoveTagFromClip failed: %v", err)
+	}
+	want := []string{"tag:removed_from_clip", "tag:deleted"}
+	if got := rec.names(); !equalNames(got, want) {
+		t.Errorf("Expected events %v, got %v", want, got)
+	}
+}
+
+func TestArchive(t *testing.T) {
+	s, rec := newTestService(t)
+
+	a, _ := s.CreateClip(User, NewClip{Data: []byte("a")})
+	b, _ := s.CreateClip(User, NewClip{Data: []byte("b")})
+	rec.names()
+
+	if err := s.SetArchived(User, a.ID, true); err != nil {
+		t.Fatalf("SetArchived failed: %v", err)
+	}
+	// Archiving again changes nothing and emits nothing
+	if err := s.SetArchived(User, a.ID, true); err != nil {
+		t.Fatalf("SetArchived failed: %v", err)
+	}
+	if err := s.ToggleArchive(User, []int64{a.ID, b.ID}); err != nil {
+		t.Fatalf("ToggleArchive failed: %v", err)
+	}
+
+	want := []string{"clip:archived", "clip:unarchived", "clip:archived"}
+	if got := rec.names(); !equalNames(got, want) {
+		t.Errorf("Expected events %v, got %v", want, got)
+	}
+	if err := s.SetArchived(User, 999, true); !errors.Is(err, ErrClipNotFound) {
+		t.Errorf("Expected ErrClipNotFound, got %v", err)
+	}
+}
+
+func TestTagValidation(t *testing.T) {
+	s, _ := newTestService(t)
+
+	if _, err := s.CreateTag(User, "   "); err == nil {
+		t.Error("Expected error for empty name")
+	}
+	tag, err := s.CreateTag(User, "  padded  ")
+	if err != nil {
+		t.Fatalf("CreateTag failed: %v", err)
+	}
+	if tag.Name != "padded" || tag.Color != TagColors[0] {
+		t.Errorf("Unexpected tag: %+v", tag)
+	}
+	if _, err := s.CreateTag(User, "padded"); err == nil {
+		t.Error("Expected error for duplicate name")
+	}
+	if err := s.UpdateTag(User, 999, "x", "#000000"); !errors.Is(err, ErrTagNotFound) {
+		t.Errorf("Expected ErrTagNotFound, got %v", err)
+	}
+}
+
+func TestUpdateClip(t *testing.T) {
+	s, rec := newTestService(t)
+
+	clip, _ := s.CreateClip(User, NewClip{ContentType: "text/plain", Data: []byte("draft"), Filename: "note.txt"})
+	rec.names()
+
+	newName := "note.json"
+	newType := "text/plain"
+	err := s.UpdateClip(Plugin(3), clip.ID, ClipUpdate{
+		Data:        []byte(`{"done": true}`),
+		ContentType: &newType,
+		Filename:    &newName,
+		Metadata:    map[string]string{"source": "editor", "empty": ""},
+	})
+	if err != nil {
+		t.Fatalf("UpdateClip failed: %v", err)
+	}
+
+	var contentType, data, filename string
+	s.db.QueryRow("SELECT content_type, data, filename FROM clips WHERE id = ?", clip.ID).Scan(&contentType, &data, &filename)
+	if contentType != "application/json" || data != `{"done": true}` || filename != newName {
+		t.Errorf("Clip not updated: %s %s %s", contentType, data, filename)
+	}
+
+	// The previous version is kept
+	var oldData, oldName string
+	s.db.QueryRow("SELECT data, filename FROM clip_revisions WHERE clip_id = ?", clip.ID).Scan(&oldData, &oldName)
+	if oldData != "draft" || oldName != "note.txt" {
+		t.Errorf("Revision not recorded: %s %s", oldData, oldName)
+	}
+
+	metadata, _ := s.GetMetadata(clip.ID)
+	if len(metadata) != 1 || metadata["source"] != "editor" {
+		t.Errorf("Unexpected metadata: %v", metadata)
+	}
+
+	if len(rec.events) != 1 || rec.events[0].Name != "clip:updated" {
+		t.Fatalf("Expected one clip:updated event, got %v", rec.names())
+	}
+	changes := rec.events[0].Data.(map[string]interface{})["changes"].([]string)
+	want := []string{"data", "content_type", "filename", "metadata"}
+	if !equalNames(changes, want) {
+		t.Errorf("Expected changes %v, got %v", want, changes)
+	}
+}
+
+func TestUpdateClip_ExpirationOnly(t *testing.T) {
+	s, rec := newTestService(t)
+
+	clip, _ := s.CreateClip(User, NewClip{Data: []byte("a")})
+	rec.names()
+
+	// Nothing to change
+	if err := s.UpdateClip(User, clip.ID, ClipUpdate{}); err != nil {
+		t.Fatalf("UpdateClip failed: %v", err)
+	}
+	if got := rec.names(); len(got) != 0 {
+		t.Errorf("Expected no events, got %v", got)
+	}
+
+	if err := s.UpdateClip(User, clip.ID, ClipUpdate{ClearExpiration: true}); err != nil {
+		t.Fatalf("UpdateClip failed: %v", err)
+	}
+	var revisions int
+	s.db.QueryRow("SELECT COUNT(*) FROM clip_revisions").Scan(&revisions)
+	if revisions != 0 {
+		t.Error("Changing only the expiration should not record a revision")
+	}
+
+	if err := s.UpdateClip(User, 999, ClipUpdate{ClearExpiration: true}); !errors.Is(err, ErrClipNotFound) {
+		t.Errorf("Expected ErrClipNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,285 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// TagQuery is a parsed boolean filter over clip tags, such as
+//
+//	project/alpha AND (urgent OR "needs review") AND NOT draft
+//
+// A tag matches clips tagged with it or any of its children, so project/alpha
+// also matches project/alpha/api; prefix a tag with = to match it alone. Terms
+// next to each other are ANDed, and -tag is short for NOT tag. Tag names are
+// matched case-insensitively and unknown tags match no clips.
+type TagQuery struct {
+	root tagExpr
+}
+
+type tagExpr interface {
+	// condition appends an SQL condition on the clip ID column to b
+	condition(b *strings.Builder, args *[]interface{}, idColumn string)
+}
+
+type tagNameTerm struct {
+	name  string
+	exact bool // don't match children
+}
+
+type tagIDTerm struct {
+	id int64
+}
+
+type tagNot struct {
+	x tagExpr
+}
+
+type tagBinary struct {
+	op   string // "AND" or "OR"
+	l, r tagExpr
+}
+
+const tagClipsSubquery = "SELECT ct.clip_id FROM clip_tags ct INNER JOIN tags t ON t.id = ct.tag_id"
+
+func (t tagNameTerm) condition(b *strings.Builder, args *[]interface{}, idColumn string) {
+	b.WriteString(idColumn + " IN (" + tagClipsSubquery + " WHERE LOWER(t.name) = LOWER(?)")
+	*args = append(*args, t.name)
+	if !t.exact {
+		b.WriteString(" OR LOWER(SUBSTR(t.name, 1, LENGTH(?) + 1)) = LOWER(? || '/')")
+		*args = append(*args, t.name, t.name)
+	}
+	b.WriteString(")")
+}
+
+func (t tagIDTerm) condition(b *strings.Builder, args *[]interface{}, idColumn string) {
+	b.WriteString(idColumn + " IN (" + tagClipsSubquery + ` INNER JOIN tags p ON p.id = ?
+		WHERE t.id = p.id OR SUBSTR(t.name, 1, LENGTH(p.name) + 1) = p.name || '/')`)
+	*args = append(*args, t.id)
+}
+
+func (n tagNot) condition(b *strings.Builder, args *[]interface{}, idColumn string) {
+	b.WriteString("NOT (")
+	n.x.condition(b, args, idColumn)
+	b.WriteString(")")
+}
+
+func (e tagBinary) condition(b *strings.Builder, args *[]interface{}, idColumn string) {
+	b.WriteString("(")
+	e.l.condition(b, args, idColumn)
+	b.WriteString(" " + e.op + " ")
+	e.r.condition(b, args, idColumn)
+	b.WriteString(")")
+}
+
+// Condition returns an SQL condition selecting the clips that match the query,
+// where idColumn is the clip ID column (e.g. "c.id")
+func (q *TagQuery) Condition(idColumn string) (string, []interface{}) {
+	var b strings.Builder
+	var args []interface{}
+	q.root.condition(&b, &args, idColumn)
+	return b.String(), args
+}
+
+// AllTags returns a query matching clips that have each of the tags or one of
+// their children
+func AllTags(tagIDs []int64) *TagQuery {
+	if len(tagIDs) == 0 {
+		return nil
+	}
+	var root tagExpr = tagIDTerm{tagIDs[0]}
+	for _, id := range tagIDs[1:] {
+		root = tagBinary{"AND", root, tagIDTerm{id}}
+	}
+	return &TagQuery{root: root}
+}
+
+// AnyTag returns a query matching clips that have at least one of the named
+// tags or one of their children
+func AnyTag(names []string) *TagQuery {
+	if len(names) == 0 {
+		return nil
+	}
+	var root tagExpr = tagNameTerm{name: names[0]}
+	for _, name := range names[1:] {
+		root = tagBinary{"OR", root, tagNameTerm{name: name}}
+	}
+	return &TagQuery{root: root}
+}
+
+// TagMatches reports whether a tag name is tag or one of its children, the
+// way tag queries match, so work matches work and work/api but not workshop
+func TagMatches(name, tag string) bool {
+	name, tag = strings.ToLower(name), strings.ToLower(tag)
+	return name == tag || strings.HasPrefix(name, tag+TagSeparator)
+}
+
+// And combines two queries, either of which may be nil
+func (q *TagQuery) And(other *TagQuery) *TagQuery {
+	if q == nil {
+		return other
+	}
+	if other == nil {
+		return q
+	}
+	return &TagQuery{root: tagBinary{"AND", q.root, other.root}}
+}
+
+// ParseTagQuery parses a tag query. An empty query returns nil.
+func ParseTagQuery(query string) (*TagQuery, error) {
+	tokens, err := tokenizeTagQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	p := &tagQueryParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("invalid tag query: unexpected %q", p.tokens[p.pos].text)
+	}
+	return &TagQuery{root: root}, nil
+}
+
+type tagToken struct {
+	kind string // "(", ")", "AND", "OR", "NOT" or "tag"
+	text string
+}
+
+func tokenizeTagQuery(query string) ([]tagToken, error) {
+	var tokens []tagToken
+	runes := []rune(query)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')':
+			tokens = append(tokens, tagToken{string(r), string(r)})
+			i++
+		case r == '-' && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]):
+			tokens = append(tokens, tagToken{"NOT", "-"})
+			i++
+		case r == '"' || (r == '=' && i+1 < len(runes) && runes[i+1] == '"'):
+			prefix := ""
+			if r == '=' {
+				prefix = "="
+				i++
+			}
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("invalid tag query: unterminated quote")
+			}
+			tokens = append(tokens, tagToken{"tag", prefix + string(runes[i+1:end])})
+			i = end + 1
+		default:
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) && !strings.ContainsRune(`()"`, runes[end]) {
+				end++
+			}
+			word := string(runes[i:end])
+			if op := strings.ToUpper(word); op == "AND" || op == "OR" || op == "NOT" {
+				tokens = append(tokens, tagToken{op, word})
+			} else {
+				tokens = append(tokens, tagToken{"tag", word})
+			}
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+type tagQueryParser struct {
+	tokens []tagToken
+	pos    int
+}
+
+func (p *tagQueryParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos].kind
+	}
+	return ""
+}
+
+// parseOr parses terms joined by OR, which binds weaker than AND
+func (p *tagQueryParser) parseOr() (tagExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "OR" {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = tagBinary{"OR", left, right}
+	}
+	return left, nil
+}
+
+// parseAnd parses terms joined by AND or placed next to each other
+func (p *tagQueryParser) parseAnd() (tagExpr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch p.peek() {
+		case "AND":
+			p.pos++
+		case "tag", "NOT", "(":
+		default:
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = tagBinary{"AND", left, right}
+	}
+}
+
+func (p *tagQueryParser) parseUnary() (tagExpr, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("invalid tag query: unexpected end")
+	}
+	tok := p.tokens[p.pos]
+	p.pos++
+
+	switch tok.kind {
+	case "NOT":
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return tagNot{x}, nil
+	case "(":
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("invalid tag query: missing )")
+		}
+		p.pos++
+		return x, nil
+	case "tag":
+		exact := strings.HasPrefix(tok.text, "=")
+		name := normalizeTagPath(strings.TrimPrefix(tok.text, "="))
+		if name == "" {
+			return nil, fmt.Errorf("invalid tag query: empty tag name")
+		}
+		return tagNameTerm{name: name, exact: exact}, nil
+	}
+	return nil, fmt.Errorf("invalid tag query: unexpected %q", tok.text)
+}
@@ -0,0 +1,134 @@
+package store
+
+import (
+	"testing"
+)
+
+func TestParseTagQuery_Errors(t *testing.T) {
+	for _, q := range []string{"a AND", "(a OR b", "a)", `"open`, "NOT", "a//b", "OR a"} {
+		if _, err := ParseTagQuery(q); err == nil {
+			t.Errorf("Expected error parsing %q", q)
+		}
+	}
+	if q, err := ParseTagQuery("   "); q != nil || err != nil {
+		t.Errorf("Expected nil query for blank input, got %v, %v", q, err)
+	}
+}
+
+func TestTagQuery_Matches(t *testing.T) {
+	s, _ := newTestService(t)
+
+	tagged := func(name string, tags ...string) int64 {
+		clip, _ := s.CreateClip(User, NewClip{Data: []byte(name)})
+		for _, tagName := range tags {
+			var id int64
+			if s.db.QueryRow("SELECT id FROM tags WHERE name = ?", tagName).Scan(&id) != nil {
+				tag, err := s.CreateTag(User, tagName)
+				if err != nil {
+					t.Fatalf("CreateTag(%q) failed: %v", tagName, err)
+				}
+				id = tag.ID
+			}
+			s.AddTagToClip(User, clip.ID, id)
+		}
+		return clip.ID
+	}
+	api := tagged("api", "project/alpha/api")
+	alpha := tagged("alpha", "project/alpha", "urgent")
+	beta := tagged("beta", "project/beta", "needs review")
+	draft := tagged("draft", "project/alpha/api", "draft")
+	other := tagged("other", "project/alphabet")
+
+	tests := []struct {
+		query string
+		want  []int64
+	}{
+		{"project/alpha", []int64{api, alpha, draft}},
+		{`"Project / Alpha"`, []int64{api, alpha, draft}},
+		{"=project/alpha", []int64{alpha}},
+		{"project/alpha -draft", []int64{api, alpha}},
+		{"project/alpha AND NOT draft", []int64{api, alpha}},
+		{`urgent OR "needs review"`, []int64{alpha, beta}},
+		{"project AND (urgent OR draft)", []int64{alpha, draft}},
+		{"project/alphabet OR missing", []int64{other}},
+		{"NOT project", nil},
+	}
+	for _, tt := range tests {
+		q, err := ParseTagQuery(tt.query)
+		if err != nil {
+			t.Fatalf("ParseTagQuery(%q) failed: %v", tt.query, err)
+		}
+		condition, args := q.Condition("c.id")
+		got, err := queryIDs(s.db, "SELECT c.id FROM clips c WHERE "+condition+" ORDER BY c.id", args...)
+		if err != nil {
+			t.Fatalf("query %q failed: %v", tt.query, err)
+		}
+		if !equalIDs(got, tt.want) {
+			t.Errorf("%q: expected %v, got %v", tt.query, tt.want, got)
+		}
+	}
+
+	// Selected tag IDs include children and are ANDed with a query
+	var alphaTag int64
+	s.db.QueryRow("SELECT id FROM tags WHERE name = 'project/alpha'").Scan(&alphaTag)
+	notDraft, _ := ParseTagQuery("-draft")
+	condition, args := AllTags([]int64{alphaTag}).And(notDraft).Condition("c.id")
+	got, _ := queryIDs(s.db, "SELECT c.id FROM clips c WHERE "+condition+" ORDER BY c.id", args...)
+	if !equalIDs(got, []int64{api, alpha}) {
+		t.Errorf("Expected %v, got %v", []int64{api, alpha}, got)
+	}
+
+	// Any of several tags, with their children
+	condition, args = AnyTag([]string{"project/beta", "Draft"}).Condition("c.id")
+	got, _ = queryIDs(s.db, "SELECT c.id FROM clips c WHERE "+condition+" ORDER BY c.id", args...)
+	if !equalIDs(got, []int64{beta, draft}) {
+		t.Errorf("Expected %v, got %v", []int64{beta, draft}, got)
+	}
+}
+
+func TestTagMatches(t *testing.T) {
+	for _, tt := range []struct {
+		name, tag string
+		want      bool
+	}{
+		{"work", "work", true},
+		{"Work/API", "work", true},
+		{"work/api/v2", "work/api", true},
+		{"workshop", "work", false},
+		{"work", "work/api", false},
+	} {
+		if got := TagMatches(tt.name, tt.tag); got != tt.want {
+			t.Errorf("TagMatches(%q, %q) = %v, want %v", tt.name, tt.tag, got, tt.want)
+		}
+	}
+}
+
+func TestUpdateTag_RenamesChildren(t *testing.T) {
+	s, rec := newTestService(t)
+
+	parent, _ := s.CreateTag(User, "project")
+	child, _ := s.CreateTag(User, "project/alpha")
+	s.CreateTag(User, "projects")
+	rec.names()
+
+	if err := s.UpdateTag(User, parent.ID, "work", parent.Color); err != nil {
+		t.Fatalf("UpdateTag failed: %v", err)
+	}
+	var name string
+	s.db.QueryRow("SELECT name FROM tags WHERE id = ?", child.ID).Scan(&name)
+	if name != "work/alpha" {
+		t.Errorf("Expected child to be renamed to work/alpha, got %q", name)
+	}
+	var untouched int
+	s.db.QueryRow("SELECT COUNT(*) FROM tags WHERE name = 'projects'").Scan(&untouched)
+	if untouched != 1 {
+		t.Error("Tags sharing the prefix without a separator should keep their name")
+	}
+	if got := rec.names(); !equalNames(got, []string{"tag:updated", "tag:updated"}) {
+		t.Errorf("Expected tag:updated for parent and child, got %v", got)
+	}
+
+	if _, err := s.CreateTag(User, "a//b"); err == nil {
+		t.Error("Expected error for empty tag level")
+	}
+}
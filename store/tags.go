@@ -0,0 +1,332 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// MaxTagNameLength is the maximum length of a tag name, including the names
+// of its parents
+const MaxTagNameLength = 100
+
+// TagSeparator separates the levels of nested tag names, e.g. "project/alpha/api"
+// is a child of "project/alpha"
+const TagSeparator = "/"
+
+// TagColors is the palette of colors auto-assigned to new tags
+var TagColors = []string{
+	"#78716C", // stone
+	"#EF4444", // red
+	"#F59E0B", // amber
+	"#22C55E", // green
+	"#3B82F6", // blue
+	"#8B5CF6", // violet
+	"#EC4899", // pink
+	"#06B6D4", // cyan
+}
+
+// Tag is a created or updated tag
+type Tag struct {
+	ID    int64
+	Name  string
+	Color string
+}
+
+// normalizeTagPath trims each level of a nested tag name. It returns an empty
+// string if any level is empty.
+func normalizeTagPath(name string) string {
+	levels := strings.Split(name, TagSeparator)
+	for i, level := range levels {
+		levels[i] = strings.TrimSpace(level)
+		if levels[i] == "" {
+			return ""
+		}
+	}
+	return strings.Join(levels, TagSeparator)
+}
+
+// validateTagName normalizes a tag name and checks its length
+func validateTagName(name string) (string, error) {
+	if strings.TrimSpace(name) == "" {
+		return "", fmt.Errorf("tag name cannot be empty")
+	}
+	name = normalizeTagPath(name)
+	if name == "" {
+		return "", fmt.Errorf("tag name cannot have empty levels")
+	}
+	if len(name) > MaxTagNameLength {
+		return "", fmt.Errorf("tag name too long (max %d characters)", MaxTagNameLength)
+	}
+	return name, nil
+}
+
+// CreateTag creates a tag with an auto-assigned color and emits tag:created
+func (s *Service) CreateTag(actor Actor, name string) (*Tag, error) {
+	name, err := validateTagName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Use transaction to prevent race condition in color assignment
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Get count of existing tags to determine color (within transaction)
+	var count int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM tags").Scan(&count); err != nil {
+		return nil, fmt.Errorf("failed to count tags: %w", err)
+	}
+	color := TagColors[count%len(TagColors)]
+
+	result, err := tx.Exec("INSERT INTO tags (name, color) VALUES (?, ?)", name, color)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return nil, fmt.Errorf("tag already exists: %s", name)
+		}
+		return nil, fmt.Errorf("failed to create tag: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tag ID: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	s.emit(actor, "tag:created", map[string]interface{}{
+		"id":    id,
+		"name":  name,
+		"color": color,
+	})
+
+	return &Tag{ID: id, Name: name, Color: color}, nil
+}
+
+// UpdateTag renames and recolors a tag and emits tag:updated. Renaming a tag
+// also renames its children, which emit tag:updated too.
+func (s *Service) UpdateTag(actor Actor, id int64, name, color string) error {
+	name, err := validateTagName(name)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var oldName string
+	if err := tx.QueryRow("SELECT name FROM tags WHERE id = ?", id).Scan(&oldName); err == sql.ErrNoRows {
+		return ErrTagNotFound
+	} else if err != nil {
+		return fmt.Errorf("failed to query tag: %w", err)
+	}
+
+	if _, err := tx.Exec("UPDATE tags SET name = ?, color = ? WHERE id = ?", name, color, id); err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return fmt.Errorf("tag name already exists: %s", name)
+		}
+		return fmt.Errorf("failed to update tag: %w", err)
+	}
+
+	var children []Tag
+	if name != oldName {
+		children, err = renameTagChildren(tx, oldName, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	s.emit(actor, "tag:updated", map[string]interface{}{
+		"id":    id,
+		"name":  name,
+		"color": color,
+	})
+	for _, child := range children {
+		s.emit(actor, "tag:updated", map[string]interface{}{
+			"id":    child.ID,
+			"name":  child.Name,
+			"color": child.Color,
+		})
+	}
+	return nil
+}
+
+// renameTagChildren moves the children of a renamed tag to its new name
+func renameTagChildren(tx *sql.Tx, oldName, newName string) ([]Tag, error) {
+	prefix := oldName + TagSeparator
+	rows, err := tx.Query("SELECT id, name, color FROM tags WHERE SUBSTR(name, 1, LENGTH(?)) = ?", prefix, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query child tags: %w", err)
+	}
+	var children []Tag
+	for rows.Next() {
+		var child Tag
+		if err := rows.Scan(&child.ID, &child.Name, &child.Color); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan child tag: %w", err)
+		}
+		child.Name = newName + strings.TrimPrefix(child.Name, oldName)
+		children = append(children, child)
+	}
+	rows.Close()
+
+	for _, child := range children {
+		if len(child.Name) > MaxTagNameLength {
+			return nil, fmt.Errorf("tag name too long (max %d characters): %s", MaxTagNameLength, child.Name)
+		}
+		if _, err := tx.Exec("UPDATE tags SET name = ? WHERE id = ?", child.Name, child.ID); err != nil {
+			if strings.Contains(err.Error(), "UNIQUE") {
+				return nil, fmt.Errorf("tag name already exists: %s", child.Name)
+			}
+			return nil, fmt.Errorf("failed to rename child tag: %w", err)
+		}
+	}
+	return children, nil
+}
+
+// DeleteTag deletes a tag (clip_tags cascade delete handles associations)
+func (s *Service) DeleteTag(actor Actor, id int64) error {
+	result, err := s.db.Exec("DELETE FROM tags WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete tag: %w", err)
+	}
+
+	if n, _ := result.RowsAffected(); n > 0 {
+		s.emit(actor, "tag:deleted", id)
+	}
+	return nil
+}
+
+// AddTagToClip tags a single clip, failing if the clip or tag doesn't exist
+func (s *Service) AddTagToClip(actor Actor, clipID, tagID int64) error {
+	if !s.clipExists(clipID) {
+		return ErrClipNotFound
+	}
+	return s.AddTagToClips(actor, []int64{clipID}, tagID)
+}
+
+// AddTagToClips tags clips, emitting tag:added_to_clip for each clip that
+// wasn't tagged yet. Clips that don't exist are skipped.
+func (s *Service) AddTagToClips(actor Actor, clipIDs []int64, tagID int64) error {
+	if !s.tagExists(tagID) {
+		return ErrTagNotFound
+	}
+	if len(clipIDs) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT OR IGNORE INTO clip_tags (clip_id, tag_id)
+		SELECT id, ? FROM clips WHERE id = ? AND deleted_at IS NULL`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	var added []int64
+	for _, clipID := range clipIDs {
+		result, err := stmt.Exec(tagID, clipID)
+		if err != nil {
+			return fmt.Errorf("failed to add tag to clip %d: %w", clipID, err)
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			added = append(added, clipID)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	for _, clipID := range added {
+		s.emit(actor, "tag:added_to_clip", map[string]interface{}{
+			"tag_id":  tagID,
+			"clip_id": clipID,
+		})
+	}
+	return nil
+}
+
+// RemoveTagFromClip untags a single clip, failing if the clip or tag doesn't exist
+func (s *Service) RemoveTagFromClip(actor Actor, clipID, tagID int64) error {
+	if !s.clipExists(clipID) {
+		return ErrClipNotFound
+	}
+	return s.RemoveTagFromClips(actor, []int64{clipID}, tagID)
+}
+
+// RemoveTagFromClips untags clips, emitting tag:removed_from_clip for each
+// clip that had the tag, and deletes the tag if no clips are left
+func (s *Service) RemoveTagFromClips(actor Actor, clipIDs []int64, tagID int64) error {
+	if !s.tagExists(tagID) {
+		return ErrTagNotFound
+	}
+	if len(clipIDs) == 0 {
+		return nil
+	}
+	marks, args := placeholders(clipIDs)
+	args = append([]interface{}{tagID}, args...)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	removed, err := queryIDs(tx, "SELECT clip_id FROM clip_tags WHERE tag_id = ? AND clip_id IN ("+marks+")", args...)
+	if err != nil {
+		return fmt.Errorf("failed to query clip tags: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM clip_tags WHERE tag_id = ? AND clip_id IN ("+marks+")", args...); err != nil {
+		return fmt.Errorf("failed to remove tag from clips: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	for _, clipID := range removed {
+		s.emit(actor, "tag:removed_from_clip", map[string]interface{}{
+			"tag_id":  tagID,
+			"clip_id": clipID,
+		})
+	}
+
+	// Clean up orphaned tag
+	s.deleteTagIfOrphaned(actor, tagID)
+	return nil
+}
+
+// deleteTagIfOrphaned deletes a tag if it has no associated clips
+func (s *Service) deleteTagIfOrphaned(actor Actor, tagID int64) {
+	result, err := s.db.Exec(`DELETE FROM tags WHERE id = ?
+		AND NOT EXISTS (SELECT 1 FROM clip_tags WHERE tag_id = ?)`, tagID, tagID)
+	if err != nil {
+		return
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		s.emit(actor, "tag:deleted", tagID)
+	}
+}
+
+func (s *Service) tagExists(id int64) bool {
+	var exists int
+	return s.db.QueryRow("SELECT 1 FROM tags WHERE id = ?", id).Scan(&exists) == nil
+}
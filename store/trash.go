@@ -0,0 +1,143 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultTrashRetentionDays is how long trashed clips are kept before they are
+// purged, unless configured otherwise
+const DefaultTrashRetentionDays = 30
+
+// TrashClips moves clips to the trash, emitting clip:trashed for each clip
+// that wasn't trashed yet. Trashed clips are hidden from every listing and
+// can't be changed until they are restored. Clips that don't exist are skipped.
+func (s *Service) TrashClips(actor Actor, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	marks, args := placeholders(ids)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	trashed, err := queryIDs(tx, "SELECT id FROM clips WHERE deleted_at IS NULL AND id IN ("+marks+")", args...)
+	if err != nil {
+		return fmt.Errorf("failed to query clips: %w", err)
+	}
+	if _, err := tx.Exec("UPDATE clips SET deleted_at = CURRENT_TIMESTAMP WHERE deleted_at IS NULL AND id IN ("+marks+")", args...); err != nil {
+		return fmt.Errorf("failed to trash clips: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	for _, id := range trashed {
+		s.emit(actor, "clip:trashed", map[string]interface{}{
+			"id": id,
+		})
+	}
+	return nil
+}
+
+// RestoreClips moves clips out of the trash, emitting clip:restored for each
+// clip that was trashed. Clips that had expired lose their expiration so they
+// aren't trashed again right away.
+func (s *Service) RestoreClips(actor Actor, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	marks, args := placeholders(ids)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	restored, err := queryIDs(tx, "SELECT id FROM clips WHERE deleted_at IS NOT NULL AND id IN ("+marks+")", args...)
+	if err != nil {
+		return fmt.Errorf("failed to query trashed clips: %w", err)
+	}
+	if _, err := tx.Exec(`UPDATE clips SET deleted_at = NULL,
+		expires_at = CASE WHEN expires_at <= CURRENT_TIMESTAMP THEN NULL ELSE expires_at END
+		WHERE deleted_at IS NOT NULL AND id IN (`+marks+")", args...); err != nil {
+		return fmt.Errorf("failed to restore clips: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	for _, id := range restored {
+		s.emit(actor, "clip:restored", map[string]interface{}{
+			"id": id,
+		})
+	}
+	return nil
+}
+
+// TrashExpired moves expired clips to the trash and returns how many were
+// trashed. Pinned clips never expire.
+func (s *Service) TrashExpired(actor Actor) (int, error) {
+	ids, err := queryIDs(s.db, `SELECT id FROM clips WHERE deleted_at IS NULL AND is_pinned = 0
+		AND expires_at IS NOT NULL AND expires_at <= CURRENT_TIMESTAMP`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query expired clips: %w", err)
+	}
+	if err := s.TrashClips(actor, ids); err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+// TrashedClips returns the IDs of the clips in the trash, most recently
+// trashed first
+func (s *Service) TrashedClips() ([]int64, error) {
+	return queryIDs(s.db, "SELECT id FROM clips WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC, id DESC")
+}
+
+// PurgeTrash permanently deletes the clips trashed before a time, emitting
+// clip:deleted for each, and returns how many were deleted
+func (s *Service) PurgeTrash(actor Actor, before time.Time) (int, error) {
+	ids, err := queryIDs(s.db, "SELECT id FROM clips WHERE deleted_at IS NOT NULL AND deleted_at < ?",
+		before.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to query trashed clips: %w", err)
+	}
+	if err := s.deleteInBatches(actor, ids); err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+// EmptyTrash permanently deletes every clip in the trash and returns how many
+// were deleted
+func (s *Service) EmptyTrash(actor Actor) (int, error) {
+	ids, err := s.TrashedClips()
+	if err != nil {
+		return 0, fmt.Errorf("failed to query trashed clips: %w", err)
+	}
+	if err := s.deleteInBatches(actor, ids); err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+// PurgeClips permanently deletes clips that are in the trash. Clips that
+// aren't trashed are skipped.
+func (s *Service) PurgeClips(actor Actor, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	marks, args := placeholders(ids)
+	trashed, err := queryIDs(s.db, "SELECT id FROM clips WHERE deleted_at IS NOT NULL AND id IN ("+marks+")", args...)
+	if err != nil {
+		return fmt.Errorf("failed to query trashed clips: %w", err)
+	}
+	return s.DeleteClips(actor, trashed)
+}
@@ -0,0 +1,119 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTrashClips(t *testing.T) {
+	s, rec := newTestService(t)
+
+	a, _ := s.CreateClip(User, NewClip{ContentType: "text/plain", Data: []byte("a")})
+	b, _ := s.CreateClip(User, NewClip{ContentType: "text/plain", Data: []byte("b")})
+	tag, _ := s.CreateTag(User, "work")
+	s.AddTagToClip(User, a.ID, tag.ID)
+	rec.names()
+
+	if err := s.TrashClips(User, []int64{a.ID, a.ID, 999}); err != nil {
+		t.Fatalf("TrashClips failed: %v", err)
+	}
+	s.TrashClips(User, []int64{a.ID}) // already trashed
+	if got := rec.names(); !equalNames(got, []string{"clip:trashed"}) {
+		t.Errorf("Expected one clip:trashed, got %v", got)
+	}
+
+	// Trashed clips are hidden and can't be changed, but keep their tags
+	condition, args, _ := Filter{}.Condition("c")
+	visible, _ := queryIDs(s.db, "SELECT c.id FROM clips c WHERE "+condition, args...)
+	if !equalIDs(visible, []int64{b.ID}) {
+		t.Errorf("Expected only clip %d visible, got %v", b.ID, visible)
+	}
+	condition, args, _ = Filter{Trashed: true}.Condition("c")
+	trashed, _ := queryIDs(s.db, "SELECT c.id FROM clips c WHERE "+condition, args...)
+	if !equalIDs(trashed, []int64{a.ID}) {
+		t.Errorf("Expected clip %d in the trash, got %v", a.ID, trashed)
+	}
+	if err := s.SetArchived(User, a.ID, true); err != ErrClipNotFound {
+		t.Errorf("Expected ErrClipNotFound archiving a trashed clip, got %v", err)
+	}
+	if err := s.UpdateClip(User, a.ID, ClipUpdate{Data: []byte("x")}); err != ErrClipNotFound {
+		t.Errorf("Expected ErrClipNotFound updating a trashed clip, got %v", err)
+	}
+	if !s.tagExists(tag.ID) {
+		t.Error("Expected the tag to be kept while its clip is in the trash")
+	}
+
+	if err := s.RestoreClips(User, []int64{a.ID, b.ID}); err != nil {
+		t.Fatalf("RestoreClips failed: %v", err)
+	}
+	if got := rec.names(); !equalNames(got, []string{"clip:restored"}) {
+		t.Errorf("Expected one clip:restored, got %v", got)
+	}
+	if !s.clipExists(a.ID) {
+		t.Error("Expected the clip to be restored")
+	}
+}
+
+func TestTrashExpired(t *testing.T) {
+	s, rec := newTestService(t)
+
+	expired, _ := s.CreateClip(User, NewClip{ContentType: "text/plain", Data: []byte("a")})
+	pinned, _ := s.CreateClip(User, NewClip{ContentType: "text/plain", Data: []byte("b")})
+	s.SetPinned(User, pinned.ID, true)
+	s.db.Exec("UPDATE clips SET expires_at = datetime('now', '-1 hour') WHERE id IN (?, ?)", expired.ID, pinned.ID)
+	rec.names()
+
+	n, err := s.TrashExpired(System)
+	if err != nil {
+		t.Fatalf("TrashExpired failed: %v", err)
+	}
+	if n != 1 || s.clipExists(expired.ID) || !s.clipExists(pinned.ID) {
+		t.Errorf("Expected only the unpinned expired clip to be trashed, got %d", n)
+	}
+
+	// Restoring drops the past expiration so the clip isn't trashed again
+	s.RestoreClips(User, []int64{expired.ID})
+	if n, _ := s.TrashExpired(System); n != 0 {
+		t.Errorf("Expected the restored clip to stay, %d trashed", n)
+	}
+}
+
+func TestPurgeTrash(t *testing.T) {
+	s, rec := newTestService(t)
+
+	old, _ := s.CreateClip(User, NewClip{ContentType: "text/plain", Data: []byte("a")})
+	recent, _ := s.CreateClip(User, NewClip{ContentType: "text/plain", Data: []byte("b")})
+	live, _ := s.CreateClip(User, NewClip{ContentType: "text/plain", Data: []byte("c")})
+	tag, _ := s.CreateTag(User, "gone")
+	s.AddTagToClip(User, old.ID, tag.ID)
+	s.TrashClips(User, []int64{old.ID, recent.ID})
+	s.db.Exec("UPDATE clips SET deleted_at = datetime('now', '-40 days') WHERE id = ?", old.ID)
+	rec.names()
+
+	n, err := s.PurgeTrash(System, time.Now().AddDate(0, 0, -30))
+	if err != nil {
+		t.Fatalf("PurgeTrash failed: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("Expected 1 clip purged, got %d", n)
+	}
+	if got := rec.names(); !equalNames(got, []string{"clip:deleted", "tag:deleted"}) {
+		t.Errorf("Unexpected events: %v", got)
+	}
+
+	// PurgeClips only deletes clips in the trash
+	if err := s.PurgeClips(User, []int64{recent.ID, live.ID}); err != nil {
+		t.Fatalf("PurgeClips failed: %v", err)
+	}
+	if trashed, _ := s.TrashedClips(); len(trashed) != 0 {
+		t.Errorf("Expected an empty trash, got %v", trashed)
+	}
+	if !s.clipExists(live.ID) {
+		t.Error("PurgeClips deleted a clip that wasn't in the trash")
+	}
+
+	s.TrashClips(User, []int64{live.ID})
+	if n, err := s.EmptyTrash(User); err != nil || n != 1 {
+		t.Errorf("Expected EmptyTrash to delete 1 clip, got %d (%v)", n, err)
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"sync"
 	"time"
 
+	"go-clipboard/store"
+
 	"github.com/fsnotify/fsnotify"
 )
 
@@ -294,7 +296,7 @@ func (w *WatcherManager) importFile(filePath string, folder *WatchedFolder) (int
 	}
 
 	// Upload and get the clip ID
-	clipID, err := w.app.UploadFileAndGetID(*fileData)
+	clipID, err := w.app.uploadFile(store.Watcher, *fileData, nil)
 	if err != nil {
 		return 0, err
 	}
@@ -302,14 +304,14 @@ func (w *WatcherManager) importFile(filePath string, folder *WatchedFolder) (int
 	// Auto-archive if configured - must happen BEFORE emitting event
 	// so frontend sees the clip in its final archived state
 	if folder.AutoArchive {
-		if err := w.app.ToggleArchive(clipID); err != nil {
+		if err := w.app.store.SetArchived(store.Watcher, clipID, true); err != nil {
 			log.Printf("Failed to auto-archive clip %d: %v", clipID, err)
 		}
 	}
 
 	// Auto-tag if configured
 	if folder.AutoTagID != nil {
-		if err := w.app.AddTagToClip(clipID, *folder.AutoTagID); err != nil {
+		if err := w.app.store.AddTagToClip(store.Watcher, clipID, *folder.AutoTagID); err != nil {
 			log.Printf("Failed to auto-tag clip %d with tag %d: %v", clipID, *folder.AutoTagID, err)
 		}
 	}